@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 	"verilog-oj/backend/internal"
 	"verilog-oj/backend/internal/config"
 	"verilog-oj/backend/internal/middleware"
 	"verilog-oj/backend/internal/models"
+	"verilog-oj/backend/internal/queue"
 	"verilog-oj/backend/internal/seed"
+	"verilog-oj/backend/internal/services"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -42,12 +46,43 @@ func main() {
 		log.Printf("Warning: Failed to initialize admin: %v", err)
 	}
 
+	// 初始化判题队列
+	judgeQueue, err := queue.New(cfg.Queue)
+	if err != nil {
+		log.Fatal("Failed to initialize judge queue:", err)
+	}
+	var pushQueue services.JudgeQueue
+	if judgeQueue != nil {
+		defer judgeQueue.Close()
+		pushQueue = judgeQueue
+	} else {
+		log.Printf("Judge queue disabled (QUEUE_TYPE=%q), submissions will not be judged", cfg.Queue.Type)
+	}
+
 	// 使用 wire 初始化应用
-	app, err := internal.InitializeApp(db)
+	app, err := internal.InitializeApp(db, pushQueue)
 	if err != nil {
 		log.Fatal("Failed to initialize app:", err)
 	}
 
+	// 消费判题结果
+	if judgeQueue != nil {
+		go func() {
+			if err := judgeQueue.ConsumeResults(context.Background(), app.Services.ApplyJudgeResult); err != nil {
+				log.Printf("Judge result consumer stopped: %v", err)
+			}
+		}()
+	}
+
+	// 卡住提交的看门狗：重新入队丢失的判题任务
+	app.Services.SubmissionService.SetWatchdogOptions(services.WatchdogOptions{
+		StuckAfter:  time.Duration(cfg.Watchdog.StuckAfter) * time.Second,
+		MaxAttempts: cfg.Watchdog.MaxAttempts,
+	})
+	if judgeQueue != nil && cfg.Watchdog.Interval > 0 {
+		go app.Services.SubmissionService.RunWatchdog(context.Background(), time.Duration(cfg.Watchdog.Interval)*time.Second)
+	}
+
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
@@ -85,6 +120,7 @@ func main() {
 			admin.GET("/whoami", app.Handlers.AdminHandler.WhoAmI)
 			admin.GET("/stats", app.Handlers.AdminHandler.Stats)
 			admin.PUT("/users/:id/role", app.Handlers.AdminHandler.UpdateUserRole)
+			admin.GET("/submissions/stuck", app.Handlers.SubmissionHandler.ListStuckSubmissions)
 		}
 
 		// 用户相关路由
@@ -144,6 +180,71 @@ func main() {
 				),
 				app.Handlers.ProblemHandler.DeleteProblem)
 
+			// 参考答案校验与发布
+			// 查看校验结果：包含隐藏测试用例的结果，需要作者或管理员权限
+			problems.GET("/:id/validation",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.GetProblemValidation)
+			// 重新校验：需要作者或管理员权限
+			problems.POST("/:id/validate",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.ValidateProblem)
+			// 由参考答案生成期望输出：生成、查看对比、确认写入，需要作者或管理员权限
+			problems.POST("/:id/expected-outputs",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.GenerateExpectedOutputs)
+			problems.GET("/:id/expected-outputs",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.GetExpectedOutputs)
+			problems.POST("/:id/expected-outputs/apply",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.ApplyExpectedOutputs)
+			// 发布题目：需要 problem.publish 权限，参考答案必须通过全部测试用例
+			problems.POST("/:id/publish",
+				middleware.AuthRequired(),
+				middleware.RequirePermission(middleware.PermProblemPublish),
+				app.Handlers.ProblemHandler.PublishProblem)
+			// 撤回题目：需要 problem.publish 权限
+			problems.POST("/:id/unpublish",
+				middleware.AuthRequired(),
+				middleware.RequirePermission(middleware.PermProblemPublish),
+				app.Handlers.ProblemHandler.UnpublishProblem)
+
+			// 题目包导入导出
+			// 导出题目包：包含隐藏测试用例和参考答案，需要作者或管理员权限
+			problems.GET("/:id/export",
+				middleware.AuthRequired(),
+				middleware.RequireOwnershipOrPermission(
+					middleware.PermProblemUpdateAll,
+					middleware.GetProblemOwner("id"),
+				),
+				app.Handlers.ProblemHandler.ExportProblem)
+			// 导入题目包：需要 problem.create 权限，dry_run=true 时只校验
+			problems.POST("/import",
+				middleware.AuthRequired(),
+				middleware.RequirePermission(middleware.PermProblemCreate),
+				app.Handlers.ProblemHandler.ImportProblems)
+
 			// 测试用例相关路由
 			// 查看测试用例：支持可选认证（用户看样例，教师和管理员看全部）
 			problems.GET("/:id/testcases",
@@ -178,11 +279,26 @@ func main() {
 				middleware.OptionalAuth(),
 				middleware.OptionalAuthPermission(middleware.PermSubmissionRead),
 				app.Handlers.SubmissionHandler.GetSubmission)
+			// 订阅判题进度：Server-Sent Events，权限与提交详情相同
+			submissions.GET("/:id/events",
+				middleware.OptionalAuth(),
+				middleware.OptionalAuthPermission(middleware.PermSubmissionRead),
+				app.Handlers.SubmissionHandler.StreamSubmissionEvents)
+			// 订阅判题进度：WebSocket
+			submissions.GET("/:id/events/ws",
+				middleware.OptionalAuth(),
+				middleware.OptionalAuthPermission(middleware.PermSubmissionRead),
+				app.Handlers.SubmissionHandler.SubmissionEventsWebSocket)
 			// 创建提交：需要 submission.create 权限
 			submissions.POST("",
 				middleware.AuthRequired(),
 				middleware.RequirePermission(middleware.PermSubmissionCreate),
 				app.Handlers.SubmissionHandler.CreateSubmission)
+			// 取消判题：提交者本人或管理员，权限在服务层检查
+			submissions.POST("/:id/cancel",
+				middleware.AuthRequired(),
+				middleware.RequirePermission(middleware.PermSubmissionCreate),
+				app.Handlers.SubmissionHandler.CancelSubmission)
 			// 删除提交：需要 submission.delete 权限（仅管理员）
 			submissions.DELETE("/:id",
 				middleware.AuthRequired(),
@@ -201,6 +317,16 @@ func main() {
 				app.Handlers.SubmissionHandler.GetSubmissionStats)
 		}
 
+		// 判题队列相关路由
+		judge := v1.Group("/judge")
+		{
+			// 判题队列概况：各通道任务数和存活节点，权限与提交列表相同
+			judge.GET("/queue",
+				middleware.OptionalAuth(),
+				middleware.OptionalAuthPermission(middleware.PermSubmissionList),
+				app.Handlers.SubmissionHandler.GetJudgeQueue)
+		}
+
 		// 论坛相关路由
 		forum := v1.Group("/forum")
 		{
@@ -317,7 +443,10 @@ func autoMigrate(db *gorm.DB) error {
 		&models.User{},
 		&models.Problem{},
 		&models.TestCase{},
+		&models.ProblemAttachment{},
+		&models.ProblemOutputGeneration{},
 		&models.Submission{},
+		&models.SubmissionRecovery{},
 		&models.ForumPost{},
 		&models.ForumReply{},
 		&models.ForumLike{},
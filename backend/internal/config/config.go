@@ -12,6 +12,7 @@ type Config struct {
 	Redis     RedisConfig     `yaml:"redis"`
 	JWT       JWTConfig       `yaml:"jwt"`
 	Queue     QueueConfig     `yaml:"queue"`
+	Watchdog  WatchdogConfig  `yaml:"watchdog"`
 	InitAdmin InitAdminConfig `yaml:"init_admin"`
 }
 
@@ -48,12 +49,22 @@ type JWTConfig struct {
 
 // QueueConfig 消息队列配置
 type QueueConfig struct {
-	Type      string `yaml:"type"` // redis, rabbitmq
+	Type      string `yaml:"type"` // redis, memory（内嵌判题服务）, none
 	Host      string `yaml:"host"`
 	Port      int    `yaml:"port"`
 	Username  string `yaml:"username"`
 	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
 	QueueName string `yaml:"queue_name"`
+	// JudgeConfig 内嵌判题服务的配置文件，仅 memory 模式使用，格式与判题服务相同
+	JudgeConfig string `yaml:"judge_config"`
+}
+
+// WatchdogConfig 卡住提交的看门狗配置
+type WatchdogConfig struct {
+	Interval    int `yaml:"interval"`     // 检查间隔（秒），0 表示关闭看门狗
+	StuckAfter  int `yaml:"stuck_after"`  // 等待或判题超过该时间（秒）未更新的提交视为卡住
+	MaxAttempts int `yaml:"max_attempts"` // 最多重新入队的次数，用尽后标记为 system_error
 }
 
 // InitAdminConfig 初始管理员配置
@@ -90,12 +101,19 @@ func LoadConfig() *Config {
 			ExpiresIn: getEnvAsInt("JWT_EXPIRES_IN", 24),
 		},
 		Queue: QueueConfig{
-			Type:      getEnv("QUEUE_TYPE", "redis"),
-			Host:      getEnv("QUEUE_HOST", "localhost"),
-			Port:      getEnvAsInt("QUEUE_PORT", 6379),
-			Username:  getEnv("QUEUE_USERNAME", ""),
-			Password:  getEnv("QUEUE_PASSWORD", ""),
-			QueueName: getEnv("QUEUE_NAME", "judge_queue"),
+			Type:        getEnv("QUEUE_TYPE", "redis"),
+			Host:        getEnv("QUEUE_HOST", "localhost"),
+			Port:        getEnvAsInt("QUEUE_PORT", 6379),
+			Username:    getEnv("QUEUE_USERNAME", ""),
+			Password:    getEnv("QUEUE_PASSWORD", ""),
+			DB:          getEnvAsInt("QUEUE_DB", 0),
+			QueueName:   getEnv("QUEUE_NAME", "judge_queue"),
+			JudgeConfig: getEnv("JUDGE_CONFIG", ""),
+		},
+		Watchdog: WatchdogConfig{
+			Interval:    getEnvAsInt("WATCHDOG_INTERVAL", 60),
+			StuckAfter:  getEnvAsInt("WATCHDOG_STUCK_AFTER", 600),
+			MaxAttempts: getEnvAsInt("WATCHDOG_MAX_ATTEMPTS", 3),
 		},
 		InitAdmin: InitAdminConfig{
 			Username: getEnv("INIT_ADMIN_USERNAME", ""),
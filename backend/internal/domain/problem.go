@@ -19,6 +19,22 @@ type Problem struct {
 	TimeLimit   int // 毫秒
 	MemoryLimit int // MB
 
+	// 代码
+	ReferenceCode string // 参考答案，不对学生公开
+	StarterCode   string // 初始代码模板
+
+	// 与参考答案的随机激励等价性检查，nil 表示不检查
+	Equivalence *EquivalenceConfig
+
+	// 由端口列表生成 testbench 的真值表测试，nil 表示没有
+	TruthTable *TruthTable
+
+	// 测试用例的输出比较方式，为空时比较VCD，其他取值比较 testbench 的标准输出，见 protocol.Checkers
+	Checker string
+
+	// 出题人提供的检查程序，配置后代替 Checker 判定测试用例的输出，nil 表示没有
+	SpecialJudge *SpecialJudge
+
 	// 统计信息
 	SubmitCount   int
 	AcceptedCount int
@@ -27,6 +43,9 @@ type Problem struct {
 	IsPublic bool
 	AuthorID uint
 
+	// 参考答案校验结果
+	Validation ProblemValidation
+
 	// 时间戳
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -34,13 +53,137 @@ type Problem struct {
 
 // TestCase 测试用例领域实体
 type TestCase struct {
+	ID          uint
+	ProblemID   uint
+	Input       string
+	Output      string
+	IsSample    bool
+	Description string
+	SimTime     int    // 仿真时间预算（纳秒），0 使用判题服务默认值
+	Clock       string // 统计时钟周期数的信号名，为空时为 clk
+
+	// 逐周期的输入输出序列，不为 nil 时由判题服务生成 testbench，Input 和 Output 为空
+	Trace *IOTrace
+
+	// 时间戳
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ProblemAttachment 题目附件领域实体
+type ProblemAttachment struct {
 	ID        uint
 	ProblemID uint
-	Input     string
-	Output    string
-	IsSample  bool
+	Name      string
+	Content   []byte
 
 	// 时间戳
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+// EquivalenceConfig 随机激励等价性检查配置，参考设计使用题目的参考答案
+type EquivalenceConfig struct {
+	TopModule      string            // 顶层模块名，为空时使用参考答案中的第一个模块
+	Seed           int               // 随机种子
+	Cycles         int               // 激励周期数，0 表示使用判题服务默认值
+	Clock          string            // 时钟输入名，为空表示组合逻辑
+	Reset          string            // 复位输入名
+	ResetActiveLow bool              // 复位低电平有效
+	ResetCycles    int               // 复位保持的时钟周期数
+	Hold           map[string]uint64 // 保持固定取值的输入
+	ExhaustiveBits int               // 激励总位宽不超过该值时穷举全部输入组合
+	Formal         bool              // 先用 Yosys 形式化证明等价，无法证明时回退到仿真
+	FormalTimeout  int               // 形式化证明的时间预算，毫秒，0 表示使用判题服务默认值
+	FormalDepth    int               // 时序电路归纳证明的最大步数
+}
+
+// TruthTable 真值表测试，判题服务根据端口列表生成 testbench，逐行施加输入并比较输出
+type TruthTable struct {
+	TopModule string              // 被测模块名
+	Ports     []Port              // 被测模块的全部端口
+	Rows      []map[string]string // 端口名 -> 取值，省略的输出列表示该行不检查
+	Delay     int                 // 施加输入到比较输出的间隔，纳秒，0 表示默认值
+}
+
+// SpecialJudge 检查程序，字段含义见 protocol.SpecialJudge
+type SpecialJudge struct {
+	Language  string // go 或 verilog
+	Source    string // 检查程序源码，属于测试数据，不对外输出
+	TimeLimit int    // 每个测试用例运行检查程序的时间限制，毫秒，0 表示使用判题服务默认值
+}
+
+// IOTrace 逐周期的输入输出序列，字段含义见 protocol.IOTrace
+type IOTrace struct {
+	TopModule      string
+	Ports          []Port // 被测模块的全部端口，包括时钟和复位
+	Clock          string
+	Reset          string // 为空表示不复位
+	ResetActiveLow bool
+	ResetCycles    int                 // 序列开始前复位保持的周期数，0 表示 1
+	Period         int                 // 时钟周期，纳秒，0 表示默认值
+	SampleEdge     string              // posedge 或 negedge，为空时为 negedge
+	Cycles         []map[string]string // 每个周期的端口名 -> 取值
+}
+
+// Port 被测模块的端口
+type Port struct {
+	Name      string
+	Direction string // input 或 output
+	Width     int    // 0 表示 1 位
+}
+
+// 参考答案校验状态
+const (
+	ValidationUnvalidated = "unvalidated" // 未校验：没有参考答案、测试用例或判题队列
+	ValidationPending     = "pending"     // 已推送校验任务，等待判题结果
+	ValidationValidated   = "validated"   // 参考答案通过全部测试用例
+	ValidationInvalid     = "invalid"     // 参考答案未通过全部测试用例
+)
+
+// ProblemValidation 参考答案校验结果
+type ProblemValidation struct {
+	Status      string
+	RunID       string // 校验任务标识，用于丢弃过期的判题结果
+	Message     string
+	Cases       []ValidationCase
+	ValidatedAt *time.Time
+}
+
+// ValidationCase 单个测试用例的校验结果
+type ValidationCase struct {
+	Index        int // 从1开始，与测试用例的顺序一致
+	Description  string
+	Status       string
+	RunTime      int // 毫秒
+	Memory       int // KB
+	ErrorMessage string
+	SimTime      int64 // 结束时的仿真时间（纳秒）
+	Cycles       int   // 时钟周期数
+}
+
+// 期望输出生成状态
+const (
+	OutputGenerationPending = "pending" // 已推送生成任务，等待判题结果
+	OutputGenerationReady   = "ready"   // 已生成全部期望输出，等待出题人确认
+	OutputGenerationFailed  = "failed"  // 参考答案未能运行全部测试用例
+)
+
+// OutputGeneration 由参考答案生成期望输出的任务，每个题目只保留最近一次，确认后写入测试用例
+type OutputGeneration struct {
+	ProblemID   uint
+	RunID       string // 生成任务标识，用于丢弃过期的判题结果
+	Fingerprint string // 生成时判题请求的摘要，参考答案、测试用例或比较方式变化后不能再确认
+	Status      string
+	Message     string
+	Cases       []GeneratedOutput   // 与生成时测试用例的顺序一致
+	TruthTable  []map[string]string // 真值表每一行输出端口的取值，没有真值表时为空
+	CreatedAt   time.Time
+	FinishedAt  *time.Time
+}
+
+// GeneratedOutput 参考答案在单个测试用例中的输出
+type GeneratedOutput struct {
+	TestCaseID uint
+	Output     string              // testbench 测试用例的期望数据：完整VCD或标准输出
+	Trace      []map[string]string // I/O 序列每个周期输出端口的取值
 }
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// QueueLane 判题队列的一个通道
+type QueueLane struct {
+	Name  string
+	Depth int64 // 等待判题的任务数
+}
+
+// QueueStats 判题队列概况
+type QueueStats struct {
+	Lanes      []QueueLane
+	Processing int64 // 已被判题节点取出、尚未完成的任务数
+	Nodes      int   // 存活的判题节点数
+	Workers    int   // 存活节点的并发判题数之和
+
+	// 最近判题任务的平均耗时，Samples 为 0 时没有记录
+	AverageJudgeTime time.Duration
+	Samples          int
+}
+
+// QueuePosition 等待中的提交在判题队列中的位置
+type QueuePosition struct {
+	Lane     string
+	Position int64 // 从 1 开始，1 表示下一个被取出
+
+	// 根据最近的判题耗时和并发判题数估算的开始时间，无法估算时为零值
+	EstimatedStart time.Time
+	EstimatedWait  time.Duration
+}
@@ -25,3 +25,27 @@ type Submission struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// 看门狗对卡住的提交的处理
+const (
+	RecoveryRequeued = "requeued" // 没有仍在进行的判题任务，重新推送到判题队列
+	RecoveryFailed   = "failed"   // 重新入队次数用尽，标记为 system_error
+)
+
+// SubmissionRecovery 看门狗对卡住的提交的一次处理记录
+type SubmissionRecovery struct {
+	ID           uint
+	SubmissionID uint
+	Action       string
+	Attempt      int    // 第几次重新入队，failed 记录为已重新入队的次数
+	Reason       string // 判定为卡住的原因或处理失败的原因
+
+	// 时间戳
+	CreatedAt time.Time
+}
+
+// StuckSubmission 等待或判题时间超过阈值的提交
+type StuckSubmission struct {
+	Submission Submission
+	Recoveries []SubmissionRecovery // 看门狗的处理记录，按时间先后
+}
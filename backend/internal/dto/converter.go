@@ -2,8 +2,11 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/models"
+	"verilog-oj/judge-service/pkg/problem"
 )
 
 func parseJSONTags(raw string) []string {
@@ -64,13 +67,16 @@ func ProblemToResponse(problem *models.Problem) ProblemResponse {
 // TestCaseToResponse 将TestCase模型转换为TestCaseResponse
 func TestCaseToResponse(testCase *models.TestCase) TestCaseResponse {
 	return TestCaseResponse{
-		ID:        testCase.ID,
-		ProblemID: testCase.ProblemID,
-		Input:     testCase.Input,
-		Output:    testCase.Output,
-		IsSample:  testCase.IsSample,
-		CreatedAt: testCase.CreatedAt,
-		UpdatedAt: testCase.UpdatedAt,
+		ID:          testCase.ID,
+		ProblemID:   testCase.ProblemID,
+		Input:       testCase.Input,
+		Output:      testCase.Output,
+		IsSample:    testCase.IsSample,
+		Description: testCase.Description,
+		SimTime:     testCase.SimTime,
+		Clock:       testCase.Clock,
+		CreatedAt:   testCase.CreatedAt,
+		UpdatedAt:   testCase.UpdatedAt,
 	}
 }
 
@@ -253,22 +259,170 @@ func ProblemCreateRequestToDomain(req *ProblemCreateRequest) *domain.Problem {
 // ProblemDomainToResponse 将Domain实体转换为ProblemResponse
 func ProblemDomainToResponse(problem *domain.Problem) ProblemResponse {
 	return ProblemResponse{
-		ID:          problem.ID,
-		Title:       problem.Title,
-		Description: problem.Description,
-		InputDesc:   problem.InputDesc,
-		OutputDesc:  problem.OutputDesc,
-		Difficulty:  problem.Difficulty,
-		Category:    problem.Category,
-		Tags:        problem.Tags,
-		TimeLimit:   problem.TimeLimit,
-		MemoryLimit: problem.MemoryLimit,
-		IsPublic:    problem.IsPublic,
-		AuthorID:    problem.AuthorID,
-		SubmitCount: problem.SubmitCount,
-		AcceptCount: problem.AcceptedCount,
-		CreatedAt:   problem.CreatedAt,
-		UpdatedAt:   problem.UpdatedAt,
+		ID:               problem.ID,
+		Title:            problem.Title,
+		Description:      problem.Description,
+		InputDesc:        problem.InputDesc,
+		OutputDesc:       problem.OutputDesc,
+		Difficulty:       problem.Difficulty,
+		Category:         problem.Category,
+		Tags:             problem.Tags,
+		TimeLimit:        problem.TimeLimit,
+		MemoryLimit:      problem.MemoryLimit,
+		StarterCode:      problem.StarterCode,
+		Equivalence:      EquivalenceDomainToDTO(problem.Equivalence),
+		TruthTable:       TruthTableDomainToResponse(problem.TruthTable),
+		Checker:          problem.Checker,
+		SpecialJudge:     SpecialJudgeDomainToResponse(problem.SpecialJudge),
+		IsPublic:         problem.IsPublic,
+		ValidationStatus: problem.Validation.Status,
+		AuthorID:         problem.AuthorID,
+		SubmitCount:      problem.SubmitCount,
+		AcceptCount:      problem.AcceptedCount,
+		CreatedAt:        problem.CreatedAt,
+		UpdatedAt:        problem.UpdatedAt,
+	}
+}
+
+// EquivalenceDTOToDomain 将等价性检查配置转换为Domain实体
+func EquivalenceDTOToDomain(config *EquivalenceConfig) *domain.EquivalenceConfig {
+	if config == nil {
+		return nil
+	}
+	result := domain.EquivalenceConfig(*config)
+	return &result
+}
+
+// EquivalenceDomainToDTO 将Domain实体转换为等价性检查配置
+func EquivalenceDomainToDTO(config *domain.EquivalenceConfig) *EquivalenceConfig {
+	if config == nil {
+		return nil
+	}
+	result := EquivalenceConfig(*config)
+	return &result
+}
+
+// TruthTableDTOToDomain 将真值表测试配置转换为Domain实体，data 按 format 解析为行
+func TruthTableDTOToDomain(config *TruthTableConfig) (*domain.TruthTable, error) {
+	if config == nil {
+		return nil, nil
+	}
+	table := &domain.TruthTable{TopModule: config.TopModule, Rows: config.Rows, Delay: config.Delay}
+	for _, port := range config.Ports {
+		table.Ports = append(table.Ports, domain.Port(port))
+	}
+	if config.Data != "" {
+		if len(config.Rows) > 0 {
+			return nil, errors.New("rows 和 data 只能提供一种")
+		}
+		format := config.Format
+		if format == "" {
+			format = problem.TruthTableCSV
+		}
+		rows, err := problem.ParseTruthTable(format, []byte(config.Data))
+		if err != nil {
+			return nil, err
+		}
+		table.Rows = rows
+	}
+	return table, nil
+}
+
+// TruthTableDomainToResponse 将Domain实体转换为真值表测试概要
+func TruthTableDomainToResponse(table *domain.TruthTable) *TruthTableResponse {
+	if table == nil {
+		return nil
+	}
+	response := &TruthTableResponse{TopModule: table.TopModule, Delay: table.Delay, RowCount: len(table.Rows)}
+	for _, port := range table.Ports {
+		response.Ports = append(response.Ports, PortConfig(port))
+	}
+	return response
+}
+
+// SpecialJudgeDTOToDomain 将检查程序配置转换为Domain实体
+func SpecialJudgeDTOToDomain(config *SpecialJudgeConfig) *domain.SpecialJudge {
+	if config == nil {
+		return nil
+	}
+	result := domain.SpecialJudge(*config)
+	return &result
+}
+
+// SpecialJudgeDomainToResponse 将Domain实体转换为检查程序概要
+func SpecialJudgeDomainToResponse(spj *domain.SpecialJudge) *SpecialJudgeResponse {
+	if spj == nil {
+		return nil
+	}
+	return &SpecialJudgeResponse{Language: spj.Language, TimeLimit: spj.TimeLimit}
+}
+
+// IOTraceDTOToDomain 将 I/O 序列配置转换为Domain实体
+func IOTraceDTOToDomain(config *IOTraceConfig) *domain.IOTrace {
+	if config == nil {
+		return nil
+	}
+	trace := &domain.IOTrace{
+		TopModule:      config.TopModule,
+		Clock:          config.Clock,
+		Reset:          config.Reset,
+		ResetActiveLow: config.ResetActiveLow,
+		ResetCycles:    config.ResetCycles,
+		Period:         config.Period,
+		SampleEdge:     config.SampleEdge,
+		Cycles:         config.Cycles,
+	}
+	for _, port := range config.Ports {
+		trace.Ports = append(trace.Ports, domain.Port(port))
+	}
+	return trace
+}
+
+// IOTraceDomainToDTO 将Domain实体转换为 I/O 序列配置
+func IOTraceDomainToDTO(trace *domain.IOTrace) *IOTraceConfig {
+	if trace == nil {
+		return nil
+	}
+	config := &IOTraceConfig{
+		TopModule:      trace.TopModule,
+		Clock:          trace.Clock,
+		Reset:          trace.Reset,
+		ResetActiveLow: trace.ResetActiveLow,
+		ResetCycles:    trace.ResetCycles,
+		Period:         trace.Period,
+		SampleEdge:     trace.SampleEdge,
+		Cycles:         trace.Cycles,
+	}
+	for _, port := range trace.Ports {
+		config.Ports = append(config.Ports, PortConfig(port))
+	}
+	return config
+}
+
+// ProblemValidationDomainToResponse 将参考答案校验结果转换为响应
+func ProblemValidationDomainToResponse(problemID uint, validation *domain.ProblemValidation) ProblemValidationResponse {
+	cases := make([]ValidationCaseResponse, 0, len(validation.Cases))
+	for _, c := range validation.Cases {
+		cases = append(cases, ValidationCaseResponse(c))
+	}
+	return ProblemValidationResponse{
+		ProblemID:   problemID,
+		Status:      validation.Status,
+		Message:     validation.Message,
+		Cases:       cases,
+		ValidatedAt: validation.ValidatedAt,
+	}
+}
+
+// OutputGenerationDomainToResponse 将期望输出生成任务转换为响应，不包含对比结果
+func OutputGenerationDomainToResponse(generation *domain.OutputGeneration) ExpectedOutputsResponse {
+	return ExpectedOutputsResponse{
+		ProblemID:  generation.ProblemID,
+		Status:     generation.Status,
+		Message:    generation.Message,
+		Changes:    []ExpectedOutputChangeResponse{},
+		CreatedAt:  generation.CreatedAt,
+		FinishedAt: generation.FinishedAt,
 	}
 }
 
@@ -402,12 +556,75 @@ func NewsDomainToResponse(news *domain.News) NewsResponse {
 // TestCaseDomainToResponse 将Domain实体转换为TestCaseResponse
 func TestCaseDomainToResponse(testCase *domain.TestCase) TestCaseResponse {
 	return TestCaseResponse{
-		ID:        testCase.ID,
-		ProblemID: testCase.ProblemID,
-		Input:     testCase.Input,
-		Output:    testCase.Output,
-		IsSample:  testCase.IsSample,
-		CreatedAt: testCase.CreatedAt,
-		UpdatedAt: testCase.UpdatedAt,
+		ID:          testCase.ID,
+		ProblemID:   testCase.ProblemID,
+		Input:       testCase.Input,
+		Output:      testCase.Output,
+		IsSample:    testCase.IsSample,
+		Description: testCase.Description,
+		SimTime:     testCase.SimTime,
+		Clock:       testCase.Clock,
+		Trace:       IOTraceDomainToDTO(testCase.Trace),
+		CreatedAt:   testCase.CreatedAt,
+		UpdatedAt:   testCase.UpdatedAt,
+	}
+}
+
+// QueuePositionDomainToResponse 将排队位置转换为响应
+func QueuePositionDomainToResponse(position *domain.QueuePosition) *QueuePositionResponse {
+	response := &QueuePositionResponse{
+		Lane:     position.Lane,
+		Position: position.Position,
+	}
+	if !position.EstimatedStart.IsZero() {
+		start := position.EstimatedStart
+		wait := int64(position.EstimatedWait.Seconds())
+		response.EstimatedStart = &start
+		response.EstimatedWait = &wait
+	}
+	return response
+}
+
+// QueueStatsDomainToResponse 将判题队列概况转换为响应
+func QueueStatsDomainToResponse(stats *domain.QueueStats) JudgeQueueResponse {
+	response := JudgeQueueResponse{
+		Lanes:            make([]JudgeQueueLaneResponse, 0, len(stats.Lanes)),
+		Processing:       stats.Processing,
+		Nodes:            stats.Nodes,
+		Workers:          stats.Workers,
+		AverageJudgeTime: stats.AverageJudgeTime.Milliseconds(),
+	}
+	for _, lane := range stats.Lanes {
+		response.Lanes = append(response.Lanes, JudgeQueueLaneResponse{Name: lane.Name, Depth: lane.Depth})
+		response.Total += lane.Depth
+	}
+	return response
+}
+
+// StuckSubmissionDomainToResponse 将卡住的提交转换为响应，now 用于计算卡住的时长
+func StuckSubmissionDomainToResponse(stuck *domain.StuckSubmission, now time.Time) StuckSubmissionResponse {
+	submission := stuck.Submission
+	response := StuckSubmissionResponse{
+		ID:         submission.ID,
+		UserID:     submission.UserID,
+		ProblemID:  submission.ProblemID,
+		Language:   submission.Language,
+		Status:     submission.Status,
+		CreatedAt:  submission.CreatedAt,
+		UpdatedAt:  submission.UpdatedAt,
+		StuckFor:   int64(now.Sub(submission.UpdatedAt).Seconds()),
+		Recoveries: make([]SubmissionRecoveryResponse, 0, len(stuck.Recoveries)),
 	}
+	for _, recovery := range stuck.Recoveries {
+		if recovery.Action == domain.RecoveryRequeued {
+			response.Attempts++
+		}
+		response.Recoveries = append(response.Recoveries, SubmissionRecoveryResponse{
+			Action:    recovery.Action,
+			Attempt:   recovery.Attempt,
+			Reason:    recovery.Reason,
+			CreatedAt: recovery.CreatedAt,
+		})
+	}
+	return response
 }
@@ -4,65 +4,160 @@ import "time"
 
 // ProblemCreateRequest 创建题目请求
 type ProblemCreateRequest struct {
-	Title       string            `json:"title" binding:"required"`
-	Description string            `json:"description" binding:"required"`
-	InputDesc   string            `json:"input_desc"`
-	OutputDesc  string            `json:"output_desc"`
-	Difficulty  string            `json:"difficulty" binding:"required,oneof=Easy Medium Hard"`
-	Category    string            `json:"category"`
-	Tags        []string          `json:"tags"`
-	TimeLimit   int               `json:"time_limit" binding:"min=100,max=30000"`
-	MemoryLimit int               `json:"memory_limit" binding:"min=16,max=1024"`
-	TestCases   []TestCaseRequest `json:"test_cases"`
+	Title         string              `json:"title" binding:"required"`
+	Description   string              `json:"description" binding:"required"`
+	InputDesc     string              `json:"input_desc"`
+	OutputDesc    string              `json:"output_desc"`
+	Difficulty    string              `json:"difficulty" binding:"required,oneof=Easy Medium Hard"`
+	Category      string              `json:"category"`
+	Tags          []string            `json:"tags"`
+	TimeLimit     int                 `json:"time_limit" binding:"min=100,max=30000"`
+	MemoryLimit   int                 `json:"memory_limit" binding:"min=16,max=1024"`
+	ReferenceCode string              `json:"reference_code"`
+	StarterCode   string              `json:"starter_code"`
+	Equivalence   *EquivalenceConfig  `json:"equivalence"`                                                          // 需要参考答案，配置后可以没有测试用例
+	TruthTable    *TruthTableConfig   `json:"truth_table"`                                                          // 配置后可以没有测试用例
+	Checker       string              `json:"checker" binding:"omitempty,oneof=vcd exact whitespace lines numeric"` // 为空时比较VCD
+	SpecialJudge  *SpecialJudgeConfig `json:"special_judge"`                                                        // 配置后代替 checker 判定输出
+	TestCases     []TestCaseRequest   `json:"test_cases"`
 }
 
 // ProblemUpdateRequest 更新题目请求
 type ProblemUpdateRequest struct {
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	InputDesc   string   `json:"input_desc"`
-	OutputDesc  string   `json:"output_desc"`
-	Difficulty  string   `json:"difficulty" binding:"omitempty,oneof=Easy Medium Hard"`
-	Category    string   `json:"category"`
-	Tags        []string `json:"tags"`
-	TimeLimit   int      `json:"time_limit" binding:"omitempty,min=100,max=30000"`
-	MemoryLimit int      `json:"memory_limit" binding:"omitempty,min=16,max=1024"`
-	IsPublic    *bool    `json:"is_public"`
+	Title              string              `json:"title"`
+	Description        string              `json:"description"`
+	InputDesc          string              `json:"input_desc"`
+	OutputDesc         string              `json:"output_desc"`
+	Difficulty         string              `json:"difficulty" binding:"omitempty,oneof=Easy Medium Hard"`
+	Category           string              `json:"category"`
+	Tags               []string            `json:"tags"`
+	TimeLimit          int                 `json:"time_limit" binding:"omitempty,min=100,max=30000"`
+	MemoryLimit        int                 `json:"memory_limit" binding:"omitempty,min=16,max=1024"`
+	ReferenceCode      *string             `json:"reference_code"` // 修改后重新校验测试用例
+	StarterCode        *string             `json:"starter_code"`
+	Equivalence        *EquivalenceConfig  `json:"equivalence"`                                                          // 修改后重新校验测试用例
+	RemoveEquivalence  bool                `json:"remove_equivalence"`                                                   // 删除等价性检查配置
+	TruthTable         *TruthTableConfig   `json:"truth_table"`                                                          // 修改后重新校验测试用例
+	RemoveTruthTable   bool                `json:"remove_truth_table"`                                                   // 删除真值表测试
+	Checker            *string             `json:"checker" binding:"omitempty,oneof=vcd exact whitespace lines numeric"` // 修改后重新校验测试用例
+	SpecialJudge       *SpecialJudgeConfig `json:"special_judge"`                                                        // 修改后重新校验测试用例
+	RemoveSpecialJudge bool                `json:"remove_special_judge"`                                                 // 删除检查程序
+	IsPublic           *bool               `json:"is_public"`
+}
+
+// EquivalenceConfig 与参考答案的随机激励等价性检查配置
+type EquivalenceConfig struct {
+	TopModule      string            `json:"top_module,omitempty"`
+	Seed           int               `json:"seed,omitempty"`
+	Cycles         int               `json:"cycles,omitempty" binding:"min=0,max=1000000"`
+	Clock          string            `json:"clock,omitempty"`
+	Reset          string            `json:"reset,omitempty"`
+	ResetActiveLow bool              `json:"reset_active_low,omitempty"`
+	ResetCycles    int               `json:"reset_cycles,omitempty" binding:"min=0,max=1000"`
+	Hold           map[string]uint64 `json:"hold,omitempty"`
+	ExhaustiveBits int               `json:"exhaustive_bits,omitempty" binding:"min=0,max=24"`
+	Formal         bool              `json:"formal,omitempty"`
+	FormalTimeout  int               `json:"formal_timeout,omitempty" binding:"min=0"`
+	FormalDepth    int               `json:"formal_depth,omitempty" binding:"min=0,max=1000"`
+}
+
+// TruthTableConfig 真值表测试配置
+// 真值表可以用 rows 直接给出，也可以用 data 提供 CSV 或 YAML 文本，两者只能选一种
+type TruthTableConfig struct {
+	TopModule string              `json:"top_module" binding:"required"`
+	Ports     []PortConfig        `json:"ports" binding:"required,dive"`
+	Format    string              `json:"format,omitempty" binding:"omitempty,oneof=csv yaml"` // data 的格式，默认 csv
+	Data      string              `json:"data,omitempty"`
+	Rows      []map[string]string `json:"rows,omitempty"`
+	Delay     int                 `json:"delay,omitempty" binding:"min=0,max=1000000"` // 纳秒
+}
+
+// PortConfig 被测模块的端口
+type PortConfig struct {
+	Name      string `json:"name" binding:"required"`
+	Direction string `json:"direction" binding:"required,oneof=input output"`
+	Width     int    `json:"width,omitempty" binding:"min=0,max=1024"`
+}
+
+// TruthTableResponse 真值表测试概要，真值表内容属于测试数据，不对外输出
+type TruthTableResponse struct {
+	TopModule string       `json:"top_module"`
+	Ports     []PortConfig `json:"ports"`
+	Delay     int          `json:"delay,omitempty"`
+	RowCount  int          `json:"row_count"`
+}
+
+// SpecialJudgeConfig 出题人提供的检查程序，接口和输出格式见 docs/problem-package.md
+type SpecialJudgeConfig struct {
+	Language  string `json:"language" binding:"required,oneof=go verilog"`
+	Source    string `json:"source" binding:"required"`
+	TimeLimit int    `json:"time_limit,omitempty" binding:"min=0,max=30000"` // 毫秒，0 使用判题服务默认值
+}
+
+// SpecialJudgeResponse 检查程序概要，源码属于测试数据，不对外输出
+type SpecialJudgeResponse struct {
+	Language  string `json:"language"`
+	TimeLimit int    `json:"time_limit,omitempty"`
+}
+
+// IOTraceConfig 逐周期的输入输出序列，判题服务据此生成带时钟的testbench
+type IOTraceConfig struct {
+	TopModule      string              `json:"top_module" binding:"required"`
+	Ports          []PortConfig        `json:"ports" binding:"required,dive"`
+	Clock          string              `json:"clock" binding:"required"`
+	Reset          string              `json:"reset,omitempty"`
+	ResetActiveLow bool                `json:"reset_active_low,omitempty"`
+	ResetCycles    int                 `json:"reset_cycles,omitempty" binding:"min=0,max=1000"`
+	Period         int                 `json:"period,omitempty" binding:"min=0,max=1000000"` // 纳秒，偶数
+	SampleEdge     string              `json:"sample_edge,omitempty" binding:"omitempty,oneof=posedge negedge"`
+	Cycles         []map[string]string `json:"cycles" binding:"required"`
 }
 
 // TestCaseRequest 测试用例请求
 type TestCaseRequest struct {
-	Input    string `json:"input" binding:"required"`
-	Output   string `json:"output" binding:"required"`
-	IsSample bool   `json:"is_sample"`
+	Input    string         `json:"input" binding:"required_without=Trace"`
+	Output   string         `json:"output"` // 为空时可以由参考答案生成，见 /problems/:id/expected-outputs
+	Trace    *IOTraceConfig `json:"trace"`  // 提供时不需要 input 和 output
+	IsSample bool           `json:"is_sample"`
+	SimTime  int            `json:"sim_time" binding:"min=0"` // 仿真时间预算（纳秒），0 使用判题服务默认值
+	Clock    string         `json:"clock" binding:"max=100"`  // 统计时钟周期数的信号名，为空时为 clk
 }
 
 // TestCaseAddRequest 添加测试用例请求
 type TestCaseAddRequest struct {
-	Input    string `json:"input" binding:"required"`
-	Output   string `json:"output" binding:"required"`
-	IsSample bool   `json:"is_sample"`
+	Input    string         `json:"input" binding:"required_without=Trace"`
+	Output   string         `json:"output"` // 为空时可以由参考答案生成，见 /problems/:id/expected-outputs
+	Trace    *IOTraceConfig `json:"trace"`
+	IsSample bool           `json:"is_sample"`
+	SimTime  int            `json:"sim_time" binding:"min=0"`
+	Clock    string         `json:"clock" binding:"max=100"`
 }
 
 // ProblemResponse 题目响应
 type ProblemResponse struct {
-	ID          uint               `json:"id"`
-	Title       string             `json:"title"`
-	Description string             `json:"description"`
-	InputDesc   string             `json:"input_desc"`
-	OutputDesc  string             `json:"output_desc"`
-	Difficulty  string             `json:"difficulty"`
-	Category    string             `json:"category"`
-	Tags        []string           `json:"tags"`
-	TimeLimit   int                `json:"time_limit"`
-	MemoryLimit int                `json:"memory_limit"`
-	IsPublic    bool               `json:"is_public"`
-	AuthorID    uint               `json:"author_id"`
-	SubmitCount int                `json:"submit_count"`
-	AcceptCount int                `json:"accept_count"`
-	TestCases   []TestCaseResponse `json:"test_cases,omitempty"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at"`
+	ID               uint                  `json:"id"`
+	Title            string                `json:"title"`
+	Description      string                `json:"description"`
+	InputDesc        string                `json:"input_desc"`
+	OutputDesc       string                `json:"output_desc"`
+	Difficulty       string                `json:"difficulty"`
+	Category         string                `json:"category"`
+	Tags             []string              `json:"tags"`
+	TimeLimit        int                   `json:"time_limit"`
+	MemoryLimit      int                   `json:"memory_limit"`
+	StarterCode      string                `json:"starter_code,omitempty"`
+	Equivalence      *EquivalenceConfig    `json:"equivalence,omitempty"`
+	TruthTable       *TruthTableResponse   `json:"truth_table,omitempty"`
+	Checker          string                `json:"checker,omitempty"`
+	SpecialJudge     *SpecialJudgeResponse `json:"special_judge,omitempty"`
+	IsPublic         bool                  `json:"is_public"`
+	ValidationStatus string                `json:"validation_status"`
+	AuthorID         uint                  `json:"author_id"`
+	SubmitCount      int                   `json:"submit_count"`
+	AcceptCount      int                   `json:"accept_count"`
+	TestCases        []TestCaseResponse    `json:"test_cases,omitempty"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
 }
 
 // ProblemListResponse 题目列表响应
@@ -97,13 +192,17 @@ type ProblemDetailsResponse struct {
 
 // TestCaseResponse 测试用例响应
 type TestCaseResponse struct {
-	ID        uint      `json:"id"`
-	ProblemID uint      `json:"problem_id"`
-	Input     string    `json:"input"`
-	Output    string    `json:"output"`
-	IsSample  bool      `json:"is_sample"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint           `json:"id"`
+	ProblemID   uint           `json:"problem_id"`
+	Input       string         `json:"input"`
+	Output      string         `json:"output"`
+	IsSample    bool           `json:"is_sample"`
+	Description string         `json:"description,omitempty"`
+	SimTime     int            `json:"sim_time,omitempty"`
+	Clock       string         `json:"clock,omitempty"`
+	Trace       *IOTraceConfig `json:"trace,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
 // TestCaseListResponse 测试用例列表响应
@@ -116,3 +215,78 @@ type TestCaseAddResponse struct {
 	Message  string           `json:"message"`
 	TestCase TestCaseResponse `json:"test_case"`
 }
+
+// ProblemImportResult 单个题目包的导入结果
+type ProblemImportResult struct {
+	Path      string   `json:"path"`
+	Title     string   `json:"title,omitempty"`
+	ProblemID uint     `json:"problem_id,omitempty"`
+	Valid     bool     `json:"valid"`
+	Errors    []string `json:"errors"`
+	Warnings  []string `json:"warnings"`
+}
+
+// ProblemImportResponse 导入题目响应
+type ProblemImportResponse struct {
+	Message string                `json:"message"`
+	DryRun  bool                  `json:"dry_run"`
+	Created int                   `json:"created"`
+	Invalid int                   `json:"invalid"`
+	Results []ProblemImportResult `json:"results"`
+}
+
+// ValidationCaseResponse 单个测试用例的参考答案校验结果
+type ValidationCaseResponse struct {
+	Index        int    `json:"index"`
+	Description  string `json:"description,omitempty"`
+	Status       string `json:"status"`
+	RunTime      int    `json:"run_time"`
+	Memory       int    `json:"memory"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	SimTime      int64  `json:"sim_time,omitempty"` // 纳秒
+	Cycles       int    `json:"cycles,omitempty"`
+}
+
+// ProblemValidationResponse 参考答案校验结果响应
+type ProblemValidationResponse struct {
+	ProblemID   uint                     `json:"problem_id"`
+	Status      string                   `json:"status"` // unvalidated, pending, validated, invalid
+	Message     string                   `json:"message,omitempty"`
+	Cases       []ValidationCaseResponse `json:"cases"`
+	ValidatedAt *time.Time               `json:"validated_at,omitempty"`
+}
+
+// ExpectedOutputChangeResponse 单个测试用例或真值表生成前后的期望输出对比
+type ExpectedOutputChangeResponse struct {
+	Index        int    `json:"index"`                  // 从1开始，真值表排在测试用例之后
+	TestCaseID   uint   `json:"test_case_id,omitempty"` // 真值表为空
+	Description  string `json:"description"`
+	Changed      bool   `json:"changed"`
+	ChangedLines int    `json:"changed_lines"`        // 不同的行数，I/O 序列为周期数，真值表为行数
+	FirstLine    int    `json:"first_line,omitempty"` // 第一处不同的位置，从1开始
+	Previous     string `json:"previous,omitempty"`   // 第一处不同的位置原来的内容
+	Generated    string `json:"generated,omitempty"`  // 第一处不同的位置生成的内容
+}
+
+// ExpectedOutputsResponse 由参考答案生成的期望输出响应
+type ExpectedOutputsResponse struct {
+	ProblemID  uint                           `json:"problem_id"`
+	Status     string                         `json:"status"` // pending, ready, failed
+	Message    string                         `json:"message,omitempty"`
+	Stale      bool                           `json:"stale"` // 生成后参考答案、测试用例或判题配置发生了变化，需要重新生成
+	Changes    []ExpectedOutputChangeResponse `json:"changes"`
+	CreatedAt  time.Time                      `json:"created_at"`
+	FinishedAt *time.Time                     `json:"finished_at,omitempty"`
+}
+
+// ExpectedOutputsGenerateResponse 生成期望输出响应
+type ExpectedOutputsGenerateResponse struct {
+	Message    string                  `json:"message"`
+	Generation ExpectedOutputsResponse `json:"generation"`
+}
+
+// ProblemValidateResponse 重新校验题目响应
+type ProblemValidateResponse struct {
+	Message    string                    `json:"message"`
+	Validation ProblemValidationResponse `json:"validation"`
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"time"
+	"verilog-oj/protocol"
+)
 
 // SubmissionCreateRequest 创建提交请求
 type SubmissionCreateRequest struct {
@@ -60,7 +63,72 @@ type SubmissionDeleteResponse struct {
 	Message string `json:"message"`
 }
 
-// SubmissionDetailsResponse 提交详情响应
-type SubmissionDetailsResponse struct {
+// SubmissionCancelResponse 取消判题响应
+type SubmissionCancelResponse struct {
+	Message    string             `json:"message"`
 	Submission SubmissionResponse `json:"submission"`
 }
+
+// SubmissionDetailsResponse 提交详情响应，等待判题的提交附带排队信息
+type SubmissionDetailsResponse struct {
+	Submission SubmissionResponse     `json:"submission"`
+	Queue      *QueuePositionResponse `json:"queue,omitempty"`
+}
+
+// QueuePositionResponse 提交在判题队列中的位置
+type QueuePositionResponse struct {
+	Lane           string     `json:"lane"`
+	Position       int64      `json:"position"`                  // 从1开始，1表示下一个被取出
+	EstimatedStart *time.Time `json:"estimated_start,omitempty"` // 没有判题耗时记录或存活节点时省略
+	EstimatedWait  *int64     `json:"estimated_wait,omitempty"`  // 秒
+}
+
+// JudgeQueueLaneResponse 判题队列通道
+type JudgeQueueLaneResponse struct {
+	Name  string `json:"name"`
+	Depth int64  `json:"depth"`
+}
+
+// JudgeQueueResponse 判题队列概况响应
+type JudgeQueueResponse struct {
+	Lanes            []JudgeQueueLaneResponse `json:"lanes"`
+	Total            int64                    `json:"total"`      // 所有通道等待中的任务数
+	Processing       int64                    `json:"processing"` // 判题节点正在处理的任务数
+	Nodes            int                      `json:"nodes"`
+	Workers          int                      `json:"workers"`
+	AverageJudgeTime int64                    `json:"average_judge_time"` // 毫秒，没有记录时为0
+}
+
+// SubmissionEventResponse 判题进度事件，客户端重连时用 id 请求补发之后的事件
+type SubmissionEventResponse struct {
+	ID string `json:"id"`
+	*protocol.JudgeEvent
+}
+
+// SubmissionRecoveryResponse 看门狗处理记录
+type SubmissionRecoveryResponse struct {
+	Action    string    `json:"action"` // requeued, failed
+	Attempt   int       `json:"attempt"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StuckSubmissionResponse 卡住的提交
+type StuckSubmissionResponse struct {
+	ID         uint                         `json:"id"`
+	UserID     uint                         `json:"user_id"`
+	ProblemID  uint                         `json:"problem_id"`
+	Language   string                       `json:"language"`
+	Status     string                       `json:"status"`
+	CreatedAt  time.Time                    `json:"created_at"`
+	UpdatedAt  time.Time                    `json:"updated_at"`
+	StuckFor   int64                        `json:"stuck_for"` // 距上次更新的秒数
+	Attempts   int                          `json:"attempts"`  // 已重新入队的次数
+	Recoveries []SubmissionRecoveryResponse `json:"recoveries"`
+}
+
+// StuckSubmissionListResponse 卡住的提交列表响应
+type StuckSubmissionListResponse struct {
+	Submissions []StuckSubmissionResponse `json:"submissions"`
+	Total       int                       `json:"total"`
+}
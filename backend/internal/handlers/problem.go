@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+	"verilog-oj/judge-service/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +22,14 @@ type ProblemService interface {
 	DeleteProblem(id uint) error
 	GetTestCases(problemID uint) ([]domain.TestCase, error)
 	AddTestCase(testCase *domain.TestCase) error
+	AddTestCases(problemID uint, testCases []*domain.TestCase) error
+	ValidateProblem(id uint) (*domain.ProblemValidation, error)
+	SetProblemPublic(id uint, public bool) (*domain.Problem, error)
+	GenerateExpectedOutputs(id uint) (*domain.OutputGeneration, error)
+	GetOutputPreview(id uint) (*services.OutputPreview, error)
+	ApplyGeneratedOutputs(id uint) (*domain.ProblemValidation, error)
+	ExportProblem(id uint) (*problem.Package, error)
+	ImportProblems(entries []problem.Entry, authorID uint, dryRun bool) []services.ProblemImportResult
 }
 
 // ProblemHandler 题目处理器
@@ -143,6 +155,27 @@ func (h *ProblemHandler) CreateProblem(c *gin.Context) {
 		return
 	}
 
+	truthTable, err := parseTruthTable(req.TruthTable)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_truth_table",
+			"message": "真值表无效：" + err.Error(),
+		})
+		return
+	}
+
+	// 创建题目前校验全部 I/O 序列，避免题目创建后测试用例失败
+	traces := make([]*domain.IOTrace, len(req.TestCases))
+	for i, tc := range req.TestCases {
+		if traces[i], err = parseTestCaseTrace(tc.Input, tc.Output, tc.Trace); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_trace",
+				"message": fmt.Sprintf("测试用例 #%d 的 I/O 序列无效：%s", i+1, err.Error()),
+			})
+			return
+		}
+	}
+
 	// 设置默认值
 	if req.TimeLimit == 0 {
 		req.TimeLimit = 1000
@@ -153,17 +186,23 @@ func (h *ProblemHandler) CreateProblem(c *gin.Context) {
 
 	// 创建题目
 	problem := &domain.Problem{
-		Title:       req.Title,
-		Description: req.Description,
-		InputDesc:   req.InputDesc,
-		OutputDesc:  req.OutputDesc,
-		Difficulty:  req.Difficulty,
-		Category:    req.Category,
-		Tags:        req.Tags,
-		TimeLimit:   req.TimeLimit,
-		MemoryLimit: req.MemoryLimit,
-		IsPublic:    false, // 默认私有
-		AuthorID:    userID.(uint),
+		Title:         req.Title,
+		Description:   req.Description,
+		InputDesc:     req.InputDesc,
+		OutputDesc:    req.OutputDesc,
+		Difficulty:    req.Difficulty,
+		Category:      req.Category,
+		Tags:          req.Tags,
+		TimeLimit:     req.TimeLimit,
+		MemoryLimit:   req.MemoryLimit,
+		ReferenceCode: req.ReferenceCode,
+		StarterCode:   req.StarterCode,
+		Equivalence:   dto.EquivalenceDTOToDomain(req.Equivalence),
+		TruthTable:    truthTable,
+		Checker:       req.Checker,
+		SpecialJudge:  dto.SpecialJudgeDTOToDomain(req.SpecialJudge),
+		IsPublic:      false, // 默认私有，参考答案校验通过后才能发布
+		AuthorID:      userID.(uint),
 	}
 
 	if err := h.problemService.CreateProblem(problem); err != nil {
@@ -174,22 +213,34 @@ func (h *ProblemHandler) CreateProblem(c *gin.Context) {
 		return
 	}
 
-	// 创建测试用例
+	// 创建测试用例，全部创建后统一校验参考答案
 	if len(req.TestCases) > 0 {
-		for _, tc := range req.TestCases {
-			testCase := &domain.TestCase{
-				ProblemID: problem.ID,
-				Input:     tc.Input,
-				Output:    tc.Output,
-				IsSample:  tc.IsSample,
-			}
-			if err := h.problemService.AddTestCase(testCase); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "creation_failed",
-					"message": "创建测试用例失败：" + err.Error(),
-				})
-				return
-			}
+		testCases := make([]*domain.TestCase, 0, len(req.TestCases))
+		for i, tc := range req.TestCases {
+			testCases = append(testCases, &domain.TestCase{
+				Input:    tc.Input,
+				Output:   tc.Output,
+				IsSample: tc.IsSample,
+				SimTime:  tc.SimTime,
+				Clock:    tc.Clock,
+				Trace:    traces[i],
+			})
+		}
+		if err := h.problemService.AddTestCases(problem.ID, testCases); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "creation_failed",
+				"message": "创建测试用例失败：" + err.Error(),
+			})
+			return
+		}
+	} else if problem.Equivalence != nil || problem.TruthTable != nil {
+		// 只有等价性检查或真值表的题目没有测试用例，直接校验参考答案
+		if _, err := h.problemService.ValidateProblem(problem.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "validation_failed",
+				"message": "题目已创建，但创建校验任务失败：" + err.Error(),
+			})
+			return
 		}
 	}
 
@@ -281,7 +332,65 @@ func (h *ProblemHandler) UpdateProblem(c *gin.Context) {
 	if req.MemoryLimit > 0 {
 		problem.MemoryLimit = req.MemoryLimit
 	}
-	if req.IsPublic != nil {
+	if req.StarterCode != nil {
+		problem.StarterCode = *req.StarterCode
+	}
+	referenceChanged := req.ReferenceCode != nil && *req.ReferenceCode != problem.ReferenceCode
+	if referenceChanged {
+		problem.ReferenceCode = *req.ReferenceCode
+	}
+	// 等价性检查配置变化同样需要重新校验
+	if req.RemoveEquivalence && problem.Equivalence != nil {
+		problem.Equivalence = nil
+		referenceChanged = true
+	} else if req.Equivalence != nil {
+		problem.Equivalence = dto.EquivalenceDTOToDomain(req.Equivalence)
+		referenceChanged = true
+	}
+	if req.RemoveTruthTable && problem.TruthTable != nil {
+		problem.TruthTable = nil
+		referenceChanged = true
+	} else if req.TruthTable != nil {
+		truthTable, err := parseTruthTable(req.TruthTable)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_truth_table",
+				"message": "真值表无效：" + err.Error(),
+			})
+			return
+		}
+		problem.TruthTable = truthTable
+		referenceChanged = true
+	}
+	// 比较方式决定期望输出的含义，修改后同样需要重新校验
+	if req.Checker != nil && *req.Checker != problem.Checker {
+		problem.Checker = *req.Checker
+		referenceChanged = true
+	}
+	if req.RemoveSpecialJudge && problem.SpecialJudge != nil {
+		problem.SpecialJudge = nil
+		referenceChanged = true
+	} else if req.SpecialJudge != nil {
+		problem.SpecialJudge = dto.SpecialJudgeDTOToDomain(req.SpecialJudge)
+		referenceChanged = true
+	}
+
+	// 修改公开状态需要发布权限，发布前参考答案必须通过全部测试用例
+	if req.IsPublic != nil && *req.IsPublic != problem.IsPublic {
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "permission_denied",
+				"message": "没有权限修改题目的公开状态",
+			})
+			return
+		}
+		if *req.IsPublic && (referenceChanged || problem.Validation.Status != domain.ValidationValidated) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "problem_not_validated",
+				"message": services.ErrProblemNotValidated.Error(),
+			})
+			return
+		}
 		problem.IsPublic = *req.IsPublic
 	}
 
@@ -299,12 +408,46 @@ func (h *ProblemHandler) UpdateProblem(c *gin.Context) {
 		return
 	}
 
+	// 参考答案或等价性检查变化后重新校验测试用例
+	if referenceChanged {
+		validation, err := h.problemService.ValidateProblem(problem.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "validation_failed",
+				"message": "题目已更新，但创建校验任务失败：" + err.Error(),
+			})
+			return
+		}
+		problem.Validation = *validation
+	}
+
 	c.JSON(http.StatusOK, dto.ProblemUpdateResponse{
 		Message: "题目更新成功",
 		Problem: dto.ProblemDomainToResponse(problem),
 	})
 }
 
+// parseTruthTable 解析请求中的真值表，并校验列是否都是声明的端口、取值是否符合位宽
+func parseTruthTable(config *dto.TruthTableConfig) (*domain.TruthTable, error) {
+	table, err := dto.TruthTableDTOToDomain(config)
+	if err != nil || table == nil {
+		return table, err
+	}
+	return table, services.ValidateTruthTable(table)
+}
+
+// parseTestCaseTrace 解析测试用例的 I/O 序列并按判题服务的规则校验，序列与 testbench、期望波形不能同时提供
+func parseTestCaseTrace(input, output string, config *dto.IOTraceConfig) (*domain.IOTrace, error) {
+	trace := dto.IOTraceDTOToDomain(config)
+	if trace == nil {
+		return nil, nil
+	}
+	if input != "" || output != "" {
+		return nil, errors.New("提供 trace 时不能再提供 input 和 output")
+	}
+	return trace, services.ValidateIOTrace(trace)
+}
+
 // DeleteProblem 删除题目
 func (h *ProblemHandler) DeleteProblem(c *gin.Context) {
 	// 获取题目ID
@@ -478,12 +621,24 @@ func (h *ProblemHandler) AddTestCase(c *gin.Context) {
 		return
 	}
 
+	trace, err := parseTestCaseTrace(req.Input, req.Output, req.Trace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_trace",
+			"message": "I/O 序列无效：" + err.Error(),
+		})
+		return
+	}
+
 	// 创建测试用例
 	testCase := &domain.TestCase{
 		ProblemID: uint(id),
 		Input:     req.Input,
 		Output:    req.Output,
 		IsSample:  req.IsSample,
+		SimTime:   req.SimTime,
+		Clock:     req.Clock,
+		Trace:     trace,
 	}
 
 	if err := h.problemService.AddTestCase(testCase); err != nil {
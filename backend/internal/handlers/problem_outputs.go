@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExpectedOutputs 获取由参考答案生成的期望输出及其与当前期望输出的对比
+func (h *ProblemHandler) GetExpectedOutputs(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	preview, err := h.problemService.GetOutputPreview(uint(id))
+	if err != nil {
+		switch {
+		case err.Error() == "题目不存在":
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+		case errors.Is(err, services.ErrOutputGenerationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "outputs_not_generated",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "get_outputs_failed",
+				"message": "获取生成的期望输出失败：" + err.Error(),
+			})
+		}
+		return
+	}
+
+	response := dto.OutputGenerationDomainToResponse(preview.Generation)
+	response.Stale = preview.Stale
+	for _, change := range preview.Changes {
+		response.Changes = append(response.Changes, dto.ExpectedOutputChangeResponse(change))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GenerateExpectedOutputs 运行参考答案生成测试用例和真值表的期望输出，生成结果需要确认后才写入
+func (h *ProblemHandler) GenerateExpectedOutputs(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	generation, err := h.problemService.GenerateExpectedOutputs(uint(id))
+	if err != nil {
+		switch {
+		case err.Error() == "题目不存在":
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+		case errors.Is(err, services.ErrCannotGenerateOutputs):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "cannot_generate_outputs",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "generate_outputs_failed",
+				"message": "创建生成任务失败：" + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.ExpectedOutputsGenerateResponse{
+		Message:    "生成任务已创建",
+		Generation: dto.OutputGenerationDomainToResponse(generation),
+	})
+}
+
+// ApplyExpectedOutputs 确认生成的期望输出，写入测试用例和真值表后重新校验
+func (h *ProblemHandler) ApplyExpectedOutputs(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	validation, err := h.problemService.ApplyGeneratedOutputs(uint(id))
+	if err != nil {
+		switch {
+		case err.Error() == "题目不存在":
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+		case errors.Is(err, services.ErrOutputGenerationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "outputs_not_generated",
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrOutputGenerationNotReady), errors.Is(err, services.ErrOutputGenerationStale):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "outputs_not_applicable",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "apply_outputs_failed",
+				"message": "写入期望输出失败：" + err.Error(),
+			})
+		}
+		return
+	}
+
+	message := "期望输出已写入，校验任务已创建"
+	if validation.Message != "" {
+		message = "期望输出已写入，无法校验：" + validation.Message
+	}
+	c.JSON(http.StatusOK, dto.ProblemValidateResponse{
+		Message:    message,
+		Validation: dto.ProblemValidationDomainToResponse(uint(id), validation),
+	})
+}
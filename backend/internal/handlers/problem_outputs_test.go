@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemHandler_GenerateExpectedOutputs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		generation   *domain.OutputGeneration
+		serviceErr   error
+		expectedCode int
+	}{
+		{
+			name:         "Success",
+			generation:   &domain.OutputGeneration{ProblemID: 1, Status: domain.OutputGenerationPending},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "No Reference",
+			serviceErr:   fmt.Errorf("%w：题目没有参考答案", services.ErrCannotGenerateOutputs),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Not Found",
+			serviceErr:   errors.New("题目不存在"),
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = []gin.Param{{Key: "id", Value: "1"}}
+			c.Request, _ = http.NewRequest(http.MethodPost, "/problems/1/expected-outputs", nil)
+
+			mockService := new(MockProblemService)
+			handler := NewProblemHandler(mockService)
+			if tt.generation != nil {
+				mockService.On("GenerateExpectedOutputs", uint(1)).Return(tt.generation, nil)
+			} else {
+				mockService.On("GenerateExpectedOutputs", uint(1)).Return(nil, tt.serviceErr)
+			}
+
+			handler.GenerateExpectedOutputs(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.generation != nil {
+				var resp dto.ExpectedOutputsGenerateResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, domain.OutputGenerationPending, resp.Generation.Status)
+			}
+		})
+	}
+}
+
+func TestProblemHandler_GetExpectedOutputs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodGet, "/problems/1/expected-outputs", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetOutputPreview", uint(1)).Return(&services.OutputPreview{
+			Generation: &domain.OutputGeneration{ProblemID: 1, Status: domain.OutputGenerationReady},
+			Changes: []services.OutputChange{
+				{Index: 1, TestCaseID: 3, Description: "测试用例 #1", Changed: true, ChangedLines: 2, FirstLine: 4, Previous: "sum = 4", Generated: "sum = 5"},
+				{Index: 2, Description: "真值表"},
+			},
+		}, nil)
+
+		handler.GetExpectedOutputs(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp dto.ExpectedOutputsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, domain.OutputGenerationReady, resp.Status)
+		assert.Len(t, resp.Changes, 2)
+		assert.Equal(t, "sum = 5", resp.Changes[0].Generated)
+		assert.False(t, resp.Changes[1].Changed)
+	})
+
+	t.Run("Not Generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodGet, "/problems/1/expected-outputs", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetOutputPreview", uint(1)).Return(nil, services.ErrOutputGenerationNotFound)
+
+		handler.GetExpectedOutputs(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestProblemHandler_ApplyExpectedOutputs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems/1/expected-outputs/apply", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("ApplyGeneratedOutputs", uint(1)).Return(&domain.ProblemValidation{Status: domain.ValidationPending}, nil)
+
+		handler.ApplyExpectedOutputs(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp dto.ProblemValidateResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, domain.ValidationPending, resp.Validation.Status)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems/1/expected-outputs/apply", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("ApplyGeneratedOutputs", uint(1)).Return(nil, services.ErrOutputGenerationStale)
+
+		handler.ApplyExpectedOutputs(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
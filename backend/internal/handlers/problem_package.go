@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/judge-service/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize 单次导入上传内容的大小上限
+const maxImportSize = 64 << 20
+
+// ExportProblem 导出题目包（zip）
+func (h *ProblemHandler) ExportProblem(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	pkg, err := h.problemService.ExportProblem(uint(id))
+	if err != nil {
+		if err.Error() == "题目不存在" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "export_failed",
+			"message": "导出题目失败：" + err.Error(),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pkg.WriteZip(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "export_failed",
+			"message": "导出题目失败：" + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="problem-%d.zip"`, id))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// ImportProblems 导入题目包
+// 通过 multipart 字段 file 上传一个或多个zip，每个zip可以包含一个或多个题目包；dry_run=true 时只返回校验报告
+func (h *ProblemHandler) ImportProblems(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "用户未认证",
+		})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_request",
+			"message": "请求参数错误：" + err.Error(),
+		})
+		return
+	}
+	files := form.File["file"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_request",
+			"message": "请上传题目包文件（字段 file）",
+		})
+		return
+	}
+
+	// 读取所有上传的zip，单个文件损坏时记录错误并继续处理其他文件
+	var entries []problem.Entry
+	for _, header := range files {
+		loaded, err := loadUploadedPackages(header)
+		if err != nil {
+			entries = append(entries, problem.Entry{Path: header.Filename, Err: err})
+			continue
+		}
+		entries = append(entries, loaded...)
+	}
+
+	results := h.problemService.ImportProblems(entries, userID.(uint), dryRun)
+
+	response := dto.ProblemImportResponse{DryRun: dryRun, Results: make([]dto.ProblemImportResult, 0, len(results))}
+	for _, result := range results {
+		if result.ProblemID != 0 {
+			response.Created++
+		}
+		if !result.Valid {
+			response.Invalid++
+		}
+		response.Results = append(response.Results, dto.ProblemImportResult{
+			Path:      result.Path,
+			Title:     result.Title,
+			ProblemID: result.ProblemID,
+			Valid:     result.Valid,
+			Errors:    nonNil(result.Errors),
+			Warnings:  nonNil(result.Warnings),
+		})
+	}
+
+	switch {
+	case dryRun:
+		response.Message = fmt.Sprintf("校验完成：%d 个题目包，%d 个存在错误", len(results), response.Invalid)
+		c.JSON(http.StatusOK, response)
+	case response.Created == 0:
+		response.Message = "没有题目被导入"
+		c.JSON(http.StatusBadRequest, response)
+	default:
+		response.Message = fmt.Sprintf("成功导入 %d 个题目，%d 个题目包被跳过", response.Created, response.Invalid)
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// loadUploadedPackages 读取上传的zip，题目包路径以文件名为前缀
+func loadUploadedPackages(header *multipart.FileHeader) ([]problem.Entry, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := problem.LoadZip(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Path == "." {
+			entries[i].Path = header.Filename
+		} else {
+			entries[i].Path = header.Filename + "/" + entries[i].Path
+		}
+	}
+	return entries, nil
+}
+
+// nonNil 保证JSON中输出空数组而不是null
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+	"verilog-oj/judge-service/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// buildImportRequest 构造包含题目包zip的 multipart 请求
+func buildImportRequest(t *testing.T, url string, files map[string]map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for filename, contents := range files {
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for name, content := range contents {
+			fw, err := zw.Create(name)
+			assert.NoError(t, err)
+			_, _ = fw.Write([]byte(content))
+		}
+		assert.NoError(t, zw.Close())
+
+		part, err := writer.CreateFormFile("file", filename)
+		assert.NoError(t, err)
+		_, _ = part.Write(archive.Bytes())
+	}
+	assert.NoError(t, writer.Close())
+
+	req, _ := http.NewRequest(http.MethodPost, url, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestProblemHandler_ImportProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	adder := map[string]string{
+		"adder/problem.yaml":  "title: Adder\ndescription: add\ntest_cases:\n  - testbench: tb.v\n",
+		"adder/tb.v":          "module tb; endmodule",
+		"broken/problem.yaml": "title: Broken\ntest_cases: []\n",
+	}
+
+	t.Run("预检", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = buildImportRequest(t, "/problems/import?dry_run=true", map[string]map[string]string{"bank.zip": adder})
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		mockService.On("ImportProblems", mock.MatchedBy(func(entries []problem.Entry) bool {
+			return len(entries) == 2 &&
+				entries[0].Path == "bank.zip/adder" && entries[0].Err == nil && entries[0].Package.Metadata.Title == "Adder" &&
+				entries[1].Path == "bank.zip/broken" && entries[1].Err != nil
+		}), uint(1), true).Return([]services.ProblemImportResult{
+			{Path: "bank.zip/adder", Title: "Adder", Valid: true},
+			{Path: "bank.zip/broken", Errors: []string{"invalid"}},
+		})
+
+		handler := NewProblemHandler(mockService)
+		handler.ImportProblems(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response dto.ProblemImportResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.DryRun)
+		assert.Equal(t, 0, response.Created)
+		assert.Equal(t, 1, response.Invalid)
+		assert.Len(t, response.Results, 2)
+		assert.Equal(t, []string{}, response.Results[0].Errors)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("导入成功", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = buildImportRequest(t, "/problems/import", map[string]map[string]string{"bank.zip": adder})
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		mockService.On("ImportProblems", mock.Anything, uint(1), false).Return([]services.ProblemImportResult{
+			{Path: "bank.zip/adder", Title: "Adder", ProblemID: 9, Valid: true},
+			{Path: "bank.zip/broken", Errors: []string{"invalid"}},
+		})
+
+		handler := NewProblemHandler(mockService)
+		handler.ImportProblems(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response dto.ProblemImportResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Created)
+		assert.Equal(t, uint(9), response.Results[0].ProblemID)
+	})
+
+	t.Run("全部无效", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = buildImportRequest(t, "/problems/import", map[string]map[string]string{"bank.zip": adder})
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		mockService.On("ImportProblems", mock.Anything, uint(1), false).Return([]services.ProblemImportResult{
+			{Path: "bank.zip/broken", Errors: []string{"invalid"}},
+		})
+
+		handler := NewProblemHandler(mockService)
+		handler.ImportProblems(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("缺少文件", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = buildImportRequest(t, "/problems/import", nil)
+		c.Set("user_id", uint(1))
+
+		handler := NewProblemHandler(new(MockProblemService))
+		handler.ImportProblems(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestProblemHandler_ExportProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("导出成功", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/problems/3/export", nil)
+		c.Params = gin.Params{{Key: "id", Value: "3"}}
+
+		pkg := &problem.Package{
+			Metadata:    problem.Metadata{Title: "Adder"},
+			Description: "add",
+			TestCases:   []problem.TestCase{{Testbench: "module tb; endmodule", Expected: "1"}},
+		}
+		mockService := new(MockProblemService)
+		mockService.On("ExportProblem", uint(3)).Return(pkg, nil)
+
+		handler := NewProblemHandler(mockService)
+		handler.ExportProblem(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "problem-3.zip")
+
+		entries, err := problem.LoadZip(w.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.NoError(t, entries[0].Err)
+		assert.Equal(t, "add", entries[0].Package.Description)
+		assert.Equal(t, "1", entries[0].Package.TestCases[0].Expected)
+	})
+
+	t.Run("题目不存在", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/problems/99/export", nil)
+		c.Params = gin.Params{{Key: "id", Value: "99"}}
+
+		mockService := new(MockProblemService)
+		mockService.On("ExportProblem", uint(99)).Return(nil, errors.New("题目不存在"))
+
+		handler := NewProblemHandler(mockService)
+		handler.ExportProblem(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
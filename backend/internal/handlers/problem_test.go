@@ -9,6 +9,8 @@ import (
 	"testing"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+	"verilog-oj/judge-service/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -58,6 +60,64 @@ func (m *MockProblemService) AddTestCase(testCase *domain.TestCase) error {
 	return args.Error(0)
 }
 
+func (m *MockProblemService) AddTestCases(problemID uint, testCases []*domain.TestCase) error {
+	args := m.Called(problemID, testCases)
+	return args.Error(0)
+}
+
+func (m *MockProblemService) ValidateProblem(id uint) (*domain.ProblemValidation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ProblemValidation), args.Error(1)
+}
+
+func (m *MockProblemService) GenerateExpectedOutputs(id uint) (*domain.OutputGeneration, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OutputGeneration), args.Error(1)
+}
+
+func (m *MockProblemService) GetOutputPreview(id uint) (*services.OutputPreview, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.OutputPreview), args.Error(1)
+}
+
+func (m *MockProblemService) ApplyGeneratedOutputs(id uint) (*domain.ProblemValidation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ProblemValidation), args.Error(1)
+}
+
+func (m *MockProblemService) SetProblemPublic(id uint, public bool) (*domain.Problem, error) {
+	args := m.Called(id, public)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Problem), args.Error(1)
+}
+
+func (m *MockProblemService) ExportProblem(id uint) (*problem.Package, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*problem.Package), args.Error(1)
+}
+
+func (m *MockProblemService) ImportProblems(entries []problem.Entry, authorID uint, dryRun bool) []services.ProblemImportResult {
+	args := m.Called(entries, authorID, dryRun)
+	return args.Get(0).([]services.ProblemImportResult)
+}
+
 func TestProblemHandler_ListProblems(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -211,6 +271,162 @@ func TestProblemHandler_CreateProblem(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 
+	t.Run("Truth Table", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+
+		req := dto.ProblemCreateRequest{
+			Title:       "Half Adder",
+			Description: "Half Adder",
+			Difficulty:  "Easy",
+			TimeLimit:   1000,
+			MemoryLimit: 128,
+			TruthTable: &dto.TruthTableConfig{
+				TopModule: "half_adder",
+				Ports:     []dto.PortConfig{{Name: "a", Direction: "input"}, {Name: "b", Direction: "input"}, {Name: "s", Direction: "output"}},
+				Data:      "a,b,s\n0,0,0\n0,1,1\n1,1,\n",
+			},
+		}
+		reqBody, _ := json.Marshal(req)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems", bytes.NewBuffer(reqBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		mockService.On("CreateProblem", mock.MatchedBy(func(p *domain.Problem) bool {
+			return p.TruthTable != nil && len(p.TruthTable.Rows) == 3 && p.TruthTable.Rows[2]["s"] == ""
+		})).Return(nil)
+		mockService.On("ValidateProblem", uint(0)).Return(&domain.ProblemValidation{Status: domain.ValidationPending}, nil)
+
+		handler.CreateProblem(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Truth Table", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+
+		req := dto.ProblemCreateRequest{
+			Title:       "Half Adder",
+			Description: "Half Adder",
+			Difficulty:  "Easy",
+			TimeLimit:   1000,
+			MemoryLimit: 128,
+			TruthTable: &dto.TruthTableConfig{
+				TopModule: "half_adder",
+				Ports:     []dto.PortConfig{{Name: "a", Direction: "input"}, {Name: "s", Direction: "output"}},
+				Rows:      []map[string]string{{"a": "1", "c": "0"}},
+			},
+		}
+		reqBody, _ := json.Marshal(req)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems", bytes.NewBuffer(reqBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateProblem(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_truth_table")
+		assert.Contains(t, w.Body.String(), "column c is not a declared port")
+		mockService.AssertNotCalled(t, "CreateProblem", mock.Anything)
+	})
+
+	t.Run("Invalid Special Judge", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+
+		req := dto.ProblemCreateRequest{
+			Title:        "Adder",
+			Description:  "Adder",
+			Difficulty:   "Easy",
+			TimeLimit:    1000,
+			MemoryLimit:  128,
+			SpecialJudge: &dto.SpecialJudgeConfig{Language: "python", Source: "print('VERDICT accepted')"},
+		}
+		reqBody, _ := json.Marshal(req)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems", bytes.NewBuffer(reqBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateProblem(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+		mockService.AssertNotCalled(t, "CreateProblem", mock.Anything)
+	})
+
+	t.Run("Invalid Checker", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+
+		req := dto.ProblemCreateRequest{
+			Title:       "Adder",
+			Description: "Adder",
+			Difficulty:  "Easy",
+			TimeLimit:   1000,
+			MemoryLimit: 128,
+			Checker:     "regex",
+		}
+		reqBody, _ := json.Marshal(req)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems", bytes.NewBuffer(reqBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateProblem(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+		mockService.AssertNotCalled(t, "CreateProblem", mock.Anything)
+	})
+
+	t.Run("Invalid Trace", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+
+		req := dto.ProblemCreateRequest{
+			Title:       "Counter",
+			Description: "Counter",
+			Difficulty:  "Easy",
+			TimeLimit:   1000,
+			MemoryLimit: 128,
+			TestCases: []dto.TestCaseRequest{{
+				Trace: &dto.IOTraceConfig{
+					TopModule: "counter",
+					Ports:     []dto.PortConfig{{Name: "clk", Direction: "input"}, {Name: "q", Direction: "output", Width: 2}},
+					Clock:     "clk",
+					Cycles:    []map[string]string{{"clk": "1", "q": "0"}},
+				},
+			}},
+		}
+		reqBody, _ := json.Marshal(req)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems", bytes.NewBuffer(reqBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateProblem(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_trace")
+		assert.Contains(t, w.Body.String(), "cycle 1: clock clk is driven by the testbench")
+		mockService.AssertNotCalled(t, "CreateProblem", mock.Anything)
+	})
+
 	t.Run("Invalid JSON", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProblemValidation 获取参考答案的校验结果
+func (h *ProblemHandler) GetProblemValidation(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	problem, err := h.problemService.GetProblem(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "problem_not_found",
+			"message": "题目不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ProblemValidationDomainToResponse(problem.ID, &problem.Validation))
+}
+
+// ValidateProblem 使用参考答案重新校验题目的测试用例
+func (h *ProblemHandler) ValidateProblem(c *gin.Context) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	validation, err := h.problemService.ValidateProblem(uint(id))
+	if err != nil {
+		if err.Error() == "题目不存在" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "validation_failed",
+			"message": "创建校验任务失败：" + err.Error(),
+		})
+		return
+	}
+
+	message := "校验任务已创建"
+	if validation.Message != "" {
+		message = "无法校验：" + validation.Message
+	}
+	c.JSON(http.StatusAccepted, dto.ProblemValidateResponse{
+		Message:    message,
+		Validation: dto.ProblemValidationDomainToResponse(uint(id), validation),
+	})
+}
+
+// PublishProblem 发布题目
+func (h *ProblemHandler) PublishProblem(c *gin.Context) {
+	h.setProblemPublic(c, true, "题目发布成功")
+}
+
+// UnpublishProblem 撤回题目
+func (h *ProblemHandler) UnpublishProblem(c *gin.Context) {
+	h.setProblemPublic(c, false, "题目已撤回")
+}
+
+// setProblemPublic 修改题目的公开状态
+func (h *ProblemHandler) setProblemPublic(c *gin.Context, public bool, successMessage string) {
+	// 获取题目ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的题目ID",
+		})
+		return
+	}
+
+	problem, err := h.problemService.SetProblemPublic(uint(id), public)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrProblemNotValidated):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "problem_not_validated",
+				"message": err.Error(),
+			})
+		case err.Error() == "题目不存在":
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "problem_not_found",
+				"message": "题目不存在",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "update_failed",
+				"message": "更新题目失败：" + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ProblemUpdateResponse{
+		Message: successMessage,
+		Problem: dto.ProblemDomainToResponse(problem),
+	})
+}
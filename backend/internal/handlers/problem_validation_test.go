@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/backend/internal/dto"
+	"verilog-oj/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProblemHandler_GetProblemValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "id", Value: "1"}}
+	c.Request, _ = http.NewRequest(http.MethodGet, "/problems/1/validation", nil)
+
+	mockService := new(MockProblemService)
+	handler := NewProblemHandler(mockService)
+	mockService.On("GetProblem", uint(1)).Return(&domain.Problem{ID: 1, Validation: domain.ProblemValidation{
+		Status:  domain.ValidationInvalid,
+		Message: "参考答案通过 1/2 个测试用例：mismatch",
+		Cases: []domain.ValidationCase{
+			{Index: 1, Status: "accepted"},
+			{Index: 2, Status: "wrong_answer", ErrorMessage: "mismatch"},
+		},
+	}}, nil)
+
+	handler.GetProblemValidation(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp dto.ProblemValidationResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, domain.ValidationInvalid, resp.Status)
+	assert.Len(t, resp.Cases, 2)
+	assert.Equal(t, "mismatch", resp.Cases[1].ErrorMessage)
+}
+
+func TestProblemHandler_ValidateProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems/1/validate", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("ValidateProblem", uint(1)).Return(&domain.ProblemValidation{Status: domain.ValidationPending}, nil)
+
+		handler.ValidateProblem(c)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var resp dto.ProblemValidateResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, domain.ValidationPending, resp.Validation.Status)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "9"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/problems/9/validate", nil)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("ValidateProblem", uint(9)).Return(nil, errors.New("题目不存在"))
+
+		handler.ValidateProblem(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestProblemHandler_PublishProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		serviceErr   error
+		expectedCode int
+	}{
+		{"Success", nil, http.StatusOK},
+		{"Not Validated", services.ErrProblemNotValidated, http.StatusBadRequest},
+		{"Not Found", errors.New("题目不存在"), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = []gin.Param{{Key: "id", Value: "1"}}
+			c.Request, _ = http.NewRequest(http.MethodPost, "/problems/1/publish", nil)
+
+			mockService := new(MockProblemService)
+			handler := NewProblemHandler(mockService)
+			if tt.serviceErr != nil {
+				mockService.On("SetProblemPublic", uint(1), true).Return(nil, tt.serviceErr)
+			} else {
+				mockService.On("SetProblemPublic", uint(1), true).Return(&domain.Problem{ID: 1, IsPublic: true}, nil)
+			}
+
+			handler.PublishProblem(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProblemHandler_UpdateProblemValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRequest := func(body string) (*httptest.ResponseRecorder, *gin.Context) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request, _ = http.NewRequest(http.MethodPut, "/problems/1", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		return w, c
+	}
+
+	t.Run("修改参考答案后重新校验", func(t *testing.T) {
+		w, c := newRequest(`{"reference_code": "module adder; endmodule"}`)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetProblem", uint(1)).Return(&domain.Problem{ID: 1, Title: "Adder", Description: "add", AuthorID: 1}, nil)
+		mockService.On("UpdateProblem", mock.MatchedBy(func(p *domain.Problem) bool {
+			return p.ReferenceCode == "module adder; endmodule"
+		})).Return(nil)
+		mockService.On("ValidateProblem", uint(1)).Return(&domain.ProblemValidation{Status: domain.ValidationPending}, nil)
+
+		handler.UpdateProblem(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("修改等价性检查后重新校验", func(t *testing.T) {
+		w, c := newRequest(`{"equivalence": {"clock": "clk", "seed": 3, "hold": {"en": 1}}}`)
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetProblem", uint(1)).Return(&domain.Problem{ID: 1, AuthorID: 1, ReferenceCode: "module m; endmodule"}, nil)
+		mockService.On("UpdateProblem", mock.MatchedBy(func(p *domain.Problem) bool {
+			return p.Equivalence != nil && p.Equivalence.Clock == "clk" && p.Equivalence.Hold["en"] == 1
+		})).Return(nil)
+		mockService.On("ValidateProblem", uint(1)).Return(&domain.ProblemValidation{Status: domain.ValidationPending}, nil)
+
+		handler.UpdateProblem(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("教师不能修改公开状态", func(t *testing.T) {
+		w, c := newRequest(`{"is_public": true}`)
+		c.Set("role", "teacher")
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetProblem", uint(1)).Return(&domain.Problem{ID: 1, AuthorID: 1,
+			Validation: domain.ProblemValidation{Status: domain.ValidationValidated}}, nil)
+
+		handler.UpdateProblem(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockService.AssertNotCalled(t, "UpdateProblem", mock.Anything)
+	})
+
+	t.Run("未通过校验不能发布", func(t *testing.T) {
+		w, c := newRequest(`{"is_public": true}`)
+		c.Set("role", "admin")
+
+		mockService := new(MockProblemService)
+		handler := NewProblemHandler(mockService)
+		mockService.On("GetProblem", uint(1)).Return(&domain.Problem{ID: 1, AuthorID: 2,
+			Validation: domain.ProblemValidation{Status: domain.ValidationInvalid}}, nil)
+
+		handler.UpdateProblem(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "problem_not_validated")
+		mockService.AssertNotCalled(t, "UpdateProblem", mock.Anything)
+	})
+}
@@ -1,15 +1,29 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/dto"
 	"verilog-oj/backend/internal/services"
+	"verilog-oj/protocol"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
 )
 
+// eventHeartbeatInterval SSE 心跳间隔，防止代理关闭空闲连接
+const eventHeartbeatInterval = 15 * time.Second
+
 // SubmissionService 接口定义
 type SubmissionService interface {
 	CreateSubmission(problemID uint, code, language string, userID uint) (*domain.Submission, error)
@@ -20,6 +34,11 @@ type SubmissionService interface {
 	GetProblemSubmissions(problemID uint, page, limit int) (*services.SubmissionListResult, error)
 	GetSubmissionStats(userID uint) (map[string]interface{}, error)
 	DeleteSubmission(id uint, userID uint, userRole string) error
+	CancelSubmission(id uint, userID uint, userRole string) (*domain.Submission, error)
+	GetQueuePosition(submission *domain.Submission) (*domain.QueuePosition, error)
+	GetQueueStats() (*domain.QueueStats, error)
+	ListStuckSubmissions() ([]domain.StuckSubmission, error)
+	SubscribeJudgeEvents(ctx context.Context, id uint, afterID string) (<-chan *protocol.JudgeEvent, error)
 }
 
 // SubmissionHandler 提交处理器
@@ -114,9 +133,63 @@ func (h *SubmissionHandler) GetSubmission(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.SubmissionDetailsResponse{
+	response := dto.SubmissionDetailsResponse{
 		Submission: dto.SubmissionDomainToResponse(submission),
-	})
+	}
+	// 排队信息只是附加信息，查询失败时照常返回提交详情
+	if submission.Status == "pending" {
+		position, err := h.submissionService.GetQueuePosition(submission)
+		if err != nil {
+			log.Printf("failed to get queue position of submission %d: %v", submission.ID, err)
+		} else if position != nil {
+			response.Queue = dto.QueuePositionDomainToResponse(position)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJudgeQueue 获取判题队列概况
+func (h *SubmissionHandler) GetJudgeQueue(c *gin.Context) {
+	stats, err := h.submissionService.GetQueueStats()
+	if err != nil {
+		if errors.Is(err, services.ErrQueueStatsUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "queue_unavailable",
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "internal_error",
+			"message": "获取判题队列失败：" + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.QueueStatsDomainToResponse(stats))
+}
+
+// ListStuckSubmissions 获取卡住的提交及看门狗的处理记录（管理员）
+func (h *SubmissionHandler) ListStuckSubmissions(c *gin.Context) {
+	stuck, err := h.submissionService.ListStuckSubmissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "internal_error",
+			"message": "获取卡住的提交失败：" + err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	response := dto.StuckSubmissionListResponse{
+		Submissions: make([]dto.StuckSubmissionResponse, 0, len(stuck)),
+		Total:       len(stuck),
+	}
+	for i := range stuck {
+		response.Submissions = append(response.Submissions, dto.StuckSubmissionDomainToResponse(&stuck[i], now))
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // CreateSubmission 创建提交
@@ -353,3 +426,234 @@ func (h *SubmissionHandler) DeleteSubmission(c *gin.Context) {
 		Message: "提交记录删除成功",
 	})
 }
+
+// CancelSubmission 取消等待中或正在判题的提交
+func (h *SubmissionHandler) CancelSubmission(c *gin.Context) {
+	// 获取当前用户信息
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "用户未认证",
+		})
+		return
+	}
+
+	userRole, exists := c.Get("role")
+	if !exists {
+		userRole = "student" // 默认角色
+	}
+
+	// 获取提交ID
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的提交ID",
+		})
+		return
+	}
+
+	submission, err := h.submissionService.CancelSubmission(uint(id), userID.(uint), userRole.(string))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCancelForbidden):
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrSubmissionNotCancellable):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "not_cancellable",
+				"message": err.Error(),
+			})
+		case err.Error() == "提交记录不存在":
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "submission_not_found",
+				"message": "提交记录不存在",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "cancel_failed",
+				"message": "取消判题失败：" + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SubmissionCancelResponse{
+		Message:    "判题已取消",
+		Submission: dto.SubmissionDomainToResponse(submission),
+	})
+}
+
+// StreamSubmissionEvents 通过 Server-Sent Events 推送提交的判题进度，发送 finished 事件后结束
+// 断线重连时浏览器会带上 Last-Event-ID 请求头，也可以用 last_event_id 查询参数指定，之前的事件不再发送
+func (h *SubmissionHandler) StreamSubmissionEvents(c *gin.Context) {
+	afterID := c.GetHeader("Last-Event-ID")
+	if afterID == "" {
+		afterID = c.Query("last_event_id")
+	}
+
+	events, ok := h.subscribeJudgeEvents(c.Request.Context(), c, afterID)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeServerSentEvent(c.Writer, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// SubmissionEventsWebSocket 通过 WebSocket 推送提交的判题进度，每条消息是一个JSON事件，发送 finished 事件后关闭连接
+// 断线重连时用 last_event_id 查询参数指定最后收到的事件ID。先校验握手请求和来源，再订阅进度
+func (h *SubmissionHandler) SubmissionEventsWebSocket(c *gin.Context) {
+	if !isWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "websocket_required",
+			"message": "需要 WebSocket 握手请求",
+		})
+		return
+	}
+	if err := checkWebSocketOrigin(c.Request); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "origin_forbidden",
+			"message": "不允许的来源：" + err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	events, ok := h.subscribeJudgeEvents(ctx, c, c.Query("last_event_id"))
+	if !ok {
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			return checkWebSocketOrigin(r)
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			// 客户端不发送消息，读取失败说明连接已关闭
+			go func() {
+				io.Copy(io.Discard, ws)
+				cancel()
+			}()
+
+			for event := range events {
+				if err := websocket.JSON.Send(ws, dto.SubmissionEventResponse{ID: event.ID, JudgeEvent: event}); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// isWebSocketUpgrade 返回请求是否为 RFC 6455 的 WebSocket 握手请求
+func isWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerHasToken(r.Header.Get("Connection"), "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		r.Header.Get("Sec-WebSocket-Key") != "" &&
+		r.Header.Get("Sec-WebSocket-Version") == "13"
+}
+
+// headerHasToken 返回逗号分隔的请求头值中是否包含 token，不区分大小写
+func headerHasToken(value, token string) bool {
+	for _, item := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebSocketOrigin 浏览器发起的连接必须与 API 同源，防止其他站点的页面以用户身份订阅进度；
+// 前端通过同一个域名的 /api 访问后端，反向代理保留 Host 请求头。没有 Origin 请求头的非浏览器客户端不受限制
+func checkWebSocketOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("无效的 Origin %q", origin)
+	}
+	if !strings.EqualFold(u.Host, r.Host) {
+		return fmt.Errorf("%s 与 %s 不同源", origin, r.Host)
+	}
+	return nil
+}
+
+// subscribeJudgeEvents 解析提交ID并订阅判题进度，失败时写入错误响应
+func (h *SubmissionHandler) subscribeJudgeEvents(ctx context.Context, c *gin.Context, afterID string) (<-chan *protocol.JudgeEvent, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_id",
+			"message": "无效的提交ID",
+		})
+		return nil, false
+	}
+
+	events, err := h.submissionService.SubscribeJudgeEvents(ctx, uint(id), afterID)
+	if err != nil {
+		if err.Error() == "提交记录不存在" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "submission_not_found",
+				"message": err.Error(),
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "events_unavailable",
+			"message": "订阅判题进度失败：" + err.Error(),
+		})
+		return nil, false
+	}
+	return events, true
+}
+
+// writeServerSentEvent 按 SSE 格式写入一个判题进度事件
+func writeServerSentEvent(w io.Writer, event *protocol.JudgeEvent) error {
+	data, err := json.Marshal(dto.SubmissionEventResponse{ID: event.ID, JudgeEvent: event})
+	if err != nil {
+		return err
+	}
+	if event.ID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -2,18 +2,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/dto"
 	"verilog-oj/backend/internal/services"
+	"verilog-oj/protocol"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/websocket"
 )
 
 // MockSubmissionService is a mock for SubmissionService
@@ -79,6 +84,46 @@ func (m *MockSubmissionService) DeleteSubmission(id uint, userID uint, userRole
 	return args.Error(0)
 }
 
+func (m *MockSubmissionService) CancelSubmission(id uint, userID uint, userRole string) (*domain.Submission, error) {
+	args := m.Called(id, userID, userRole)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Submission), args.Error(1)
+}
+
+func (m *MockSubmissionService) GetQueuePosition(submission *domain.Submission) (*domain.QueuePosition, error) {
+	args := m.Called(submission)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QueuePosition), args.Error(1)
+}
+
+func (m *MockSubmissionService) GetQueueStats() (*domain.QueueStats, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QueueStats), args.Error(1)
+}
+
+func (m *MockSubmissionService) ListStuckSubmissions() ([]domain.StuckSubmission, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.StuckSubmission), args.Error(1)
+}
+
+func (m *MockSubmissionService) SubscribeJudgeEvents(ctx context.Context, id uint, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	args := m.Called(ctx, id, afterID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan *protocol.JudgeEvent), args.Error(1)
+}
+
 func TestSubmissionHandler_ListSubmissions(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -153,6 +198,57 @@ func TestSubmissionHandler_GetSubmission(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 
+	t.Run("Pending With Queue Position", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		submission := &domain.Submission{ID: 2, UserID: 1, ProblemID: 1, Status: "pending", Language: "verilog"}
+		position := &domain.QueuePosition{
+			Lane:           "lang-verilog",
+			Position:       3,
+			EstimatedStart: time.Now().Add(90 * time.Second),
+			EstimatedWait:  90 * time.Second,
+		}
+		mockService.On("GetSubmission", uint(2)).Return(submission, nil)
+		mockService.On("GetQueuePosition", submission).Return(position, nil)
+
+		handler.GetSubmission(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response dto.SubmissionDetailsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if assert.NotNil(t, response.Queue) {
+			assert.Equal(t, "lang-verilog", response.Queue.Lane)
+			assert.Equal(t, int64(3), response.Queue.Position)
+			assert.Equal(t, int64(90), *response.Queue.EstimatedWait)
+			assert.NotNil(t, response.Queue.EstimatedStart)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Queue Position Error Ignored", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		submission := &domain.Submission{ID: 2, UserID: 1, ProblemID: 1, Status: "pending"}
+		mockService.On("GetSubmission", uint(2)).Return(submission, nil)
+		mockService.On("GetQueuePosition", submission).Return(nil, errors.New("redis down"))
+
+		handler.GetSubmission(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), `"queue"`)
+		mockService.AssertExpectations(t)
+	})
+
 	t.Run("Invalid ID", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -465,3 +561,396 @@ func TestSubmissionHandler_DeleteSubmission(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 }
+
+func TestSubmissionHandler_CancelSubmission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		submission   *domain.Submission
+		serviceError error
+		expectedCode int
+	}{
+		{
+			name:         "取消成功",
+			submission:   &domain.Submission{ID: 1, UserID: 1, Status: "cancelled"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "无权限",
+			serviceError: services.ErrCancelForbidden,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "已判题结束",
+			serviceError: services.ErrSubmissionNotCancellable,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "提交不存在",
+			serviceError: errors.New("提交记录不存在"),
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "取消失败",
+			serviceError: errors.New("queue unavailable"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("user_id", uint(1))
+			c.Set("role", "student")
+			c.Params = []gin.Param{{Key: "id", Value: "1"}}
+
+			mockService := new(MockSubmissionService)
+			handler := NewSubmissionHandler(mockService)
+			if tt.submission != nil {
+				mockService.On("CancelSubmission", uint(1), uint(1), "student").Return(tt.submission, nil)
+			} else {
+				mockService.On("CancelSubmission", uint(1), uint(1), "student").Return(nil, tt.serviceError)
+			}
+
+			handler.CancelSubmission(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.submission != nil {
+				assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", uint(1))
+		c.Params = []gin.Param{{Key: "id", Value: "invalid"}}
+
+		handler := NewSubmissionHandler(new(MockSubmissionService))
+		handler.CancelSubmission(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestSubmissionHandler_GetJudgeQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("GetQueueStats").Return(&domain.QueueStats{
+			Lanes: []domain.QueueLane{
+				{Name: "default", Depth: 1},
+				{Name: "lang-verilog", Depth: 4},
+			},
+			Processing:       2,
+			Nodes:            1,
+			Workers:          2,
+			AverageJudgeTime: 1500 * time.Millisecond,
+			Samples:          10,
+		}, nil)
+
+		handler.GetJudgeQueue(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response dto.JudgeQueueResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Lanes, 2)
+		assert.Equal(t, int64(5), response.Total)
+		assert.Equal(t, int64(2), response.Processing)
+		assert.Equal(t, 2, response.Workers)
+		assert.Equal(t, int64(1500), response.AverageJudgeTime)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("GetQueueStats").Return(nil, services.ErrQueueStatsUnavailable)
+
+		handler.GetJudgeQueue(c)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("GetQueueStats").Return(nil, errors.New("redis down"))
+
+		handler.GetJudgeQueue(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestSubmissionHandler_ListStuckSubmissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		updatedAt := time.Now().Add(-20 * time.Minute)
+		mockService.On("ListStuckSubmissions").Return([]domain.StuckSubmission{
+			{
+				Submission: domain.Submission{ID: 4, UserID: 1, ProblemID: 2, Language: "verilog", Status: "pending", UpdatedAt: updatedAt},
+				Recoveries: []domain.SubmissionRecovery{
+					{SubmissionID: 4, Action: domain.RecoveryRequeued, Attempt: 1, Reason: "pending 状态超过 10m0s 未更新"},
+					{SubmissionID: 4, Action: domain.RecoveryRequeued, Attempt: 2, Reason: "pending 状态超过 10m0s 未更新"},
+				},
+			},
+		}, nil)
+
+		handler.ListStuckSubmissions(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response dto.StuckSubmissionListResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Total)
+		if assert.Len(t, response.Submissions, 1) {
+			stuck := response.Submissions[0]
+			assert.Equal(t, uint(4), stuck.ID)
+			assert.Equal(t, 2, stuck.Attempts)
+			assert.Len(t, stuck.Recoveries, 2)
+			assert.GreaterOrEqual(t, stuck.StuckFor, int64(20*60))
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("ListStuckSubmissions").Return(nil, errors.New("db down"))
+
+		handler.ListStuckSubmissions(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestSubmissionHandler_StreamSubmissionEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("推送事件直到判题结束", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		events := make(chan *protocol.JudgeEvent, 2)
+		running := protocol.NewEvent("1", protocol.EventRunning)
+		running.ID = "2-0"
+		running.CaseIndex = 1
+		running.TotalCases = 2
+		finished := protocol.NewEvent("1", protocol.EventFinished)
+		finished.ID = "3-0"
+		finished.Result = &protocol.JudgeResult{SubmissionID: "1", Status: "accepted", Score: 100}
+		events <- running
+		events <- finished
+		close(events)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(1), "1-0").Return((<-chan *protocol.JudgeEvent)(events), nil)
+
+		router := gin.New()
+		router.GET("/submissions/:id/events", handler.StreamSubmissionEvents)
+		req, _ := http.NewRequest("GET", "/submissions/1/events", nil)
+		req.Header.Set("Last-Event-ID", "1-0")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+		body := w.Body.String()
+		assert.Contains(t, body, "id: 2-0\nevent: running\ndata: ")
+		assert.Contains(t, body, "id: 3-0\nevent: finished\ndata: ")
+		assert.Contains(t, body, `"case_index":1`)
+		assert.Contains(t, body, `"status":"accepted"`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("查询参数指定最后收到的事件", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		events := make(chan *protocol.JudgeEvent)
+		close(events)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(1), "final").Return((<-chan *protocol.JudgeEvent)(events), nil)
+
+		router := gin.New()
+		router.GET("/submissions/:id/events", handler.StreamSubmissionEvents)
+		req, _ := http.NewRequest("GET", "/submissions/1/events?last_event_id=final", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("提交不存在", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(9), "").Return(nil, errors.New("提交记录不存在"))
+
+		router := gin.New()
+		router.GET("/submissions/:id/events", handler.StreamSubmissionEvents)
+		req, _ := http.NewRequest("GET", "/submissions/9/events", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("判题队列不支持进度事件", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(1), "").Return(nil, errors.New("判题队列未启用或不支持进度事件"))
+
+		router := gin.New()
+		router.GET("/submissions/:id/events", handler.StreamSubmissionEvents)
+		req, _ := http.NewRequest("GET", "/submissions/1/events", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "events_unavailable", response["error"])
+	})
+}
+
+// newWebSocketRequest 构造同源的 WebSocket 握手请求
+func newWebSocketRequest(target string) *http.Request {
+	req, _ := http.NewRequest("GET", target, nil)
+	req.Host = "oj.example.com"
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "https://oj.example.com")
+	return req
+}
+
+func TestSubmissionHandler_SubmissionEventsWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("推送事件直到判题结束", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		events := make(chan *protocol.JudgeEvent, 1)
+		finished := protocol.NewEvent("1", protocol.EventFinished)
+		finished.ID = "3-0"
+		finished.Result = &protocol.JudgeResult{SubmissionID: "1", Status: "accepted", Score: 100}
+		events <- finished
+		close(events)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(1), "2-0").Return((<-chan *protocol.JudgeEvent)(events), nil)
+
+		router := gin.New()
+		router.GET("/submissions/:id/events/ws", handler.SubmissionEventsWebSocket)
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/submissions/1/events/ws?last_event_id=2-0"
+		ws, err := websocket.Dial(wsURL, "", server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ws.Close()
+		var response dto.SubmissionEventResponse
+		assert.NoError(t, websocket.JSON.Receive(ws, &response))
+		assert.Equal(t, "3-0", response.ID)
+		assert.Equal(t, protocol.EventFinished, response.Type)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("不是握手请求时不订阅", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+
+		router := gin.New()
+		router.GET("/submissions/:id/events/ws", handler.SubmissionEventsWebSocket)
+		req, _ := http.NewRequest("GET", "/submissions/1/events/ws", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "websocket_required")
+		mockService.AssertNotCalled(t, "SubscribeJudgeEvents", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("其他站点发起的连接被拒绝", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.example.com", "null", "https://oj.example.com.evil.com"} {
+			mockService := new(MockSubmissionService)
+			handler := NewSubmissionHandler(mockService)
+
+			router := gin.New()
+			router.GET("/submissions/:id/events/ws", handler.SubmissionEventsWebSocket)
+			req := newWebSocketRequest("/submissions/1/events/ws")
+			req.Header.Set("Origin", origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code, origin)
+			assert.Contains(t, w.Body.String(), "origin_forbidden")
+			mockService.AssertNotCalled(t, "SubscribeJudgeEvents", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("订阅失败时返回错误响应", func(t *testing.T) {
+		mockService := new(MockSubmissionService)
+		handler := NewSubmissionHandler(mockService)
+		mockService.On("SubscribeJudgeEvents", mock.Anything, uint(9), "").Return(nil, errors.New("提交记录不存在"))
+
+		router := gin.New()
+		router.GET("/submissions/:id/events/ws", handler.SubmissionEventsWebSocket)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newWebSocketRequest("/submissions/9/events/ws"))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		host   string
+		ok     bool
+	}{
+		{"", "oj.example.com", true},
+		{"https://oj.example.com", "oj.example.com", true},
+		{"http://OJ.example.com:8080", "oj.example.com:8080", true},
+		{"http://oj.example.com:8080", "oj.example.com", false},
+		{"https://evil.example.com", "oj.example.com", false},
+		{"null", "oj.example.com", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		err := checkWebSocketOrigin(req)
+		assert.Equal(t, tt.ok, err == nil, "origin %q, host %q: %v", tt.origin, tt.host, err)
+	}
+}
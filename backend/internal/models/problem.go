@@ -28,6 +28,14 @@ type Problem struct {
 	TimeLimit   int `json:"time_limit" gorm:"default:1000"`  // 毫秒
 	MemoryLimit int `json:"memory_limit" gorm:"default:128"` // MB
 
+	// 代码
+	ReferenceCode string `json:"-" gorm:"type:text"` // 参考答案，不对外输出
+	StarterCode   string `json:"starter_code" gorm:"type:text"`
+	Equivalence   string `json:"-" gorm:"type:text"`     // 等价性检查配置JSON，为空表示不检查
+	TruthTable    string `json:"-" gorm:"type:text"`     // 真值表测试JSON，为空表示没有
+	Checker       string `json:"checker" gorm:"size:20"` // 输出比较方式，为空时比较VCD
+	SpecialJudge  string `json:"-" gorm:"type:text"`     // 检查程序JSON，为空表示没有
+
 	// 统计信息
 	SubmitCount   int `json:"submit_count" gorm:"default:0"`
 	AcceptedCount int `json:"accepted_count" gorm:"default:0"`
@@ -36,6 +44,13 @@ type Problem struct {
 	IsPublic bool `json:"is_public" gorm:"default:false"`
 	AuthorID uint `json:"author_id"`
 	Author   User `json:"author" gorm:"foreignKey:AuthorID"`
+
+	// 参考答案校验
+	ValidationStatus  string     `json:"validation_status" gorm:"size:20;default:unvalidated"` // unvalidated, pending, validated, invalid
+	ValidationRunID   string     `json:"-" gorm:"size:64"`
+	ValidationMessage string     `json:"validation_message" gorm:"type:text"`
+	ValidationCases   string     `json:"-" gorm:"type:text"` // JSON数组字符串
+	ValidatedAt       *time.Time `json:"validated_at"`
 }
 
 // TestCase 测试用例模型
@@ -48,7 +63,36 @@ type TestCase struct {
 	ProblemID uint    `json:"problem_id" gorm:"not null"`
 	Problem   Problem `json:"problem" gorm:"foreignKey:ProblemID"`
 
-	Input    string `json:"input" gorm:"type:text"`
-	Output   string `json:"output" gorm:"type:text"`
-	IsSample bool   `json:"is_sample" gorm:"default:false"`
+	Input       string `json:"input" gorm:"type:text"`
+	Output      string `json:"output" gorm:"type:text"`
+	IsSample    bool   `json:"is_sample" gorm:"default:false"`
+	Description string `json:"description" gorm:"size:255"`
+	SimTime     int    `json:"sim_time" gorm:"default:0"` // 仿真时间预算（纳秒），0 使用判题服务默认值
+	Clock       string `json:"clock" gorm:"size:100"`     // 统计时钟周期数的信号名，为空时为 clk
+	Trace       string `json:"-" gorm:"type:text"`        // I/O 序列JSON，为空表示使用 Input 中的 testbench
+}
+
+// ProblemOutputGeneration 由参考答案生成期望输出的任务，每个题目只保留最近一次
+type ProblemOutputGeneration struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProblemID   uint       `json:"problem_id" gorm:"not null;uniqueIndex"`
+	RunID       string     `json:"-" gorm:"size:64"`
+	Fingerprint string     `json:"-" gorm:"size:64"`
+	Status      string     `json:"status" gorm:"size:20"` // pending, ready, failed
+	Message     string     `json:"message" gorm:"type:text"`
+	Outputs     string     `json:"-" gorm:"type:text"` // 生成的期望输出JSON
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// ProblemAttachment 题目附件模型
+type ProblemAttachment struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	ProblemID uint   `json:"problem_id" gorm:"not null;index"`
+	Name      string `json:"name" gorm:"size:255;not null"`
+	Content   []byte `json:"-"`
 }
@@ -36,6 +36,17 @@ type Submission struct {
 	JudgeID     string `json:"judge_id"` // 用于与判题服务通信的ID
 }
 
+// SubmissionRecovery 看门狗处理卡住的提交的记录
+type SubmissionRecovery struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SubmissionID uint   `json:"submission_id" gorm:"not null;index"`
+	Action       string `json:"action" gorm:"size:20;not null"` // requeued, failed
+	Attempt      int    `json:"attempt" gorm:"default:0"`
+	Reason       string `json:"reason" gorm:"type:text"`
+}
+
 // JudgeStatus 判题状态常量
 const (
 	StatusPending             = "pending"
@@ -44,7 +55,10 @@ const (
 	StatusWrongAnswer         = "wrong_answer"
 	StatusTimeLimitExceeded   = "time_limit_exceeded"
 	StatusMemoryLimitExceeded = "memory_limit_exceeded"
+	StatusOutputLimitExceeded = "output_limit_exceeded"
+	StatusSimTimeExceeded     = "sim_time_exceeded"
 	StatusRuntimeError        = "runtime_error"
 	StatusCompileError        = "compile_error"
 	StatusSystemError         = "system_error"
+	StatusCancelled           = "cancelled"
 )
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"verilog-oj/backend/internal/config"
+	"verilog-oj/judge-service/pkg/embedded"
+	"verilog-oj/protocol"
+)
+
+// Queue 判题队列：推送判题任务、消费判题结果并读取判题进度事件
+type Queue interface {
+	Push(ctx context.Context, request *protocol.JudgeRequest) error
+	ConsumeResults(ctx context.Context, handle func(*protocol.JudgeResult) error) error
+	// SubscribeEvents 先补发 afterID 之后的进度事件，再推送新事件，收到 finished 事件后关闭通道
+	SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error)
+	// ReadEvents 返回 afterID 之后已记录的进度事件
+	ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error)
+	// Cancel 取消判题任务：等待中的任务从队列移除并返回 true；已被判题服务取出的任务返回 false，
+	// 判题服务收到取消通知后终止判题并发布 cancelled 结果
+	Cancel(ctx context.Context, submissionID, language string) (bool, error)
+	Close() error
+}
+
+// New 按配置创建判题队列，类型为 none 时返回 nil 表示不判题
+func New(cfg config.QueueConfig) (Queue, error) {
+	switch cfg.Type {
+	case "redis":
+		return NewRedisQueue(cfg.Host, cfg.Port, cfg.Password, cfg.DB, cfg.QueueName), nil
+	case "memory":
+		// 单机部署：判题服务运行在后端进程内，不需要Redis
+		judge, err := embedded.Start(cfg.JudgeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start embedded judge: %w", err)
+		}
+		return judge, nil
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported queue type %q", cfg.Type)
+	}
+}
@@ -0,0 +1,613 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 判题结果消费
+const (
+	// resultGroup 后端读取 protocol.ResultStream 的消费组，多个后端实例共同消费，每条结果只交给其中一个
+	resultGroup = "backend"
+	// resultClaimIdle 结果被取出后超过该时间未确认时，由任一后端实例重新处理
+	resultClaimIdle = time.Minute
+	// resultMaxDeliveries 结果最多处理的次数，超过后记录日志并确认，避免无法写回的结果被反复处理
+	resultMaxDeliveries = 5
+	// resultReadBlock 读取新结果时的最长阻塞时间
+	resultReadBlock = 5 * time.Second
+)
+
+const (
+	// eventStreamMaxLen 每个提交最多保留的进度事件数，与判题服务一致
+	eventStreamMaxLen = 1000
+	// eventStreamTTL 进度事件的保留时间，与判题服务一致
+	eventStreamTTL = 24 * time.Hour
+	// eventReadBlock 等待新进度事件的单次阻塞时间，超时后检查订阅是否已取消
+	eventReadBlock = 5 * time.Second
+)
+
+const (
+	// defaultLane 默认通道，直接使用队列名作为键
+	defaultLane = "default"
+	// processingLane 判题服务暂存已取出但未确认任务的列表
+	processingLane = "processing"
+)
+
+// enqueueScript 推送任务并写入等待索引，见 protocol.EnqueueScript
+var enqueueScript = redis.NewScript(protocol.EnqueueScript)
+
+// RedisQueue 基于Redis的判题队列客户端
+type RedisQueue struct {
+	client    *redis.Client
+	queueName string
+}
+
+// NewRedisQueue 创建Redis判题队列客户端
+func NewRedisQueue(host string, port int, password string, db int, queueName string) *RedisQueue {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+		DB:       db,
+	})
+
+	return &RedisQueue{
+		client:    rdb,
+		queueName: queueName,
+	}
+}
+
+// eventStreamKey 返回提交的进度事件流键，判题服务向 judge_events:<submission_id> 追加事件
+func eventStreamKey(submissionID string) string {
+	return "judge_events:" + submissionID
+}
+
+// laneKey 返回通道对应的Redis键，与判题服务一致：default 通道即 queue_name
+func (q *RedisQueue) laneKey(lane string) string {
+	if lane == defaultLane {
+		return q.queueName
+	}
+	return q.queueName + ":" + lane
+}
+
+// liveNodes 返回节点注册表中存活的判题节点
+func (q *RedisQueue) liveNodes(ctx context.Context) ([]*protocol.Capabilities, error) {
+	payloads, err := q.client.HVals(ctx, protocol.NodeRegistryKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read judge nodes: %w", err)
+	}
+
+	now := time.Now()
+	nodes := make([]*protocol.Capabilities, 0, len(payloads))
+	for _, payload := range payloads {
+		capabilities, err := protocol.DecodeCapabilities([]byte(payload))
+		if err != nil || !capabilities.Alive(now) {
+			continue
+		}
+		nodes = append(nodes, capabilities)
+	}
+	return nodes, nil
+}
+
+// languageLanes 返回该语言的任务可能所在的通道：语言通道、各检查程序通道和默认通道
+func languageLanes(language string) []string {
+	lanes := []string{protocol.LanguageLane(language)}
+	for _, checker := range protocol.SpecialJudgeLanguages {
+		lanes = append(lanes, protocol.CheckerLane(language, checker))
+	}
+	return append(lanes, defaultLane)
+}
+
+// pushKey 返回判题请求应推送到的列表
+// 节点注册表为空时推送到默认通道，兼容不发布能力的旧版判题服务；否则推送到 protocol.RequestLane 返回的通道，
+// 运行检查程序的请求只推送到同时通过该语言和该检查程序语言自检的节点消费的通道；
+// 没有这样的存活节点时返回错误，避免任务在没有节点消费的通道中一直等待
+func (q *RedisQueue) pushKey(ctx context.Context, request *protocol.JudgeRequest) (string, error) {
+	nodes, err := q.liveNodes(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) == 0 {
+		return q.laneKey(defaultLane), nil
+	}
+	checker := request.CheckerLanguage()
+	for _, capabilities := range nodes {
+		if capabilities.Supports(request.Language) && (checker == "" || capabilities.SupportsChecker(checker)) {
+			return q.laneKey(protocol.RequestLane(request)), nil
+		}
+	}
+	if checker != "" {
+		return "", fmt.Errorf("no judge node supports language %q with %s checkers", request.Language, checker)
+	}
+	return "", fmt.Errorf("no judge node supports language %q", request.Language)
+}
+
+// Push 校验并推送判题请求到请求语言或检查程序的队列通道，同时记录 queued 事件
+func (q *RedisQueue) Push(ctx context.Context, request *protocol.JudgeRequest) error {
+	data, err := protocol.EncodeRequest(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode judge request: %w", err)
+	}
+	queueKey, err := q.pushKey(ctx, request)
+	if err != nil {
+		return err
+	}
+	event, err := protocol.EncodeEvent(protocol.NewEvent(request.SubmissionID, protocol.EventQueued))
+	if err != nil {
+		return fmt.Errorf("failed to encode judge event: %w", err)
+	}
+
+	key := eventStreamKey(request.SubmissionID)
+	_, err = q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: eventStreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{"event": event},
+		})
+		pipe.Expire(ctx, key, eventStreamTTL)
+		enqueueScript.Eval(ctx, pipe, []string{queueKey, protocol.QueueIndexKey(queueKey), protocol.QueueSeqKey}, request.SubmissionID, data)
+		return nil
+	})
+	return err
+}
+
+// Cancel 从默认通道、语言通道和检查程序通道中移除等待中的任务，找不到时记录取消标记并通知判题服务
+// 移除成功时记录带 cancelled 结果的 finished 事件，正在订阅进度的客户端据此结束
+func (q *RedisQueue) Cancel(ctx context.Context, submissionID, language string) (bool, error) {
+	for _, lane := range languageLanes(language) {
+		removed, err := q.removeQueued(ctx, q.laneKey(lane), submissionID)
+		if err != nil || removed {
+			return removed, err
+		}
+	}
+
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, protocol.CancelKey(submissionID), 1, protocol.CancelTTL)
+		pipe.Publish(ctx, protocol.CancelChannel, submissionID)
+		return nil
+	})
+	return false, err
+}
+
+// removeQueued 从列表中移除提交的任务并记录 finished 事件，任务已被取出时返回 false
+func (q *RedisQueue) removeQueued(ctx context.Context, key, submissionID string) (bool, error) {
+	payloads, err := q.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return false, err
+	}
+	for _, payload := range payloads {
+		if queuedSubmissionID(payload) != submissionID {
+			continue
+		}
+		var removed *redis.IntCmd
+		_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			removed = pipe.LRem(ctx, key, 1, payload)
+			pipe.ZRem(ctx, protocol.QueueIndexKey(key), submissionID)
+			return nil
+		})
+		if err != nil || removed.Val() == 0 {
+			return false, err
+		}
+
+		event := protocol.NewEvent(submissionID, protocol.EventFinished)
+		event.Result = protocol.NewCancelledResult(submissionID)
+		data, err := protocol.EncodeEvent(event)
+		if err != nil {
+			return true, fmt.Errorf("failed to encode judge event: %w", err)
+		}
+		eventKey := eventStreamKey(submissionID)
+		_, err = q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: eventKey,
+				MaxLen: eventStreamMaxLen,
+				Approx: true,
+				Values: map[string]interface{}{"event": data},
+			})
+			pipe.Expire(ctx, eventKey, eventStreamTTL)
+			return nil
+		})
+		return true, err
+	}
+	return false, nil
+}
+
+// JobActive 返回提交是否有仍在进行的判题任务：任务在默认通道、语言通道或检查程序通道中等待，
+// 或 since 之后记录过进度事件且最近的事件不是 finished，即任务已被判题节点取出并仍在推进。
+// 判题节点崩溃时取出的任务不再有新的进度事件，不视为仍在进行
+func (q *RedisQueue) JobActive(ctx context.Context, submissionID, language string, since time.Time) (bool, error) {
+	_, position, err := q.QueuePosition(ctx, submissionID, language)
+	if err != nil || position > 0 {
+		return position > 0, err
+	}
+
+	messages, err := q.client.XRevRangeN(ctx, eventStreamKey(submissionID), "+", "-", 1).Result()
+	if err != nil || len(messages) == 0 {
+		return false, err
+	}
+	// 事件ID的前半部分是写入时的毫秒时间戳
+	ms, err := strconv.ParseInt(strings.SplitN(messages[0].ID, "-", 2)[0], 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid judge event id %q", messages[0].ID)
+	}
+	if time.UnixMilli(ms).Before(since) {
+		return false, nil
+	}
+	event := decodeStreamEvent(messages[0])
+	return event != nil && event.Type != protocol.EventFinished, nil
+}
+
+// DropJob 从处理中列表移除提交遗留的任务，重新入队前调用
+func (q *RedisQueue) DropJob(ctx context.Context, submissionID string) error {
+	key := q.laneKey(processingLane)
+	payloads, err := q.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		if queuedSubmissionID(payload) == submissionID {
+			if err := q.client.LRem(ctx, key, 0, payload).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// queuedSubmissionID 读取队列消息中的提交ID，消息无法解析时返回空字符串
+func queuedSubmissionID(payload string) string {
+	var envelope struct {
+		SubmissionID string `json:"submission_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		return ""
+	}
+	return envelope.SubmissionID
+}
+
+// QueuePosition 返回等待中的提交所在的通道和位置，任务不在默认通道、语言通道和检查程序通道中时位置为 0
+// 位置由通道的等待索引计算，见 protocol.QueuedPosition
+func (q *RedisQueue) QueuePosition(ctx context.Context, submissionID, language string) (string, int64, error) {
+	for _, lane := range languageLanes(language) {
+		key := q.laneKey(lane)
+		var newer, length *redis.IntCmd
+		_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			newer = pipe.ZRevRank(ctx, protocol.QueueIndexKey(key), submissionID)
+			length = pipe.LLen(ctx, key)
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return "", 0, err
+		}
+		// 提交不在该通道的等待索引中时 ZREVRANK 返回 nil
+		if newer.Err() == redis.Nil {
+			continue
+		}
+		if position := protocol.QueuedPosition(newer.Val(), length.Val()); position > 0 {
+			return lane, position, nil
+		}
+	}
+	return "", 0, nil
+}
+
+// QueueStats 返回各通道的任务数、处理中的任务数、存活节点和最近的判题耗时
+// 通道包括 default、存活节点的语言通道和检查程序通道，以及 Redis 中其他以 <queue_name>: 开头的列表
+func (q *RedisQueue) QueueStats(ctx context.Context) (*domain.QueueStats, error) {
+	nodes, err := q.liveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &domain.QueueStats{Nodes: len(nodes)}
+	lanes := map[string]bool{defaultLane: true}
+	for _, capabilities := range nodes {
+		stats.Workers += capabilities.Concurrency
+		for _, language := range capabilities.Languages {
+			lanes[protocol.LanguageLane(language)] = true
+			for _, checker := range capabilities.Checkers {
+				lanes[protocol.CheckerLane(language, checker)] = true
+			}
+		}
+	}
+
+	prefix := q.queueName + ":"
+	iter := q.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		lanes[strings.TrimPrefix(iter.Val(), prefix)] = true
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	delete(lanes, processingLane)
+
+	names := make([]string, 0, len(lanes))
+	for lane := range lanes {
+		names = append(names, lane)
+	}
+	sort.Strings(names)
+	for _, lane := range names {
+		depth, err := q.client.LLen(ctx, q.laneKey(lane)).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats.Lanes = append(stats.Lanes, domain.QueueLane{Name: lane, Depth: depth})
+	}
+	if stats.Processing, err = q.client.LLen(ctx, q.laneKey(processingLane)).Result(); err != nil {
+		return nil, err
+	}
+
+	durations, err := q.client.LRange(ctx, protocol.JudgeDurationsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, value := range durations {
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms >= 0 {
+			total += ms
+			stats.Samples++
+		}
+	}
+	if stats.Samples > 0 {
+		stats.AverageJudgeTime = time.Duration(total/int64(stats.Samples)) * time.Millisecond
+	}
+	return stats, nil
+}
+
+// ReadEvents 返回提交在 afterID 之后的进度事件，afterID 为空时返回全部
+func (q *RedisQueue) ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error) {
+	start := afterID
+	if start == "" {
+		start = "-"
+	}
+	messages, err := q.client.XRange(ctx, eventStreamKey(submissionID), start, "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read judge events: %v", err)
+	}
+
+	events := make([]*protocol.JudgeEvent, 0, len(messages))
+	for _, msg := range messages {
+		if msg.ID == afterID {
+			continue // XRANGE 的起点是闭区间
+		}
+		if event := decodeStreamEvent(msg); event != nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// SubscribeEvents 先补发 afterID 之后的进度事件，再阻塞读取新事件，收到 finished 事件或 ctx 取消后关闭通道
+func (q *RedisQueue) SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	backlog, err := q.ReadEvents(ctx, submissionID, afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventChan := make(chan *protocol.JudgeEvent, 16)
+	go func() {
+		defer close(eventChan)
+
+		lastID := afterID
+		if lastID == "" {
+			lastID = "0"
+		}
+		send := func(event *protocol.JudgeEvent) bool {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return false
+			}
+			lastID = event.ID
+			return event.Type != protocol.EventFinished
+		}
+
+		for _, event := range backlog {
+			if !send(event) {
+				return
+			}
+		}
+
+		key := eventStreamKey(submissionID)
+		for ctx.Err() == nil {
+			streams, err := q.client.XRead(ctx, &redis.XReadArgs{
+				Streams: []string{key, lastID},
+				Block:   eventReadBlock,
+			}).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("failed to read judge events for submission %s: %v", submissionID, err)
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+
+			for _, stream := range streams {
+				for _, msg := range stream.Messages {
+					event := decodeStreamEvent(msg)
+					if event == nil {
+						lastID = msg.ID
+						continue
+					}
+					if !send(event) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
+
+// decodeStreamEvent 解码事件流中的一条消息，不符合协议的事件会被记录并丢弃
+func decodeStreamEvent(msg redis.XMessage) *protocol.JudgeEvent {
+	payload, _ := msg.Values["event"].(string)
+	event, err := protocol.DecodeEvent([]byte(payload))
+	if err != nil {
+		log.Printf("discarded judge event %s: %v", msg.ID, err)
+		return nil
+	}
+	event.ID = msg.ID
+	return event
+}
+
+// ConsumeResults 以消费组读取 protocol.ResultStream 中的判题结果并交给 handle 处理，直到 ctx 取消
+// handle 成功后才确认结果，失败或后端在处理中退出时，结果在 resultClaimIdle 后被重新处理，
+// 因此 handle 必须是幂等的。不符合协议的结果会被记录并确认丢弃
+func (q *RedisQueue) ConsumeResults(ctx context.Context, handle func(*protocol.JudgeResult) error) error {
+	err := q.client.XGroupCreateMkStream(ctx, protocol.ResultStream, resultGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create judge result group: %v", err)
+	}
+	consumer := resultConsumerName()
+	q.replayResults(ctx, consumer, handle)
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= resultClaimIdle {
+			q.claimResults(ctx, consumer, handle)
+			lastClaim = time.Now()
+		}
+
+		streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    resultGroup,
+			Consumer: consumer,
+			Streams:  []string{protocol.ResultStream, ">"},
+			Count:    10,
+			Block:    resultReadBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("failed to read judge results: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for _, msg := range streams[0].Messages {
+			q.handleResult(ctx, msg, handle)
+		}
+	}
+	return nil
+}
+
+// replayResults 处理本实例上次退出前已取出但未确认的结果，每条只处理一次，失败的留给 claimResults
+func (q *RedisQueue) replayResults(ctx context.Context, consumer string, handle func(*protocol.JudgeResult) error) {
+	lastID := "0"
+	for ctx.Err() == nil {
+		streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    resultGroup,
+			Consumer: consumer,
+			Streams:  []string{protocol.ResultStream, lastID},
+			Count:    100,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				log.Printf("failed to read pending judge results: %v", err)
+			}
+			return
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return
+		}
+		for _, msg := range streams[0].Messages {
+			q.handleResult(ctx, msg, handle)
+			lastID = msg.ID
+		}
+	}
+}
+
+// claimResults 接管取出后超过 resultClaimIdle 仍未确认的结果并重新处理，这些结果的后端实例可能已经退出
+func (q *RedisQueue) claimResults(ctx context.Context, consumer string, handle func(*protocol.JudgeResult) error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: protocol.ResultStream,
+		Group:  resultGroup,
+		Idle:   resultClaimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  100,
+	}).Result()
+	if err != nil {
+		log.Printf("failed to list pending judge results: %v", err)
+		return
+	}
+
+	for _, entry := range pending {
+		if entry.RetryCount >= resultMaxDeliveries {
+			log.Printf("discarded judge result %s after %d deliveries", entry.ID, entry.RetryCount)
+			q.ackResult(ctx, entry.ID)
+			continue
+		}
+		messages, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   protocol.ResultStream,
+			Group:    resultGroup,
+			Consumer: consumer,
+			MinIdle:  resultClaimIdle,
+			Messages: []string{entry.ID},
+		}).Result()
+		if err != nil {
+			log.Printf("failed to claim judge result %s: %v", entry.ID, err)
+			continue
+		}
+		for _, msg := range messages {
+			q.handleResult(ctx, msg, handle)
+		}
+	}
+}
+
+// handleResult 处理一条结果，处理成功或结果无法解析时确认
+func (q *RedisQueue) handleResult(ctx context.Context, msg redis.XMessage, handle func(*protocol.JudgeResult) error) {
+	payload, _ := msg.Values["result"].(string)
+	result, err := protocol.DecodeResult([]byte(payload))
+	if err != nil {
+		log.Printf("discarded judge result %s: %v", msg.ID, err)
+		q.ackResult(ctx, msg.ID)
+		return
+	}
+
+	if err := handle(result); err != nil {
+		log.Printf("failed to apply judge result for submission %s: %v", result.SubmissionID, err)
+		return
+	}
+	q.ackResult(ctx, msg.ID)
+}
+
+// ackResult 确认结果已处理
+func (q *RedisQueue) ackResult(ctx context.Context, id string) {
+	if err := q.client.XAck(ctx, protocol.ResultStream, resultGroup, id).Err(); err != nil {
+		log.Printf("failed to ack judge result %s: %v", id, err)
+	}
+}
+
+// resultConsumerName 返回本实例在结果消费组中的名称，使用主机名，容器重启后仍能处理上次未确认的结果
+func resultConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "backend"
+	}
+	return host
+}
+
+// Close 关闭连接
+func (q *RedisQueue) Close() error {
+	return q.client.Close()
+}
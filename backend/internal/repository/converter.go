@@ -89,12 +89,25 @@ func ProblemDomainToModel(problem *domain.Problem) *models.Problem {
 		Tags:          tagsJSON,
 		TimeLimit:     problem.TimeLimit,
 		MemoryLimit:   problem.MemoryLimit,
+		ReferenceCode: problem.ReferenceCode,
+		StarterCode:   problem.StarterCode,
+		Equivalence:   equivalenceToJSON(problem.Equivalence),
+		TruthTable:    truthTableToJSON(problem.TruthTable),
+		Checker:       problem.Checker,
+		SpecialJudge:  specialJudgeToJSON(problem.SpecialJudge),
 		SubmitCount:   problem.SubmitCount,
 		AcceptedCount: problem.AcceptedCount,
 		IsPublic:      problem.IsPublic,
 		AuthorID:      problem.AuthorID,
-		CreatedAt:     problem.CreatedAt,
-		UpdatedAt:     problem.UpdatedAt,
+
+		ValidationStatus:  problem.Validation.Status,
+		ValidationRunID:   problem.Validation.RunID,
+		ValidationMessage: problem.Validation.Message,
+		ValidationCases:   validationCasesToJSON(problem.Validation.Cases),
+		ValidatedAt:       problem.Validation.ValidatedAt,
+
+		CreatedAt: problem.CreatedAt,
+		UpdatedAt: problem.UpdatedAt,
 	}
 }
 
@@ -111,13 +124,253 @@ func ProblemModelToDomain(problem *models.Problem) *domain.Problem {
 		Tags:          parseModelTags(problem.Tags),
 		TimeLimit:     problem.TimeLimit,
 		MemoryLimit:   problem.MemoryLimit,
+		ReferenceCode: problem.ReferenceCode,
+		StarterCode:   problem.StarterCode,
+		Equivalence:   parseEquivalence(problem.Equivalence),
+		TruthTable:    parseTruthTable(problem.TruthTable),
+		Checker:       problem.Checker,
+		SpecialJudge:  parseSpecialJudge(problem.SpecialJudge),
 		SubmitCount:   problem.SubmitCount,
 		AcceptedCount: problem.AcceptedCount,
 		IsPublic:      problem.IsPublic,
 		AuthorID:      problem.AuthorID,
-		CreatedAt:     problem.CreatedAt,
-		UpdatedAt:     problem.UpdatedAt,
+		Validation: domain.ProblemValidation{
+			Status:      problem.ValidationStatus,
+			RunID:       problem.ValidationRunID,
+			Message:     problem.ValidationMessage,
+			Cases:       parseValidationCases(problem.ValidationCases),
+			ValidatedAt: problem.ValidatedAt,
+		},
+		CreatedAt: problem.CreatedAt,
+		UpdatedAt: problem.UpdatedAt,
+	}
+}
+
+// validationCaseRecord 校验结果在数据库中的JSON格式
+type validationCaseRecord struct {
+	Index        int    `json:"index"`
+	Description  string `json:"description,omitempty"`
+	Status       string `json:"status"`
+	RunTime      int    `json:"run_time"`
+	Memory       int    `json:"memory"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	SimTime      int64  `json:"sim_time,omitempty"`
+	Cycles       int    `json:"cycles,omitempty"`
+}
+
+// validationCasesToJSON 将校验结果转换为JSON字符串
+func validationCasesToJSON(cases []domain.ValidationCase) string {
+	if len(cases) == 0 {
+		return ""
+	}
+	records := make([]validationCaseRecord, len(cases))
+	for i, c := range cases {
+		records[i] = validationCaseRecord(c)
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// parseValidationCases 解析JSON字符串形式的校验结果
+func parseValidationCases(data string) []domain.ValidationCase {
+	if data == "" {
+		return nil
+	}
+	var records []validationCaseRecord
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		return nil
+	}
+	cases := make([]domain.ValidationCase, len(records))
+	for i, r := range records {
+		cases[i] = domain.ValidationCase(r)
+	}
+	return cases
+}
+
+// equivalenceRecord 等价性检查配置在数据库中的JSON格式
+type equivalenceRecord struct {
+	TopModule      string            `json:"top_module,omitempty"`
+	Seed           int               `json:"seed,omitempty"`
+	Cycles         int               `json:"cycles,omitempty"`
+	Clock          string            `json:"clock,omitempty"`
+	Reset          string            `json:"reset,omitempty"`
+	ResetActiveLow bool              `json:"reset_active_low,omitempty"`
+	ResetCycles    int               `json:"reset_cycles,omitempty"`
+	Hold           map[string]uint64 `json:"hold,omitempty"`
+	ExhaustiveBits int               `json:"exhaustive_bits,omitempty"`
+	Formal         bool              `json:"formal,omitempty"`
+	FormalTimeout  int               `json:"formal_timeout,omitempty"`
+	FormalDepth    int               `json:"formal_depth,omitempty"`
+}
+
+// equivalenceToJSON 将等价性检查配置转换为JSON字符串
+func equivalenceToJSON(config *domain.EquivalenceConfig) string {
+	if config == nil {
+		return ""
+	}
+	data, err := json.Marshal(equivalenceRecord(*config))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// parseEquivalence 解析JSON字符串形式的等价性检查配置
+func parseEquivalence(data string) *domain.EquivalenceConfig {
+	if data == "" {
+		return nil
 	}
+	var record equivalenceRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil
+	}
+	config := domain.EquivalenceConfig(record)
+	return &config
+}
+
+// truthTableRecord 真值表测试在数据库中的JSON格式
+type truthTableRecord struct {
+	TopModule string              `json:"top_module"`
+	Ports     []portRecord        `json:"ports"`
+	Rows      []map[string]string `json:"rows"`
+	Delay     int                 `json:"delay,omitempty"`
+}
+
+// portRecord 端口在数据库中的JSON格式
+type portRecord struct {
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	Width     int    `json:"width,omitempty"`
+}
+
+// truthTableToJSON 将真值表测试转换为JSON字符串
+func truthTableToJSON(table *domain.TruthTable) string {
+	if table == nil {
+		return ""
+	}
+	record := truthTableRecord{TopModule: table.TopModule, Rows: table.Rows, Delay: table.Delay}
+	for _, port := range table.Ports {
+		record.Ports = append(record.Ports, portRecord(port))
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// parseTruthTable 解析JSON字符串形式的真值表测试
+func parseTruthTable(data string) *domain.TruthTable {
+	if data == "" {
+		return nil
+	}
+	var record truthTableRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil
+	}
+	table := &domain.TruthTable{TopModule: record.TopModule, Rows: record.Rows, Delay: record.Delay}
+	for _, port := range record.Ports {
+		table.Ports = append(table.Ports, domain.Port(port))
+	}
+	return table
+}
+
+// specialJudgeRecord 检查程序在数据库中的JSON格式
+type specialJudgeRecord struct {
+	Language  string `json:"language"`
+	Source    string `json:"source"`
+	TimeLimit int    `json:"time_limit,omitempty"`
+}
+
+// specialJudgeToJSON 将检查程序转换为JSON字符串
+func specialJudgeToJSON(spj *domain.SpecialJudge) string {
+	if spj == nil {
+		return ""
+	}
+	data, err := json.Marshal(specialJudgeRecord(*spj))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// parseSpecialJudge 解析JSON字符串形式的检查程序
+func parseSpecialJudge(data string) *domain.SpecialJudge {
+	if data == "" {
+		return nil
+	}
+	var record specialJudgeRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil
+	}
+	spj := domain.SpecialJudge(record)
+	return &spj
+}
+
+// ioTraceRecord I/O 序列在数据库中的JSON格式
+type ioTraceRecord struct {
+	TopModule      string              `json:"top_module"`
+	Ports          []portRecord        `json:"ports"`
+	Clock          string              `json:"clock"`
+	Reset          string              `json:"reset,omitempty"`
+	ResetActiveLow bool                `json:"reset_active_low,omitempty"`
+	ResetCycles    int                 `json:"reset_cycles,omitempty"`
+	Period         int                 `json:"period,omitempty"`
+	SampleEdge     string              `json:"sample_edge,omitempty"`
+	Cycles         []map[string]string `json:"cycles"`
+}
+
+// ioTraceToJSON 将 I/O 序列转换为JSON字符串
+func ioTraceToJSON(trace *domain.IOTrace) string {
+	if trace == nil {
+		return ""
+	}
+	record := ioTraceRecord{
+		TopModule:      trace.TopModule,
+		Clock:          trace.Clock,
+		Reset:          trace.Reset,
+		ResetActiveLow: trace.ResetActiveLow,
+		ResetCycles:    trace.ResetCycles,
+		Period:         trace.Period,
+		SampleEdge:     trace.SampleEdge,
+		Cycles:         trace.Cycles,
+	}
+	for _, port := range trace.Ports {
+		record.Ports = append(record.Ports, portRecord(port))
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// parseIOTrace 解析JSON字符串形式的 I/O 序列
+func parseIOTrace(data string) *domain.IOTrace {
+	if data == "" {
+		return nil
+	}
+	var record ioTraceRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil
+	}
+	trace := &domain.IOTrace{
+		TopModule:      record.TopModule,
+		Clock:          record.Clock,
+		Reset:          record.Reset,
+		ResetActiveLow: record.ResetActiveLow,
+		ResetCycles:    record.ResetCycles,
+		Period:         record.Period,
+		SampleEdge:     record.SampleEdge,
+		Cycles:         record.Cycles,
+	}
+	for _, port := range record.Ports {
+		trace.Ports = append(trace.Ports, domain.Port(port))
+	}
+	return trace
 }
 
 // SubmissionDomainToModel 将Domain实体转换为Model
@@ -295,26 +548,148 @@ func NewsModelToDomain(news *models.News) *domain.News {
 // TestCaseDomainToModel 将Domain实体转换为Model
 func TestCaseDomainToModel(testCase *domain.TestCase) *models.TestCase {
 	return &models.TestCase{
-		ID:        testCase.ID,
-		ProblemID: testCase.ProblemID,
-		Input:     testCase.Input,
-		Output:    testCase.Output,
-		IsSample:  testCase.IsSample,
-		CreatedAt: testCase.CreatedAt,
-		UpdatedAt: testCase.UpdatedAt,
+		ID:          testCase.ID,
+		ProblemID:   testCase.ProblemID,
+		Input:       testCase.Input,
+		Output:      testCase.Output,
+		IsSample:    testCase.IsSample,
+		Description: testCase.Description,
+		SimTime:     testCase.SimTime,
+		Clock:       testCase.Clock,
+		Trace:       ioTraceToJSON(testCase.Trace),
+		CreatedAt:   testCase.CreatedAt,
+		UpdatedAt:   testCase.UpdatedAt,
 	}
 }
 
 // TestCaseModelToDomain 将Model转换为Domain实体
 func TestCaseModelToDomain(testCase *models.TestCase) *domain.TestCase {
 	return &domain.TestCase{
-		ID:        testCase.ID,
-		ProblemID: testCase.ProblemID,
-		Input:     testCase.Input,
-		Output:    testCase.Output,
-		IsSample:  testCase.IsSample,
-		CreatedAt: testCase.CreatedAt,
-		UpdatedAt: testCase.UpdatedAt,
+		ID:          testCase.ID,
+		ProblemID:   testCase.ProblemID,
+		Input:       testCase.Input,
+		Output:      testCase.Output,
+		IsSample:    testCase.IsSample,
+		Description: testCase.Description,
+		SimTime:     testCase.SimTime,
+		Clock:       testCase.Clock,
+		Trace:       parseIOTrace(testCase.Trace),
+		CreatedAt:   testCase.CreatedAt,
+		UpdatedAt:   testCase.UpdatedAt,
+	}
+}
+
+// ProblemAttachmentDomainToModel 将Domain实体转换为Model
+func ProblemAttachmentDomainToModel(attachment *domain.ProblemAttachment) *models.ProblemAttachment {
+	return &models.ProblemAttachment{
+		ID:        attachment.ID,
+		ProblemID: attachment.ProblemID,
+		Name:      attachment.Name,
+		Content:   attachment.Content,
+		CreatedAt: attachment.CreatedAt,
+	}
+}
+
+// ProblemAttachmentModelToDomain 将Model转换为Domain实体
+func ProblemAttachmentModelToDomain(attachment *models.ProblemAttachment) *domain.ProblemAttachment {
+	return &domain.ProblemAttachment{
+		ID:        attachment.ID,
+		ProblemID: attachment.ProblemID,
+		Name:      attachment.Name,
+		Content:   attachment.Content,
+		CreatedAt: attachment.CreatedAt,
+	}
+}
+
+// outputGenerationRecord 生成的期望输出在数据库中的JSON格式
+type outputGenerationRecord struct {
+	Cases      []generatedOutputRecord `json:"cases,omitempty"`
+	TruthTable []map[string]string     `json:"truth_table,omitempty"`
+}
+
+// generatedOutputRecord 单个测试用例生成的期望输出在数据库中的JSON格式
+type generatedOutputRecord struct {
+	TestCaseID uint                `json:"test_case_id"`
+	Output     string              `json:"output,omitempty"`
+	Trace      []map[string]string `json:"trace,omitempty"`
+}
+
+// generatedOutputsToJSON 将生成的期望输出转换为JSON字符串
+func generatedOutputsToJSON(generation *domain.OutputGeneration) string {
+	if len(generation.Cases) == 0 && len(generation.TruthTable) == 0 {
+		return ""
+	}
+	record := outputGenerationRecord{TruthTable: generation.TruthTable}
+	for _, c := range generation.Cases {
+		record.Cases = append(record.Cases, generatedOutputRecord(c))
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// OutputGenerationDomainToModel 将Domain实体转换为Model
+func OutputGenerationDomainToModel(generation *domain.OutputGeneration) *models.ProblemOutputGeneration {
+	return &models.ProblemOutputGeneration{
+		ProblemID:   generation.ProblemID,
+		RunID:       generation.RunID,
+		Fingerprint: generation.Fingerprint,
+		Status:      generation.Status,
+		Message:     generation.Message,
+		Outputs:     generatedOutputsToJSON(generation),
+		CreatedAt:   generation.CreatedAt,
+		FinishedAt:  generation.FinishedAt,
+	}
+}
+
+// OutputGenerationModelToDomain 将Model转换为Domain实体
+func OutputGenerationModelToDomain(generation *models.ProblemOutputGeneration) *domain.OutputGeneration {
+	result := &domain.OutputGeneration{
+		ProblemID:   generation.ProblemID,
+		RunID:       generation.RunID,
+		Fingerprint: generation.Fingerprint,
+		Status:      generation.Status,
+		Message:     generation.Message,
+		CreatedAt:   generation.CreatedAt,
+		FinishedAt:  generation.FinishedAt,
+	}
+	if generation.Outputs == "" {
+		return result
+	}
+	var record outputGenerationRecord
+	if err := json.Unmarshal([]byte(generation.Outputs), &record); err != nil {
+		return result
+	}
+	result.TruthTable = record.TruthTable
+	for _, c := range record.Cases {
+		result.Cases = append(result.Cases, domain.GeneratedOutput(c))
+	}
+	return result
+}
+
+// SubmissionRecoveryDomainToModel 将Domain实体转换为Model
+func SubmissionRecoveryDomainToModel(recovery *domain.SubmissionRecovery) *models.SubmissionRecovery {
+	return &models.SubmissionRecovery{
+		ID:           recovery.ID,
+		SubmissionID: recovery.SubmissionID,
+		Action:       recovery.Action,
+		Attempt:      recovery.Attempt,
+		Reason:       recovery.Reason,
+		CreatedAt:    recovery.CreatedAt,
+	}
+}
+
+// SubmissionRecoveryModelToDomain 将Model转换为Domain实体
+func SubmissionRecoveryModelToDomain(recovery *models.SubmissionRecovery) *domain.SubmissionRecovery {
+	return &domain.SubmissionRecovery{
+		ID:           recovery.ID,
+		SubmissionID: recovery.SubmissionID,
+		Action:       recovery.Action,
+		Attempt:      recovery.Attempt,
+		Reason:       recovery.Reason,
+		CreatedAt:    recovery.CreatedAt,
 	}
 }
 
@@ -98,10 +98,13 @@ func (r *ProblemRepository) List(page, limit int, filters map[string]interface{}
 	return problems, total, nil
 }
 
-// Update 更新题目
+// validationColumns 参考答案校验相关的列，只通过 UpdateValidation 修改
+var validationColumns = []string{"validation_status", "validation_run_id", "validation_message", "validation_cases", "validated_at"}
+
+// Update 更新题目，不修改校验结果，避免覆盖异步写回的判题结果
 func (r *ProblemRepository) Update(problem *domain.Problem) error {
 	modelProblem := ProblemDomainToModel(problem)
-	err := r.db.Save(modelProblem).Error
+	err := r.db.Omit(validationColumns...).Save(modelProblem).Error
 	if err != nil {
 		return err
 	}
@@ -112,6 +115,17 @@ func (r *ProblemRepository) Update(problem *domain.Problem) error {
 	return nil
 }
 
+// UpdateValidation 更新题目的参考答案校验结果
+func (r *ProblemRepository) UpdateValidation(problemID uint, validation *domain.ProblemValidation) error {
+	return r.db.Model(&models.Problem{}).Where("id = ?", problemID).Updates(map[string]interface{}{
+		"validation_status":  validation.Status,
+		"validation_run_id":  validation.RunID,
+		"validation_message": validation.Message,
+		"validation_cases":   validationCasesToJSON(validation.Cases),
+		"validated_at":       validation.ValidatedAt,
+	}).Error
+}
+
 // Delete 删除题目
 func (r *ProblemRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Problem{}, id).Error
@@ -155,6 +169,109 @@ func (r *ProblemRepository) DeleteTestCases(problemID uint) error {
 	return r.db.Where("problem_id = ?", problemID).Delete(&models.TestCase{}).Error
 }
 
+// CreateAttachment 创建题目附件
+func (r *ProblemRepository) CreateAttachment(attachment *domain.ProblemAttachment) error {
+	modelAttachment := ProblemAttachmentDomainToModel(attachment)
+	err := r.db.Create(modelAttachment).Error
+	if err != nil {
+		return err
+	}
+
+	// 更新ID和时间戳
+	attachment.ID = modelAttachment.ID
+	attachment.CreatedAt = modelAttachment.CreatedAt
+
+	return nil
+}
+
+// GetAttachments 获取题目的附件
+func (r *ProblemRepository) GetAttachments(problemID uint) ([]domain.ProblemAttachment, error) {
+	var modelAttachments []models.ProblemAttachment
+	err := r.db.Where("problem_id = ?", problemID).Order("id ASC").Find(&modelAttachments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换为domain对象
+	attachments := make([]domain.ProblemAttachment, len(modelAttachments))
+	for i, modelAttachment := range modelAttachments {
+		attachments[i] = *ProblemAttachmentModelToDomain(&modelAttachment)
+	}
+
+	return attachments, nil
+}
+
+// DeleteAttachments 删除题目的附件
+func (r *ProblemRepository) DeleteAttachments(problemID uint) error {
+	return r.db.Where("problem_id = ?", problemID).Delete(&models.ProblemAttachment{}).Error
+}
+
+// SaveOutputGeneration 保存期望输出生成任务，替换题目之前的生成任务
+func (r *ProblemRepository) SaveOutputGeneration(generation *domain.OutputGeneration) error {
+	modelGeneration := OutputGenerationDomainToModel(generation)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("problem_id = ?", generation.ProblemID).Delete(&models.ProblemOutputGeneration{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(modelGeneration).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	generation.CreatedAt = modelGeneration.CreatedAt
+	return nil
+}
+
+// GetOutputGeneration 获取题目最近一次期望输出生成任务，没有时返回 nil
+func (r *ProblemRepository) GetOutputGeneration(problemID uint) (*domain.OutputGeneration, error) {
+	var modelGeneration models.ProblemOutputGeneration
+	err := r.db.Where("problem_id = ?", problemID).First(&modelGeneration).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return OutputGenerationModelToDomain(&modelGeneration), nil
+}
+
+// UpdateOutputGeneration 写回期望输出生成结果，生成任务已被新的任务替换时不修改并返回 false
+func (r *ProblemRepository) UpdateOutputGeneration(generation *domain.OutputGeneration) (bool, error) {
+	result := r.db.Model(&models.ProblemOutputGeneration{}).
+		Where("problem_id = ? AND run_id = ?", generation.ProblemID, generation.RunID).
+		Updates(map[string]interface{}{
+			"status":      generation.Status,
+			"message":     generation.Message,
+			"outputs":     generatedOutputsToJSON(generation),
+			"finished_at": generation.FinishedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ApplyGeneratedOutputs 将生成的期望输出写入测试用例和真值表，并删除生成任务
+// 只修改测试用例的 Output 和 Trace，truthTable 为 nil 时不修改题目
+func (r *ProblemRepository) ApplyGeneratedOutputs(problemID uint, testCases []domain.TestCase, truthTable *domain.TruthTable) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, tc := range testCases {
+			err := tx.Model(&models.TestCase{}).Where("id = ? AND problem_id = ?", tc.ID, problemID).Updates(map[string]interface{}{
+				"output": tc.Output,
+				"trace":  ioTraceToJSON(tc.Trace),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if truthTable != nil {
+			err := tx.Model(&models.Problem{}).Where("id = ?", problemID).Update("truth_table", truthTableToJSON(truthTable)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("problem_id = ?", problemID).Delete(&models.ProblemOutputGeneration{}).Error
+	})
+}
+
 // UpdateSubmitCount 更新题目提交统计
 func (r *ProblemRepository) UpdateSubmitCount(id uint, increment int) error {
 	return r.db.Model(&models.Problem{}).Where("id = ?", id).Update("submit_count", gorm.Expr("submit_count + ?", increment)).Error
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"testing"
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/models"
 
@@ -18,7 +19,7 @@ func setupProblemTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&models.Problem{}, &models.TestCase{})
+	err = db.AutoMigrate(&models.Problem{}, &models.TestCase{}, &models.ProblemAttachment{}, &models.ProblemOutputGeneration{})
 	if err != nil {
 		t.Fatalf("failed to migrate db: %v", err)
 	}
@@ -60,6 +61,101 @@ func TestProblemRepository_Update(t *testing.T) {
 	assert.Equal(t, "Updated", retrieved.Title)
 }
 
+func TestProblemRepository_Equivalence(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{
+		Title:       "Counter",
+		Description: "Counter",
+		Equivalence: &domain.EquivalenceConfig{Clock: "clk", Reset: "rst_n", ResetActiveLow: true, Hold: map[string]uint64{"en": 1}, Formal: true},
+	}
+	assert.NoError(t, repo.Create(problem))
+
+	retrieved, err := repo.GetByID(problem.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, problem.Equivalence, retrieved.Equivalence)
+
+	retrieved.Equivalence = nil
+	assert.NoError(t, repo.Update(retrieved))
+	retrieved, _ = repo.GetByID(problem.ID)
+	assert.Nil(t, retrieved.Equivalence)
+}
+
+func TestProblemRepository_TruthTable(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{
+		Title:       "Adder",
+		Description: "Adder",
+		TruthTable: &domain.TruthTable{
+			TopModule: "adder",
+			Ports:     []domain.Port{{Name: "a", Direction: "input", Width: 2}, {Name: "sum", Direction: "output", Width: 3}},
+			Rows:      []map[string]string{{"a": "1", "sum": "0b0x1"}, {"a": "3"}},
+		},
+	}
+	assert.NoError(t, repo.Create(problem))
+
+	retrieved, err := repo.GetByID(problem.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, problem.TruthTable, retrieved.TruthTable)
+
+	retrieved.TruthTable = nil
+	assert.NoError(t, repo.Update(retrieved))
+	retrieved, _ = repo.GetByID(problem.ID)
+	assert.Nil(t, retrieved.TruthTable)
+}
+
+func TestProblemRepository_SpecialJudge(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{
+		Title:        "Gray Code",
+		Description:  "Gray Code",
+		SpecialJudge: &domain.SpecialJudge{Language: "go", Source: "package main\n\nfunc main() {}\n", TimeLimit: 2000},
+	}
+	assert.NoError(t, repo.Create(problem))
+
+	retrieved, err := repo.GetByID(problem.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, problem.SpecialJudge, retrieved.SpecialJudge)
+
+	retrieved.SpecialJudge = nil
+	assert.NoError(t, repo.Update(retrieved))
+	retrieved, _ = repo.GetByID(problem.ID)
+	assert.Nil(t, retrieved.SpecialJudge)
+}
+
+func TestProblemRepository_TestCaseTrace(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{Title: "Counter", Description: "Counter"}
+	assert.NoError(t, repo.Create(problem))
+
+	testCase := &domain.TestCase{
+		ProblemID: problem.ID,
+		Trace: &domain.IOTrace{
+			TopModule:      "counter",
+			Ports:          []domain.Port{{Name: "clk", Direction: "input"}, {Name: "rst_n", Direction: "input"}, {Name: "q", Direction: "output", Width: 2}},
+			Clock:          "clk",
+			Reset:          "rst_n",
+			ResetActiveLow: true,
+			SampleEdge:     "posedge",
+			Cycles:         []map[string]string{{"q": "0"}, {"q": "1"}, {"rst_n": "0", "q": "-"}},
+		},
+	}
+	assert.NoError(t, repo.CreateTestCase(testCase))
+
+	cases, err := repo.GetTestCases(problem.ID)
+	assert.NoError(t, err)
+	assert.Len(t, cases, 1)
+	assert.Equal(t, testCase.Trace, cases[0].Trace)
+	assert.Empty(t, cases[0].Input)
+}
+
 func TestProblemRepository_Delete(t *testing.T) {
 	db := setupProblemTestDB(t)
 	repo := NewProblemRepository(db)
@@ -141,3 +237,113 @@ func TestProblemRepository_UpdateCounts(t *testing.T) {
 	retrieved, _ = repo.GetByID(problem.ID)
 	assert.Equal(t, 1, retrieved.AcceptedCount)
 }
+
+func TestProblemRepository_UpdateValidation(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{Title: "Validated Problem", Description: "Description"}
+	repo.Create(problem)
+
+	retrieved, _ := repo.GetByID(problem.ID)
+	assert.Equal(t, domain.ValidationUnvalidated, retrieved.Validation.Status)
+
+	now := time.Now()
+	err := repo.UpdateValidation(problem.ID, &domain.ProblemValidation{
+		Status:  domain.ValidationInvalid,
+		RunID:   "run-1",
+		Message: "参考答案未通过 1 个测试用例",
+		Cases: []domain.ValidationCase{
+			{Index: 1, Status: "accepted", RunTime: 3},
+			{Index: 2, Status: "wrong_answer", ErrorMessage: "mismatch"},
+		},
+		ValidatedAt: &now,
+	})
+	assert.NoError(t, err)
+
+	retrieved, _ = repo.GetByID(problem.ID)
+	assert.Equal(t, domain.ValidationInvalid, retrieved.Validation.Status)
+	assert.Equal(t, "run-1", retrieved.Validation.RunID)
+	assert.Len(t, retrieved.Validation.Cases, 2)
+	assert.Equal(t, "mismatch", retrieved.Validation.Cases[1].ErrorMessage)
+	assert.NotNil(t, retrieved.Validation.ValidatedAt)
+
+	// 更新题目时不覆盖校验结果
+	problem.Title = "Renamed"
+	assert.NoError(t, repo.Update(problem))
+	retrieved, _ = repo.GetByID(problem.ID)
+	assert.Equal(t, "Renamed", retrieved.Title)
+	assert.Equal(t, domain.ValidationInvalid, retrieved.Validation.Status)
+	assert.Len(t, retrieved.Validation.Cases, 2)
+}
+
+func TestProblemRepository_OutputGeneration(t *testing.T) {
+	db := setupProblemTestDB(t)
+	repo := NewProblemRepository(db)
+
+	problem := &domain.Problem{
+		Title:       "Inverter",
+		Description: "Inverter",
+		TruthTable: &domain.TruthTable{
+			TopModule: "inv",
+			Ports:     []domain.Port{{Name: "a", Direction: "input"}, {Name: "y", Direction: "output"}},
+			Rows:      []map[string]string{{"a": "0"}, {"a": "1"}},
+		},
+	}
+	assert.NoError(t, repo.Create(problem))
+	testCase := &domain.TestCase{ProblemID: problem.ID, Input: "module tb; endmodule"}
+	assert.NoError(t, repo.CreateTestCase(testCase))
+
+	generation, err := repo.GetOutputGeneration(problem.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, generation)
+
+	pending := &domain.OutputGeneration{
+		ProblemID: problem.ID,
+		RunID:     "run-1",
+		Status:    domain.OutputGenerationPending,
+		Cases:     []domain.GeneratedOutput{{TestCaseID: testCase.ID}},
+	}
+	assert.NoError(t, repo.SaveOutputGeneration(pending))
+	// 新的生成任务替换之前的任务，旧任务的结果不再写回
+	pending.RunID = "run-2"
+	assert.NoError(t, repo.SaveOutputGeneration(pending))
+
+	now := time.Now()
+	ready := &domain.OutputGeneration{
+		ProblemID:  problem.ID,
+		RunID:      "run-1",
+		Status:     domain.OutputGenerationReady,
+		Cases:      []domain.GeneratedOutput{{TestCaseID: testCase.ID, Output: "y=1\n"}},
+		TruthTable: []map[string]string{{"y": "0b1"}, {"y": "0b0"}},
+		FinishedAt: &now,
+	}
+	updated, err := repo.UpdateOutputGeneration(ready)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	ready.RunID = "run-2"
+	updated, err = repo.UpdateOutputGeneration(ready)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	generation, err = repo.GetOutputGeneration(problem.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OutputGenerationReady, generation.Status)
+	assert.Equal(t, ready.Cases, generation.Cases)
+	assert.Equal(t, ready.TruthTable, generation.TruthTable)
+	assert.NotNil(t, generation.FinishedAt)
+
+	testCase.Output = "y=1\n"
+	table := *problem.TruthTable
+	table.Rows = []map[string]string{{"a": "0", "y": "0b1"}, {"a": "1", "y": "0b0"}}
+	assert.NoError(t, repo.ApplyGeneratedOutputs(problem.ID, []domain.TestCase{*testCase}, &table))
+
+	testCases, _ := repo.GetTestCases(problem.ID)
+	assert.Equal(t, "y=1\n", testCases[0].Output)
+	assert.Equal(t, "module tb; endmodule", testCases[0].Input)
+	retrieved, _ := repo.GetByID(problem.ID)
+	assert.Equal(t, table.Rows, retrieved.TruthTable.Rows)
+	generation, _ = repo.GetOutputGeneration(problem.ID)
+	assert.Nil(t, generation)
+}
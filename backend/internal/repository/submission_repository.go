@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/models"
 	"verilog-oj/backend/internal/services"
@@ -89,7 +90,8 @@ func (r *SubmissionRepository) List(page, limit int, userID, problemID uint, sta
 	return submissions, total, nil
 }
 
-// UpdateStatus 更新提交状态
+// UpdateStatus 更新提交状态，已取消的提交不再更新，避免取消后才到达的判题结果覆盖 cancelled 状态
+// 更新为 accepted 时在同一个事务中更新解题数和通过数
 func (r *SubmissionRepository) UpdateStatus(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) error {
 	updates := map[string]interface{}{
 		"status":        status,
@@ -101,13 +103,147 @@ func (r *SubmissionRepository) UpdateStatus(id uint, status string, score int, r
 		"total_tests":   totalTests,
 	}
 
-	return r.db.Model(&models.Submission{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var previous models.Submission
+		err := tx.Select("status").Where("id = ?", id).Take(&previous).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+
+		result := tx.Model(&models.Submission{}).Where("id = ? AND status <> ?", id, models.StatusCancelled).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || status != models.StatusAccepted || previous.Status == models.StatusAccepted {
+			return nil
+		}
+		return recordAccepted(tx, id)
+	})
+}
+
+// FinishJudging 写回判题结果，只更新 pending 或 judging 状态的提交，返回是否更新了提交
+// 重复投递的结果和取消后才到达的结果不会覆盖已有结果
+// 结果为 accepted 时在同一个事务中更新解题数和通过数，统计更新失败时结果也不写入，重新投递时可以再次写回
+func (r *SubmissionRepository) FinishJudging(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) (bool, error) {
+	updates := map[string]interface{}{
+		"status":        status,
+		"score":         score,
+		"run_time":      runTime,
+		"memory":        memory,
+		"error_message": errorMessage,
+		"passed_tests":  passedTests,
+		"total_tests":   totalTests,
+	}
+
+	applied := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Submission{}).
+			Where("id = ? AND status IN ?", id, []string{models.StatusPending, models.StatusJudging}).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		applied = true
+		if status != models.StatusAccepted {
+			return nil
+		}
+		return recordAccepted(tx, id)
+	})
+	if err != nil {
+		return false, err
+	}
+	return applied, nil
+}
+
+// recordAccepted 提交者第一次通过该题时，更新用户的解题数和题目的通过数
+// 需要在写入 accepted 状态的同一个事务中调用
+func recordAccepted(tx *gorm.DB, id uint) error {
+	var submission models.Submission
+	if err := tx.Select("user_id", "problem_id").Where("id = ?", id).Take(&submission).Error; err != nil {
+		return err
+	}
+
+	// 检查是否是第一次通过此题
+	var accepted int64
+	err := tx.Model(&models.Submission{}).
+		Where("user_id = ? AND problem_id = ? AND status = ?", submission.UserID, submission.ProblemID, models.StatusAccepted).
+		Count(&accepted).Error
+	if err != nil {
+		return err
+	}
+	if accepted != 1 {
+		return nil
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", submission.UserID).Update("solved", gorm.Expr("solved + 1")).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Problem{}).Where("id = ?", submission.ProblemID).Update("accepted_count", gorm.Expr("accepted_count + 1")).Error
+}
+
+// ListStuck 返回更新时间早于 before 的等待中或判题中的提交，按更新时间先后，最多 limit 条
+func (r *SubmissionRepository) ListStuck(before time.Time, limit int) ([]domain.Submission, error) {
+	var modelSubmissions []models.Submission
+	err := r.db.Where("status IN ? AND updated_at < ?", []string{models.StatusPending, models.StatusJudging}, before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&modelSubmissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return SubmissionsModelToDomain(modelSubmissions), nil
+}
+
+// RecordRecovery 记录看门狗的处理，重新入队时同时刷新提交的更新时间，下一个超时周期内不再视为卡住
+func (r *SubmissionRepository) RecordRecovery(recovery *domain.SubmissionRecovery) error {
+	modelRecovery := SubmissionRecoveryDomainToModel(recovery)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(modelRecovery).Error; err != nil {
+			return err
+		}
+		if recovery.Action != domain.RecoveryRequeued {
+			return nil
+		}
+		return tx.Model(&models.Submission{}).Where("id = ?", recovery.SubmissionID).Update("updated_at", modelRecovery.CreatedAt).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 更新ID和时间戳
+	recovery.ID = modelRecovery.ID
+	recovery.CreatedAt = modelRecovery.CreatedAt
+
+	return nil
+}
+
+// ListRecoveries 获取提交的看门狗处理记录，按时间先后
+func (r *SubmissionRepository) ListRecoveries(submissionID uint) ([]domain.SubmissionRecovery, error) {
+	var modelRecoveries []models.SubmissionRecovery
+	err := r.db.Where("submission_id = ?", submissionID).Order("id ASC").Find(&modelRecoveries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换为domain对象
+	recoveries := make([]domain.SubmissionRecovery, len(modelRecoveries))
+	for i, modelRecovery := range modelRecoveries {
+		recoveries[i] = *SubmissionRecoveryModelToDomain(&modelRecovery)
+	}
+
+	return recoveries, nil
 }
 
 // CountAcceptedByUser 统计用户通过的题目数
 func (r *SubmissionRepository) CountAcceptedByUser(userID, problemID uint) (int64, error) {
 	var count int64
-	query := r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, "Accepted")
+	query := r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, models.StatusAccepted)
 
 	if problemID > 0 {
 		query = query.Where("problem_id = ?", problemID)
@@ -121,9 +257,9 @@ func (r *SubmissionRepository) CountAcceptedByUser(userID, problemID uint) (int6
 func (r *SubmissionRepository) GetStats(userID uint) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
-	// 总提交数
+	// 总提交数，已取消的提交不计入
 	var totalSubmissions int64
-	err := r.db.Model(&models.Submission{}).Where("user_id = ?", userID).Count(&totalSubmissions).Error
+	err := r.db.Model(&models.Submission{}).Where("user_id = ? AND status <> ?", userID, models.StatusCancelled).Count(&totalSubmissions).Error
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +267,7 @@ func (r *SubmissionRepository) GetStats(userID uint) (map[string]interface{}, er
 
 	// 通过的提交数
 	var acceptedSubmissions int64
-	err = r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, "Accepted").Count(&acceptedSubmissions).Error
+	err = r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, models.StatusAccepted).Count(&acceptedSubmissions).Error
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +275,7 @@ func (r *SubmissionRepository) GetStats(userID uint) (map[string]interface{}, er
 
 	// 通过的题目数（去重）
 	var solvedProblems int64
-	err = r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, "Accepted").Distinct("problem_id").Count(&solvedProblems).Error
+	err = r.db.Model(&models.Submission{}).Where("user_id = ? AND status = ?", userID, models.StatusAccepted).Distinct("problem_id").Count(&solvedProblems).Error
 	if err != nil {
 		return nil, err
 	}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"errors"
 	"testing"
+	"time"
 	"verilog-oj/backend/internal/domain"
 	"verilog-oj/backend/internal/models"
 	"verilog-oj/backend/internal/services"
@@ -16,7 +18,7 @@ func setupSubmissionTestDB(t *testing.T) (*gorm.DB, services.UserRepository, ser
 	if err != nil {
 		t.Fatalf("failed to connect to memory db: %v", err)
 	}
-	err = db.AutoMigrate(&models.User{}, &models.Problem{}, &models.Submission{})
+	err = db.AutoMigrate(&models.User{}, &models.Problem{}, &models.Submission{}, &models.SubmissionRecovery{})
 	if err != nil {
 		t.Fatalf("failed to migrate db: %v", err)
 	}
@@ -104,9 +106,9 @@ func TestSubmissionRepository_CountAcceptedByUser(t *testing.T) {
 	p2 := &domain.Problem{Title: "P2"}
 	problemRepo.Create(p2)
 
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "Accepted"})
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "Wrong Answer"})
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p2.ID, Status: "Accepted"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "accepted"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "wrong_answer"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p2.ID, Status: "accepted"})
 
 	count, err := repo.CountAcceptedByUser(user.ID, p1.ID)
 	assert.NoError(t, err)
@@ -123,9 +125,9 @@ func TestSubmissionRepository_GetStats(t *testing.T) {
 	p2 := &domain.Problem{Title: "P2"}
 	problemRepo.Create(p2)
 
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "Accepted"})
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "Wrong Answer"})
-	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p2.ID, Status: "Accepted"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "accepted"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "wrong_answer"})
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p2.ID, Status: "accepted"})
 
 	stats, err := repo.GetStats(user.ID)
 	assert.NoError(t, err)
@@ -133,3 +135,176 @@ func TestSubmissionRepository_GetStats(t *testing.T) {
 	assert.Equal(t, int64(2), stats["accepted_submissions"])
 	assert.Equal(t, int64(2), stats["solved_problems"])
 }
+
+func TestSubmissionRepository_Cancelled(t *testing.T) {
+	db, userRepo, problemRepo := setupSubmissionTestDB(t)
+	repo := NewSubmissionRepository(db)
+	user := &domain.User{Username: "u1", Email: "u1@test.com", Password: "pw"}
+	userRepo.Create(user)
+	p1 := &domain.Problem{Title: "P1"}
+	problemRepo.Create(p1)
+
+	submission := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "pending"}
+	repo.Create(submission)
+	repo.Create(&domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "wrong_answer"})
+
+	// 取消后才到达的判题结果不覆盖 cancelled
+	assert.NoError(t, repo.UpdateStatus(submission.ID, "cancelled", 0, 0, 0, "判题已取消", 0, 0))
+	assert.NoError(t, repo.UpdateStatus(submission.ID, "accepted", 100, 50, 1024, "", 10, 10))
+	retrieved, _ := repo.GetByID(submission.ID)
+	assert.Equal(t, "cancelled", retrieved.Status)
+	assert.Equal(t, 0, retrieved.Score)
+
+	// 已取消的提交不计入统计
+	stats, err := repo.GetStats(user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats["total_submissions"])
+}
+
+func TestSubmissionRepository_FinishJudging(t *testing.T) {
+	db, userRepo, problemRepo := setupSubmissionTestDB(t)
+	repo := NewSubmissionRepository(db)
+	user := &domain.User{Username: "u1", Email: "u1@test.com", Password: "pw"}
+	userRepo.Create(user)
+	p1 := &domain.Problem{Title: "P1"}
+	problemRepo.Create(p1)
+
+	submission := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "pending"}
+	repo.Create(submission)
+
+	applied, err := repo.FinishJudging(submission.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	// 重复投递的结果不再更新
+	applied, err = repo.FinishJudging(submission.ID, "wrong_answer", 0, 50, 1024, "mismatch", 0, 10)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	retrieved, _ := repo.GetByID(submission.ID)
+	assert.Equal(t, "accepted", retrieved.Status)
+	assert.Equal(t, 100, retrieved.Score)
+
+	// 取消后才到达的结果不更新
+	cancelled := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "cancelled"}
+	repo.Create(cancelled)
+	applied, err = repo.FinishJudging(cancelled.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+}
+
+func TestSubmissionRepository_AcceptedCounters(t *testing.T) {
+	db, userRepo, problemRepo := setupSubmissionTestDB(t)
+	repo := NewSubmissionRepository(db)
+	user := &domain.User{Username: "u1", Email: "u1@test.com", Password: "pw"}
+	userRepo.Create(user)
+	p1 := &domain.Problem{Title: "P1"}
+	problemRepo.Create(p1)
+
+	first := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "judging"}
+	repo.Create(first)
+	second := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "judging"}
+	repo.Create(second)
+
+	// 第一次通过时更新解题数和通过数，之后的通过不再计数
+	applied, err := repo.FinishJudging(first.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	applied, err = repo.FinishJudging(second.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	// 重新设置为 accepted 不重复计数
+	assert.NoError(t, repo.UpdateStatus(first.ID, "accepted", 100, 50, 1024, "", 10, 10))
+
+	retrievedUser, _ := userRepo.GetByID(user.ID)
+	assert.Equal(t, 1, retrievedUser.Solved)
+	retrievedProblem, _ := problemRepo.GetByID(p1.ID)
+	assert.Equal(t, 1, retrievedProblem.AcceptedCount)
+}
+
+func TestSubmissionRepository_FinishJudgingRollsBackOnCounterFailure(t *testing.T) {
+	db, userRepo, problemRepo := setupSubmissionTestDB(t)
+	repo := NewSubmissionRepository(db)
+	user := &domain.User{Username: "u1", Email: "u1@test.com", Password: "pw"}
+	userRepo.Create(user)
+	p1 := &domain.Problem{Title: "P1"}
+	problemRepo.Create(p1)
+
+	submission := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "judging"}
+	repo.Create(submission)
+
+	// 写入提交状态和用户解题数之后，更新题目通过数失败
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_problem_update", func(tx *gorm.DB) {
+		if tx.Statement.Table == "problems" {
+			tx.AddError(errors.New("update accepted_count failed"))
+		}
+	})
+	assert.NoError(t, err)
+
+	applied, err := repo.FinishJudging(submission.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.EqualError(t, err, "update accepted_count failed")
+	assert.False(t, applied)
+
+	// 整个事务回滚，提交仍在判题中，统计没有变化
+	retrieved, _ := repo.GetByID(submission.ID)
+	assert.Equal(t, "judging", retrieved.Status)
+	retrievedUser, _ := userRepo.GetByID(user.ID)
+	assert.Equal(t, 0, retrievedUser.Solved)
+
+	// 重新投递的结果可以写回并完成计数
+	assert.NoError(t, db.Callback().Update().Remove("test:fail_problem_update"))
+	applied, err = repo.FinishJudging(submission.ID, "accepted", 100, 50, 1024, "", 10, 10)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	retrieved, _ = repo.GetByID(submission.ID)
+	assert.Equal(t, "accepted", retrieved.Status)
+	retrievedUser, _ = userRepo.GetByID(user.ID)
+	assert.Equal(t, 1, retrievedUser.Solved)
+	retrievedProblem, _ := problemRepo.GetByID(p1.ID)
+	assert.Equal(t, 1, retrievedProblem.AcceptedCount)
+}
+
+func TestSubmissionRepository_StuckAndRecoveries(t *testing.T) {
+	db, userRepo, problemRepo := setupSubmissionTestDB(t)
+	repo := NewSubmissionRepository(db)
+	user := &domain.User{Username: "u1", Email: "u1@test.com", Password: "pw"}
+	userRepo.Create(user)
+	p1 := &domain.Problem{Title: "P1"}
+	problemRepo.Create(p1)
+
+	old := time.Now().Add(-time.Hour)
+	stuck := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "pending"}
+	judged := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "accepted"}
+	fresh := &domain.Submission{UserID: user.ID, ProblemID: p1.ID, Status: "pending"}
+	for _, submission := range []*domain.Submission{stuck, judged, fresh} {
+		repo.Create(submission)
+	}
+	for _, submission := range []*domain.Submission{stuck, judged} {
+		db.Model(&models.Submission{}).Where("id = ?", submission.ID).UpdateColumn("updated_at", old)
+	}
+
+	cutoff := time.Now().Add(-10 * time.Minute)
+	list, err := repo.ListStuck(cutoff, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, stuck.ID, list[0].ID)
+	}
+
+	// 重新入队后刷新更新时间，不再视为卡住
+	recovery := &domain.SubmissionRecovery{SubmissionID: stuck.ID, Action: domain.RecoveryRequeued, Attempt: 1, Reason: "lost"}
+	assert.NoError(t, repo.RecordRecovery(recovery))
+	assert.NotZero(t, recovery.ID)
+	list, err = repo.ListStuck(cutoff, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	assert.NoError(t, repo.RecordRecovery(&domain.SubmissionRecovery{SubmissionID: stuck.ID, Action: domain.RecoveryFailed, Attempt: 1}))
+	recoveries, err := repo.ListRecoveries(stuck.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, recoveries, 2) {
+		assert.Equal(t, domain.RecoveryRequeued, recoveries[0].Action)
+		assert.Equal(t, "lost", recoveries[0].Reason)
+		assert.Equal(t, domain.RecoveryFailed, recoveries[1].Action)
+	}
+}
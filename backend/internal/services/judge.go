@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+)
+
+// JudgeQueue 判题队列接口
+type JudgeQueue interface {
+	// 推送判题请求
+	Push(ctx context.Context, request *protocol.JudgeRequest) error
+}
+
+// JudgeEventSource 判题进度事件来源，判题队列实现该接口时支持订阅提交的判题进度
+type JudgeEventSource interface {
+	// 先补发 afterID 之后的事件，再推送新事件，收到 finished 事件后关闭通道
+	SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error)
+	// 返回 afterID 之后已记录的事件
+	ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error)
+}
+
+// JudgeQueueInspector 判题队列查询，判题队列实现该接口时支持查询排队位置和队列概况
+type JudgeQueueInspector interface {
+	// 返回等待中的任务所在通道和位置（从1开始），任务不在队列中时位置为0
+	QueuePosition(ctx context.Context, submissionID, language string) (string, int64, error)
+	// 返回各通道的任务数、存活节点和最近的判题耗时
+	QueueStats(ctx context.Context) (*domain.QueueStats, error)
+}
+
+// JudgeJobTracker 判题任务跟踪，判题队列实现该接口时看门狗能区分仍在进行的任务和丢失的任务
+type JudgeJobTracker interface {
+	// 任务仍在等待，或已被取出且 since 之后有判题进度时返回 true
+	JobActive(ctx context.Context, submissionID, language string, since time.Time) (bool, error)
+	// 移除提交遗留在判题队列中的任务，重新入队前调用
+	DropJob(ctx context.Context, submissionID string) error
+}
+
+// JudgeCanceller 判题任务取消，判题队列实现该接口时支持取消等待中或正在进行的判题
+type JudgeCanceller interface {
+	// 等待中的任务从队列移除并返回 true，已被取出的任务通知判题服务终止并返回 false
+	Cancel(ctx context.Context, submissionID, language string) (bool, error)
+}
+
+// BuildJudgeRequest 根据提交、题目和测试用例构造判题请求
+// 测试用例的 Input 保存 testbench 代码，Output 保存期望的完整 VCD 或 VCD 匹配模式
+// 题目配置了等价性检查且有参考答案时附带等价性检查，配置了真值表时附带真值表，此时可以没有测试用例
+// 配置了检查程序时由检查程序判定测试用例的输出，Output 作为期望数据交给检查程序
+func BuildJudgeRequest(submission *domain.Submission, problem *domain.Problem, testCases []domain.TestCase) (*protocol.JudgeRequest, error) {
+	equivalence := equivalenceRequest(problem)
+	truthTable := truthTableRequest(problem.TruthTable)
+	if len(testCases) == 0 && equivalence == nil && truthTable == nil {
+		return nil, errors.New("题目没有测试用例")
+	}
+
+	cases := make([]protocol.TestCase, 0, len(testCases))
+	for i, tc := range testCases {
+		description := fmt.Sprintf("测试用例 #%d", i+1)
+		if tc.IsSample {
+			description = fmt.Sprintf("样例 #%d", i+1)
+		}
+		testCase := protocol.TestCase{
+			Testbench:   tc.Input,
+			ExpectedVCD: tc.Output,
+			Description: description,
+			SimTime:     tc.SimTime,
+			Clock:       tc.Clock,
+			Sample:      tc.IsSample,
+			Trace:       ioTraceRequest(tc.Trace),
+		}
+		if protocol.IsStdoutChecker(problem.Checker) {
+			// 比较标准输出的题目中，测试用例的 Output 是期望的标准输出
+			testCase.ExpectedVCD, testCase.ExpectedOutput = "", tc.Output
+		}
+		cases = append(cases, testCase)
+	}
+
+	return &protocol.JudgeRequest{
+		ProtocolVersion: protocol.Version,
+		SubmissionID:    strconv.FormatUint(uint64(submission.ID), 10),
+		Code:            submission.Code,
+		Language:        submission.Language,
+		TimeLimit:       problem.TimeLimit,
+		MemoryLimit:     problem.MemoryLimit,
+		TestCases:       cases,
+		TruthTable:      truthTable,
+		Equivalence:     equivalence,
+		Checker:         problem.Checker,
+		SpecialJudge:    specialJudgeRequest(problem.SpecialJudge),
+	}, nil
+}
+
+// equivalenceRequest 根据题目配置构造等价性检查，未配置或没有参考答案时返回 nil
+func equivalenceRequest(problem *domain.Problem) *protocol.Equivalence {
+	config := problem.Equivalence
+	if config == nil || problem.ReferenceCode == "" {
+		return nil
+	}
+	return &protocol.Equivalence{
+		Reference:      problem.ReferenceCode,
+		TopModule:      config.TopModule,
+		Seed:           config.Seed,
+		Cycles:         config.Cycles,
+		Clock:          config.Clock,
+		Reset:          config.Reset,
+		ResetActiveLow: config.ResetActiveLow,
+		ResetCycles:    config.ResetCycles,
+		Hold:           config.Hold,
+		ExhaustiveBits: config.ExhaustiveBits,
+		Formal:         config.Formal,
+		FormalTimeout:  config.FormalTimeout,
+		FormalDepth:    config.FormalDepth,
+	}
+}
+
+// truthTableRequest 将真值表测试转换为判题协议中的真值表，未配置时返回 nil
+func truthTableRequest(table *domain.TruthTable) *protocol.TruthTable {
+	if table == nil {
+		return nil
+	}
+	request := &protocol.TruthTable{TopModule: table.TopModule, Rows: table.Rows, Delay: table.Delay}
+	for _, port := range table.Ports {
+		request.Ports = append(request.Ports, protocol.Port(port))
+	}
+	return request
+}
+
+// specialJudgeRequest 将检查程序转换为判题协议中的检查程序，未配置时返回 nil
+func specialJudgeRequest(spj *domain.SpecialJudge) *protocol.SpecialJudge {
+	if spj == nil {
+		return nil
+	}
+	request := protocol.SpecialJudge(*spj)
+	return &request
+}
+
+// ValidateTruthTable 按判题服务的规则校验真值表：列必须是声明的端口，输入齐全且取值符合位宽
+func ValidateTruthTable(table *domain.TruthTable) error {
+	return truthTableRequest(table).Validate()
+}
+
+// ioTraceRequest 将 I/O 序列转换为判题协议中的 I/O 序列，未配置时返回 nil
+func ioTraceRequest(trace *domain.IOTrace) *protocol.IOTrace {
+	if trace == nil {
+		return nil
+	}
+	request := &protocol.IOTrace{
+		TopModule:      trace.TopModule,
+		Clock:          trace.Clock,
+		Reset:          trace.Reset,
+		ResetActiveLow: trace.ResetActiveLow,
+		ResetCycles:    trace.ResetCycles,
+		Period:         trace.Period,
+		SampleEdge:     trace.SampleEdge,
+		Cycles:         trace.Cycles,
+	}
+	for _, port := range trace.Ports {
+		request.Ports = append(request.Ports, protocol.Port(port))
+	}
+	return request
+}
+
+// ValidateIOTrace 按判题服务的规则校验 I/O 序列：时钟和复位是声明的 1 位输入，第一个周期给出全部其他输入
+func ValidateIOTrace(trace *domain.IOTrace) error {
+	return ioTraceRequest(trace).Validate()
+}
+
+// ParseJudgeSubmissionID 解析判题结果中的提交ID
+func ParseJudgeSubmissionID(submissionID string) (uint, error) {
+	id, err := strconv.ParseUint(submissionID, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("无效的提交ID %q", submissionID)
+	}
+	return uint(id), nil
+}
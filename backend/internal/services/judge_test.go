@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJudgeQueue Mock 判题队列
+type MockJudgeQueue struct {
+	mock.Mock
+}
+
+func (m *MockJudgeQueue) Push(ctx context.Context, request *protocol.JudgeRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+// TestBuildJudgeRequest 测试判题请求构造
+func TestBuildJudgeRequest(t *testing.T) {
+	submission := &domain.Submission{ID: 12, Code: "module m; endmodule", Language: "verilog"}
+	problem := &domain.Problem{ID: 3, TimeLimit: 1000, MemoryLimit: 128}
+
+	t.Run("字段映射", func(t *testing.T) {
+		testCases := []domain.TestCase{
+			{Input: "module tb; endmodule", Output: "#10", IsSample: true},
+			{Input: "module tb2; endmodule", Output: "#20", SimTime: 5000, Clock: "clock"},
+		}
+
+		request, err := BuildJudgeRequest(submission, problem, testCases)
+
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.Version, request.ProtocolVersion)
+		assert.Equal(t, "12", request.SubmissionID)
+		assert.Equal(t, 1000, request.TimeLimit)
+		assert.Len(t, request.TestCases, 2)
+		assert.Equal(t, "module tb; endmodule", request.TestCases[0].Testbench)
+		assert.Equal(t, "#10", request.TestCases[0].ExpectedVCD)
+		assert.Equal(t, "样例 #1", request.TestCases[0].Description)
+		assert.Equal(t, "测试用例 #2", request.TestCases[1].Description)
+		assert.Equal(t, 0, request.TestCases[0].SimTime)
+		assert.Equal(t, 5000, request.TestCases[1].SimTime)
+		assert.Equal(t, "clock", request.TestCases[1].Clock)
+		assert.True(t, request.TestCases[0].Sample)
+		assert.False(t, request.TestCases[1].Sample)
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("比较标准输出", func(t *testing.T) {
+		problem := &domain.Problem{ID: 8, TimeLimit: 1000, MemoryLimit: 128, Checker: protocol.CheckerNumeric}
+		testCases := []domain.TestCase{{Input: "module tb; endmodule", Output: "sum = 0x1f\n"}}
+
+		request, err := BuildJudgeRequest(submission, problem, testCases)
+
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.CheckerNumeric, request.Checker)
+		assert.Empty(t, request.TestCases[0].ExpectedVCD)
+		assert.Equal(t, "sum = 0x1f\n", request.TestCases[0].ExpectedOutput)
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("检查程序", func(t *testing.T) {
+		problem := &domain.Problem{
+			ID:           9,
+			TimeLimit:    1000,
+			MemoryLimit:  128,
+			SpecialJudge: &domain.SpecialJudge{Language: protocol.SpecialJudgeGo, Source: "package main\n\nfunc main() {}\n", TimeLimit: 2000},
+		}
+		testCases := []domain.TestCase{{Input: "module tb; endmodule", Output: "4\n"}}
+
+		request, err := BuildJudgeRequest(submission, problem, testCases)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &protocol.SpecialJudge{Language: "go", Source: "package main\n\nfunc main() {}\n", TimeLimit: 2000}, request.SpecialJudge)
+		assert.Equal(t, "4\n", request.TestCases[0].ExpectedVCD)
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("没有测试用例", func(t *testing.T) {
+		request, err := BuildJudgeRequest(submission, problem, nil)
+		assert.Nil(t, request)
+		assert.EqualError(t, err, "题目没有测试用例")
+	})
+
+	t.Run("只有等价性检查", func(t *testing.T) {
+		problem := &domain.Problem{
+			ID:            4,
+			TimeLimit:     1000,
+			MemoryLimit:   128,
+			ReferenceCode: "module m(input a, output y); assign y = a; endmodule",
+			Equivalence:   &domain.EquivalenceConfig{Seed: 7, Cycles: 200, Hold: map[string]uint64{"en": 1}, Formal: true, FormalDepth: 30},
+		}
+
+		request, err := BuildJudgeRequest(submission, problem, nil)
+
+		assert.NoError(t, err)
+		assert.Empty(t, request.TestCases)
+		assert.Equal(t, problem.ReferenceCode, request.Equivalence.Reference)
+		assert.Equal(t, 7, request.Equivalence.Seed)
+		assert.Equal(t, 200, request.Equivalence.Cycles)
+		assert.Equal(t, uint64(1), request.Equivalence.Hold["en"])
+		assert.True(t, request.Equivalence.Formal)
+		assert.Equal(t, 30, request.Equivalence.FormalDepth)
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("只有真值表", func(t *testing.T) {
+		problem := &domain.Problem{
+			ID:          6,
+			TimeLimit:   1000,
+			MemoryLimit: 128,
+			TruthTable: &domain.TruthTable{
+				TopModule: "half_adder",
+				Ports:     []domain.Port{{Name: "a", Direction: "input"}, {Name: "b", Direction: "input"}, {Name: "s", Direction: "output"}},
+				Rows:      []map[string]string{{"a": "0", "b": "1", "s": "1"}, {"a": "1", "b": "1", "s": "0"}},
+			},
+		}
+
+		request, err := BuildJudgeRequest(submission, problem, nil)
+
+		assert.NoError(t, err)
+		assert.Empty(t, request.TestCases)
+		assert.Nil(t, request.Equivalence)
+		assert.Equal(t, "half_adder", request.TruthTable.TopModule)
+		assert.Equal(t, protocol.Port{Name: "s", Direction: "output"}, request.TruthTable.Ports[2])
+		assert.Len(t, request.TruthTable.Rows, 2)
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("I/O 序列测试用例", func(t *testing.T) {
+		problem := &domain.Problem{ID: 7, TimeLimit: 1000, MemoryLimit: 128}
+		testCases := []domain.TestCase{{
+			Trace: &domain.IOTrace{
+				TopModule: "toggle",
+				Ports:     []domain.Port{{Name: "clk", Direction: "input"}, {Name: "en", Direction: "input"}, {Name: "q", Direction: "output"}},
+				Clock:     "clk",
+				Cycles:    []map[string]string{{"en": "1", "q": "1"}, {"q": "0"}},
+			},
+		}}
+
+		request, err := BuildJudgeRequest(submission, problem, testCases)
+
+		assert.NoError(t, err)
+		assert.Len(t, request.TestCases, 1)
+		assert.Empty(t, request.TestCases[0].Testbench)
+		assert.Equal(t, "toggle", request.TestCases[0].Trace.TopModule)
+		assert.Equal(t, protocol.Port{Name: "q", Direction: "output"}, request.TestCases[0].Trace.Ports[2])
+
+		_, err = protocol.EncodeRequest(request)
+		assert.NoError(t, err)
+	})
+
+	t.Run("没有参考答案时忽略等价性检查", func(t *testing.T) {
+		problem := &domain.Problem{ID: 5, Equivalence: &domain.EquivalenceConfig{Cycles: 10}}
+
+		request, err := BuildJudgeRequest(submission, problem, nil)
+		assert.Nil(t, request)
+		assert.EqualError(t, err, "题目没有测试用例")
+	})
+}
+
+// TestSubmissionService_CreateSubmissionDispatch 测试提交后推送判题任务
+func TestSubmissionService_CreateSubmissionDispatch(t *testing.T) {
+	user := &domain.User{ID: 1, Solved: 0, Submitted: 0}
+	problem := &domain.Problem{ID: 2, IsPublic: true, TimeLimit: 1000, MemoryLimit: 128}
+
+	tests := []struct {
+		name           string
+		testCases      []domain.TestCase
+		pushError      error
+		expectedStatus string
+	}{
+		{
+			name:           "推送成功",
+			testCases:      []domain.TestCase{{Input: "module tb; endmodule", Output: "1"}},
+			expectedStatus: "pending",
+		},
+		{
+			name:           "题目没有测试用例",
+			expectedStatus: "system_error",
+		},
+		{
+			name:           "推送失败",
+			testCases:      []domain.TestCase{{Input: "module tb; endmodule", Output: "1"}},
+			pushError:      errors.New("queue unavailable"),
+			expectedStatus: "system_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSubmissionRepo := new(MockSubmissionRepository)
+			mockProblemRepo := new(MockProblemRepository)
+			mockUserRepo := new(MockUserRepository)
+			mockQueue := new(MockJudgeQueue)
+
+			mockUserRepo.On("GetByID", user.ID).Return(user, nil)
+			mockUserRepo.On("UpdateStats", user.ID, 0, 1).Return(nil)
+			mockProblemRepo.On("GetByID", problem.ID).Return(problem, nil)
+			mockProblemRepo.On("UpdateSubmitCount", problem.ID, 1).Return(nil)
+			mockProblemRepo.On("GetTestCases", problem.ID).Return(tt.testCases, nil)
+			mockSubmissionRepo.On("Create", mock.AnythingOfType("*domain.Submission")).Return(nil)
+			if len(tt.testCases) > 0 {
+				mockQueue.On("Push", mock.AnythingOfType("*protocol.JudgeRequest")).Return(tt.pushError)
+			}
+			if tt.expectedStatus == "system_error" {
+				mockSubmissionRepo.On("UpdateStatus", uint(0), "system_error", 0, 0, 0, mock.AnythingOfType("string"), 0, 0).Return(nil)
+			}
+
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, mockQueue)
+			submission, err := service.CreateSubmission(problem.ID, "module m; endmodule", "verilog", user.ID)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, submission.Status)
+			mockQueue.AssertExpectations(t)
+			mockSubmissionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// TestSubmissionService_ApplyJudgeResult 测试写回判题结果
+func TestSubmissionService_ApplyJudgeResult(t *testing.T) {
+	t.Run("写回结果", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockSubmissionRepo.On("FinishJudging", uint(7), "wrong_answer", 50, 20, 1024, "mismatch", 1, 2).Return(true, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), nil)
+		err := service.ApplyJudgeResult(&protocol.JudgeResult{
+			SubmissionID: "7",
+			Status:       "wrong_answer",
+			Score:        50,
+			RunTime:      20,
+			Memory:       1024,
+			ErrorMessage: "mismatch",
+			PassedTests:  1,
+			TotalTests:   2,
+		})
+
+		assert.NoError(t, err)
+		mockSubmissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("通过统计由仓储在同一个事务中更新", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockProblemRepo := new(MockProblemRepository)
+		mockUserRepo := new(MockUserRepository)
+		mockSubmissionRepo.On("FinishJudging", uint(7), "accepted", 100, 20, 1024, "", 2, 2).Return(true, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
+		err := service.ApplyJudgeResult(&protocol.JudgeResult{
+			SubmissionID: "7",
+			Status:       "accepted",
+			Score:        100,
+			RunTime:      20,
+			Memory:       1024,
+			PassedTests:  2,
+			TotalTests:   2,
+		})
+
+		assert.NoError(t, err)
+		mockSubmissionRepo.AssertExpectations(t)
+		mockProblemRepo.AssertExpectations(t)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("重复投递的结果不重复计数", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockProblemRepo := new(MockProblemRepository)
+		mockUserRepo := new(MockUserRepository)
+		mockSubmissionRepo.On("FinishJudging", uint(7), "accepted", 100, 20, 1024, "", 2, 2).Return(false, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
+		err := service.ApplyJudgeResult(&protocol.JudgeResult{
+			SubmissionID: "7",
+			Status:       "accepted",
+			Score:        100,
+			RunTime:      20,
+			Memory:       1024,
+			PassedTests:  2,
+			TotalTests:   2,
+		})
+
+		assert.NoError(t, err)
+		mockSubmissionRepo.AssertExpectations(t)
+		mockProblemRepo.AssertNotCalled(t, "UpdateAcceptedCount", mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "UpdateStats", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("写回失败时返回错误以便重新投递", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockSubmissionRepo.On("FinishJudging", uint(7), "accepted", 100, 20, 1024, "", 2, 2).Return(false, errors.New("update accepted_count failed"))
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), nil)
+		err := service.ApplyJudgeResult(&protocol.JudgeResult{
+			SubmissionID: "7",
+			Status:       "accepted",
+			Score:        100,
+			RunTime:      20,
+			Memory:       1024,
+			PassedTests:  2,
+			TotalTests:   2,
+		})
+
+		assert.EqualError(t, err, "update accepted_count failed")
+		mockSubmissionRepo.AssertExpectations(t)
+	})
+
+	t.Run("无效的提交ID", func(t *testing.T) {
+		service := NewSubmissionService(new(MockSubmissionRepository), new(MockProblemRepository), new(MockUserRepository), nil)
+		err := service.ApplyJudgeResult(&protocol.JudgeResult{SubmissionID: "abc"})
+		assert.Error(t, err)
+	})
+}
+
+// TestValidateTruthTable 测试按端口声明校验真值表
+func TestValidateIOTrace(t *testing.T) {
+	ports := []domain.Port{{Name: "clk", Direction: "input"}, {Name: "d", Direction: "input"}, {Name: "q", Direction: "output"}}
+
+	tests := []struct {
+		name    string
+		clock   string
+		cycles  []map[string]string
+		wantErr string
+	}{
+		{name: "合法", clock: "clk", cycles: []map[string]string{{"d": "1"}, {"d": "0", "q": "1"}}},
+		{name: "时钟是输出", clock: "q", cycles: []map[string]string{{"d": "1"}}, wantErr: `clock "q" must be a declared 1-bit input`},
+		{name: "周期中设置时钟", clock: "clk", cycles: []map[string]string{{"d": "1", "clk": "1"}}, wantErr: "cycle 1: clock clk is driven by the testbench and must not be set"},
+		{name: "第一个周期缺少输入", clock: "clk", cycles: []map[string]string{{"q": "0"}}, wantErr: "cycle 1: missing input d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIOTrace(&domain.IOTrace{TopModule: "dff", Ports: ports, Clock: tt.clock, Cycles: tt.cycles})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTruthTable(t *testing.T) {
+	ports := []domain.Port{{Name: "a", Direction: "input", Width: 2}, {Name: "y", Direction: "output"}}
+
+	tests := []struct {
+		name    string
+		rows    []map[string]string
+		wantErr string
+	}{
+		{name: "合法", rows: []map[string]string{{"a": "3", "y": "1"}, {"a": "0b01", "y": "-"}}},
+		{name: "未声明的列", rows: []map[string]string{{"a": "1", "z": "0"}}, wantErr: "row 1: column z is not a declared port"},
+		{name: "缺少输入", rows: []map[string]string{{"y": "0"}}, wantErr: "row 1: missing input a"},
+		{name: "超出位宽", rows: []map[string]string{{"a": "4", "y": "0"}}, wantErr: "row 1: a: value 4 does not fit in 2 bit(s)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTruthTable(&domain.TruthTable{TopModule: "m", Ports: ports, Rows: tt.rows})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"log"
 	"verilog-oj/backend/internal/domain"
 )
 
@@ -27,17 +28,36 @@ type ProblemRepository interface {
 	CreateTestCase(testCase *domain.TestCase) error
 	// 删除题目的所有测试用例
 	DeleteTestCases(problemID uint) error
+	// 创建题目附件
+	CreateAttachment(attachment *domain.ProblemAttachment) error
+	// 获取题目的附件
+	GetAttachments(problemID uint) ([]domain.ProblemAttachment, error)
+	// 删除题目的所有附件
+	DeleteAttachments(problemID uint) error
+	// 更新参考答案校验结果
+	UpdateValidation(problemID uint, validation *domain.ProblemValidation) error
+	// 保存期望输出生成任务，替换题目之前的生成任务
+	SaveOutputGeneration(generation *domain.OutputGeneration) error
+	// 获取题目最近一次期望输出生成任务，没有时返回 nil
+	GetOutputGeneration(problemID uint) (*domain.OutputGeneration, error)
+	// 写回期望输出生成结果，生成任务已被替换时返回 false
+	UpdateOutputGeneration(generation *domain.OutputGeneration) (bool, error)
+	// 将生成的期望输出写入测试用例和真值表，并删除生成任务
+	ApplyGeneratedOutputs(problemID uint, testCases []domain.TestCase, truthTable *domain.TruthTable) error
 }
 
 // ProblemService 题目服务
 type ProblemService struct {
 	problemRepo ProblemRepository
+	judgeQueue  JudgeQueue
 }
 
 // NewProblemService 创建题目服务
-func NewProblemService(problemRepo ProblemRepository) *ProblemService {
+// judgeQueue 为 nil 时不校验参考答案
+func NewProblemService(problemRepo ProblemRepository, judgeQueue JudgeQueue) *ProblemService {
 	return &ProblemService{
 		problemRepo: problemRepo,
+		judgeQueue:  judgeQueue,
 	}
 }
 
@@ -109,10 +129,13 @@ func (s *ProblemService) DeleteProblem(id uint) error {
 		return errors.New("题目不存在")
 	}
 
-	// 删除相关的测试用例
+	// 删除相关的测试用例和附件
 	if err := s.problemRepo.DeleteTestCases(id); err != nil {
 		return err
 	}
+	if err := s.problemRepo.DeleteAttachments(id); err != nil {
+		return err
+	}
 
 	// 删除题目
 	return s.problemRepo.Delete(id)
@@ -134,16 +157,24 @@ func (s *ProblemService) GetTestCases(problemID uint) ([]domain.TestCase, error)
 
 // AddTestCase 添加测试用例
 func (s *ProblemService) AddTestCase(testCase *domain.TestCase) error {
+	return s.AddTestCases(testCase.ProblemID, []*domain.TestCase{testCase})
+}
+
+// AddTestCases 批量添加测试用例，添加后使用参考答案重新校验
+// 校验任务创建失败不影响测试用例的保存
+func (s *ProblemService) AddTestCases(problemID uint, testCases []*domain.TestCase) error {
 	// 验证测试用例
-	if testCase.ProblemID == 0 {
+	if problemID == 0 {
 		return errors.New("题目ID不能为空")
 	}
-	if testCase.Input == "" && testCase.Output == "" {
-		return errors.New("测试用例输入和输出不能同时为空")
+	for _, testCase := range testCases {
+		if testCase.Input == "" && testCase.Output == "" {
+			return errors.New("测试用例输入和输出不能同时为空")
+		}
 	}
 
 	// 检查题目是否存在
-	problem, err := s.problemRepo.GetByID(testCase.ProblemID)
+	problem, err := s.problemRepo.GetByID(problemID)
 	if err != nil {
 		return err
 	}
@@ -151,7 +182,17 @@ func (s *ProblemService) AddTestCase(testCase *domain.TestCase) error {
 		return errors.New("题目不存在")
 	}
 
-	return s.problemRepo.CreateTestCase(testCase)
+	for _, testCase := range testCases {
+		testCase.ProblemID = problemID
+		if err := s.problemRepo.CreateTestCase(testCase); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.startValidation(problem); err != nil {
+		log.Printf("failed to start validation for problem %d: %v", problemID, err)
+	}
+	return nil
 }
 
 // UpdateProblemStats 更新题目统计信息
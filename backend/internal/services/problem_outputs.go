@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+)
+
+// outputsJudgePrefix 期望输出生成任务的提交ID前缀，判题结果据此分发给题目服务
+const outputsJudgePrefix = "outputs-"
+
+// 期望输出生成的错误
+var (
+	ErrCannotGenerateOutputs    = errors.New("无法生成期望输出")
+	ErrOutputGenerationNotFound = errors.New("题目没有生成的期望输出")
+	ErrOutputGenerationNotReady = errors.New("期望输出尚未生成成功，不能确认")
+	ErrOutputGenerationStale    = errors.New("生成后参考答案、测试用例或判题配置发生了变化，请重新生成")
+)
+
+// OutputGenerationJudgeID 构造期望输出生成任务的提交ID，格式为 outputs-<题目ID>-<生成任务标识>
+func OutputGenerationJudgeID(problemID uint, runID string) string {
+	return fmt.Sprintf("%s%d-%s", outputsJudgePrefix, problemID, runID)
+}
+
+// IsOutputGenerationJudgeID 判断提交ID是否属于期望输出生成任务
+func IsOutputGenerationJudgeID(submissionID string) bool {
+	return strings.HasPrefix(submissionID, outputsJudgePrefix)
+}
+
+// ParseOutputGenerationJudgeID 解析期望输出生成任务的提交ID
+func ParseOutputGenerationJudgeID(submissionID string) (uint, string, error) {
+	return parseProblemJudgeID(outputsJudgePrefix, submissionID)
+}
+
+// OutputPreview 生成的期望输出与测试用例当前期望输出的对比
+type OutputPreview struct {
+	Generation *domain.OutputGeneration
+	Stale      bool           // 生成后判题数据发生了变化，不能确认
+	Changes    []OutputChange // 生成成功后每个测试用例和真值表的对比
+}
+
+// OutputChange 单个测试用例或真值表的期望输出对比
+// testbench 测试用例逐行比较，忽略VCD中的 $date 和 $version；I/O 序列按周期、真值表按行比较输出端口
+type OutputChange struct {
+	Index        int  // 从1开始，真值表排在测试用例之后
+	TestCaseID   uint // 真值表为 0
+	Description  string
+	Changed      bool
+	ChangedLines int    // 不同的行数（周期数、真值表行数）
+	FirstLine    int    // 第一处不同的行号（周期、真值表行），从1开始
+	Previous     string // 第一处不同的位置原来的内容
+	Generated    string // 第一处不同的位置生成的内容
+}
+
+// GenerateExpectedOutputs 运行参考答案生成测试用例和真值表的期望输出
+// 判题任务异步执行，结果保存为待确认的生成任务，之前的生成任务被替换
+func (s *ProblemService) GenerateExpectedOutputs(id uint) (*domain.OutputGeneration, error) {
+	problem, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case problem.ReferenceCode == "":
+		return nil, fmt.Errorf("%w：题目没有参考答案", ErrCannotGenerateOutputs)
+	case s.judgeQueue == nil:
+		return nil, fmt.Errorf("%w：判题队列未启用", ErrCannotGenerateOutputs)
+	}
+
+	testCases, err := s.problemRepo.GetTestCases(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(testCases) == 0 && problem.TruthTable == nil {
+		return nil, fmt.Errorf("%w：题目没有测试用例或真值表", ErrCannotGenerateOutputs)
+	}
+	request, fingerprint, err := outputGenerationRequest(problem, testCases)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%v", ErrCannotGenerateOutputs, err)
+	}
+
+	generation := &domain.OutputGeneration{
+		ProblemID:   id,
+		RunID:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		Fingerprint: fingerprint,
+		Status:      domain.OutputGenerationPending,
+	}
+	for _, tc := range testCases {
+		generation.Cases = append(generation.Cases, domain.GeneratedOutput{TestCaseID: tc.ID})
+	}
+	request.SubmissionID = OutputGenerationJudgeID(id, generation.RunID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.judgeQueue.Push(ctx, request); err != nil {
+		return nil, fmt.Errorf("期望输出生成任务创建失败：%v", err)
+	}
+	if err := s.problemRepo.SaveOutputGeneration(generation); err != nil {
+		return nil, err
+	}
+	return generation, nil
+}
+
+// outputGenerationRequest 以参考答案构造生成期望输出的判题请求，并返回不含提交ID的请求摘要
+func outputGenerationRequest(problem *domain.Problem, testCases []domain.TestCase) (*protocol.JudgeRequest, string, error) {
+	reference := &domain.Submission{Code: problem.ReferenceCode, Language: "verilog"}
+	request, err := BuildJudgeRequest(reference, problem, testCases)
+	if err != nil {
+		return nil, "", err
+	}
+	request.CaptureOutputs = true
+	request.Equivalence = nil
+	request.SubmissionID = ""
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return request, hex.EncodeToString(sum[:]), nil
+}
+
+// ApplyOutputGenerationResult 保存期望输出生成任务的判题结果，过期的结果会被丢弃
+func (s *ProblemService) ApplyOutputGenerationResult(result *protocol.JudgeResult) error {
+	problemID, runID, err := ParseOutputGenerationJudgeID(result.SubmissionID)
+	if err != nil {
+		return err
+	}
+
+	generation, err := s.problemRepo.GetOutputGeneration(problemID)
+	if err != nil {
+		return err
+	}
+	if generation == nil || generation.RunID != runID {
+		log.Printf("discarding stale output generation result %s for problem %d", result.SubmissionID, problemID)
+		return nil
+	}
+
+	updated, err := s.problemRepo.UpdateOutputGeneration(outputGenerationFromResult(generation, result))
+	if err != nil {
+		return err
+	}
+	if !updated {
+		log.Printf("discarding stale output generation result %s for problem %d", result.SubmissionID, problemID)
+	}
+	return nil
+}
+
+// outputGenerationFromResult 根据判题结果填写生成任务，参考答案未通过全部测试用例时不保留输出
+func outputGenerationFromResult(generation *domain.OutputGeneration, result *protocol.JudgeResult) *domain.OutputGeneration {
+	judgedAt := result.JudgedAt
+	generation.FinishedAt = &judgedAt
+	generation.Status = domain.OutputGenerationFailed
+
+	switch result.Status {
+	case protocol.StatusAccepted:
+	case protocol.StatusCompileError:
+		generation.Message = "参考答案编译失败：" + result.ErrorMessage
+		return generation
+	case protocol.StatusSystemError:
+		generation.Message = "生成失败：" + result.ErrorMessage
+		return generation
+	default:
+		generation.Message = fmt.Sprintf("参考答案运行 %d/%d 个测试用例：%s", result.PassedTests, result.TotalTests, result.ErrorMessage)
+		return generation
+	}
+
+	captured := make(map[int]*protocol.CapturedOutput, len(result.Cases))
+	for _, c := range result.Cases {
+		captured[c.Index] = c.Captured
+	}
+	for i := range generation.Cases {
+		output := captured[i+1]
+		if output == nil {
+			generation.Message = fmt.Sprintf("判题服务没有返回测试用例 #%d 的输出", i+1)
+			return generation
+		}
+		generation.Cases[i].Output = output.Expected
+		generation.Cases[i].Trace = output.Rows
+	}
+	if output, ok := captured[len(generation.Cases)+1]; ok {
+		if output == nil {
+			generation.Message = "判题服务没有返回真值表的输出"
+			return generation
+		}
+		generation.TruthTable = output.Rows
+	}
+	generation.Status = domain.OutputGenerationReady
+	return generation
+}
+
+// GetOutputPreview 获取最近一次生成的期望输出及其与当前期望输出的对比
+func (s *ProblemService) GetOutputPreview(id uint) (*OutputPreview, error) {
+	problem, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+	generation, err := s.problemRepo.GetOutputGeneration(id)
+	if err != nil {
+		return nil, err
+	}
+	if generation == nil {
+		return nil, ErrOutputGenerationNotFound
+	}
+	testCases, err := s.problemRepo.GetTestCases(id)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &OutputPreview{Generation: generation, Stale: generationStale(generation, problem, testCases)}
+	if generation.Status != domain.OutputGenerationReady || preview.Stale {
+		return preview, nil
+	}
+	for i, tc := range testCases {
+		change := OutputChange{Index: i + 1, TestCaseID: tc.ID, Description: fmt.Sprintf("测试用例 #%d", i+1)}
+		if tc.IsSample {
+			change.Description = fmt.Sprintf("样例 #%d", i+1)
+		}
+		generated := generation.Cases[i]
+		if tc.Trace != nil {
+			compareLines(&change, outputRows(tc.Trace.Ports, tc.Trace.Cycles), outputRows(tc.Trace.Ports, generated.Trace))
+		} else {
+			compareLines(&change, outputLines(tc.Output), outputLines(generated.Output))
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+	if problem.TruthTable != nil {
+		change := OutputChange{Index: len(testCases) + 1, Description: "真值表"}
+		compareLines(&change, outputRows(problem.TruthTable.Ports, problem.TruthTable.Rows), outputRows(problem.TruthTable.Ports, generation.TruthTable))
+		preview.Changes = append(preview.Changes, change)
+	}
+	return preview, nil
+}
+
+// ApplyGeneratedOutputs 将生成的期望输出写入测试用例和真值表，然后使用参考答案重新校验
+func (s *ProblemService) ApplyGeneratedOutputs(id uint) (*domain.ProblemValidation, error) {
+	problem, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+	generation, err := s.problemRepo.GetOutputGeneration(id)
+	if err != nil {
+		return nil, err
+	}
+	if generation == nil {
+		return nil, ErrOutputGenerationNotFound
+	}
+	if generation.Status != domain.OutputGenerationReady {
+		return nil, ErrOutputGenerationNotReady
+	}
+	testCases, err := s.problemRepo.GetTestCases(id)
+	if err != nil {
+		return nil, err
+	}
+	if generationStale(generation, problem, testCases) {
+		return nil, ErrOutputGenerationStale
+	}
+
+	for i := range testCases {
+		generated := generation.Cases[i]
+		if testCases[i].Trace == nil {
+			testCases[i].Output = generated.Output
+			continue
+		}
+		trace, err := ioTraceRequest(testCases[i].Trace).WithOutputs(generated.Trace)
+		if err != nil {
+			return nil, fmt.Errorf("测试用例 #%d 的输出无法写入 I/O 序列：%v", i+1, err)
+		}
+		testCases[i].Trace = ioTraceDomain(trace)
+	}
+	var truthTable *domain.TruthTable
+	if problem.TruthTable != nil {
+		table, err := truthTableRequest(problem.TruthTable).WithOutputs(generation.TruthTable)
+		if err != nil {
+			return nil, fmt.Errorf("输出无法写入真值表：%v", err)
+		}
+		truthTable = &domain.TruthTable{TopModule: problem.TruthTable.TopModule, Ports: problem.TruthTable.Ports, Rows: table.Rows, Delay: problem.TruthTable.Delay}
+		problem.TruthTable = truthTable
+	}
+
+	if err := s.problemRepo.ApplyGeneratedOutputs(id, testCases, truthTable); err != nil {
+		return nil, err
+	}
+	return s.startValidation(problem)
+}
+
+// generationStale 判断生成任务是否与题目当前的测试用例和判题配置不一致
+func generationStale(generation *domain.OutputGeneration, problem *domain.Problem, testCases []domain.TestCase) bool {
+	if len(generation.Cases) != len(testCases) {
+		return true
+	}
+	for i, tc := range testCases {
+		if generation.Cases[i].TestCaseID != tc.ID {
+			return true
+		}
+	}
+	_, fingerprint, err := outputGenerationRequest(problem, testCases)
+	return err != nil || fingerprint != generation.Fingerprint
+}
+
+// outputLine 参与比较的一行，Number 为原文中的行号
+type outputLine struct {
+	Number int
+	Text   string
+}
+
+// outputLines 将 testbench 测试用例的期望数据按行拆分，跳过VCD头部中每次仿真都会变化的 $date 和 $version
+func outputLines(text string) []outputLine {
+	var lines []outputLine
+	skipping := false
+	for i, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "$date") || strings.HasPrefix(trimmed, "$version") {
+			skipping = true
+		}
+		if skipping {
+			skipping = !strings.Contains(trimmed, "$end")
+			continue
+		}
+		lines = append(lines, outputLine{Number: i + 1, Text: strings.TrimRight(line, "\r")})
+	}
+	return lines
+}
+
+// outputRows 将 I/O 序列的周期或真值表的行格式化为按端口名排序的输出取值，如 "q=0b01 valid=0b1"
+// 取值统一转换为二进制，不检查的位为 x，写法不同但含义相同的取值视为相同
+func outputRows(ports []domain.Port, rows []map[string]string) []outputLine {
+	widths := make(map[string]int, len(ports))
+	for _, port := range ports {
+		if port.Direction == protocol.PortOutput {
+			widths[port.Name] = protocol.Port(port).PortWidth()
+		}
+	}
+	lines := make([]outputLine, len(rows))
+	for i, row := range rows {
+		var values []string
+		for name, value := range row {
+			width, ok := widths[name]
+			if !ok {
+				continue
+			}
+			if bits, err := protocol.TruthTableBits(value, width, true); err == nil {
+				value = protocol.CapturedValue(bits)
+			}
+			values = append(values, name+"="+value)
+		}
+		sort.Strings(values)
+		lines[i] = outputLine{Number: i + 1, Text: strings.Join(values, " ")}
+	}
+	return lines
+}
+
+// compareLines 逐行比较原来和生成的内容，结果写入 change
+func compareLines(change *OutputChange, previous, generated []outputLine) {
+	for i := 0; i < len(previous) || i < len(generated); i++ {
+		var prev, gen *outputLine
+		if i < len(previous) {
+			prev = &previous[i]
+		}
+		if i < len(generated) {
+			gen = &generated[i]
+		}
+		if prev != nil && gen != nil && prev.Text == gen.Text {
+			continue
+		}
+
+		change.ChangedLines++
+		if change.Changed {
+			continue
+		}
+		change.Changed = true
+		if gen != nil {
+			change.FirstLine, change.Generated = gen.Number, gen.Text
+		}
+		if prev != nil {
+			change.FirstLine, change.Previous = prev.Number, prev.Text
+		}
+	}
+}
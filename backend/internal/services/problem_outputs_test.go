@@ -0,0 +1,326 @@
+package services
+
+import (
+	"testing"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// outputsTestProblem 带 testbench 测试用例、I/O 序列测试用例和真值表的题目
+func outputsTestProblem() (*domain.Problem, []domain.TestCase) {
+	ports := []domain.Port{{Name: "clk", Direction: "input"}, {Name: "d", Direction: "input"}, {Name: "q", Direction: "output"}}
+	problem := &domain.Problem{
+		ID:            5,
+		ReferenceCode: "module dff; endmodule",
+		TimeLimit:     1000,
+		MemoryLimit:   128,
+		Checker:       protocol.CheckerExact,
+		TruthTable: &domain.TruthTable{
+			TopModule: "inv",
+			Ports:     []domain.Port{{Name: "a", Direction: "input"}, {Name: "y", Direction: "output"}},
+			Rows:      []map[string]string{{"a": "0"}, {"a": "1", "y": "1"}},
+		},
+	}
+	testCases := []domain.TestCase{
+		{ID: 11, ProblemID: 5, Input: "module tb; endmodule", Output: "q=0\nq=1\n"},
+		{ID: 12, ProblemID: 5, Trace: &domain.IOTrace{
+			TopModule: "dff",
+			Ports:     ports,
+			Clock:     "clk",
+			Cycles:    []map[string]string{{"d": "1"}, {"d": "0", "q": "1"}},
+		}},
+	}
+	return problem, testCases
+}
+
+// TestOutputGenerationJudgeID 测试期望输出生成任务ID的构造和解析
+func TestOutputGenerationJudgeID(t *testing.T) {
+	id := OutputGenerationJudgeID(12, "abc")
+	assert.Equal(t, "outputs-12-abc", id)
+	assert.True(t, IsOutputGenerationJudgeID(id))
+	assert.False(t, IsOutputGenerationJudgeID(ValidationJudgeID(12, "abc")))
+
+	problemID, runID, err := ParseOutputGenerationJudgeID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), problemID)
+	assert.Equal(t, "abc", runID)
+
+	_, _, err = ParseOutputGenerationJudgeID("outputs-12")
+	assert.Error(t, err)
+}
+
+// TestProblemService_GenerateExpectedOutputs 测试推送期望输出生成任务
+func TestProblemService_GenerateExpectedOutputs(t *testing.T) {
+	t.Run("推送生成任务", func(t *testing.T) {
+		problem, testCases := outputsTestProblem()
+		problem.Equivalence = &domain.EquivalenceConfig{Cycles: 10}
+		mockRepo := new(MockProblemRepository)
+		mockQueue := new(MockJudgeQueue)
+		mockRepo.On("GetByID", uint(5)).Return(problem, nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil)
+		mockQueue.On("Push", mock.MatchedBy(func(r *protocol.JudgeRequest) bool {
+			return IsOutputGenerationJudgeID(r.SubmissionID) && r.CaptureOutputs && r.Equivalence == nil &&
+				r.Code == problem.ReferenceCode && len(r.TestCases) == 2 && r.TruthTable != nil
+		})).Return(nil)
+		mockRepo.On("SaveOutputGeneration", mock.MatchedBy(func(g *domain.OutputGeneration) bool {
+			return g.Status == domain.OutputGenerationPending && g.RunID != "" && g.Fingerprint != "" &&
+				len(g.Cases) == 2 && g.Cases[1].TestCaseID == 12
+		})).Return(nil)
+
+		service := NewProblemService(mockRepo, mockQueue)
+		generation, err := service.GenerateExpectedOutputs(5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OutputGenerationPending, generation.Status)
+		mockRepo.AssertExpectations(t)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("没有参考答案", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(2)).Return(&domain.Problem{ID: 2}, nil)
+
+		service := NewProblemService(mockRepo, new(MockJudgeQueue))
+		_, err := service.GenerateExpectedOutputs(2)
+
+		assert.ErrorIs(t, err, ErrCannotGenerateOutputs)
+		assert.Contains(t, err.Error(), "题目没有参考答案")
+	})
+
+	t.Run("没有测试用例", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(2)).Return(&domain.Problem{ID: 2, ReferenceCode: "module m; endmodule"}, nil)
+		mockRepo.On("GetTestCases", uint(2)).Return([]domain.TestCase{}, nil)
+
+		service := NewProblemService(mockRepo, new(MockJudgeQueue))
+		_, err := service.GenerateExpectedOutputs(2)
+
+		assert.ErrorIs(t, err, ErrCannotGenerateOutputs)
+	})
+}
+
+// TestProblemService_ApplyOutputGenerationResult 测试保存期望输出生成结果
+func TestProblemService_ApplyOutputGenerationResult(t *testing.T) {
+	pending := func() *domain.OutputGeneration {
+		return &domain.OutputGeneration{
+			ProblemID: 5,
+			RunID:     "run2",
+			Status:    domain.OutputGenerationPending,
+			Cases:     []domain.GeneratedOutput{{TestCaseID: 11}, {TestCaseID: 12}},
+		}
+	}
+
+	t.Run("生成成功", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(pending(), nil)
+		mockRepo.On("UpdateOutputGeneration", mock.MatchedBy(func(g *domain.OutputGeneration) bool {
+			return g.Status == domain.OutputGenerationReady && g.FinishedAt != nil &&
+				g.Cases[0].Output == "q=1\n" && g.Cases[1].Trace[1]["q"] == "0b1" && g.TruthTable[0]["y"] == "0b1"
+		})).Return(true, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyOutputGenerationResult(&protocol.JudgeResult{
+			SubmissionID: OutputGenerationJudgeID(5, "run2"),
+			Status:       protocol.StatusAccepted,
+			Cases: []protocol.CaseResult{
+				{Index: 1, Status: protocol.StatusAccepted, Captured: &protocol.CapturedOutput{Expected: "q=1\n"}},
+				{Index: 2, Status: protocol.StatusAccepted, Captured: &protocol.CapturedOutput{Rows: []map[string]string{{"q": "0b0"}, {"q": "0b1"}}}},
+				{Index: 3, Status: protocol.StatusAccepted, Captured: &protocol.CapturedOutput{Rows: []map[string]string{{"y": "0b1"}, {"y": "0b0"}}}},
+			},
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("参考答案运行失败", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(pending(), nil)
+		mockRepo.On("UpdateOutputGeneration", mock.MatchedBy(func(g *domain.OutputGeneration) bool {
+			return g.Status == domain.OutputGenerationFailed && g.Message == "参考答案编译失败：syntax error" && g.Cases[0].Output == ""
+		})).Return(true, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyOutputGenerationResult(&protocol.JudgeResult{
+			SubmissionID: OutputGenerationJudgeID(5, "run2"),
+			Status:       protocol.StatusCompileError,
+			ErrorMessage: "syntax error",
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("判题服务没有返回输出", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(pending(), nil)
+		mockRepo.On("UpdateOutputGeneration", mock.MatchedBy(func(g *domain.OutputGeneration) bool {
+			return g.Status == domain.OutputGenerationFailed && g.Message == "判题服务没有返回测试用例 #2 的输出"
+		})).Return(true, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyOutputGenerationResult(&protocol.JudgeResult{
+			SubmissionID: OutputGenerationJudgeID(5, "run2"),
+			Status:       protocol.StatusAccepted,
+			Cases:        []protocol.CaseResult{{Index: 1, Status: protocol.StatusAccepted, Captured: &protocol.CapturedOutput{}}},
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("丢弃过期结果", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(pending(), nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyOutputGenerationResult(&protocol.JudgeResult{
+			SubmissionID: OutputGenerationJudgeID(5, "run1"),
+			Status:       protocol.StatusAccepted,
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateOutputGeneration", mock.Anything)
+	})
+}
+
+// readyGeneration 与 outputsTestProblem 一致的已生成任务
+func readyGeneration(t *testing.T, problem *domain.Problem, testCases []domain.TestCase) *domain.OutputGeneration {
+	_, fingerprint, err := outputGenerationRequest(problem, testCases)
+	assert.NoError(t, err)
+	return &domain.OutputGeneration{
+		ProblemID:   problem.ID,
+		RunID:       "run2",
+		Fingerprint: fingerprint,
+		Status:      domain.OutputGenerationReady,
+		Cases: []domain.GeneratedOutput{
+			{TestCaseID: 11, Output: "q=0\nq=0\n"},
+			{TestCaseID: 12, Trace: []map[string]string{{"q": "0b0"}, {"q": "0b1"}}},
+		},
+		TruthTable: []map[string]string{{"y": "0b1"}, {"y": "0b0"}},
+	}
+}
+
+// TestProblemService_GetOutputPreview 测试生成结果与当前期望输出的对比
+func TestProblemService_GetOutputPreview(t *testing.T) {
+	t.Run("对比期望输出", func(t *testing.T) {
+		problem, testCases := outputsTestProblem()
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(5)).Return(problem, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(readyGeneration(t, problem, testCases), nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		preview, err := service.GetOutputPreview(5)
+
+		assert.NoError(t, err)
+		assert.False(t, preview.Stale)
+		assert.Len(t, preview.Changes, 3)
+		assert.Equal(t, OutputChange{Index: 1, TestCaseID: 11, Description: "测试用例 #1", Changed: true, ChangedLines: 1, FirstLine: 2, Previous: "q=1", Generated: "q=0"}, preview.Changes[0])
+		// 第一个周期原来不检查输出
+		assert.Equal(t, OutputChange{Index: 2, TestCaseID: 12, Description: "测试用例 #2", Changed: true, ChangedLines: 1, FirstLine: 1, Previous: "", Generated: "q=0b0"}, preview.Changes[1])
+		assert.Equal(t, 2, preview.Changes[2].ChangedLines)
+		assert.Equal(t, "真值表", preview.Changes[2].Description)
+	})
+
+	t.Run("测试用例发生变化", func(t *testing.T) {
+		problem, testCases := outputsTestProblem()
+		generation := readyGeneration(t, problem, testCases)
+		testCases[0].Input = "module tb2; endmodule"
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(5)).Return(problem, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(generation, nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		preview, err := service.GetOutputPreview(5)
+
+		assert.NoError(t, err)
+		assert.True(t, preview.Stale)
+		assert.Empty(t, preview.Changes)
+	})
+
+	t.Run("没有生成任务", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(5)).Return(&domain.Problem{ID: 5}, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(nil, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		_, err := service.GetOutputPreview(5)
+
+		assert.ErrorIs(t, err, ErrOutputGenerationNotFound)
+	})
+}
+
+// TestOutputLines 测试比较VCD时忽略每次仿真都会变化的头部
+func TestOutputLines(t *testing.T) {
+	previous := outputLines("$date\n  Mon Jan 1\n$end\n$version Icarus $end\n$timescale 1ns $end\n#0\n1!\n")
+	generated := outputLines("$date\n  Tue Jan 2\n$end\n$version Icarus $end\n$timescale 1ns $end\n#0\n1!\n")
+
+	change := OutputChange{}
+	compareLines(&change, previous, generated)
+	assert.False(t, change.Changed)
+	assert.Equal(t, outputLine{Number: 5, Text: "$timescale 1ns $end"}, previous[0])
+}
+
+// TestProblemService_ApplyGeneratedOutputs 测试确认生成的期望输出
+func TestProblemService_ApplyGeneratedOutputs(t *testing.T) {
+	t.Run("写入期望输出并重新校验", func(t *testing.T) {
+		problem, testCases := outputsTestProblem()
+		generation := readyGeneration(t, problem, testCases)
+		mockRepo := new(MockProblemRepository)
+		mockQueue := new(MockJudgeQueue)
+		mockRepo.On("GetByID", uint(5)).Return(problem, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(generation, nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil).Once()
+		mockRepo.On("ApplyGeneratedOutputs", uint(5), mock.MatchedBy(func(cases []domain.TestCase) bool {
+			return cases[0].Output == "q=0\nq=0\n" && cases[0].Input == "module tb; endmodule" &&
+				cases[1].Trace.Cycles[0]["d"] == "1" && cases[1].Trace.Cycles[0]["q"] == "0b0" && cases[1].Trace.Cycles[1]["q"] == "0b1"
+		}), mock.MatchedBy(func(table *domain.TruthTable) bool {
+			return table.Rows[0]["a"] == "0" && table.Rows[0]["y"] == "0b1" && table.Rows[1]["y"] == "0b0"
+		})).Return(nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil)
+		mockQueue.On("Push", mock.MatchedBy(func(r *protocol.JudgeRequest) bool {
+			return IsValidationJudgeID(r.SubmissionID) && r.TruthTable.Rows[0]["y"] == "0b1"
+		})).Return(nil)
+		mockRepo.On("UpdateValidation", uint(5), mock.AnythingOfType("*domain.ProblemValidation")).Return(nil)
+
+		service := NewProblemService(mockRepo, mockQueue)
+		validation, err := service.ApplyGeneratedOutputs(5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ValidationPending, validation.Status)
+		mockRepo.AssertExpectations(t)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("生成失败", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(5)).Return(&domain.Problem{ID: 5}, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(&domain.OutputGeneration{ProblemID: 5, Status: domain.OutputGenerationFailed}, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		_, err := service.ApplyGeneratedOutputs(5)
+
+		assert.ErrorIs(t, err, ErrOutputGenerationNotReady)
+	})
+
+	t.Run("参考答案发生变化", func(t *testing.T) {
+		problem, testCases := outputsTestProblem()
+		generation := readyGeneration(t, problem, testCases)
+		problem.ReferenceCode = "module dff2; endmodule"
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(5)).Return(problem, nil)
+		mockRepo.On("GetOutputGeneration", uint(5)).Return(generation, nil)
+		mockRepo.On("GetTestCases", uint(5)).Return(testCases, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		_, err := service.ApplyGeneratedOutputs(5)
+
+		assert.ErrorIs(t, err, ErrOutputGenerationStale)
+		mockRepo.AssertNotCalled(t, "ApplyGeneratedOutputs", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/judge-service/pkg/problem"
+	"verilog-oj/protocol"
+)
+
+// 导入题目时的默认限制，与创建题目接口一致
+const (
+	defaultTimeLimit   = 1000
+	defaultMemoryLimit = 128
+)
+
+// ProblemImportResult 单个题目包的导入结果
+type ProblemImportResult struct {
+	Path      string   // 题目包在压缩包中的位置
+	Title     string   // 题目标题
+	ProblemID uint     // 创建的题目ID，预检或失败时为0
+	Valid     bool     // 是否通过校验
+	Errors    []string // 导致无法导入的问题
+	Warnings  []string // 不影响导入的问题
+}
+
+// ExportProblem 将题目及其测试用例、附件导出为题目包
+func (s *ProblemService) ExportProblem(id uint) (*problem.Package, error) {
+	p, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	testCases, err := s.problemRepo.GetTestCases(id)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.problemRepo.GetAttachments(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &problem.Package{
+		Metadata: problem.Metadata{
+			Title:       p.Title,
+			InputDesc:   p.InputDesc,
+			OutputDesc:  p.OutputDesc,
+			Difficulty:  p.Difficulty,
+			Category:    p.Category,
+			Tags:        p.Tags,
+			TimeLimit:   p.TimeLimit,
+			MemoryLimit: p.MemoryLimit,
+			Language:    "verilog",
+			Checker:     p.Checker,
+		},
+		Description: p.Description,
+		Reference:   p.ReferenceCode,
+		Starter:     p.StarterCode,
+	}
+	if p.Equivalence != nil && p.ReferenceCode != "" {
+		// 题目包中的等价性检查必须有参考答案
+		pkg.Metadata.Equivalence = &problem.EquivalenceSpec{
+			TopModule:      p.Equivalence.TopModule,
+			Seed:           p.Equivalence.Seed,
+			Cycles:         p.Equivalence.Cycles,
+			Clock:          p.Equivalence.Clock,
+			Reset:          p.Equivalence.Reset,
+			ResetActiveLow: p.Equivalence.ResetActiveLow,
+			ResetCycles:    p.Equivalence.ResetCycles,
+			Hold:           p.Equivalence.Hold,
+			ExhaustiveBits: p.Equivalence.ExhaustiveBits,
+			Formal:         p.Equivalence.Formal,
+			FormalTimeout:  p.Equivalence.FormalTimeout,
+			FormalDepth:    p.Equivalence.FormalDepth,
+		}
+	}
+	pkg.TruthTable = truthTableRequest(p.TruthTable)
+	pkg.SpecialJudge = specialJudgeRequest(p.SpecialJudge)
+	for _, tc := range testCases {
+		pkg.TestCases = append(pkg.TestCases, problem.TestCase{
+			Testbench:   tc.Input,
+			Expected:    tc.Output,
+			Sample:      tc.IsSample,
+			Description: tc.Description,
+			SimTime:     tc.SimTime,
+			Clock:       tc.Clock,
+			Trace:       ioTraceRequest(tc.Trace),
+		})
+	}
+	for _, attachment := range attachments {
+		pkg.Attachments = append(pkg.Attachments, problem.Attachment{
+			Name:    attachment.Name,
+			Content: attachment.Content,
+		})
+	}
+	return pkg, nil
+}
+
+// ImportProblems 校验并导入题目包，dryRun 为 true 时只校验不写入
+// 每个题目包独立导入，未通过校验的题目包会被跳过
+func (s *ProblemService) ImportProblems(entries []problem.Entry, authorID uint, dryRun bool) []ProblemImportResult {
+	results := make([]ProblemImportResult, 0, len(entries))
+	for _, entry := range entries {
+		result := ProblemImportResult{Path: entry.Path}
+		if entry.Err != nil {
+			result.Errors = append(result.Errors, entry.Err.Error())
+			results = append(results, result)
+			continue
+		}
+
+		p, testCases, attachments := packageToDomain(entry.Package, authorID)
+		result.Title = p.Title
+		result.Errors, result.Warnings = validatePackage(entry.Package, p)
+		result.Valid = len(result.Errors) == 0
+
+		if result.Valid && !dryRun {
+			if err := s.createFromPackage(p, testCases, attachments); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, "导入失败："+err.Error())
+			} else {
+				result.ProblemID = p.ID
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// packageToDomain 将题目包转换为领域实体，导入的题目默认私有
+func packageToDomain(pkg *problem.Package, authorID uint) (*domain.Problem, []domain.TestCase, []domain.ProblemAttachment) {
+	meta := pkg.Metadata
+	p := &domain.Problem{
+		Title:         meta.Title,
+		Description:   pkg.Description,
+		InputDesc:     meta.InputDesc,
+		OutputDesc:    meta.OutputDesc,
+		Difficulty:    meta.Difficulty,
+		Category:      meta.Category,
+		Tags:          meta.Tags,
+		TimeLimit:     meta.TimeLimit,
+		MemoryLimit:   meta.MemoryLimit,
+		ReferenceCode: pkg.Reference,
+		StarterCode:   pkg.Starter,
+		Checker:       meta.Checker,
+		IsPublic:      false,
+		AuthorID:      authorID,
+	}
+	if spec := meta.Equivalence; spec != nil {
+		p.Equivalence = &domain.EquivalenceConfig{
+			TopModule:      spec.TopModule,
+			Seed:           spec.Seed,
+			Cycles:         spec.Cycles,
+			Clock:          spec.Clock,
+			Reset:          spec.Reset,
+			ResetActiveLow: spec.ResetActiveLow,
+			ResetCycles:    spec.ResetCycles,
+			Hold:           spec.Hold,
+			ExhaustiveBits: spec.ExhaustiveBits,
+			Formal:         spec.Formal,
+			FormalTimeout:  spec.FormalTimeout,
+			FormalDepth:    spec.FormalDepth,
+		}
+	}
+	if table := pkg.TruthTable; table != nil {
+		p.TruthTable = &domain.TruthTable{TopModule: table.TopModule, Rows: table.Rows, Delay: table.Delay}
+		for _, port := range table.Ports {
+			p.TruthTable.Ports = append(p.TruthTable.Ports, domain.Port(port))
+		}
+	}
+	if spj := pkg.SpecialJudge; spj != nil {
+		p.SpecialJudge = &domain.SpecialJudge{Language: spj.Language, Source: spj.Source, TimeLimit: spj.TimeLimit}
+	}
+	if p.Difficulty == "" {
+		p.Difficulty = "Easy"
+	}
+	if p.TimeLimit == 0 {
+		p.TimeLimit = defaultTimeLimit
+	}
+	if p.MemoryLimit == 0 {
+		p.MemoryLimit = defaultMemoryLimit
+	}
+
+	testCases := make([]domain.TestCase, 0, len(pkg.TestCases))
+	for _, tc := range pkg.TestCases {
+		testCases = append(testCases, domain.TestCase{
+			Input:       tc.Testbench,
+			Output:      tc.Expected,
+			IsSample:    tc.Sample,
+			Description: tc.Description,
+			SimTime:     tc.SimTime,
+			Clock:       tc.Clock,
+			Trace:       ioTraceDomain(tc.Trace),
+		})
+	}
+
+	attachments := make([]domain.ProblemAttachment, 0, len(pkg.Attachments))
+	for _, attachment := range pkg.Attachments {
+		attachments = append(attachments, domain.ProblemAttachment{
+			Name:    attachment.Name,
+			Content: attachment.Content,
+		})
+	}
+	return p, testCases, attachments
+}
+
+// ioTraceDomain 将题目包中的 I/O 序列转换为Domain实体
+func ioTraceDomain(trace *protocol.IOTrace) *domain.IOTrace {
+	if trace == nil {
+		return nil
+	}
+	result := &domain.IOTrace{
+		TopModule:      trace.TopModule,
+		Clock:          trace.Clock,
+		Reset:          trace.Reset,
+		ResetActiveLow: trace.ResetActiveLow,
+		ResetCycles:    trace.ResetCycles,
+		Period:         trace.Period,
+		SampleEdge:     trace.SampleEdge,
+		Cycles:         trace.Cycles,
+	}
+	for _, port := range trace.Ports {
+		result.Ports = append(result.Ports, domain.Port(port))
+	}
+	return result
+}
+
+// validatePackage 按创建题目的规则校验题目包
+func validatePackage(pkg *problem.Package, p *domain.Problem) (errs []string, warnings []string) {
+	if p.Description == "" {
+		errs = append(errs, "题目描述不能为空（description 或 statement）")
+	}
+	switch p.Difficulty {
+	case "Easy", "Medium", "Hard":
+	default:
+		errs = append(errs, fmt.Sprintf("难度必须是 Easy、Medium 或 Hard，实际为 %q", p.Difficulty))
+	}
+	if lang := pkg.Metadata.Language; lang != "" && lang != "verilog" {
+		warnings = append(warnings, fmt.Sprintf("语言 %q 不会被保存，题目按 verilog 判题", lang))
+	}
+	if p.ReferenceCode == "" {
+		warnings = append(warnings, "没有参考答案，无法校验测试用例")
+	}
+
+	hasSample := false
+	for _, tc := range pkg.TestCases {
+		hasSample = hasSample || tc.Sample
+	}
+	if !hasSample {
+		warnings = append(warnings, "没有样例测试用例")
+	}
+	return errs, warnings
+}
+
+// createFromPackage 创建题目、测试用例和附件，任何一步失败都会删除已创建的题目
+func (s *ProblemService) createFromPackage(p *domain.Problem, testCases []domain.TestCase, attachments []domain.ProblemAttachment) error {
+	if err := s.CreateProblem(p); err != nil {
+		return err
+	}
+
+	err := func() error {
+		for i := range testCases {
+			testCases[i].ProblemID = p.ID
+			if err := s.problemRepo.CreateTestCase(&testCases[i]); err != nil {
+				return fmt.Errorf("创建测试用例失败：%v", err)
+			}
+		}
+		for i := range attachments {
+			attachments[i].ProblemID = p.ID
+			if err := s.problemRepo.CreateAttachment(&attachments[i]); err != nil {
+				return fmt.Errorf("创建附件失败：%v", err)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		if rollbackErr := s.DeleteProblem(p.ID); rollbackErr != nil {
+			log.Printf("failed to roll back imported problem %d: %v", p.ID, rollbackErr)
+		}
+		return err
+	}
+
+	if _, err := s.startValidation(p); err != nil {
+		log.Printf("failed to start validation for imported problem %d: %v", p.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/judge-service/pkg/problem"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestPackage() *problem.Package {
+	return &problem.Package{
+		Metadata:    problem.Metadata{Title: "Adder", Difficulty: "Medium"},
+		Description: "实现加法器",
+		Reference:   "module adder; endmodule",
+		TestCases: []problem.TestCase{
+			{Testbench: "module tb; endmodule", Expected: "1", Sample: true, Description: "basic"},
+		},
+		Attachments: []problem.Attachment{{Name: "wave.png", Content: []byte{1, 2}}},
+	}
+}
+
+// TestProblemService_ImportProblems 测试导入题目包
+func TestProblemService_ImportProblems(t *testing.T) {
+	t.Run("预检不写入", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		service := NewProblemService(mockRepo, nil)
+
+		results := service.ImportProblems([]problem.Entry{
+			{Path: "adder", Package: newTestPackage()},
+			{Path: "broken", Err: errors.New("invalid problem.yaml")},
+		}, 1, true)
+
+		assert.Len(t, results, 2)
+		assert.True(t, results[0].Valid)
+		assert.Equal(t, "Adder", results[0].Title)
+		assert.Zero(t, results[0].ProblemID)
+		assert.False(t, results[1].Valid)
+		assert.Equal(t, []string{"invalid problem.yaml"}, results[1].Errors)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("校验失败", func(t *testing.T) {
+		pkg := newTestPackage()
+		pkg.Description = ""
+		pkg.Metadata.Difficulty = "Insane"
+		pkg.Reference = ""
+
+		service := NewProblemService(new(MockProblemRepository), nil)
+		results := service.ImportProblems([]problem.Entry{{Path: "adder", Package: pkg}}, 1, true)
+
+		assert.False(t, results[0].Valid)
+		assert.Len(t, results[0].Errors, 2)
+		assert.Contains(t, results[0].Warnings, "没有参考答案，无法校验测试用例")
+	})
+
+	t.Run("导入成功", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("Create", mock.MatchedBy(func(p *domain.Problem) bool {
+			return p.Title == "Adder" && p.TimeLimit == 1000 && p.MemoryLimit == 128 &&
+				p.ReferenceCode == "module adder; endmodule" && !p.IsPublic && p.AuthorID == 1
+		})).Run(func(args mock.Arguments) {
+			args.Get(0).(*domain.Problem).ID = 5
+		}).Return(nil)
+		mockRepo.On("CreateTestCase", mock.MatchedBy(func(tc *domain.TestCase) bool {
+			return tc.ProblemID == 5 && tc.Input == "module tb; endmodule" && tc.IsSample && tc.Description == "basic"
+		})).Return(nil)
+		mockRepo.On("CreateAttachment", mock.MatchedBy(func(a *domain.ProblemAttachment) bool {
+			return a.ProblemID == 5 && a.Name == "wave.png"
+		})).Return(nil)
+		mockRepo.On("UpdateValidation", uint(5), mock.MatchedBy(func(v *domain.ProblemValidation) bool {
+			return v.Status == domain.ValidationUnvalidated && v.Message == "判题队列未启用"
+		})).Return(nil)
+
+		service := NewProblemService(mockRepo, nil)
+		results := service.ImportProblems([]problem.Entry{{Path: "adder", Package: newTestPackage()}}, 1, false)
+
+		assert.True(t, results[0].Valid)
+		assert.Equal(t, uint(5), results[0].ProblemID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("创建测试用例失败时回滚", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("Create", mock.AnythingOfType("*domain.Problem")).Run(func(args mock.Arguments) {
+			args.Get(0).(*domain.Problem).ID = 6
+		}).Return(nil)
+		mockRepo.On("CreateTestCase", mock.AnythingOfType("*domain.TestCase")).Return(errors.New("数据库错误"))
+		mockRepo.On("GetByID", uint(6)).Return(&domain.Problem{ID: 6}, nil)
+		mockRepo.On("DeleteTestCases", uint(6)).Return(nil)
+		mockRepo.On("DeleteAttachments", uint(6)).Return(nil)
+		mockRepo.On("Delete", uint(6)).Return(nil)
+
+		service := NewProblemService(mockRepo, nil)
+		results := service.ImportProblems([]problem.Entry{{Path: "adder", Package: newTestPackage()}}, 1, false)
+
+		assert.False(t, results[0].Valid)
+		assert.Zero(t, results[0].ProblemID)
+		mockRepo.AssertCalled(t, "Delete", uint(6))
+	})
+}
+
+// TestProblemService_ExportProblem 测试导出题目包
+func TestProblemService_ExportProblem(t *testing.T) {
+	t.Run("导出成功", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(1)).Return(&domain.Problem{
+			ID: 1, Title: "Adder", Description: "实现加法器", Difficulty: "Easy",
+			TimeLimit: 1000, MemoryLimit: 128, ReferenceCode: "module adder; endmodule", StarterCode: "module adder;",
+		}, nil)
+		mockRepo.On("GetTestCases", uint(1)).Return([]domain.TestCase{
+			{Input: "module tb; endmodule", Output: "1", IsSample: true},
+		}, nil)
+		mockRepo.On("GetAttachments", uint(1)).Return([]domain.ProblemAttachment{
+			{Name: "wave.png", Content: []byte{1}},
+		}, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		pkg, err := service.ExportProblem(1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Adder", pkg.Metadata.Title)
+		assert.Equal(t, "实现加法器", pkg.Description)
+		assert.Equal(t, "module adder;", pkg.Starter)
+		assert.Equal(t, "1", pkg.TestCases[0].Expected)
+		assert.Len(t, pkg.Attachments, 1)
+	})
+
+	t.Run("题目不存在", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(2)).Return((*domain.Problem)(nil), nil)
+
+		service := NewProblemService(mockRepo, nil)
+		_, err := service.ExportProblem(2)
+
+		assert.EqualError(t, err, "题目不存在")
+	})
+}
@@ -59,11 +59,54 @@ func (m *MockProblemRepository) CreateTestCase(testCase *domain.TestCase) error
 	return args.Error(0)
 }
 
+func (m *MockProblemRepository) CreateAttachment(attachment *domain.ProblemAttachment) error {
+	args := m.Called(attachment)
+	return args.Error(0)
+}
+
+func (m *MockProblemRepository) GetAttachments(problemID uint) ([]domain.ProblemAttachment, error) {
+	args := m.Called(problemID)
+	return args.Get(0).([]domain.ProblemAttachment), args.Error(1)
+}
+
+func (m *MockProblemRepository) DeleteAttachments(problemID uint) error {
+	args := m.Called(problemID)
+	return args.Error(0)
+}
+
 func (m *MockProblemRepository) DeleteTestCases(problemID uint) error {
 	args := m.Called(problemID)
 	return args.Error(0)
 }
 
+func (m *MockProblemRepository) UpdateValidation(problemID uint, validation *domain.ProblemValidation) error {
+	args := m.Called(problemID, validation)
+	return args.Error(0)
+}
+
+func (m *MockProblemRepository) SaveOutputGeneration(generation *domain.OutputGeneration) error {
+	args := m.Called(generation)
+	return args.Error(0)
+}
+
+func (m *MockProblemRepository) GetOutputGeneration(problemID uint) (*domain.OutputGeneration, error) {
+	args := m.Called(problemID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OutputGeneration), args.Error(1)
+}
+
+func (m *MockProblemRepository) UpdateOutputGeneration(generation *domain.OutputGeneration) (bool, error) {
+	args := m.Called(generation)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockProblemRepository) ApplyGeneratedOutputs(problemID uint, testCases []domain.TestCase, truthTable *domain.TruthTable) error {
+	args := m.Called(problemID, testCases, truthTable)
+	return args.Error(0)
+}
+
 // TestProblemService_CreateProblem 测试创建题目
 func TestProblemService_CreateProblem(t *testing.T) {
 	tests := []struct {
@@ -155,7 +198,7 @@ func TestProblemService_CreateProblem(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			err := service.CreateProblem(tt.problem)
 
 			if tt.wantErr {
@@ -227,7 +270,7 @@ func TestProblemService_GetProblem(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			result, err := service.GetProblem(tt.id)
 
 			if tt.wantErr {
@@ -315,7 +358,7 @@ func TestProblemService_ListProblems(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			_, _, err := service.ListProblems(tt.page, tt.limit, tt.filters)
 
 			if tt.wantErr {
@@ -379,7 +422,7 @@ func TestProblemService_UpdateProblem(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			err := service.UpdateProblem(tt.problem)
 
 			if tt.wantErr {
@@ -410,6 +453,7 @@ func TestProblemService_DeleteProblem(t *testing.T) {
 				problem := &domain.Problem{ID: 1, Title: "测试题目"}
 				m.On("GetByID", uint(1)).Return(problem, nil)
 				m.On("DeleteTestCases", uint(1)).Return(nil)
+				m.On("DeleteAttachments", uint(1)).Return(nil)
 				m.On("Delete", uint(1)).Return(nil)
 			},
 			wantErr: false,
@@ -439,7 +483,7 @@ func TestProblemService_DeleteProblem(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			err := service.DeleteProblem(tt.id)
 
 			if tt.wantErr {
@@ -474,6 +518,7 @@ func TestProblemService_AddTestCase(t *testing.T) {
 				problem := &domain.Problem{ID: 1, Title: "测试题目"}
 				m.On("GetByID", uint(1)).Return(problem, nil)
 				m.On("CreateTestCase", mock.AnythingOfType("*domain.TestCase")).Return(nil)
+				m.On("UpdateValidation", uint(1), mock.AnythingOfType("*domain.ProblemValidation")).Return(nil)
 			},
 			wantErr: false,
 		},
@@ -519,7 +564,7 @@ func TestProblemService_AddTestCase(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			err := service.AddTestCase(tt.testCase)
 
 			if tt.wantErr {
@@ -590,7 +635,7 @@ func TestProblemService_UpdateProblemStats(t *testing.T) {
 			mockRepo := new(MockProblemRepository)
 			tt.mockFn(mockRepo)
 
-			service := NewProblemService(mockRepo)
+			service := NewProblemService(mockRepo, nil)
 			err := service.UpdateProblemStats(tt.problemID, tt.submitIncrement, tt.acceptedIncrement)
 
 			if tt.wantErr {
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+)
+
+// validationJudgePrefix 参考答案校验任务的提交ID前缀，判题结果据此分发给题目服务
+const validationJudgePrefix = "validation-"
+
+// ErrProblemNotValidated 参考答案未通过校验时发布题目返回的错误
+var ErrProblemNotValidated = errors.New("参考答案未通过全部测试用例，不能发布题目")
+
+// ValidationJudgeID 构造参考答案校验任务的提交ID，格式为 validation-<题目ID>-<校验任务标识>
+func ValidationJudgeID(problemID uint, runID string) string {
+	return fmt.Sprintf("%s%d-%s", validationJudgePrefix, problemID, runID)
+}
+
+// IsValidationJudgeID 判断提交ID是否属于参考答案校验任务
+func IsValidationJudgeID(submissionID string) bool {
+	return strings.HasPrefix(submissionID, validationJudgePrefix)
+}
+
+// ParseValidationJudgeID 解析参考答案校验任务的提交ID
+func ParseValidationJudgeID(submissionID string) (uint, string, error) {
+	return parseProblemJudgeID(validationJudgePrefix, submissionID)
+}
+
+// parseProblemJudgeID 解析 <前缀><题目ID>-<任务标识> 格式的提交ID，用于以参考答案判题的题目任务
+func parseProblemJudgeID(prefix, submissionID string) (uint, string, error) {
+	rest, ok := strings.CutPrefix(submissionID, prefix)
+	if !ok {
+		return 0, "", fmt.Errorf("无效的题目任务ID %q", submissionID)
+	}
+	idPart, runID, ok := strings.Cut(rest, "-")
+	if !ok || runID == "" {
+		return 0, "", fmt.Errorf("无效的题目任务ID %q", submissionID)
+	}
+	problemID, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("无效的题目任务ID %q", submissionID)
+	}
+	return uint(problemID), runID, nil
+}
+
+// ValidateProblem 使用参考答案对题目的全部测试用例判题
+// 判题任务异步执行，返回的校验状态为 pending；无法校验时状态为 unvalidated 并给出原因
+func (s *ProblemService) ValidateProblem(id uint) (*domain.ProblemValidation, error) {
+	problem, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.startValidation(problem)
+}
+
+// startValidation 推送参考答案校验任务并保存校验状态，之前未完成的校验结果会被丢弃
+func (s *ProblemService) startValidation(problem *domain.Problem) (*domain.ProblemValidation, error) {
+	validation := &domain.ProblemValidation{Status: domain.ValidationUnvalidated}
+
+	switch {
+	case problem.ReferenceCode == "":
+		validation.Message = "题目没有参考答案"
+	case s.judgeQueue == nil:
+		validation.Message = "判题队列未启用"
+	default:
+		testCases, err := s.problemRepo.GetTestCases(problem.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(testCases) == 0 && equivalenceRequest(problem) == nil && problem.TruthTable == nil {
+			validation.Message = "题目没有测试用例"
+			break
+		}
+
+		runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+		if err := s.dispatchValidation(problem, testCases, runID); err != nil {
+			log.Printf("failed to dispatch validation for problem %d: %v", problem.ID, err)
+			validation.Message = "校验任务创建失败：" + err.Error()
+			break
+		}
+		validation.Status = domain.ValidationPending
+		validation.RunID = runID
+	}
+
+	if err := s.problemRepo.UpdateValidation(problem.ID, validation); err != nil {
+		return nil, err
+	}
+	problem.Validation = *validation
+	return validation, nil
+}
+
+// dispatchValidation 以参考答案构造判题请求并推送到判题队列
+func (s *ProblemService) dispatchValidation(problem *domain.Problem, testCases []domain.TestCase, runID string) error {
+	reference := &domain.Submission{Code: problem.ReferenceCode, Language: "verilog"}
+	request, err := BuildJudgeRequest(reference, problem, testCases)
+	if err != nil {
+		return err
+	}
+	request.SubmissionID = ValidationJudgeID(problem.ID, runID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.judgeQueue.Push(ctx, request)
+}
+
+// ApplyValidationResult 将参考答案的判题结果写回题目，过期的校验结果会被丢弃
+func (s *ProblemService) ApplyValidationResult(result *protocol.JudgeResult) error {
+	problemID, runID, err := ParseValidationJudgeID(result.SubmissionID)
+	if err != nil {
+		return err
+	}
+
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return err
+	}
+	if problem == nil {
+		log.Printf("discarding validation result for deleted problem %d", problemID)
+		return nil
+	}
+	if problem.Validation.RunID != runID {
+		// 测试用例或参考答案在判题期间发生了变化，以最新一次校验为准
+		log.Printf("discarding stale validation result %s for problem %d", result.SubmissionID, problemID)
+		return nil
+	}
+
+	return s.problemRepo.UpdateValidation(problemID, validationFromResult(runID, result))
+}
+
+// validationFromResult 根据判题结果生成校验结果
+func validationFromResult(runID string, result *protocol.JudgeResult) *domain.ProblemValidation {
+	judgedAt := result.JudgedAt
+	validation := &domain.ProblemValidation{
+		RunID:       runID,
+		ValidatedAt: &judgedAt,
+	}
+	for _, c := range result.Cases {
+		validation.Cases = append(validation.Cases, domain.ValidationCase{
+			Index:        c.Index,
+			Description:  c.Description,
+			Status:       c.Status,
+			RunTime:      c.RunTime,
+			Memory:       c.Memory,
+			ErrorMessage: c.ErrorMessage,
+			SimTime:      c.SimTime,
+			Cycles:       c.Cycles,
+		})
+	}
+
+	switch result.Status {
+	case protocol.StatusAccepted:
+		validation.Status = domain.ValidationValidated
+	case protocol.StatusSystemError:
+		// 判题服务自身出错，不能说明测试用例有问题，需要重新校验
+		validation.Status = domain.ValidationUnvalidated
+		validation.Message = "校验失败：" + result.ErrorMessage
+	case protocol.StatusCompileError:
+		validation.Status = domain.ValidationInvalid
+		validation.Message = "参考答案编译失败：" + result.ErrorMessage
+	default:
+		validation.Status = domain.ValidationInvalid
+		validation.Message = fmt.Sprintf("参考答案通过 %d/%d 个测试用例：%s", result.PassedTests, result.TotalTests, result.ErrorMessage)
+	}
+	return validation
+}
+
+// SetProblemPublic 发布或撤回题目，发布前参考答案必须通过全部测试用例
+func (s *ProblemService) SetProblemPublic(id uint, public bool) (*domain.Problem, error) {
+	problem, err := s.GetProblem(id)
+	if err != nil {
+		return nil, err
+	}
+	if public && !problem.IsPublic && problem.Validation.Status != domain.ValidationValidated {
+		return nil, ErrProblemNotValidated
+	}
+
+	problem.IsPublic = public
+	if err := s.problemRepo.Update(problem); err != nil {
+		return nil, err
+	}
+	return problem, nil
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestValidationJudgeID 测试校验任务ID的构造和解析
+func TestValidationJudgeID(t *testing.T) {
+	id := ValidationJudgeID(12, "abc")
+	assert.Equal(t, "validation-12-abc", id)
+	assert.True(t, IsValidationJudgeID(id))
+	assert.False(t, IsValidationJudgeID("12"))
+
+	problemID, runID, err := ParseValidationJudgeID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), problemID)
+	assert.Equal(t, "abc", runID)
+
+	for _, invalid := range []string{"12", "validation-", "validation-12", "validation-x-abc"} {
+		_, _, err := ParseValidationJudgeID(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestProblemService_ValidateProblem 测试推送参考答案校验任务
+func TestProblemService_ValidateProblem(t *testing.T) {
+	reference := &domain.Problem{ID: 1, ReferenceCode: "module adder; endmodule", TimeLimit: 1000, MemoryLimit: 128}
+	testCases := []domain.TestCase{{Input: "module tb; endmodule", Output: "1"}}
+
+	t.Run("推送校验任务", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockQueue := new(MockJudgeQueue)
+		mockRepo.On("GetByID", uint(1)).Return(reference, nil)
+		mockRepo.On("GetTestCases", uint(1)).Return(testCases, nil)
+		mockQueue.On("Push", mock.MatchedBy(func(r *protocol.JudgeRequest) bool {
+			return IsValidationJudgeID(r.SubmissionID) && r.Code == "module adder; endmodule" && len(r.TestCases) == 1
+		})).Return(nil)
+		mockRepo.On("UpdateValidation", uint(1), mock.MatchedBy(func(v *domain.ProblemValidation) bool {
+			return v.Status == domain.ValidationPending && v.RunID != ""
+		})).Return(nil)
+
+		service := NewProblemService(mockRepo, mockQueue)
+		validation, err := service.ValidateProblem(1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ValidationPending, validation.Status)
+		mockRepo.AssertExpectations(t)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("没有参考答案", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(2)).Return(&domain.Problem{ID: 2}, nil)
+		mockRepo.On("UpdateValidation", uint(2), mock.AnythingOfType("*domain.ProblemValidation")).Return(nil)
+
+		service := NewProblemService(mockRepo, new(MockJudgeQueue))
+		validation, err := service.ValidateProblem(2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ValidationUnvalidated, validation.Status)
+		assert.Equal(t, "题目没有参考答案", validation.Message)
+	})
+
+	t.Run("推送失败", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockQueue := new(MockJudgeQueue)
+		mockRepo.On("GetByID", uint(1)).Return(reference, nil)
+		mockRepo.On("GetTestCases", uint(1)).Return(testCases, nil)
+		mockQueue.On("Push", mock.AnythingOfType("*protocol.JudgeRequest")).Return(errors.New("queue unavailable"))
+		mockRepo.On("UpdateValidation", uint(1), mock.AnythingOfType("*domain.ProblemValidation")).Return(nil)
+
+		service := NewProblemService(mockRepo, mockQueue)
+		validation, err := service.ValidateProblem(1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ValidationUnvalidated, validation.Status)
+		assert.Contains(t, validation.Message, "queue unavailable")
+	})
+}
+
+// TestProblemService_ApplyValidationResult 测试写回参考答案校验结果
+func TestProblemService_ApplyValidationResult(t *testing.T) {
+	pending := &domain.Problem{ID: 3, Validation: domain.ProblemValidation{Status: domain.ValidationPending, RunID: "run2"}}
+
+	tests := []struct {
+		name           string
+		result         *protocol.JudgeResult
+		expectedStatus string
+	}{
+		{
+			name:           "全部通过",
+			result:         &protocol.JudgeResult{Status: protocol.StatusAccepted, PassedTests: 2, TotalTests: 2},
+			expectedStatus: domain.ValidationValidated,
+		},
+		{
+			name: "部分测试用例失败",
+			result: &protocol.JudgeResult{
+				Status: protocol.StatusWrongAnswer, PassedTests: 1, TotalTests: 2,
+				Cases: []protocol.CaseResult{
+					{Index: 1, Status: protocol.StatusAccepted},
+					{Index: 2, Status: protocol.StatusWrongAnswer, ErrorMessage: "mismatch"},
+				},
+			},
+			expectedStatus: domain.ValidationInvalid,
+		},
+		{
+			name:           "判题系统错误",
+			result:         &protocol.JudgeResult{Status: protocol.StatusSystemError, ErrorMessage: "disk full"},
+			expectedStatus: domain.ValidationUnvalidated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockProblemRepository)
+			mockRepo.On("GetByID", uint(3)).Return(pending, nil)
+			mockRepo.On("UpdateValidation", uint(3), mock.MatchedBy(func(v *domain.ProblemValidation) bool {
+				return v.Status == tt.expectedStatus && v.RunID == "run2" && len(v.Cases) == len(tt.result.Cases)
+			})).Return(nil)
+
+			tt.result.SubmissionID = ValidationJudgeID(3, "run2")
+			tt.result.JudgedAt = time.Now()
+			service := NewProblemService(mockRepo, nil)
+			err := service.ApplyValidationResult(tt.result)
+
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("记录仿真时间和时钟周期数", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(3)).Return(pending, nil)
+		mockRepo.On("UpdateValidation", uint(3), mock.MatchedBy(func(v *domain.ProblemValidation) bool {
+			return v.Status == domain.ValidationInvalid && len(v.Cases) == 1 &&
+				v.Cases[0].Status == protocol.StatusSimTimeExceeded && v.Cases[0].SimTime == 100000 && v.Cases[0].Cycles == 10000
+		})).Return(nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyValidationResult(&protocol.JudgeResult{
+			SubmissionID: ValidationJudgeID(3, "run2"),
+			Status:       protocol.StatusSimTimeExceeded,
+			TotalTests:   1,
+			JudgedAt:     time.Now(),
+			Cases:        []protocol.CaseResult{{Index: 1, Status: protocol.StatusSimTimeExceeded, SimTime: 100000, Cycles: 10000}},
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("丢弃过期结果", func(t *testing.T) {
+		mockRepo := new(MockProblemRepository)
+		mockRepo.On("GetByID", uint(3)).Return(pending, nil)
+
+		service := NewProblemService(mockRepo, nil)
+		err := service.ApplyValidationResult(&protocol.JudgeResult{
+			SubmissionID: ValidationJudgeID(3, "run1"),
+			Status:       protocol.StatusAccepted,
+		})
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateValidation", mock.Anything, mock.Anything)
+	})
+}
+
+// TestProblemService_SetProblemPublic 测试发布题目
+func TestProblemService_SetProblemPublic(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem *domain.Problem
+		public  bool
+		wantErr error
+	}{
+		{
+			name:    "校验通过后发布",
+			problem: &domain.Problem{ID: 1, Validation: domain.ProblemValidation{Status: domain.ValidationValidated}},
+			public:  true,
+		},
+		{
+			name:    "校验未通过时禁止发布",
+			problem: &domain.Problem{ID: 1, Validation: domain.ProblemValidation{Status: domain.ValidationInvalid}},
+			public:  true,
+			wantErr: ErrProblemNotValidated,
+		},
+		{
+			name:    "校验进行中时禁止发布",
+			problem: &domain.Problem{ID: 1, Validation: domain.ProblemValidation{Status: domain.ValidationPending}},
+			public:  true,
+			wantErr: ErrProblemNotValidated,
+		},
+		{
+			name:    "撤回不需要校验",
+			problem: &domain.Problem{ID: 1, IsPublic: true, Validation: domain.ProblemValidation{Status: domain.ValidationInvalid}},
+			public:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockProblemRepository)
+			mockRepo.On("GetByID", uint(1)).Return(tt.problem, nil)
+			if tt.wantErr == nil {
+				mockRepo.On("Update", mock.MatchedBy(func(p *domain.Problem) bool {
+					return p.IsPublic == tt.public
+				})).Return(nil)
+			}
+
+			service := NewProblemService(mockRepo, nil)
+			problem, err := service.SetProblemPublic(1, tt.public)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, problem)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.public, problem.IsPublic)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package services
 
+import "verilog-oj/protocol"
+
 // Services 包含所有服务
 type Services struct {
 	UserService       *UserService
@@ -18,13 +20,25 @@ func NewServices(
 	forumRepo ForumRepository,
 	newsRepo NewsRepository,
 	adminRepo AdminRepository,
+	judgeQueue JudgeQueue,
 ) *Services {
 	return &Services{
 		UserService:       NewUserService(userRepo),
-		ProblemService:    NewProblemService(problemRepo),
-		SubmissionService: NewSubmissionService(submissionRepo, problemRepo, userRepo),
+		ProblemService:    NewProblemService(problemRepo, judgeQueue),
+		SubmissionService: NewSubmissionService(submissionRepo, problemRepo, userRepo, judgeQueue),
 		ForumService:      NewForumService(forumRepo, userRepo),
 		NewsService:       NewNewsService(newsRepo, userRepo),
 		AdminService:      NewAdminService(adminRepo),
 	}
 }
+
+// ApplyJudgeResult 分发判题结果：参考答案校验和期望输出生成的结果写回题目，其余写回提交记录
+func (s *Services) ApplyJudgeResult(result *protocol.JudgeResult) error {
+	if IsValidationJudgeID(result.SubmissionID) {
+		return s.ProblemService.ApplyValidationResult(result)
+	}
+	if IsOutputGenerationJudgeID(result.SubmissionID) {
+		return s.ProblemService.ApplyOutputGenerationResult(result)
+	}
+	return s.SubmissionService.ApplyJudgeResult(result)
+}
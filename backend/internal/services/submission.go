@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"log"
+	"strconv"
+	"time"
 	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
 )
 
 // SubmissionRepository 提交仓储接口
@@ -14,29 +18,68 @@ type SubmissionRepository interface {
 	GetByID(id uint) (*domain.Submission, error)
 	// 获取提交列表
 	List(page, limit int, userID, problemID uint, status string) ([]domain.Submission, int64, error)
-	// 更新提交状态
+	// 更新提交状态，更新为通过时在同一个事务中更新用户和题目的通过统计
 	UpdateStatus(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) error
+	// 写回判题结果，只更新等待中或判题中的提交，返回是否更新；结果为通过时在同一个事务中更新通过统计
+	FinishJudging(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) (bool, error)
 	// 统计用户通过的题目数
 	CountAcceptedByUser(userID, problemID uint) (int64, error)
 	// 获取提交统计信息
 	GetStats(userID uint) (map[string]interface{}, error)
 	// 软删除提交记录
 	SoftDelete(id uint) error
+	// 获取更新时间早于 before 的等待中或判题中的提交
+	ListStuck(before time.Time, limit int) ([]domain.Submission, error)
+	// 记录看门狗的处理，重新入队时刷新提交的更新时间
+	RecordRecovery(recovery *domain.SubmissionRecovery) error
+	// 获取提交的看门狗处理记录
+	ListRecoveries(submissionID uint) ([]domain.SubmissionRecovery, error)
 }
 
+// 取消判题的错误
+var (
+	ErrSubmissionNotCancellable = errors.New("提交已判题结束，不能取消")
+	ErrCancelForbidden          = errors.New("没有权限取消此提交记录")
+)
+
+// ErrQueueStatsUnavailable 判题队列不支持查询时返回
+var ErrQueueStatsUnavailable = errors.New("当前判题队列不支持查询排队情况")
+
 // SubmissionService 提交服务
 type SubmissionService struct {
 	submissionRepo SubmissionRepository
 	problemRepo    ProblemRepository
 	userRepo       UserRepository
+	judgeQueue     JudgeQueue
+	judgeEvents    JudgeEventSource
+	judgeCanceller JudgeCanceller
+	judgeInspector JudgeQueueInspector
+	judgeTracker   JudgeJobTracker
+	watchdog       WatchdogOptions
 }
 
+// finalEventID 根据提交记录补发的 finished 事件的ID
+const finalEventID = "final"
+
 // NewSubmissionService 创建提交服务
-func NewSubmissionService(submissionRepo SubmissionRepository, problemRepo ProblemRepository, userRepo UserRepository) *SubmissionService {
+// judgeQueue 为 nil 时只保存提交记录，不推送判题任务；judgeQueue 实现 JudgeEventSource 时支持订阅判题进度，
+// 实现 JudgeCanceller 时取消提交会同时取消判题任务，实现 JudgeQueueInspector 时支持查询排队位置，
+// 实现 JudgeJobTracker 时看门狗只重新入队已经丢失的任务
+func NewSubmissionService(submissionRepo SubmissionRepository, problemRepo ProblemRepository, userRepo UserRepository, judgeQueue JudgeQueue) *SubmissionService {
+	judgeEvents, _ := judgeQueue.(JudgeEventSource)
+	judgeCanceller, _ := judgeQueue.(JudgeCanceller)
+	judgeInspector, _ := judgeQueue.(JudgeQueueInspector)
+	judgeTracker, _ := judgeQueue.(JudgeJobTracker)
 	return &SubmissionService{
 		submissionRepo: submissionRepo,
 		problemRepo:    problemRepo,
 		userRepo:       userRepo,
+		judgeQueue:     judgeQueue,
+		judgeEvents:    judgeEvents,
+		judgeCanceller: judgeCanceller,
+		judgeInspector: judgeInspector,
+		judgeTracker:   judgeTracker,
+		watchdog:       DefaultWatchdogOptions,
 	}
 }
 
@@ -115,9 +158,58 @@ func (s *SubmissionService) CreateSubmission(problemID uint, code, language stri
 		log.Printf("failed to update user submit stats: %v", err)
 	}
 
+	// 推送判题任务
+	if s.judgeQueue != nil {
+		if err := s.dispatchJudge(submission, problem); err != nil {
+			log.Printf("failed to dispatch submission %d: %v", submission.ID, err)
+			submission.Status = "system_error"
+			submission.ErrorMessage = "判题任务创建失败：" + err.Error()
+			if updateErr := s.submissionRepo.UpdateStatus(submission.ID, submission.Status, 0, 0, 0, submission.ErrorMessage, 0, 0); updateErr != nil {
+				log.Printf("failed to mark submission %d as system_error: %v", submission.ID, updateErr)
+			}
+		}
+	}
+
 	return submission, nil
 }
 
+// dispatchJudge 构造判题请求并推送到判题队列
+func (s *SubmissionService) dispatchJudge(submission *domain.Submission, problem *domain.Problem) error {
+	testCases, err := s.problemRepo.GetTestCases(problem.ID)
+	if err != nil {
+		return err
+	}
+
+	request, err := BuildJudgeRequest(submission, problem, testCases)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.judgeQueue.Push(ctx, request)
+}
+
+// ApplyJudgeResult 将判题服务返回的结果写回提交记录
+// 结果可能被重复投递，只有第一次写回的结果更新统计，之后的结果和取消后才到达的结果被忽略
+// 提交状态和通过统计由 FinishJudging 在同一个事务中写入
+func (s *SubmissionService) ApplyJudgeResult(result *protocol.JudgeResult) error {
+	id, err := ParseJudgeSubmissionID(result.SubmissionID)
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.submissionRepo.FinishJudging(id, result.Status, result.Score, result.RunTime, result.Memory,
+		result.ErrorMessage, result.PassedTests, result.TotalTests)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		log.Printf("ignoring judge result for submission %d: already judged or cancelled", id)
+	}
+	return nil
+}
+
 // GetSubmission 获取提交详情
 func (s *SubmissionService) GetSubmission(id uint) (*domain.Submission, error) {
 	submission, err := s.submissionRepo.GetByID(id)
@@ -131,6 +223,79 @@ func (s *SubmissionService) GetSubmission(id uint) (*domain.Submission, error) {
 	return submission, nil
 }
 
+// SubscribeJudgeEvents 订阅提交的判题进度事件，afterID 为客户端最后收到的事件ID，之前的事件不再发送
+// 判题结束的提交只补发已记录的事件，记录中没有 finished 事件（例如已过期）时根据提交记录补上
+func (s *SubmissionService) SubscribeJudgeEvents(ctx context.Context, id uint, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	submission, err := s.GetSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	submissionID := strconv.FormatUint(uint64(id), 10)
+
+	if submission.Status != "pending" && submission.Status != "judging" {
+		return s.replayJudgeEvents(ctx, submission, submissionID, afterID)
+	}
+	if s.judgeEvents == nil {
+		return nil, errors.New("判题队列未启用或不支持进度事件")
+	}
+	return s.judgeEvents.SubscribeEvents(ctx, submissionID, afterID)
+}
+
+// replayJudgeEvents 补发已结束提交的判题进度事件
+func (s *SubmissionService) replayJudgeEvents(ctx context.Context, submission *domain.Submission, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	events := []*protocol.JudgeEvent{}
+	if s.judgeEvents != nil {
+		recorded, err := s.judgeEvents.ReadEvents(ctx, submissionID, "")
+		if err != nil {
+			return nil, err
+		}
+		events = recorded
+	}
+
+	finished := false
+	for _, event := range events {
+		if event.Type == protocol.EventFinished {
+			finished = true
+		}
+	}
+	if !finished {
+		events = append(events, finalJudgeEvent(submission, submissionID))
+	}
+
+	// 只发送 afterID 之后的事件，找不到 afterID 时全部重发
+	for i, event := range events {
+		if afterID != "" && event.ID == afterID {
+			events = events[i+1:]
+			break
+		}
+	}
+
+	eventChan := make(chan *protocol.JudgeEvent, len(events))
+	for _, event := range events {
+		eventChan <- event
+	}
+	close(eventChan)
+	return eventChan, nil
+}
+
+// finalJudgeEvent 根据提交记录构造 finished 事件
+func finalJudgeEvent(submission *domain.Submission, submissionID string) *protocol.JudgeEvent {
+	event := protocol.NewEvent(submissionID, protocol.EventFinished)
+	event.ID = finalEventID
+	event.Time = submission.UpdatedAt
+	event.Result = &protocol.JudgeResult{
+		ProtocolVersion: protocol.Version,
+		SubmissionID:    submissionID,
+		Status:          submission.Status,
+		Score:           submission.Score,
+		ErrorMessage:    submission.ErrorMessage,
+		PassedTests:     submission.PassedTests,
+		TotalTests:      submission.TotalTests,
+		JudgedAt:        submission.UpdatedAt,
+	}
+	return event
+}
+
 // ListSubmissions 获取提交列表
 func (s *SubmissionService) ListSubmissions(page, limit int, userID, problemID uint, status string) (*SubmissionListResult, error) {
 	// 验证分页参数
@@ -154,51 +319,50 @@ func (s *SubmissionService) ListSubmissions(page, limit int, userID, problemID u
 	}, nil
 }
 
-// UpdateSubmissionStatus 更新提交状态
-func (s *SubmissionService) UpdateSubmissionStatus(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) error {
-	// 更新提交状态
-	if err := s.submissionRepo.UpdateStatus(id, status, score, runTime, memory, errorMessage, passedTests, totalTests); err != nil {
-		return err
+// GetQueuePosition 返回等待中的提交在判题队列中的位置和预计开始时间
+// 提交不在等待中、任务已被取出或判题队列不支持查询时返回 nil
+func (s *SubmissionService) GetQueuePosition(submission *domain.Submission) (*domain.QueuePosition, error) {
+	if s.judgeInspector == nil || submission.Status != "pending" {
+		return nil, nil
 	}
 
-	// 如果状态是accepted，更新用户和题目的统计
-	if status == "accepted" {
-		// 获取提交信息
-		submission, err := s.submissionRepo.GetByID(id)
-		if err != nil {
-			return err
-		}
-		if submission == nil {
-			return errors.New("提交记录不存在")
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lane, position, err := s.judgeInspector.QueuePosition(ctx, strconv.FormatUint(uint64(submission.ID), 10), submission.Language)
+	if err != nil || position == 0 {
+		return nil, err
+	}
+	stats, err := s.judgeInspector.QueueStats(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		// 检查是否是第一次通过此题
-		existingAccepted, err := s.submissionRepo.CountAcceptedByUser(submission.UserID, submission.ProblemID)
-		if err != nil {
-			return err
+	result := &domain.QueuePosition{Lane: lane, Position: position}
+	// 全部节点每 AverageJudgeTime 共完成 Workers 个任务；正在判题的任务和同通道中排在前面的任务
+	// 不少于 Workers 个时，需要等其中 ahead-Workers+1 个完成才能开始
+	if stats.Workers > 0 && stats.Samples > 0 {
+		ahead := stats.Processing + position - 1
+		if waiting := ahead - int64(stats.Workers) + 1; waiting > 0 {
+			result.EstimatedWait = time.Duration(waiting) * stats.AverageJudgeTime / time.Duration(stats.Workers)
 		}
+		result.EstimatedStart = time.Now().Add(result.EstimatedWait)
+	}
+	return result, nil
+}
 
-		if existingAccepted == 1 { // 这是第一次通过
-			// 获取用户当前信息
-			user, err := s.userRepo.GetByID(submission.UserID)
-			if err != nil {
-				return err
-			}
-			if user != nil {
-				// 更新用户的解题数
-				if err := s.userRepo.UpdateStats(submission.UserID, user.Solved+1, user.Submitted); err != nil {
-					return err
-				}
-			}
-
-			// 更新题目的通过数
-			if err := s.problemRepo.UpdateAcceptedCount(submission.ProblemID, 1); err != nil {
-				return err
-			}
-		}
+// GetQueueStats 返回判题队列概况
+func (s *SubmissionService) GetQueueStats() (*domain.QueueStats, error) {
+	if s.judgeInspector == nil {
+		return nil, ErrQueueStatsUnavailable
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.judgeInspector.QueueStats(ctx)
+}
 
-	return nil
+// UpdateSubmissionStatus 更新提交状态，更新为 accepted 时仓储在同一个事务中更新用户和题目的统计
+func (s *SubmissionService) UpdateSubmissionStatus(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) error {
+	return s.submissionRepo.UpdateStatus(id, status, score, runTime, memory, errorMessage, passedTests, totalTests)
 }
 
 // GetUserSubmissions 获取用户的提交记录
@@ -242,6 +406,52 @@ func (s *SubmissionService) ValidateSubmissionAccess(submissionID, userID uint)
 	return nil
 }
 
+// CancelSubmission 取消等待中或正在判题的提交（仅管理员或提交者本人）
+// 提交标记为 cancelled，并从提交者和题目的提交数中扣除
+func (s *SubmissionService) CancelSubmission(id uint, userID uint, userRole string) (*domain.Submission, error) {
+	submission, err := s.GetSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if submission.UserID != userID && userRole != "admin" && userRole != "super_admin" {
+		return nil, ErrCancelForbidden
+	}
+	if submission.Status != "pending" && submission.Status != "judging" {
+		return nil, ErrSubmissionNotCancellable
+	}
+
+	if s.judgeCanceller != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := s.judgeCanceller.Cancel(ctx, strconv.FormatUint(uint64(id), 10), submission.Language); err != nil {
+			return nil, err
+		}
+	}
+
+	submission.Status = "cancelled"
+	submission.ErrorMessage = "判题已取消"
+	submission.Score, submission.PassedTests, submission.TotalTests = 0, 0, 0
+	if err := s.submissionRepo.UpdateStatus(id, submission.Status, 0, 0, 0, submission.ErrorMessage, 0, 0); err != nil {
+		return nil, err
+	}
+
+	// 已取消的提交不计入统计
+	if err := s.problemRepo.UpdateSubmitCount(submission.ProblemID, -1); err != nil {
+		log.Printf("failed to update problem submit count: %v", err)
+	}
+	user, err := s.userRepo.GetByID(submission.UserID)
+	if err != nil {
+		log.Printf("failed to load user %d for submit stats: %v", submission.UserID, err)
+	} else if user != nil && user.Submitted > 0 {
+		if err := s.userRepo.UpdateStats(user.ID, user.Solved, user.Submitted-1); err != nil {
+			log.Printf("failed to update user submit stats: %v", err)
+		}
+	}
+
+	return submission, nil
+}
+
 // DeleteSubmission 删除提交记录（仅管理员或提交者本人）
 func (s *SubmissionService) DeleteSubmission(id uint, userID uint, userRole string) error {
 	submission, err := s.GetSubmission(id)
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 	"verilog-oj/backend/internal/domain"
+	"verilog-oj/protocol"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -34,6 +37,11 @@ func (m *MockSubmissionRepository) UpdateStatus(id uint, status string, score in
 	return args.Error(0)
 }
 
+func (m *MockSubmissionRepository) FinishJudging(id uint, status string, score int, runTime, memory int, errorMessage string, passedTests, totalTests int) (bool, error) {
+	args := m.Called(id, status, score, runTime, memory, errorMessage, passedTests, totalTests)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockSubmissionRepository) CountAcceptedByUser(userID, problemID uint) (int64, error) {
 	args := m.Called(userID, problemID)
 	return args.Get(0).(int64), args.Error(1)
@@ -49,6 +57,42 @@ func (m *MockSubmissionRepository) SoftDelete(id uint) error {
 	return args.Error(0)
 }
 
+func (m *MockSubmissionRepository) ListStuck(before time.Time, limit int) ([]domain.Submission, error) {
+	args := m.Called(before, limit)
+	return args.Get(0).([]domain.Submission), args.Error(1)
+}
+
+func (m *MockSubmissionRepository) RecordRecovery(recovery *domain.SubmissionRecovery) error {
+	args := m.Called(recovery)
+	return args.Error(0)
+}
+
+func (m *MockSubmissionRepository) ListRecoveries(submissionID uint) ([]domain.SubmissionRecovery, error) {
+	args := m.Called(submissionID)
+	return args.Get(0).([]domain.SubmissionRecovery), args.Error(1)
+}
+
+// MockJudgeEventQueue Mock 支持进度事件的判题队列
+type MockJudgeEventQueue struct {
+	MockJudgeQueue
+}
+
+func (m *MockJudgeEventQueue) SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	args := m.Called(ctx, submissionID, afterID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan *protocol.JudgeEvent), args.Error(1)
+}
+
+func (m *MockJudgeEventQueue) ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error) {
+	args := m.Called(ctx, submissionID, afterID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*protocol.JudgeEvent), args.Error(1)
+}
+
 // TestSubmissionService_CreateSubmission 测试创建提交
 func TestSubmissionService_CreateSubmission(t *testing.T) {
 	tests := []struct {
@@ -218,7 +262,7 @@ func TestSubmissionService_CreateSubmission(t *testing.T) {
 			}
 
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			result, err := service.CreateSubmission(tt.problemID, tt.code, tt.language, tt.userID)
@@ -297,7 +341,7 @@ func TestSubmissionService_GetSubmission(t *testing.T) {
 			mockSubmissionRepo.On("GetByID", tt.id).Return(tt.mockSubmission, tt.repoError)
 
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			result, err := service.GetSubmission(tt.id)
@@ -412,7 +456,7 @@ func TestSubmissionService_ListSubmissions(t *testing.T) {
 			mockSubmissionRepo.On("List", expectedPage, expectedLimit, tt.userID, tt.problemID, tt.status).Return(tt.mockSubmissions, tt.mockTotal, tt.repoError)
 
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			result, err := service.ListSubmissions(tt.page, tt.limit, tt.userID, tt.problemID, tt.status)
@@ -438,23 +482,20 @@ func TestSubmissionService_ListSubmissions(t *testing.T) {
 }
 
 // TestSubmissionService_UpdateSubmissionStatus 测试更新提交状态
+// 通过统计由仓储在写入状态的同一个事务中更新，见 repository 中的测试
 func TestSubmissionService_UpdateSubmissionStatus(t *testing.T) {
 	tests := []struct {
-		name           string
-		id             uint
-		status         string
-		score          int
-		runTime        int
-		memory         int
-		errorMessage   string
-		passedTests    int
-		totalTests     int
-		mockSubmission *domain.Submission
-		mockUser       *domain.User
-		acceptedCount  int64
-		updateError    error
-		getError       error
-		expectedError  string
+		name          string
+		id            uint
+		status        string
+		score         int
+		runTime       int
+		memory        int
+		errorMessage  string
+		passedTests   int
+		totalTests    int
+		updateError   error
+		expectedError string
 	}{
 		{
 			name:         "成功更新为pending状态",
@@ -468,29 +509,7 @@ func TestSubmissionService_UpdateSubmissionStatus(t *testing.T) {
 			totalTests:   10,
 		},
 		{
-			name:         "成功更新为accepted状态-首次通过",
-			id:           1,
-			status:       "accepted",
-			score:        100,
-			runTime:      500,
-			memory:       1024,
-			errorMessage: "",
-			passedTests:  10,
-			totalTests:   10,
-			mockSubmission: &domain.Submission{
-				ID:        1,
-				UserID:    1,
-				ProblemID: 1,
-			},
-			mockUser: &domain.User{
-				ID:        1,
-				Solved:    5,
-				Submitted: 10,
-			},
-			acceptedCount: 1,
-		},
-		{
-			name:         "成功更新为accepted状态-非首次通过",
+			name:         "成功更新为accepted状态",
 			id:           1,
 			status:       "accepted",
 			score:        100,
@@ -499,12 +518,6 @@ func TestSubmissionService_UpdateSubmissionStatus(t *testing.T) {
 			errorMessage: "",
 			passedTests:  10,
 			totalTests:   10,
-			mockSubmission: &domain.Submission{
-				ID:        1,
-				UserID:    1,
-				ProblemID: 1,
-			},
-			acceptedCount: 2,
 		},
 		{
 			name:          "更新状态失败",
@@ -513,20 +526,6 @@ func TestSubmissionService_UpdateSubmissionStatus(t *testing.T) {
 			updateError:   errors.New("update failed"),
 			expectedError: "update failed",
 		},
-		{
-			name:          "accepted状态但获取提交失败",
-			id:            1,
-			status:        "accepted",
-			getError:      errors.New("get submission failed"),
-			expectedError: "get submission failed",
-		},
-		{
-			name:           "accepted状态但提交不存在",
-			id:             1,
-			status:         "accepted",
-			mockSubmission: nil,
-			expectedError:  "提交记录不存在",
-		},
 	}
 
 	for _, tt := range tests {
@@ -539,22 +538,8 @@ func TestSubmissionService_UpdateSubmissionStatus(t *testing.T) {
 			// 设置 Mock 期望
 			mockSubmissionRepo.On("UpdateStatus", tt.id, tt.status, tt.score, tt.runTime, tt.memory, tt.errorMessage, tt.passedTests, tt.totalTests).Return(tt.updateError)
 
-			if tt.updateError == nil && tt.status == "accepted" {
-				mockSubmissionRepo.On("GetByID", tt.id).Return(tt.mockSubmission, tt.getError)
-				if tt.getError == nil && tt.mockSubmission != nil {
-					mockSubmissionRepo.On("CountAcceptedByUser", tt.mockSubmission.UserID, tt.mockSubmission.ProblemID).Return(tt.acceptedCount, nil)
-					if tt.acceptedCount == 1 {
-						mockUserRepo.On("GetByID", tt.mockSubmission.UserID).Return(tt.mockUser, nil)
-						if tt.mockUser != nil {
-							mockUserRepo.On("UpdateStats", tt.mockSubmission.UserID, tt.mockUser.Solved+1, tt.mockUser.Submitted).Return(nil)
-						}
-						mockProblemRepo.On("UpdateAcceptedCount", tt.mockSubmission.ProblemID, 1).Return(nil)
-					}
-				}
-			}
-
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			err := service.UpdateSubmissionStatus(tt.id, tt.status, tt.score, tt.runTime, tt.memory, tt.errorMessage, tt.passedTests, tt.totalTests)
@@ -591,7 +576,7 @@ func TestSubmissionService_GetUserSubmissions(t *testing.T) {
 
 	mockSubmissionRepo.On("List", page, limit, userID, uint(0), "").Return(mockSubmissions, mockTotal, nil)
 
-	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 	result, err := service.GetUserSubmissions(userID, page, limit)
 
 	assert.NoError(t, err)
@@ -617,7 +602,7 @@ func TestSubmissionService_GetProblemSubmissions(t *testing.T) {
 
 	mockSubmissionRepo.On("List", page, limit, uint(0), problemID, "").Return(mockSubmissions, mockTotal, nil)
 
-	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 	result, err := service.GetProblemSubmissions(problemID, page, limit)
 
 	assert.NoError(t, err)
@@ -642,7 +627,7 @@ func TestSubmissionService_GetSubmissionStats(t *testing.T) {
 
 	mockSubmissionRepo.On("GetStats", userID).Return(mockStats, nil)
 
-	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+	service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 	result, err := service.GetSubmissionStats(userID)
 
 	assert.NoError(t, err)
@@ -749,7 +734,7 @@ func TestSubmissionService_ValidateSubmissionAccess(t *testing.T) {
 			}
 
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			err := service.ValidateSubmissionAccess(tt.submissionID, tt.userID)
@@ -848,7 +833,7 @@ func TestSubmissionService_DeleteSubmission(t *testing.T) {
 			}
 
 			// 创建服务
-			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo)
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, nil)
 
 			// 执行测试
 			err := service.DeleteSubmission(tt.id, tt.userID, tt.userRole)
@@ -866,3 +851,274 @@ func TestSubmissionService_DeleteSubmission(t *testing.T) {
 		})
 	}
 }
+
+// MockJudgeCancelQueue Mock 支持取消判题的判题队列
+type MockJudgeCancelQueue struct {
+	MockJudgeQueue
+}
+
+func (m *MockJudgeCancelQueue) Cancel(ctx context.Context, submissionID, language string) (bool, error) {
+	args := m.Called(submissionID, language)
+	return args.Bool(0), args.Error(1)
+}
+
+// TestSubmissionService_CancelSubmission 测试取消判题
+func TestSubmissionService_CancelSubmission(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        uint
+		userRole      string
+		status        string
+		cancelError   error
+		expectedError error
+	}{
+		{name: "提交者取消等待中的提交", userID: 1, userRole: "student", status: "pending"},
+		{name: "管理员取消正在判题的提交", userID: 2, userRole: "admin", status: "judging"},
+		{name: "无权限取消", userID: 2, userRole: "student", status: "pending", expectedError: ErrCancelForbidden},
+		{name: "已判题结束", userID: 1, userRole: "student", status: "accepted", expectedError: ErrSubmissionNotCancellable},
+		{name: "取消判题任务失败", userID: 1, userRole: "student", status: "pending", cancelError: errors.New("queue unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSubmissionRepo := new(MockSubmissionRepository)
+			mockProblemRepo := new(MockProblemRepository)
+			mockUserRepo := new(MockUserRepository)
+			mockQueue := new(MockJudgeCancelQueue)
+
+			submission := &domain.Submission{ID: 5, UserID: 1, ProblemID: 3, Language: "verilog", Status: tt.status}
+			mockSubmissionRepo.On("GetByID", uint(5)).Return(submission, nil)
+			reachesQueue := tt.expectedError == nil
+			succeeds := reachesQueue && tt.cancelError == nil
+			if reachesQueue {
+				mockQueue.On("Cancel", "5", "verilog").Return(false, tt.cancelError)
+			}
+			if succeeds {
+				mockSubmissionRepo.On("UpdateStatus", uint(5), "cancelled", 0, 0, 0, "判题已取消", 0, 0).Return(nil)
+				mockProblemRepo.On("UpdateSubmitCount", uint(3), -1).Return(nil)
+				mockUserRepo.On("GetByID", uint(1)).Return(&domain.User{ID: 1, Solved: 2, Submitted: 4}, nil)
+				mockUserRepo.On("UpdateStats", uint(1), 2, 3).Return(nil)
+			}
+
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, mockUserRepo, mockQueue)
+			cancelled, err := service.CancelSubmission(5, tt.userID, tt.userRole)
+
+			switch {
+			case tt.expectedError != nil:
+				assert.ErrorIs(t, err, tt.expectedError)
+			case tt.cancelError != nil:
+				assert.ErrorIs(t, err, tt.cancelError)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, "cancelled", cancelled.Status)
+			}
+			mockQueue.AssertExpectations(t)
+			mockSubmissionRepo.AssertExpectations(t)
+			mockProblemRepo.AssertExpectations(t)
+			mockUserRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// MockJudgeInspectQueue Mock 支持查询排队情况的判题队列
+type MockJudgeInspectQueue struct {
+	MockJudgeQueue
+}
+
+func (m *MockJudgeInspectQueue) QueuePosition(ctx context.Context, submissionID, language string) (string, int64, error) {
+	args := m.Called(submissionID, language)
+	return args.String(0), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockJudgeInspectQueue) QueueStats(ctx context.Context) (*domain.QueueStats, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QueueStats), args.Error(1)
+}
+
+// TestSubmissionService_GetQueuePosition 测试排队位置和预计开始时间
+func TestSubmissionService_GetQueuePosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		position     int64
+		stats        *domain.QueueStats
+		expectedWait time.Duration
+		estimated    bool
+	}{
+		{
+			name:      "有空闲名额时立即开始",
+			position:  1,
+			stats:     &domain.QueueStats{Processing: 1, Workers: 2, AverageJudgeTime: 4 * time.Second, Samples: 10},
+			estimated: true,
+		},
+		{
+			name:         "按并发数分摊前面的任务",
+			position:     3,
+			stats:        &domain.QueueStats{Processing: 2, Workers: 2, AverageJudgeTime: 4 * time.Second, Samples: 10},
+			expectedWait: 6 * time.Second,
+			estimated:    true,
+		},
+		{
+			name:     "没有判题耗时记录",
+			position: 3,
+			stats:    &domain.QueueStats{Processing: 2, Workers: 2},
+		},
+		{
+			name:     "没有存活节点",
+			position: 3,
+			stats:    &domain.QueueStats{AverageJudgeTime: 4 * time.Second, Samples: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := new(MockJudgeInspectQueue)
+			mockQueue.On("QueuePosition", "5", "verilog").Return("lang-verilog", tt.position, nil)
+			mockQueue.On("QueueStats").Return(tt.stats, nil)
+
+			service := NewSubmissionService(new(MockSubmissionRepository), new(MockProblemRepository), new(MockUserRepository), mockQueue)
+			position, err := service.GetQueuePosition(&domain.Submission{ID: 5, Language: "verilog", Status: "pending"})
+
+			assert.NoError(t, err)
+			if assert.NotNil(t, position) {
+				assert.Equal(t, "lang-verilog", position.Lane)
+				assert.Equal(t, tt.position, position.Position)
+				assert.Equal(t, tt.expectedWait, position.EstimatedWait)
+				assert.Equal(t, tt.estimated, !position.EstimatedStart.IsZero())
+			}
+			mockQueue.AssertExpectations(t)
+		})
+	}
+
+	t.Run("任务已被取出", func(t *testing.T) {
+		mockQueue := new(MockJudgeInspectQueue)
+		mockQueue.On("QueuePosition", "5", "verilog").Return("", int64(0), nil)
+
+		service := NewSubmissionService(new(MockSubmissionRepository), new(MockProblemRepository), new(MockUserRepository), mockQueue)
+		position, err := service.GetQueuePosition(&domain.Submission{ID: 5, Language: "verilog", Status: "pending"})
+
+		assert.NoError(t, err)
+		assert.Nil(t, position)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("提交不在等待中", func(t *testing.T) {
+		mockQueue := new(MockJudgeInspectQueue)
+
+		service := NewSubmissionService(new(MockSubmissionRepository), new(MockProblemRepository), new(MockUserRepository), mockQueue)
+		position, err := service.GetQueuePosition(&domain.Submission{ID: 5, Language: "verilog", Status: "judging"})
+
+		assert.NoError(t, err)
+		assert.Nil(t, position)
+		mockQueue.AssertNotCalled(t, "QueuePosition", mock.Anything, mock.Anything)
+	})
+
+	t.Run("队列不支持查询", func(t *testing.T) {
+		service := NewSubmissionService(new(MockSubmissionRepository), new(MockProblemRepository), new(MockUserRepository), new(MockJudgeQueue))
+
+		position, err := service.GetQueuePosition(&domain.Submission{ID: 5, Status: "pending"})
+		assert.NoError(t, err)
+		assert.Nil(t, position)
+
+		_, err = service.GetQueueStats()
+		assert.ErrorIs(t, err, ErrQueueStatsUnavailable)
+	})
+}
+
+// TestSubmissionService_SubscribeJudgeEvents 测试订阅判题进度
+func TestSubmissionService_SubscribeJudgeEvents(t *testing.T) {
+	ctx := context.Background()
+	event := func(id, eventType string) *protocol.JudgeEvent {
+		e := protocol.NewEvent("1", eventType)
+		e.ID = id
+		return e
+	}
+	collect := func(events <-chan *protocol.JudgeEvent) []string {
+		ids := []string{}
+		for e := range events {
+			ids = append(ids, e.ID+":"+e.Type)
+		}
+		return ids
+	}
+
+	t.Run("判题中的提交订阅队列事件", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockQueue := new(MockJudgeEventQueue)
+		mockSubmissionRepo.On("GetByID", uint(1)).Return(&domain.Submission{ID: 1, Status: "judging"}, nil)
+
+		live := make(chan *protocol.JudgeEvent, 1)
+		live <- event("2-0", protocol.EventCompiling)
+		close(live)
+		mockQueue.On("SubscribeEvents", ctx, "1", "1-0").Return((<-chan *protocol.JudgeEvent)(live), nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), mockQueue)
+		events, err := service.SubscribeJudgeEvents(ctx, 1, "1-0")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2-0:compiling"}, collect(events))
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("已结束的提交只补发之后的事件", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockQueue := new(MockJudgeEventQueue)
+		mockSubmissionRepo.On("GetByID", uint(1)).Return(&domain.Submission{ID: 1, Status: "accepted"}, nil)
+		mockQueue.On("ReadEvents", ctx, "1", "").Return([]*protocol.JudgeEvent{
+			event("1-0", protocol.EventQueued),
+			event("2-0", protocol.EventCompiling),
+			event("3-0", protocol.EventFinished),
+		}, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), mockQueue)
+		events, err := service.SubscribeJudgeEvents(ctx, 1, "1-0")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2-0:compiling", "3-0:finished"}, collect(events))
+		mockQueue.AssertNotCalled(t, "SubscribeEvents", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("事件已过期时根据提交记录补发结果", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockQueue := new(MockJudgeEventQueue)
+		mockSubmissionRepo.On("GetByID", uint(1)).Return(&domain.Submission{ID: 1, Status: "wrong_answer", Score: 50, PassedTests: 1, TotalTests: 2}, nil)
+		mockQueue.On("ReadEvents", ctx, "1", "").Return([]*protocol.JudgeEvent{}, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), mockQueue)
+		events, err := service.SubscribeJudgeEvents(ctx, 1, "")
+
+		assert.NoError(t, err)
+		final := <-events
+		assert.Equal(t, protocol.EventFinished, final.Type)
+		assert.Equal(t, "wrong_answer", final.Result.Status)
+		assert.Equal(t, 50, final.Result.Score)
+		assert.Equal(t, 1, final.Result.PassedTests)
+
+		// 收到补发的结果后重连不再重复发送
+		events, err = service.SubscribeJudgeEvents(ctx, 1, final.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, collect(events))
+	})
+
+	t.Run("判题队列不支持进度事件", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockSubmissionRepo.On("GetByID", uint(1)).Return(&domain.Submission{ID: 1, Status: "pending"}, nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), new(MockJudgeQueue))
+		events, err := service.SubscribeJudgeEvents(ctx, 1, "")
+
+		assert.Nil(t, events)
+		assert.EqualError(t, err, "判题队列未启用或不支持进度事件")
+	})
+
+	t.Run("提交不存在", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		mockSubmissionRepo.On("GetByID", uint(9)).Return((*domain.Submission)(nil), nil)
+
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), nil)
+		_, err := service.SubscribeJudgeEvents(ctx, 9, "")
+
+		assert.EqualError(t, err, "提交记录不存在")
+	})
+}
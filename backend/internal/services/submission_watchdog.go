@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"verilog-oj/backend/internal/domain"
+)
+
+// WatchdogOptions 卡住提交的看门狗配置
+type WatchdogOptions struct {
+	StuckAfter  time.Duration // 等待或判题超过该时间未更新的提交视为卡住
+	MaxAttempts int           // 最多重新入队的次数，用尽后标记为 system_error
+}
+
+// DefaultWatchdogOptions 看门狗默认配置
+var DefaultWatchdogOptions = WatchdogOptions{
+	StuckAfter:  10 * time.Minute,
+	MaxAttempts: 3,
+}
+
+// watchdogBatch 每次检查最多处理的提交数
+const watchdogBatch = 100
+
+// SetWatchdogOptions 设置看门狗配置，需要在 RunWatchdog 和处理请求之前调用
+func (s *SubmissionService) SetWatchdogOptions(options WatchdogOptions) {
+	s.watchdog = options
+}
+
+// RunWatchdog 每隔 interval 检查一次卡住的提交，直到 ctx 取消
+func (s *SubmissionService) RunWatchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.CheckStuckSubmissions(now); err != nil {
+				log.Printf("submission watchdog: %v", err)
+			}
+		}
+	}
+}
+
+// CheckStuckSubmissions 检查一次卡住的提交：仍有判题任务的跳过，否则重新入队，
+// 重新入队次数用尽时标记为 system_error。每次处理都会记录，未配置判题队列时不做任何处理
+func (s *SubmissionService) CheckStuckSubmissions(now time.Time) error {
+	if s.judgeQueue == nil {
+		return nil
+	}
+
+	cutoff := now.Add(-s.watchdog.StuckAfter)
+	submissions, err := s.submissionRepo.ListStuck(cutoff, watchdogBatch)
+	if err != nil {
+		return err
+	}
+	for i := range submissions {
+		if err := s.recoverSubmission(&submissions[i], cutoff); err != nil {
+			log.Printf("submission watchdog: failed to recover submission %d: %v", submissions[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// recoverSubmission 处理一个卡住的提交，cutoff 之后仍有判题进度的任务视为仍在进行
+func (s *SubmissionService) recoverSubmission(submission *domain.Submission, cutoff time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	submissionID := strconv.FormatUint(uint64(submission.ID), 10)
+
+	if s.judgeTracker != nil {
+		active, err := s.judgeTracker.JobActive(ctx, submissionID, submission.Language, cutoff)
+		if err != nil {
+			return err
+		}
+		if active {
+			return nil
+		}
+	}
+
+	recoveries, err := s.submissionRepo.ListRecoveries(submission.ID)
+	if err != nil {
+		return err
+	}
+	attempts := 0
+	for _, recovery := range recoveries {
+		if recovery.Action == domain.RecoveryRequeued {
+			attempts++
+		}
+	}
+	reason := fmt.Sprintf("%s 状态超过 %s 未更新", submission.Status, s.watchdog.StuckAfter)
+
+	if attempts >= s.watchdog.MaxAttempts {
+		return s.failStuckSubmission(submission, attempts, reason, fmt.Sprintf("判题超时：重新判题 %d 次后仍未完成", attempts))
+	}
+
+	problem, err := s.problemRepo.GetByID(submission.ProblemID)
+	if err != nil {
+		return err
+	}
+	if problem == nil {
+		return s.failStuckSubmission(submission, attempts, reason+"，题目已删除", "判题超时：题目已删除")
+	}
+
+	if s.judgeTracker != nil {
+		if err := s.judgeTracker.DropJob(ctx, submissionID); err != nil {
+			return err
+		}
+	}
+	// 入队失败同样计入次数，避免无法入队的提交每次检查都重试
+	if err := s.dispatchJudge(submission, problem); err != nil {
+		reason += "，重新入队失败：" + err.Error()
+	}
+	log.Printf("submission watchdog: requeued submission %d (attempt %d): %s", submission.ID, attempts+1, reason)
+	return s.submissionRepo.RecordRecovery(&domain.SubmissionRecovery{
+		SubmissionID: submission.ID,
+		Action:       domain.RecoveryRequeued,
+		Attempt:      attempts + 1,
+		Reason:       reason,
+	})
+}
+
+// failStuckSubmission 将卡住的提交标记为 system_error 并记录，提交在此期间已有结果时不做处理
+func (s *SubmissionService) failStuckSubmission(submission *domain.Submission, attempts int, reason, message string) error {
+	applied, err := s.submissionRepo.FinishJudging(submission.ID, "system_error", 0, 0, 0, message, 0, 0)
+	if err != nil || !applied {
+		return err
+	}
+	log.Printf("submission watchdog: marked submission %d as system_error: %s", submission.ID, reason)
+	return s.submissionRepo.RecordRecovery(&domain.SubmissionRecovery{
+		SubmissionID: submission.ID,
+		Action:       domain.RecoveryFailed,
+		Attempt:      attempts,
+		Reason:       reason,
+	})
+}
+
+// ListStuckSubmissions 返回当前卡住的提交及看门狗的处理记录
+func (s *SubmissionService) ListStuckSubmissions() ([]domain.StuckSubmission, error) {
+	submissions, err := s.submissionRepo.ListStuck(time.Now().Add(-s.watchdog.StuckAfter), watchdogBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	stuck := make([]domain.StuckSubmission, 0, len(submissions))
+	for _, submission := range submissions {
+		recoveries, err := s.submissionRepo.ListRecoveries(submission.ID)
+		if err != nil {
+			return nil, err
+		}
+		stuck = append(stuck, domain.StuckSubmission{Submission: submission, Recoveries: recoveries})
+	}
+	return stuck, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"verilog-oj/backend/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJudgeTrackQueue Mock 支持跟踪判题任务的判题队列
+type MockJudgeTrackQueue struct {
+	MockJudgeQueue
+}
+
+func (m *MockJudgeTrackQueue) JobActive(ctx context.Context, submissionID, language string, since time.Time) (bool, error) {
+	args := m.Called(submissionID, language, since)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockJudgeTrackQueue) DropJob(ctx context.Context, submissionID string) error {
+	args := m.Called(submissionID)
+	return args.Error(0)
+}
+
+// TestSubmissionService_CheckStuckSubmissions 测试看门狗处理卡住的提交
+func TestSubmissionService_CheckStuckSubmissions(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	options := WatchdogOptions{StuckAfter: 10 * time.Minute, MaxAttempts: 2}
+	cutoff := now.Add(-options.StuckAfter)
+	problem := &domain.Problem{ID: 2, TimeLimit: 1000, MemoryLimit: 128}
+	testCases := []domain.TestCase{{Input: "module tb; endmodule", Output: "1"}}
+	requeued := domain.SubmissionRecovery{SubmissionID: 8, Action: domain.RecoveryRequeued}
+
+	tests := []struct {
+		name           string
+		active         bool
+		recoveries     []domain.SubmissionRecovery
+		pushError      error
+		finished       bool // 标记 system_error 时提交仍未有结果
+		expectedAction string
+		expectedTry    int
+	}{
+		{name: "任务仍在进行", active: true},
+		{name: "任务丢失时重新入队", expectedAction: domain.RecoveryRequeued, expectedTry: 1},
+		{name: "入队失败同样计数", pushError: errors.New("queue unavailable"), expectedAction: domain.RecoveryRequeued, expectedTry: 1},
+		{
+			name:           "重新入队次数用尽",
+			recoveries:     []domain.SubmissionRecovery{requeued, requeued},
+			finished:       true,
+			expectedAction: domain.RecoveryFailed,
+			expectedTry:    2,
+		},
+		{name: "标记前已有结果", recoveries: []domain.SubmissionRecovery{requeued, requeued}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSubmissionRepo := new(MockSubmissionRepository)
+			mockProblemRepo := new(MockProblemRepository)
+			mockQueue := new(MockJudgeTrackQueue)
+
+			submission := domain.Submission{ID: 8, ProblemID: 2, Language: "verilog", Status: "pending"}
+			mockSubmissionRepo.On("ListStuck", cutoff, watchdogBatch).Return([]domain.Submission{submission}, nil)
+			mockQueue.On("JobActive", "8", "verilog", cutoff).Return(tt.active, nil)
+			if !tt.active {
+				mockSubmissionRepo.On("ListRecoveries", uint(8)).Return(tt.recoveries, nil)
+			}
+			exhausted := len(tt.recoveries) >= options.MaxAttempts
+			if !tt.active && !exhausted {
+				mockProblemRepo.On("GetByID", uint(2)).Return(problem, nil)
+				mockProblemRepo.On("GetTestCases", uint(2)).Return(testCases, nil)
+				mockQueue.On("DropJob", "8").Return(nil)
+				mockQueue.On("Push", mock.AnythingOfType("*protocol.JudgeRequest")).Return(tt.pushError)
+			}
+			if exhausted {
+				mockSubmissionRepo.On("FinishJudging", uint(8), "system_error", 0, 0, 0, "判题超时：重新判题 2 次后仍未完成", 0, 0).Return(tt.finished, nil)
+			}
+			if tt.expectedAction != "" {
+				mockSubmissionRepo.On("RecordRecovery", mock.MatchedBy(func(recovery *domain.SubmissionRecovery) bool {
+					return recovery.SubmissionID == 8 && recovery.Action == tt.expectedAction && recovery.Attempt == tt.expectedTry
+				})).Return(nil)
+			}
+
+			service := NewSubmissionService(mockSubmissionRepo, mockProblemRepo, new(MockUserRepository), mockQueue)
+			service.SetWatchdogOptions(options)
+			err := service.CheckStuckSubmissions(now)
+
+			assert.NoError(t, err)
+			if tt.expectedAction == "" {
+				mockSubmissionRepo.AssertNotCalled(t, "RecordRecovery", mock.Anything)
+			}
+			mockQueue.AssertExpectations(t)
+			mockSubmissionRepo.AssertExpectations(t)
+			mockProblemRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("未配置判题队列", func(t *testing.T) {
+		mockSubmissionRepo := new(MockSubmissionRepository)
+		service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), nil)
+
+		assert.NoError(t, service.CheckStuckSubmissions(now))
+		mockSubmissionRepo.AssertNotCalled(t, "ListStuck", mock.Anything, mock.Anything)
+	})
+}
+
+// TestSubmissionService_ListStuckSubmissions 测试获取卡住的提交
+func TestSubmissionService_ListStuckSubmissions(t *testing.T) {
+	mockSubmissionRepo := new(MockSubmissionRepository)
+	submissions := []domain.Submission{{ID: 3, Status: "pending"}, {ID: 4, Status: "judging"}}
+	mockSubmissionRepo.On("ListStuck", mock.AnythingOfType("time.Time"), watchdogBatch).Return(submissions, nil)
+	mockSubmissionRepo.On("ListRecoveries", uint(3)).Return([]domain.SubmissionRecovery{{SubmissionID: 3, Action: domain.RecoveryRequeued, Attempt: 1}}, nil)
+	mockSubmissionRepo.On("ListRecoveries", uint(4)).Return([]domain.SubmissionRecovery{}, nil)
+
+	service := NewSubmissionService(mockSubmissionRepo, new(MockProblemRepository), new(MockUserRepository), nil)
+	stuck, err := service.ListStuckSubmissions()
+
+	assert.NoError(t, err)
+	if assert.Len(t, stuck, 2) {
+		assert.Equal(t, uint(3), stuck[0].Submission.ID)
+		assert.Len(t, stuck[0].Recoveries, 1)
+		assert.Empty(t, stuck[1].Recoveries)
+	}
+	mockSubmissionRepo.AssertExpectations(t)
+}
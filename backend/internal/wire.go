@@ -13,7 +13,7 @@ import (
 )
 
 // InitializeApp 初始化整个应用
-func InitializeApp(db *gorm.DB) (*App, error) {
+func InitializeApp(db *gorm.DB, judgeQueue services.JudgeQueue) (*App, error) {
 	wire.Build(
 		repository.RepositorySet,
 		services.ServiceSet,
@@ -16,13 +16,13 @@ import (
 // Injectors from wire.go:
 
 // InitializeApp 初始化整个应用
-func InitializeApp(db *gorm.DB) (*App, error) {
+func InitializeApp(db *gorm.DB, judgeQueue services.JudgeQueue) (*App, error) {
 	userRepository := repository.NewUserRepository(db)
 	userService := services.NewUserService(userRepository)
 	problemRepository := repository.NewProblemRepository(db)
-	problemService := services.NewProblemService(problemRepository)
+	problemService := services.NewProblemService(problemRepository, judgeQueue)
 	submissionRepository := repository.NewSubmissionRepository(db)
-	submissionService := services.NewSubmissionService(submissionRepository, problemRepository, userRepository)
+	submissionService := services.NewSubmissionService(submissionRepository, problemRepository, userRepository, judgeQueue)
 	forumRepository := repository.NewForumRepository(db)
 	forumService := services.NewForumService(forumRepository, userRepository)
 	newsRepository := repository.NewNewsRepository(db)
@@ -30,7 +30,7 @@ func InitializeApp(db *gorm.DB) (*App, error) {
 	adminRepository := repository.NewAdminRepository(db)
 	adminService := services.NewAdminService(adminRepository)
 	handlersHandlers := handlers.NewHandlers(userService, problemService, submissionService, forumService, newsService, adminService)
-	servicesServices := services.NewServices(userRepository, problemRepository, submissionRepository, forumRepository, newsRepository, adminRepository)
+	servicesServices := services.NewServices(userRepository, problemRepository, submissionRepository, forumRepository, newsRepository, adminRepository, judgeQueue)
 	repositories := repository.NewRepositories(db)
 	app := NewApp(handlersHandlers, servicesServices, repositories)
 	return app, nil
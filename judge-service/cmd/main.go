@@ -2,22 +2,54 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"verilog-oj/judge-service/internal/config"
 	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/judge-service/internal/metrics"
 	"verilog-oj/judge-service/internal/queue"
+	"verilog-oj/judge-service/internal/server"
+	"verilog-oj/judge-service/internal/worker"
+	"verilog-oj/protocol"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "selftest":
+			os.Exit(selfTestCommand(os.Args[2:]))
+		}
+	}
+
+	configPath := flag.String("config", os.Getenv("JUDGE_CONFIG"), "path to the YAML config file (env JUDGE_CONFIG)")
+	printConfig := flag.Bool("print-config", false, "print the effective config and exit")
+	flag.Parse()
+
 	// 加载配置
-	cfg := config.LoadJudgeConfig()
+	cfg, err := config.LoadJudgeConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *printConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("Failed to print config: %v", err)
+		}
+		return
+	}
 
 	// 初始化判题器
-	judger := judge.NewJudge(cfg.WorkDir)
+	judger, err := judge.NewJudge(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize judge: %v", err)
+	}
 
 	// 初始化消息队列
 	rq := queue.NewRedisQueue(
@@ -29,89 +61,140 @@ func main() {
 	)
 	defer rq.Close()
 
+	// 启动健康检查与指标服务
+	metrics.RegisterQueueDepth(rq.Len)
+	metrics.RegisterWorkDirUsage(cfg.WorkDir)
+	httpServer := server.New(cfg.HTTPAddr, map[string]server.Check{
+		"redis":    rq.Health,
+		"iverilog": server.BinaryCheck(cfg.Simulator.CompilerPath),
+		"vvp":      server.BinaryCheck(cfg.Simulator.RuntimePath),
+	})
+	httpServer.Start()
+
+	// 自检未通过的节点不拉取任务，避免把所有任务判为 compile_error
+	report, err := runSelfTest(judger)
+	for _, check := range report.Checks {
+		if !check.Passed {
+			log.Printf("Self-test check %s failed: %s", check.Name, check.Message)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Refusing to consume jobs: %v", err)
+	}
+	capabilities := &report.Capabilities
+	log.Printf("Self-test passed: languages=%v, checkers=%v, formal=%t, versions=%v", capabilities.Languages, capabilities.Checkers, capabilities.Formal, capabilities.Versions)
+	rq.SetLanes(withLanguageLanes(cfg.Queue.Lanes, capabilities))
+
 	// 创建上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// 启动判题服务
-	log.Println("Starting judge service...")
-	go startJudgeWorker(ctx, judger, rq)
+	log.Printf("Starting judge service with concurrency %d...", cfg.Concurrency)
+	judgeWorker := worker.New(judger, rq, cfg.Concurrency)
+	judgeWorker.SetResultCache(cfg.Cache.TTLDuration())
+	go judgeWorker.Run(ctx)
 
-	// 等待信号
+	// 发布节点能力，后端据此只把任务推送到有节点能运行的语言通道，并按并发数估算排队时间
+	go heartbeat(ctx, rq, httpServer, capabilities, judgeWorker.Concurrency)
+
+	// 等待信号，SIGHUP 重新加载配置
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		cfg = reloadConfig(*configPath, cfg, judger, rq, judgeWorker, capabilities)
+	}
 
-	<-sigChan
 	log.Println("Shutting down judge service...")
 	cancel()
 
 	// 等待一段时间让正在进行的判题完成
-	time.Sleep(5 * time.Second)
+	judgeWorker.Drain(5 * time.Second)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := rq.Unregister(shutdownCtx, capabilities.Node); err != nil {
+		log.Printf("Failed to unregister judge node: %v", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down health server: %v", err)
+	}
 	log.Println("Judge service stopped")
 }
 
-// startJudgeWorker 启动判题工作器
-func startJudgeWorker(ctx context.Context, judger *judge.Judge, rq *queue.RedisQueue) {
-	retryCount := 0
-	maxRetries := 10
+// reloadConfig 重新加载配置并应用可热更新的部分，加载失败时保留原配置
+func reloadConfig(path string, current *config.JudgeConfig, judger *judge.Judge, rq *queue.RedisQueue, judgeWorker *worker.Worker, capabilities *protocol.Capabilities) *config.JudgeConfig {
+	next, err := config.LoadJudgeConfig(path)
+	if err != nil {
+		log.Printf("Config reload failed, keeping current config: %v", err)
+		return current
+	}
+
+	if changed := current.StructuralChanges(next); len(changed) > 0 {
+		log.Printf("Config reload ignored changes to %s; restart the service to apply them", strings.Join(changed, ", "))
+	}
+
+	judgeWorker.SetConcurrency(next.Concurrency)
+	judgeWorker.SetResultCache(next.Cache.TTLDuration())
+	judger.SetLimits(next.Limits)
+	rq.SetLanes(withLanguageLanes(next.Queue.Lanes, capabilities))
+	log.Printf("Config reloaded: concurrency=%d, lanes=%v, cache=%t", next.Concurrency, next.Queue.Lanes, next.Cache.Enabled)
+
+	// 结构性配置保持不变，只替换热更新部分
+	applied := *current
+	applied.Concurrency = next.Concurrency
+	applied.Limits = next.Limits
+	applied.Queue.Lanes = next.Queue.Lanes
+	applied.Cache = next.Cache
+	return &applied
+}
+
+// withLanguageLanes 返回节点拉取的通道：配置了的 default 通道，以及通过自检的语言通道和检查程序通道
+// 配置了权重的通道使用配置的权重，其余与 default 通道相同（未配置 default 时为 1）；未通过自检的语言和检查程序的通道即使配置了也不拉取
+func withLanguageLanes(lanes map[string]int, capabilities *protocol.Capabilities) map[string]int {
+	merged := map[string]int{}
+	weight, ok := lanes[queue.DefaultLane]
+	if ok {
+		merged[queue.DefaultLane] = weight
+	} else {
+		weight = 1
+	}
+	add := func(lane string) {
+		if w, ok := lanes[lane]; ok {
+			merged[lane] = w
+		} else {
+			merged[lane] = weight
+		}
+	}
+	for _, language := range capabilities.Languages {
+		add(protocol.LanguageLane(language))
+		for _, checker := range capabilities.Checkers {
+			add(protocol.CheckerLane(language, checker))
+		}
+	}
+	return merged
+}
 
+// heartbeat 每隔 protocol.NodeHeartbeatInterval 刷新节点注册表中的能力，直到 ctx 取消
+// 并发数可以热更新，每次刷新时读取当前值
+func heartbeat(ctx context.Context, rq *queue.RedisQueue, httpServer *server.Server, capabilities *protocol.Capabilities, concurrency func() int) {
+	ticker := time.NewTicker(protocol.NodeHeartbeatInterval)
+	defer ticker.Stop()
 	for {
+		current := *capabilities
+		current.Concurrency = concurrency()
+		httpServer.SetCapabilities(&current)
+		if err := rq.Register(ctx, &current); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to register judge node: %v", err)
+		}
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			// 从队列获取判题请求
-			request, err := rq.Pop(ctx)
-			if err != nil {
-				retryCount++
-				log.Printf("Failed to pop from queue (retry %d/%d): %v", retryCount, maxRetries, err)
-
-				// 超过最大重试次数则退出
-				if retryCount >= maxRetries {
-					log.Printf("Max retries exceeded, shutting down worker")
-					return
-				}
-
-				// 重试延迟：指数退避，最大30秒
-				retryDelay := time.Duration(retryCount) * time.Second
-				if retryDelay > 30*time.Second {
-					retryDelay = 30 * time.Second
-				}
-
-				log.Printf("Waiting %v before retry...", retryDelay)
-				select {
-				case <-time.After(retryDelay):
-				case <-ctx.Done():
-					return
-				}
-				continue
-			}
-
-			// 连接成功，重置重试计数
-			retryCount = 0
-
-			if request == nil {
-				// 队列为空，继续轮询
-				continue
-			}
-
-			log.Printf("Processing submission: %s", request.SubmissionID)
-
-			// 执行判题
-			result, err := judger.Judge(ctx, request)
-			if err != nil {
-				log.Printf("Judge failed for submission %s: %v", request.SubmissionID, err)
-				continue
-			}
-
-			// 发布结果
-			if err := rq.PublishResult(ctx, result); err != nil {
-				log.Printf("Failed to publish result for submission %s: %v", request.SubmissionID, err)
-				continue
-			}
-
-			log.Printf("Completed submission: %s, Status: %s, Score: %d",
-				result.SubmissionID, result.Status, result.Score)
+		case <-ticker.C:
 		}
 	}
 }
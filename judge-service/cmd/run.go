@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/judge-service/pkg/problem"
+	"verilog-oj/protocol"
+)
+
+// runReport judge run 的输出
+type runReport struct {
+	Problem string `json:"problem"`
+	Design  string `json:"design"`
+	*judge.Report
+}
+
+// runCommand 离线判题：judge run --problem <目录> [--design <文件>]
+// 全部通过时返回0，判题未通过返回1，参数或题目包错误返回2
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	problemDir := flags.String("problem", "", "problem directory containing problem.yaml")
+	designPath := flags.String("design", "", "design file to judge (defaults to the problem's reference)")
+	language := flags.String("language", "", "override the problem language")
+	configPath := flags.String("config", os.Getenv("JUDGE_CONFIG"), "path to the YAML config file (env JUDGE_CONFIG)")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	keepWorkDir := flags.Bool("keep-workdir", false, "keep the work directory for debugging")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *problemDir == "" {
+		fmt.Fprintln(os.Stderr, "judge run: --problem is required")
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadJudgeConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judge run: failed to load config: %v\n", err)
+		return 2
+	}
+
+	pkg, err := problem.Load(*problemDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judge run: %v\n", err)
+		return 2
+	}
+
+	code := pkg.Reference
+	design := "reference"
+	if *designPath != "" {
+		data, err := os.ReadFile(*designPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "judge run: failed to read design: %v\n", err)
+			return 2
+		}
+		code = string(data)
+		design = *designPath
+	} else if code == "" {
+		fmt.Fprintln(os.Stderr, "judge run: --design is required when the problem has no reference")
+		return 2
+	}
+
+	request := pkg.JudgeRequest("local", code, *language)
+	judger, err := judge.NewJudge(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judge run: %v\n", err)
+		return 2
+	}
+	report, err := judger.Run(context.Background(), request, judge.RunOptions{KeepWorkDir: *keepWorkDir})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judge run: %v\n", err)
+		return 2
+	}
+
+	output := runReport{Problem: pkg.Metadata.Title, Design: design, Report: report}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			fmt.Fprintf(os.Stderr, "judge run: %v\n", err)
+			return 2
+		}
+	} else {
+		printRunReport(os.Stdout, output)
+	}
+
+	if report.Result.Status != protocol.StatusAccepted {
+		return 1
+	}
+	return 0
+}
+
+// printRunReport 以表格形式输出每个测试用例的结果
+func printRunReport(w io.Writer, output runReport) {
+	result := output.Result
+	fmt.Fprintf(w, "Problem: %s\nDesign:  %s\n\n", output.Problem, output.Design)
+
+	if len(output.Cases) > 0 {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "#\tDESCRIPTION\tVERDICT\tTIME(ms)\tSIM(ns)\tCYCLES\tMESSAGE")
+		for _, c := range output.Cases {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%d\t%d\t%d\t%s\n", c.Index, c.Description, c.Status, c.RunTime, c.SimTime, c.Cycles, firstLine(c.ErrorMessage))
+		}
+		table.Flush()
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Result: %s  score=%d  passed=%d/%d  time=%dms\n",
+		result.Status, result.Score, result.PassedTests, result.TotalTests, result.RunTime)
+	if len(output.Cases) == 0 && result.ErrorMessage != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(result.ErrorMessage))
+	}
+	if output.WorkDir != "" {
+		fmt.Fprintf(w, "Work directory kept at %s\n", output.WorkDir)
+	}
+}
+
+// firstLine 表格中只显示消息的第一行
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/protocol"
+)
+
+// captureRun 运行 judge run，返回退出码和 stdout、stderr 的内容
+func captureRun(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("JUDGE_CONFIG", "")
+	t.Setenv("JUDGE_WORK_DIR", t.TempDir())
+	// 测试环境通常无法创建命名空间
+	t.Setenv("JUDGE_SANDBOX_INSECURE", "true")
+
+	dir := t.TempDir()
+	open := func(name string) *os.File {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	stdout, stderr := open("stdout"), open("stderr")
+	defer stdout.Close()
+	defer stderr.Close()
+
+	savedStdout, savedStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	code := runCommand(args)
+	os.Stdout, os.Stderr = savedStdout, savedStderr
+
+	read := func(f *os.File) string {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	return code, read(stdout), read(stderr)
+}
+
+// TestRunCommandErrors 参数和题目包错误返回2，不需要仿真工具
+func TestRunCommandErrors(t *testing.T) {
+	noReference := t.TempDir()
+	for name, content := range map[string]string{
+		"problem.yaml": "title: Adder\ntest_cases:\n  - testbench: tb.v\n",
+		"tb.v":         "module tb; endmodule\n",
+	} {
+		if err := os.WriteFile(filepath.Join(noReference, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stderr string
+	}{
+		{"missing problem", nil, "--problem is required"},
+		{"unknown flag", []string{"--bogus"}, "flag provided but not defined"},
+		{"missing package", []string{"--problem", filepath.Join(t.TempDir(), "missing")}, "judge run:"},
+		{"missing design", []string{"--problem", "testdata/adder", "--design", "testdata/missing.v"}, "failed to read design"},
+		{"no reference", []string{"--problem", noReference}, "--design is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := captureRun(t, tt.args...)
+			if code != 2 {
+				t.Errorf("exit code = %d, want 2", code)
+			}
+			if stdout != "" {
+				t.Errorf("stdout = %q, want empty", stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+// TestRunCommand 用 testdata/adder 判参考答案和错误设计
+func TestRunCommand(t *testing.T) {
+	for _, tool := range []string{"iverilog", "vvp"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+
+	t.Run("reference", func(t *testing.T) {
+		code, stdout, stderr := captureRun(t, "--problem", "testdata/adder")
+		if code != 0 {
+			t.Fatalf("exit code = %d, want 0\nstdout: %s\nstderr: %s", code, stdout, stderr)
+		}
+		for _, want := range []string{"Problem: Adder", "Design:  reference", "Result: accepted"} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, want)
+			}
+		}
+	})
+
+	t.Run("wrong design as JSON", func(t *testing.T) {
+		code, stdout, stderr := captureRun(t, "--problem", "testdata/adder", "--design", "testdata/adder_wrong.v", "--json")
+		if code != 1 {
+			t.Fatalf("exit code = %d, want 1\nstdout: %s\nstderr: %s", code, stdout, stderr)
+		}
+		var report struct {
+			Problem string                `json:"problem"`
+			Design  string                `json:"design"`
+			Result  *protocol.JudgeResult `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+			t.Fatalf("invalid JSON output %q: %v", stdout, err)
+		}
+		if report.Problem != "Adder" || report.Design != "testdata/adder_wrong.v" || report.Result == nil || report.Result.Status != protocol.StatusWrongAnswer {
+			t.Errorf("report = %+v, want a wrong_answer result for testdata/adder_wrong.v", report)
+		}
+	})
+}
+
+func TestPrintRunReport(t *testing.T) {
+	var b strings.Builder
+	printRunReport(&b, runReport{
+		Problem: "Adder",
+		Design:  "adder.v",
+		Report: &judge.Report{
+			Result: &protocol.JudgeResult{Status: protocol.StatusWrongAnswer, Score: 50, PassedTests: 1, TotalTests: 2, RunTime: 12},
+			Cases: []judge.CaseResult{
+				{Index: 1, Description: "sample", Status: protocol.StatusAccepted},
+				{Index: 2, Description: "carry", Status: protocol.StatusWrongAnswer, ErrorMessage: "sum mismatch\nat 10ns"},
+			},
+			WorkDir: "/tmp/judge-1",
+		},
+	})
+	output := b.String()
+	for _, want := range []string{
+		"Problem: Adder\nDesign:  adder.v\n",
+		"DESCRIPTION",
+		"sum mismatch ...",
+		"Result: wrong_answer  score=50  passed=1/2  time=12ms",
+		"Work directory kept at /tmp/judge-1",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output = %q, want it to contain %q", output, want)
+		}
+	}
+	if strings.Contains(output, "at 10ns") {
+		t.Errorf("output = %q, want only the first line of case messages", output)
+	}
+
+	// 没有测试用例时输出完整的错误信息，例如编译错误
+	b.Reset()
+	printRunReport(&b, runReport{Problem: "Adder", Design: "reference", Report: &judge.Report{
+		Result: &protocol.JudgeResult{Status: protocol.StatusCompileError, ErrorMessage: "design.v:1: syntax error\nI give up.\n"},
+	}})
+	if output := b.String(); !strings.Contains(output, "design.v:1: syntax error\nI give up.") || strings.Contains(output, "DESCRIPTION") {
+		t.Errorf("compile error output = %q", output)
+	}
+}
@@ -1,52 +1,380 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/protocol"
+
+	"gopkg.in/yaml.v3"
 )
 
 // JudgeConfig 判题服务配置
 type JudgeConfig struct {
-	WorkDir string      `yaml:"work_dir"`
-	Queue   QueueConfig `yaml:"queue"`
+	WorkDir     string                    `yaml:"work_dir"`
+	HTTPAddr    string                    `yaml:"http_addr"` // 健康检查与指标监听地址
+	Concurrency int                       `yaml:"concurrency"`
+	Queue       QueueConfig               `yaml:"queue"`
+	Simulator   SimulatorConfig           `yaml:"simulator"`
+	Languages   map[string]LanguageConfig `yaml:"languages"`
+	Limits      LimitsConfig              `yaml:"limits"`
+	Sandbox     SandboxConfig             `yaml:"sandbox"`
+	Cache       CacheConfig               `yaml:"cache"`
 }
 
 // QueueConfig 消息队列配置
 type QueueConfig struct {
-	Host      string `yaml:"host"`
-	Port      int    `yaml:"port"`
-	Password  string `yaml:"password"`
-	DB        int    `yaml:"db"`
-	QueueName string `yaml:"queue_name"`
+	Host      string         `yaml:"host"`
+	Port      int            `yaml:"port"`
+	Password  string         `yaml:"password"`
+	DB        int            `yaml:"db"`
+	QueueName string         `yaml:"queue_name"`
+	Lanes     map[string]int `yaml:"lanes"` // 通道名 -> 拉取权重，default 通道即 queue_name，可用的通道见 KnownLane
 }
 
-// LoadJudgeConfig 加载判题服务配置
-func LoadJudgeConfig() *JudgeConfig {
+// SimulatorConfig 仿真器可执行文件配置
+type SimulatorConfig struct {
+	CompilerPath string `yaml:"compiler_path"` // iverilog
+	RuntimePath  string `yaml:"runtime_path"`  // vvp
+	FormalPath   string `yaml:"formal_path"`   // yosys，为空时不做形式化等价证明
+	GoPath       string `yaml:"go_path"`       // go，编译 Go 检查程序，为空时不支持 Go 检查程序
+}
+
+// SandboxConfig 仿真工具的沙箱配置
+type SandboxConfig struct {
+	UID       int  `yaml:"uid"`        // 沙箱内运行工具的非特权用户
+	GID       int  `yaml:"gid"`        // 沙箱内运行工具的用户组
+	TmpfsSize int  `yaml:"tmpfs_size"` // MB，沙箱内 /tmp 的大小，工作目录的大小由 limits.disk_limit 决定
+	Insecure  bool `yaml:"insecure"`   // 无法创建命名空间时不隔离直接运行工具，只应在开发环境开启
+}
+
+// CacheConfig 判题结果缓存配置
+// 缓存键包含规范化后的代码、测试数据、限制、仿真器版本和编译参数，题目修改测试用例或限制后自动失效
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	TTL     int  `yaml:"ttl"` // 秒，缓存结果的保留时间
+}
+
+// TTLDuration 返回缓存结果的保留时间，未启用缓存时为 0
+func (c CacheConfig) TTLDuration() time.Duration {
+	if !c.Enabled {
+		return 0
+	}
+	return time.Duration(c.TTL) * time.Second
+}
+
+// LanguageConfig 单个语言的编译参数
+type LanguageConfig struct {
+	CompileFlags []string `yaml:"compile_flags"`
+}
+
+// LimitsConfig 判题资源限制
+type LimitsConfig struct {
+	DefaultTimeLimit   int `yaml:"default_time_limit"`   // 毫秒，请求未指定时使用
+	DefaultMemoryLimit int `yaml:"default_memory_limit"` // MB，请求未指定时使用
+	MaxTimeLimit       int `yaml:"max_time_limit"`       // 毫秒，请求超出时截断
+	MaxMemoryLimit     int `yaml:"max_memory_limit"`     // MB，请求超出时截断
+	CompileTimeout     int `yaml:"compile_timeout"`      // 毫秒
+
+	DefaultFormalTimeout int `yaml:"default_formal_timeout"` // 毫秒，形式化等价证明的时间预算
+	MaxFormalTimeout     int `yaml:"max_formal_timeout"`     // 毫秒，请求超出时截断
+	DefaultSimTime       int `yaml:"default_sim_time"`       // 纳秒，测试用例未指定时的仿真时间预算，0 表示不限制
+
+	CheckerCompileTimeout   int `yaml:"checker_compile_timeout"`    // 毫秒，编译检查程序的时间限制
+	DefaultCheckerTimeLimit int `yaml:"default_checker_time_limit"` // 毫秒，检查程序每次运行的时间限制，请求未指定时使用
+	MaxCheckerTimeLimit     int `yaml:"max_checker_time_limit"`     // 毫秒，请求超出时截断
+
+	OutputLimit int `yaml:"output_limit"` // KB，每次运行 stdout 和 stderr 的合计大小
+	VCDLimit    int `yaml:"vcd_limit"`    // MB，工作目录中单个文件（主要是 output.vcd）的大小
+	DiskLimit   int `yaml:"disk_limit"`   // MB，每个测试用例工作目录中所有文件的合计大小
+}
+
+// Default 返回默认配置
+func Default() *JudgeConfig {
 	return &JudgeConfig{
-		WorkDir: getEnv("JUDGE_WORK_DIR", "/tmp/judge"),
+		WorkDir:     "/tmp/judge",
+		HTTPAddr:    ":9090",
+		Concurrency: 1,
 		Queue: QueueConfig{
-			Host:      getEnv("QUEUE_HOST", "localhost"),
-			Port:      getEnvAsInt("QUEUE_PORT", 6379),
-			Password:  getEnv("QUEUE_PASSWORD", ""),
-			DB:        getEnvAsInt("QUEUE_DB", 0),
-			QueueName: getEnv("QUEUE_NAME", "judge_queue"),
+			Host:      "localhost",
+			Port:      6379,
+			QueueName: "judge_queue",
+			Lanes:     map[string]int{"default": 1},
+		},
+		Simulator: SimulatorConfig{
+			CompilerPath: "iverilog",
+			RuntimePath:  "vvp",
+			FormalPath:   "yosys",
+			GoPath:       "go",
+		},
+		Languages: map[string]LanguageConfig{
+			"verilog":       {CompileFlags: []string{"-g2005"}},
+			"systemverilog": {CompileFlags: []string{"-g2012"}},
 		},
+		Limits: LimitsConfig{
+			DefaultTimeLimit:   1000,
+			DefaultMemoryLimit: 128,
+			MaxTimeLimit:       30000,
+			MaxMemoryLimit:     1024,
+			CompileTimeout:     10000,
+
+			DefaultFormalTimeout: 10000,
+			MaxFormalTimeout:     60000,
+
+			CheckerCompileTimeout:   60000,
+			DefaultCheckerTimeLimit: 5000,
+			MaxCheckerTimeLimit:     30000,
+
+			OutputLimit: 1024,
+			VCDLimit:    64,
+			DiskLimit:   256,
+		},
+		Sandbox: SandboxConfig{
+			UID:       65534,
+			GID:       65534,
+			TmpfsSize: 256,
+		},
+		Cache: CacheConfig{
+			Enabled: true,
+			TTL:     7 * 24 * 3600,
+		},
+	}
+}
+
+// LoadJudgeConfig 加载判题服务配置
+// 依次应用默认值、YAML配置文件（path 为空时跳过）和环境变量，最后校验
+func LoadJudgeConfig(path string) (*JudgeConfig, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := decodeStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeStrict 解析YAML，出现未知字段时报错
+// yaml.v3 会把映射合并进已有的 map，因此先清空默认的 queue.lanes 和 languages：
+// 文件中给出的映射整体替换默认值，文件没有给出时才使用默认值
+func decodeStrict(data []byte, cfg *JudgeConfig) error {
+	lanes, languages := cfg.Queue.Lanes, cfg.Languages
+	cfg.Queue.Lanes, cfg.Languages = nil, nil
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if cfg.Queue.Lanes == nil {
+		cfg.Queue.Lanes = lanes
+	}
+	if cfg.Languages == nil {
+		cfg.Languages = languages
 	}
+	return nil
 }
 
-// 辅助函数
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// applyEnv 使用环境变量覆盖配置
+func applyEnv(cfg *JudgeConfig) error {
+	var errs []string
+	setString := func(key string, target *string) {
+		if value := os.Getenv(key); value != "" {
+			*target = value
+		}
+	}
+	setInt := func(key string, target *int) {
+		if value := os.Getenv(key); value != "" {
+			intValue, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be an integer, got %q", key, value))
+				return
+			}
+			*target = intValue
+		}
 	}
-	return defaultValue
+	setBool := func(key string, target *bool) {
+		if value := os.Getenv(key); value != "" {
+			boolValue, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be a boolean, got %q", key, value))
+				return
+			}
+			*target = boolValue
+		}
+	}
+
+	setString("JUDGE_WORK_DIR", &cfg.WorkDir)
+	setString("JUDGE_HTTP_ADDR", &cfg.HTTPAddr)
+	setInt("JUDGE_CONCURRENCY", &cfg.Concurrency)
+	setString("QUEUE_HOST", &cfg.Queue.Host)
+	setInt("QUEUE_PORT", &cfg.Queue.Port)
+	setString("QUEUE_PASSWORD", &cfg.Queue.Password)
+	setInt("QUEUE_DB", &cfg.Queue.DB)
+	setString("QUEUE_NAME", &cfg.Queue.QueueName)
+	setString("JUDGE_IVERILOG_PATH", &cfg.Simulator.CompilerPath)
+	setString("JUDGE_VVP_PATH", &cfg.Simulator.RuntimePath)
+	setString("JUDGE_YOSYS_PATH", &cfg.Simulator.FormalPath)
+	setString("JUDGE_GO_PATH", &cfg.Simulator.GoPath)
+	setBool("JUDGE_SANDBOX_INSECURE", &cfg.Sandbox.Insecure)
+	setBool("JUDGE_CACHE_ENABLED", &cfg.Cache.Enabled)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment: %s", strings.Join(errs, "; "))
+	}
+	return nil
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// Validate 校验配置取值范围
+func (c *JudgeConfig) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
 		}
 	}
-	return defaultValue
-} 
\ No newline at end of file
+
+	check(c.WorkDir != "", "work_dir must not be empty")
+	check(c.HTTPAddr != "", "http_addr must not be empty")
+	check(c.Concurrency >= 1 && c.Concurrency <= 64, "concurrency must be between 1 and 64, got %d", c.Concurrency)
+
+	check(c.Queue.Host != "", "queue.host must not be empty")
+	check(c.Queue.Port >= 1 && c.Queue.Port <= 65535, "queue.port must be between 1 and 65535, got %d", c.Queue.Port)
+	check(c.Queue.DB >= 0, "queue.db must not be negative, got %d", c.Queue.DB)
+	check(c.Queue.QueueName != "", "queue.queue_name must not be empty")
+	check(len(c.Queue.Lanes) > 0, "queue.lanes must define at least one lane")
+	for _, lane := range c.LaneNames() {
+		weight := c.Queue.Lanes[lane]
+		check(lane != "", "queue.lanes must not contain an empty lane name")
+		check(lane == "" || c.KnownLane(lane), "queue.lanes.%s is not a lane the backend pushes to; use default, lang-<language> or lang-<language>-checker-<checker> with a configured language", lane)
+		check(weight >= 1 && weight <= 100, "queue.lanes.%s weight must be between 1 and 100, got %d", lane, weight)
+	}
+
+	check(c.Simulator.CompilerPath != "", "simulator.compiler_path must not be empty")
+	check(c.Simulator.RuntimePath != "", "simulator.runtime_path must not be empty")
+	check(len(c.Languages) > 0, "languages must define at least one language")
+
+	l := c.Limits
+	check(l.DefaultTimeLimit >= 1, "limits.default_time_limit must be positive, got %d", l.DefaultTimeLimit)
+	check(l.DefaultMemoryLimit >= 1, "limits.default_memory_limit must be positive, got %d", l.DefaultMemoryLimit)
+	check(l.MaxTimeLimit >= l.DefaultTimeLimit, "limits.max_time_limit (%d) must not be less than default_time_limit (%d)", l.MaxTimeLimit, l.DefaultTimeLimit)
+	check(l.MaxMemoryLimit >= l.DefaultMemoryLimit, "limits.max_memory_limit (%d) must not be less than default_memory_limit (%d)", l.MaxMemoryLimit, l.DefaultMemoryLimit)
+	check(l.CompileTimeout >= 100 && l.CompileTimeout <= 300000, "limits.compile_timeout must be between 100 and 300000 ms, got %d", l.CompileTimeout)
+	check(l.DefaultFormalTimeout >= 100, "limits.default_formal_timeout must be at least 100 ms, got %d", l.DefaultFormalTimeout)
+	check(l.MaxFormalTimeout >= l.DefaultFormalTimeout, "limits.max_formal_timeout (%d) must not be less than default_formal_timeout (%d)", l.MaxFormalTimeout, l.DefaultFormalTimeout)
+	check(l.DefaultSimTime >= 0, "limits.default_sim_time must not be negative, got %d", l.DefaultSimTime)
+	check(l.CheckerCompileTimeout >= 100 && l.CheckerCompileTimeout <= 600000, "limits.checker_compile_timeout must be between 100 and 600000 ms, got %d", l.CheckerCompileTimeout)
+	check(l.DefaultCheckerTimeLimit >= 1, "limits.default_checker_time_limit must be positive, got %d", l.DefaultCheckerTimeLimit)
+	check(l.MaxCheckerTimeLimit >= l.DefaultCheckerTimeLimit, "limits.max_checker_time_limit (%d) must not be less than default_checker_time_limit (%d)", l.MaxCheckerTimeLimit, l.DefaultCheckerTimeLimit)
+	check(l.OutputLimit >= 1 && l.OutputLimit <= 1048576, "limits.output_limit must be between 1 and 1048576 KB, got %d", l.OutputLimit)
+	check(l.VCDLimit >= 1, "limits.vcd_limit must be positive, got %d", l.VCDLimit)
+	check(l.DiskLimit >= l.VCDLimit && l.DiskLimit <= 65536, "limits.disk_limit must be between vcd_limit (%d) and 65536 MB, got %d", l.VCDLimit, l.DiskLimit)
+
+	check(c.Sandbox.UID >= 1, "sandbox.uid must be a non-root user, got %d", c.Sandbox.UID)
+	check(c.Sandbox.GID >= 1, "sandbox.gid must be a non-root group, got %d", c.Sandbox.GID)
+	check(c.Sandbox.TmpfsSize >= 1 && c.Sandbox.TmpfsSize <= 65536, "sandbox.tmpfs_size must be between 1 and 65536 MB, got %d", c.Sandbox.TmpfsSize)
+	check(!c.Cache.Enabled || c.Cache.TTL >= 1, "cache.ttl must be positive when the cache is enabled, got %d", c.Cache.TTL)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid judge config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// LaneNames 返回排序后的通道名称
+func (c *JudgeConfig) LaneNames() []string {
+	names := make([]string, 0, len(c.Queue.Lanes))
+	for name := range c.Queue.Lanes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KnownLane 返回后端是否会向该通道推送任务：default、配置的语言的语言通道和检查程序通道
+func (c *JudgeConfig) KnownLane(lane string) bool {
+	if lane == "default" {
+		return true
+	}
+	for language := range c.Languages {
+		if lane == protocol.LanguageLane(language) {
+			return true
+		}
+		for _, checker := range protocol.SpecialJudgeLanguages {
+			if lane == protocol.CheckerLane(language, checker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StructuralChanges 返回需要重启才能生效的配置差异
+func (c *JudgeConfig) StructuralChanges(next *JudgeConfig) []string {
+	var changed []string
+	if c.WorkDir != next.WorkDir {
+		changed = append(changed, "work_dir")
+	}
+	if c.HTTPAddr != next.HTTPAddr {
+		changed = append(changed, "http_addr")
+	}
+	if c.Queue.Host != next.Queue.Host || c.Queue.Port != next.Queue.Port ||
+		c.Queue.Password != next.Queue.Password || c.Queue.DB != next.Queue.DB ||
+		c.Queue.QueueName != next.Queue.QueueName {
+		changed = append(changed, "queue connection")
+	}
+	if c.Simulator != next.Simulator {
+		changed = append(changed, "simulator")
+	}
+	if !languagesEqual(c.Languages, next.Languages) {
+		changed = append(changed, "languages")
+	}
+	if c.Sandbox != next.Sandbox {
+		changed = append(changed, "sandbox")
+	}
+	return changed
+}
+
+func languagesEqual(a, b map[string]LanguageConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, lang := range a {
+		other, ok := b[name]
+		if !ok || strings.Join(lang.CompileFlags, "\x00") != strings.Join(other.CompileFlags, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// Dump 以YAML格式输出生效配置，密码会被隐藏
+func (c *JudgeConfig) Dump(w io.Writer) error {
+	redacted := *c
+	if redacted.Queue.Password != "" {
+		redacted.Queue.Password = "******"
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&redacted)
+}
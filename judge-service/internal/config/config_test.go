@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig 将 content 写入临时配置文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "judge.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadJudgeConfigDefaults(t *testing.T) {
+	cfg, err := LoadJudgeConfig("")
+	if err != nil {
+		t.Fatalf("LoadJudgeConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("LoadJudgeConfig(\"\") = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadJudgeConfigFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		check     func(t *testing.T, cfg *JudgeConfig)
+		wantError string
+	}{
+		{
+			name:    "empty file keeps defaults",
+			content: "",
+			check: func(t *testing.T, cfg *JudgeConfig) {
+				if !reflect.DeepEqual(cfg, Default()) {
+					t.Errorf("config = %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name:    "scalars override defaults",
+			content: "concurrency: 4\nlimits:\n  default_time_limit: 2000\n",
+			check: func(t *testing.T, cfg *JudgeConfig) {
+				if cfg.Concurrency != 4 || cfg.Limits.DefaultTimeLimit != 2000 {
+					t.Errorf("concurrency = %d, default_time_limit = %d", cfg.Concurrency, cfg.Limits.DefaultTimeLimit)
+				}
+				if cfg.Limits.MaxTimeLimit != Default().Limits.MaxTimeLimit || cfg.Queue.Host != "localhost" {
+					t.Error("unset fields should keep their defaults")
+				}
+			},
+		},
+		{
+			name:    "lanes replace the default lanes",
+			content: "queue:\n  lanes:\n    lang-verilog: 3\n",
+			check: func(t *testing.T, cfg *JudgeConfig) {
+				if want := map[string]int{"lang-verilog": 3}; !reflect.DeepEqual(cfg.Queue.Lanes, want) {
+					t.Errorf("lanes = %v, want %v", cfg.Queue.Lanes, want)
+				}
+				if cfg.Queue.QueueName != "judge_queue" {
+					t.Errorf("queue_name = %q, want the default", cfg.Queue.QueueName)
+				}
+			},
+		},
+		{
+			name:    "languages replace the default languages",
+			content: "languages:\n  verilog:\n    compile_flags: [\"-g2012\"]\n",
+			check: func(t *testing.T, cfg *JudgeConfig) {
+				want := map[string]LanguageConfig{"verilog": {CompileFlags: []string{"-g2012"}}}
+				if !reflect.DeepEqual(cfg.Languages, want) {
+					t.Errorf("languages = %v, want %v", cfg.Languages, want)
+				}
+				if !reflect.DeepEqual(cfg.Queue.Lanes, Default().Queue.Lanes) {
+					t.Errorf("lanes = %v, want the defaults", cfg.Queue.Lanes)
+				}
+			},
+		},
+		{
+			name:      "lane of a removed language",
+			content:   "languages:\n  verilog: {}\nqueue:\n  lanes:\n    lang-systemverilog: 1\n",
+			wantError: "queue.lanes.lang-systemverilog is not a lane the backend pushes to",
+		},
+		{
+			name:      "empty lanes",
+			content:   "queue:\n  lanes: {}\n",
+			wantError: "queue.lanes must define at least one lane",
+		},
+		{
+			name:      "empty languages",
+			content:   "languages: {}\n",
+			wantError: "languages must define at least one language",
+		},
+		{
+			name:      "unknown field",
+			content:   "concurency: 4\n",
+			wantError: "field concurency not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadJudgeConfig(writeConfig(t, tt.content))
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("LoadJudgeConfig() error = %v, want it to contain %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadJudgeConfig() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+
+	// 解析后的配置不与默认值共享 map
+	if _, err := LoadJudgeConfig(writeConfig(t, "queue:\n  lanes:\n    default: 5\n")); err != nil {
+		t.Fatal(err)
+	}
+	if Default().Queue.Lanes["default"] != 1 {
+		t.Error("loading a config file modified the defaults")
+	}
+
+	if _, err := LoadJudgeConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadJudgeConfig() with a missing file should fail")
+	}
+}
+
+func TestLoadJudgeConfigEnv(t *testing.T) {
+	path := writeConfig(t, "concurrency: 2\nqueue:\n  host: redis.internal\n")
+
+	t.Setenv("JUDGE_CONCURRENCY", "8")
+	t.Setenv("QUEUE_PASSWORD", "secret")
+	t.Setenv("JUDGE_SANDBOX_INSECURE", "true")
+	t.Setenv("JUDGE_CACHE_ENABLED", "false")
+	cfg, err := LoadJudgeConfig(path)
+	if err != nil {
+		t.Fatalf("LoadJudgeConfig() error = %v", err)
+	}
+	if cfg.Concurrency != 8 || cfg.Queue.Password != "secret" || !cfg.Sandbox.Insecure || cfg.Cache.Enabled {
+		t.Errorf("environment did not override the file: %+v", cfg)
+	}
+	if cfg.Queue.Host != "redis.internal" {
+		t.Errorf("queue.host = %q, want the value from the file", cfg.Queue.Host)
+	}
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"JUDGE_CONCURRENCY", "many", `JUDGE_CONCURRENCY must be an integer, got "many"`},
+		{"JUDGE_SANDBOX_INSECURE", "maybe", `JUDGE_SANDBOX_INSECURE must be a boolean, got "maybe"`},
+		{"JUDGE_CONCURRENCY", "100", "concurrency must be between 1 and 64, got 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := LoadJudgeConfig(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadJudgeConfig() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *JudgeConfig)
+		want   string // 为空表示校验通过
+	}{
+		{"defaults", func(*JudgeConfig) {}, ""},
+		{"language lane", func(cfg *JudgeConfig) { cfg.Queue.Lanes["lang-verilog"] = 2 }, ""},
+		{"checker lane", func(cfg *JudgeConfig) { cfg.Queue.Lanes["lang-verilog-checker-go"] = 2 }, ""},
+		{"without default lane", func(cfg *JudgeConfig) { cfg.Queue.Lanes = map[string]int{"lang-verilog": 1} }, ""},
+		{"unknown lane", func(cfg *JudgeConfig) { cfg.Queue.Lanes["fast"] = 1 }, "queue.lanes.fast is not a lane the backend pushes to"},
+		{"unknown checker lane", func(cfg *JudgeConfig) { cfg.Queue.Lanes["lang-verilog-checker-python"] = 1 }, "queue.lanes.lang-verilog-checker-python is not a lane"},
+		{"empty lane name", func(cfg *JudgeConfig) { cfg.Queue.Lanes[""] = 1 }, "queue.lanes must not contain an empty lane name"},
+		{"lane weight", func(cfg *JudgeConfig) { cfg.Queue.Lanes["default"] = 0 }, "queue.lanes.default weight must be between 1 and 100, got 0"},
+		{"concurrency", func(cfg *JudgeConfig) { cfg.Concurrency = 0 }, "concurrency must be between 1 and 64, got 0"},
+		{"port", func(cfg *JudgeConfig) { cfg.Queue.Port = 70000 }, "queue.port must be between 1 and 65535, got 70000"},
+		{"max below default", func(cfg *JudgeConfig) { cfg.Limits.MaxTimeLimit = 10 }, "limits.max_time_limit (10) must not be less than default_time_limit (1000)"},
+		{"disk below vcd", func(cfg *JudgeConfig) { cfg.Limits.DiskLimit = 1 }, "limits.disk_limit must be between vcd_limit (64) and 65536 MB, got 1"},
+		{"negative sim time", func(cfg *JudgeConfig) { cfg.Limits.DefaultSimTime = -1 }, "limits.default_sim_time must not be negative, got -1"},
+		{"root sandbox user", func(cfg *JudgeConfig) { cfg.Sandbox.UID = 0 }, "sandbox.uid must be a non-root user, got 0"},
+		{"cache without ttl", func(cfg *JudgeConfig) { cfg.Cache.TTL = 0 }, "cache.ttl must be positive when the cache is enabled, got 0"},
+		{"disabled cache without ttl", func(cfg *JudgeConfig) { cfg.Cache = CacheConfig{} }, ""},
+		{"empty compiler", func(cfg *JudgeConfig) { cfg.Simulator.CompilerPath = "" }, "simulator.compiler_path must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	// 所有错误一起报告
+	cfg := Default()
+	cfg.Concurrency = 0
+	cfg.WorkDir = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "work_dir must not be empty; concurrency") {
+		t.Errorf("Validate() error = %v, want all problems reported together", err)
+	}
+}
+
+func TestStructuralChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *JudgeConfig)
+		want   []string
+	}{
+		{"nothing", func(*JudgeConfig) {}, nil},
+		{"hot reloadable fields", func(cfg *JudgeConfig) {
+			cfg.Concurrency = 8
+			cfg.Limits.DefaultTimeLimit = 2000
+			cfg.Queue.Lanes = map[string]int{"lang-verilog": 2}
+			cfg.Cache.Enabled = false
+		}, nil},
+		{"work dir", func(cfg *JudgeConfig) { cfg.WorkDir = "/var/judge" }, []string{"work_dir"}},
+		{"queue connection", func(cfg *JudgeConfig) { cfg.Queue.Password = "new" }, []string{"queue connection"}},
+		{"compile flags", func(cfg *JudgeConfig) {
+			cfg.Languages["verilog"] = LanguageConfig{CompileFlags: []string{"-g2012"}}
+		}, []string{"languages"}},
+		{"removed language", func(cfg *JudgeConfig) { delete(cfg.Languages, "systemverilog") }, []string{"languages"}},
+		{"several", func(cfg *JudgeConfig) {
+			cfg.HTTPAddr = ":9091"
+			cfg.Simulator.FormalPath = ""
+			cfg.Sandbox.Insecure = true
+		}, []string{"http_addr", "simulator", "sandbox"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := Default()
+			tt.modify(next)
+			if got := Default().StructuralChanges(next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StructuralChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReload SIGHUP 重新加载同一个文件：热更新字段直接生效，结构性修改由 StructuralChanges 报告
+func TestReload(t *testing.T) {
+	path := writeConfig(t, "concurrency: 2\nqueue:\n  lanes:\n    default: 1\n")
+	current, err := LoadJudgeConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("concurrency: 4\nwork_dir: /var/judge\nqueue:\n  lanes:\n    lang-verilog: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	next, err := LoadJudgeConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Concurrency != 4 || !reflect.DeepEqual(next.Queue.Lanes, map[string]int{"lang-verilog": 2}) {
+		t.Errorf("reloaded concurrency = %d, lanes = %v", next.Concurrency, next.Queue.Lanes)
+	}
+	if got := current.StructuralChanges(next); !reflect.DeepEqual(got, []string{"work_dir"}) {
+		t.Errorf("StructuralChanges() = %v, want [work_dir]", got)
+	}
+
+	// 重新加载失败时返回错误，调用方保留原配置
+	if err := os.WriteFile(path, []byte("concurrency: 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadJudgeConfig(path); err == nil {
+		t.Error("reloading an invalid config should fail")
+	}
+}
+
+func TestKnownLane(t *testing.T) {
+	cfg := Default()
+	for lane, want := range map[string]bool{
+		"default":                          true,
+		"lang-verilog":                     true,
+		"lang-systemverilog-checker-go":    true,
+		"lang-verilog-checker-verilog":     true,
+		"lang-vhdl":                        false,
+		"lang-verilog-checker-python":      false,
+		"judge_queue":                      false,
+		"lang-verilog-checker-go-extended": false,
+	} {
+		if got := cfg.KnownLane(lane); got != want {
+			t.Errorf("KnownLane(%q) = %t, want %t", lane, got, want)
+		}
+	}
+}
+
+func TestDumpRedactsPassword(t *testing.T) {
+	cfg := Default()
+	cfg.Queue.Password = "secret"
+	var buf bytes.Buffer
+	if err := cfg.Dump(&buf); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") || !strings.Contains(buf.String(), "******") {
+		t.Errorf("Dump() should redact the password:\n%s", buf.String())
+	}
+	if cfg.Queue.Password != "secret" {
+		t.Error("Dump() modified the config")
+	}
+}
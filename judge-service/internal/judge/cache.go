@@ -0,0 +1,93 @@
+package judge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/protocol"
+)
+
+// cacheFormat 结果缓存键的格式版本，判题逻辑的变化使旧结果不再可信时递增
+const cacheFormat = 2
+
+// cacheableStatuses 只由代码、测试数据和工具决定的判题状态
+// 超时、超内存和运行错误可能受节点负载影响，系统错误和取消与代码无关，这些结果不缓存
+var cacheableStatuses = map[string]bool{
+	protocol.StatusAccepted:            true,
+	protocol.StatusWrongAnswer:         true,
+	protocol.StatusCompileError:        true,
+	protocol.StatusOutputLimitExceeded: true,
+	protocol.StatusSimTimeExceeded:     true,
+}
+
+// cacheInput 参与结果缓存键的全部输入
+type cacheInput struct {
+	Format       int                    `json:"format"`
+	Code         string                 `json:"code"`
+	Language     string                 `json:"language"`
+	Flags        []string               `json:"flags"`
+	Simulator    config.SimulatorConfig `json:"simulator"`
+	Versions     map[string]string      `json:"versions"`
+	Limits       config.LimitsConfig    `json:"limits"`
+	TimeLimit    int                    `json:"time_limit"`
+	MemoryLimit  int                    `json:"memory_limit"`
+	TestCases    []protocol.TestCase    `json:"test_cases"`
+	TruthTable   *protocol.TruthTable   `json:"truth_table,omitempty"`
+	Equivalence  *protocol.Equivalence  `json:"equivalence,omitempty"`
+	Checker      string                 `json:"checker,omitempty"`
+	SpecialJudge *protocol.SpecialJudge `json:"special_judge,omitempty"`
+	Capture      bool                   `json:"capture,omitempty"`
+}
+
+// CacheKey 返回判题结果的缓存键：规范化代码、测试数据、限制、仿真器及编译参数的 SHA-256
+// 题目的测试用例或限制修改后请求内容随之变化，旧的缓存结果自然不再命中
+func (j *Judge) CacheKey(req *protocol.JudgeRequest) string {
+	j.mu.RLock()
+	input := cacheInput{
+		Format:       cacheFormat,
+		Code:         normalizeCode(req.Code),
+		Language:     req.Language,
+		Flags:        j.languages[req.Language].CompileFlags,
+		Simulator:    j.simulator,
+		Versions:     j.versions,
+		Limits:       j.limits,
+		TimeLimit:    req.TimeLimit,
+		MemoryLimit:  req.MemoryLimit,
+		TestCases:    req.TestCases,
+		TruthTable:   req.TruthTable,
+		Equivalence:  req.Equivalence,
+		Checker:      req.Checker,
+		SpecialJudge: req.SpecialJudge,
+		Capture:      req.CaptureOutputs,
+	}
+	data, _ := json.Marshal(&input)
+	j.mu.RUnlock()
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Cacheable 返回判题结果能否缓存，结果及每个测试用例的状态都必须只由代码和测试数据决定
+func Cacheable(result *protocol.JudgeResult) bool {
+	if !cacheableStatuses[result.Status] {
+		return false
+	}
+	for _, caseResult := range result.Cases {
+		if !cacheableStatuses[caseResult.Status] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeCode 统一换行符并去掉行尾空白和末尾空行，只有空白差异的代码共用缓存
+// 保留行首空白和空行，编译错误中的行号不受影响
+func normalizeCode(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
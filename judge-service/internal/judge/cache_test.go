@@ -0,0 +1,112 @@
+package judge
+
+import (
+	"testing"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/protocol"
+)
+
+func testCacheJudge() *Judge {
+	return &Judge{
+		simulator: config.SimulatorConfig{CompilerPath: "iverilog", RuntimePath: "vvp"},
+		languages: map[string]config.LanguageConfig{"verilog": {CompileFlags: []string{"-g2012"}}},
+		limits:    config.LimitsConfig{DefaultTimeLimit: 1000, MaxTimeLimit: 10000},
+		versions:  map[string]string{"iverilog": "12.0"},
+	}
+}
+
+func testCacheRequest() *protocol.JudgeRequest {
+	return &protocol.JudgeRequest{
+		Code:        "module m;\nendmodule\n",
+		Language:    "verilog",
+		TimeLimit:   1000,
+		MemoryLimit: 256,
+		TestCases:   []protocol.TestCase{{Testbench: "module tb; m u(); endmodule", ExpectedOutput: "ok"}},
+		Checker:     protocol.CheckerExact,
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	base := testCacheJudge().CacheKey(testCacheRequest())
+
+	tests := []struct {
+		name   string
+		change func(j *Judge, req *protocol.JudgeRequest)
+		same   bool
+	}{
+		{"unchanged", func(*Judge, *protocol.JudgeRequest) {}, true},
+		{"trailing whitespace and CRLF", func(_ *Judge, req *protocol.JudgeRequest) {
+			req.Code = "module m;  \r\nendmodule\t\r\n\r\n"
+		}, true},
+		{"ignored submission fields", func(_ *Judge, req *protocol.JudgeRequest) { req.SubmissionID = "42" }, true},
+		{"code", func(_ *Judge, req *protocol.JudgeRequest) { req.Code = "module n;\nendmodule\n" }, false},
+		{"leading whitespace", func(_ *Judge, req *protocol.JudgeRequest) { req.Code = "  module m;\nendmodule\n" }, false},
+		{"time limit", func(_ *Judge, req *protocol.JudgeRequest) { req.TimeLimit = 2000 }, false},
+		{"memory limit", func(_ *Judge, req *protocol.JudgeRequest) { req.MemoryLimit = 512 }, false},
+		{"test case", func(_ *Judge, req *protocol.JudgeRequest) { req.TestCases[0].ExpectedOutput = "fail" }, false},
+		{"checker", func(_ *Judge, req *protocol.JudgeRequest) { req.Checker = protocol.CheckerNumeric }, false},
+		{"capture", func(_ *Judge, req *protocol.JudgeRequest) { req.CaptureOutputs = true }, false},
+		{"special judge", func(_ *Judge, req *protocol.JudgeRequest) {
+			req.SpecialJudge = &protocol.SpecialJudge{Language: protocol.SpecialJudgeGo, Source: "package main"}
+		}, false},
+		{"compile flags", func(j *Judge, _ *protocol.JudgeRequest) {
+			j.languages = map[string]config.LanguageConfig{"verilog": {CompileFlags: []string{"-g2005"}}}
+		}, false},
+		{"tool versions", func(j *Judge, _ *protocol.JudgeRequest) { j.versions = map[string]string{"iverilog": "11.0"} }, false},
+		{"node limits", func(j *Judge, _ *protocol.JudgeRequest) { j.SetLimits(config.LimitsConfig{DefaultTimeLimit: 500}) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, req := testCacheJudge(), testCacheRequest()
+			tt.change(j, req)
+			if same := j.CacheKey(req) == base; same != tt.same {
+				t.Errorf("key unchanged = %t, want %t", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a\nb", "a\nb"},
+		{"a \t\r\nb\r\n\r\n\n", "a\nb"},
+		{"  a\n\n\tb", "  a\n\n\tb"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeCode(tt.code); got != tt.want {
+			t.Errorf("normalizeCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *protocol.JudgeResult
+		want   bool
+	}{
+		{"accepted", &protocol.JudgeResult{Status: protocol.StatusAccepted}, true},
+		{"compile error", &protocol.JudgeResult{Status: protocol.StatusCompileError}, true},
+		{"system error", &protocol.JudgeResult{Status: protocol.StatusSystemError}, false},
+		{"time limit", &protocol.JudgeResult{Status: protocol.StatusTimeLimitExceeded}, false},
+		{"wrong answer with cacheable cases", &protocol.JudgeResult{
+			Status: protocol.StatusWrongAnswer,
+			Cases:  []protocol.CaseResult{{Status: protocol.StatusAccepted}, {Status: protocol.StatusWrongAnswer}},
+		}, true},
+		{"wrong answer with a timed out case", &protocol.JudgeResult{
+			Status: protocol.StatusWrongAnswer,
+			Cases:  []protocol.CaseResult{{Status: protocol.StatusWrongAnswer}, {Status: protocol.StatusTimeLimitExceeded}},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cacheable(tt.result); got != tt.want {
+				t.Errorf("Cacheable() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
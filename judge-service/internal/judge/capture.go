@@ -0,0 +1,53 @@
+package judge
+
+import (
+	"fmt"
+	"os"
+	"verilog-oj/protocol"
+)
+
+// 生成期望输出时记录的内容
+const (
+	captureVCD    = "vcd"    // testbench 输出的完整VCD
+	captureStdout = "stdout" // testbench 的标准输出，用于比较标准输出或配置了检查程序的题目
+)
+
+// captureMode 返回生成期望输出时测试用例记录的内容
+func captureMode(req *protocol.JudgeRequest) string {
+	if protocol.IsStdoutChecker(req.Checker) || req.SpecialJudge != nil {
+		return captureStdout
+	}
+	return captureVCD
+}
+
+// captureOutput 记录参考设计在测试用例中的实际输出，output 为仿真输出
+// I/O 序列测试用例在每个周期的采样时刻记录全部输出端口的取值
+func captureOutput(vcdFile string, testCase protocol.TestCase, mode, output string) (*protocol.CapturedOutput, error) {
+	if testCase.Trace != nil {
+		wave, err := traceWaveform(vcdFile, testCase.Trace)
+		if err != nil {
+			return nil, err
+		}
+		captured := &protocol.CapturedOutput{}
+		for _, at := range traceSampleTimes(testCase.Trace) {
+			row := map[string]string{}
+			for _, port := range testCase.Trace.Ports {
+				if port.Direction == protocol.PortOutput {
+					bits := traceBits(valueAt(wave.changes[traceTestbenchModule+"."+port.Name], at), port.PortWidth())
+					row[port.Name] = protocol.CapturedValue(bits)
+				}
+			}
+			captured.Rows = append(captured.Rows, row)
+		}
+		return captured, nil
+	}
+
+	if mode == captureStdout {
+		return &protocol.CapturedOutput{Expected: testbenchStdout(output)}, nil
+	}
+	vcd, err := os.ReadFile(vcdFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output VCD: %v", err)
+	}
+	return &protocol.CapturedOutput{Expected: string(vcd)}, nil
+}
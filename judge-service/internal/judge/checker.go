@@ -0,0 +1,219 @@
+package judge
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"verilog-oj/protocol"
+)
+
+// vvpMessagePrefixes vvp 自身输出的提示行前缀，不属于 testbench 的输出
+var vvpMessagePrefixes = []string{"VCD info:", "VCD warning:", "LXT2 info:", "FST info:", "** VVP Stop"}
+
+// testbenchStdout 从仿真输出中去掉 vvp 的提示和 $finish 信息，只保留 testbench 的输出
+// 仿真时间预算用完时不比较输出，预算标记行不会出现在这里；设计输出的同名行按普通输出比较
+func testbenchStdout(output string) string {
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if finishTimeRegex.MatchString(trimmed) || hasVVPPrefix(trimmed) {
+			continue
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// hasVVPPrefix 判断一行是否是 vvp 的提示
+func hasVVPPrefix(line string) bool {
+	for _, prefix := range vvpMessagePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStdout 按比较方式比较 testbench 的标准输出与期望输出，不一致时返回第一处差异的说明
+func checkStdout(checker, actual, expected string) (bool, string) {
+	expected = strings.ReplaceAll(expected, "\r\n", "\n")
+	switch checker {
+	case protocol.CheckerExact:
+		return checkExact(actual, expected)
+	case protocol.CheckerWhitespace:
+		return checkTokens(strings.Fields(actual), strings.Fields(expected), tokenEqual)
+	case protocol.CheckerLines:
+		return checkLineSet(actual, expected)
+	case protocol.CheckerNumeric:
+		return checkTokens(numericTokens(actual), numericTokens(expected), numericEqual)
+	}
+	return false, fmt.Sprintf("unknown checker %q", checker)
+}
+
+// checkExact 逐行比较，忽略末尾的空行
+func checkExact(actual, expected string) (bool, string) {
+	actualLines := strings.Split(strings.TrimRight(actual, "\n"), "\n")
+	expectedLines := strings.Split(strings.TrimRight(expected, "\n"), "\n")
+	for i := 0; i < len(actualLines) || i < len(expectedLines); i++ {
+		switch {
+		case i >= len(actualLines):
+			return false, fmt.Sprintf("line %d: output ended, expected %q", i+1, expectedLines[i])
+		case i >= len(expectedLines):
+			return false, fmt.Sprintf("line %d: unexpected extra output %q", i+1, actualLines[i])
+		case actualLines[i] != expectedLines[i]:
+			return false, fmt.Sprintf("line %d: got %q, expected %q", i+1, actualLines[i], expectedLines[i])
+		}
+	}
+	return true, ""
+}
+
+// checkTokens 逐个比较记号
+func checkTokens(actual, expected []string, equal func(a, e string) bool) (bool, string) {
+	for i := 0; i < len(actual) || i < len(expected); i++ {
+		switch {
+		case i >= len(actual):
+			return false, fmt.Sprintf("token %d: output ended, expected %q", i+1, expected[i])
+		case i >= len(expected):
+			return false, fmt.Sprintf("token %d: unexpected extra output %q", i+1, actual[i])
+		case !equal(actual[i], expected[i]):
+			return false, fmt.Sprintf("token %d: got %q, expected %q", i+1, actual[i], expected[i])
+		}
+	}
+	return true, ""
+}
+
+// checkLineSet 将去掉首尾空白的非空行作为多重集合比较
+func checkLineSet(actual, expected string) (bool, string) {
+	counts := make(map[string]int)
+	for _, line := range nonEmptyLines(expected) {
+		counts[line]++
+	}
+	var extra []string
+	for _, line := range nonEmptyLines(actual) {
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			extra = append(extra, line)
+		}
+	}
+	var missing []string
+	for line, n := range counts {
+		for ; n > 0; n-- {
+			missing = append(missing, line)
+		}
+	}
+	sort.Strings(missing)
+
+	switch {
+	case len(missing) > 0:
+		return false, fmt.Sprintf("%d expected line(s) missing, first %q", len(missing), missing[0])
+	case len(extra) > 0:
+		return false, fmt.Sprintf("%d unexpected line(s), first %q", len(extra), extra[0])
+	}
+	return true, ""
+}
+
+// nonEmptyLines 返回去掉首尾空白后的非空行
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func tokenEqual(a, e string) bool {
+	return a == e
+}
+
+// numericSeparators 数值比较时单独成为记号的分隔符，使 "sum=0x1f," 拆分为 sum、=、0x1f、,
+const numericSeparators = "=,:;()[]{}"
+
+// numericTokens 按空白和 numericSeparators 拆分记号
+func numericTokens(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		start := 0
+		for i, r := range field {
+			if strings.ContainsRune(numericSeparators, r) {
+				if i > start {
+					tokens = append(tokens, field[start:i])
+				}
+				tokens = append(tokens, string(r))
+				start = i + 1
+			}
+		}
+		if start < len(field) {
+			tokens = append(tokens, field[start:])
+		}
+	}
+	return tokens
+}
+
+// numericEqual 两个记号都是数值时按数值比较，否则按文本比较
+func numericEqual(a, e string) bool {
+	if a == e {
+		return true
+	}
+	av, aok := parseNumber(a)
+	ev, eok := parseNumber(e)
+	return aok && eok && av.Cmp(ev) == 0
+}
+
+// parseNumber 解析十进制、0x/0b/0o 前缀或 Verilog 字面量（如 8'hff、'b1010）形式的数值，可以用 _ 分隔
+// 含 x、z 的取值不是数值
+func parseNumber(token string) (*big.Int, bool) {
+	token = strings.ReplaceAll(token, "_", "")
+	negative := strings.HasPrefix(token, "-")
+	token = strings.TrimPrefix(token, "-")
+
+	base := 10
+	digits := token
+	if i := strings.IndexByte(token, '\''); i >= 0 {
+		// Verilog 字面量，位宽只用于显示，不参与比较
+		if strings.Trim(token[:i], "0123456789") != "" || i+1 >= len(token) {
+			return nil, false
+		}
+		spec := strings.TrimLeft(token[i+1:], "sS")
+		if spec == "" {
+			return nil, false
+		}
+		switch spec[0] {
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		case 'd', 'D':
+			base = 10
+		case 'h', 'H':
+			base = 16
+		default:
+			return nil, false
+		}
+		digits = spec[1:]
+	} else if len(token) > 2 && token[0] == '0' {
+		switch token[1] {
+		case 'x', 'X':
+			base, digits = 16, token[2:]
+		case 'b', 'B':
+			base, digits = 2, token[2:]
+		case 'o', 'O':
+			base, digits = 8, token[2:]
+		}
+	}
+	if digits == "" || strings.ContainsAny(digits[:1], "+-") {
+		return nil, false
+	}
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, false
+	}
+	if negative {
+		value.Neg(value)
+	}
+	return value, true
+}
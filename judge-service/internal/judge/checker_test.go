@@ -0,0 +1,196 @@
+package judge
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+func TestTestbenchStdout(t *testing.T) {
+	output := strings.Join([]string{
+		"VCD info: dumpfile output.vcd opened for output.",
+		"sum = 5",
+		"  carry = 0  ",
+		"tb.v:12: $finish called at 150000 (1ps)",
+		"** VVP Stop(0) **",
+		"",
+	}, "\r\n")
+	want := "sum = 5\n  carry = 0  \n"
+	if got := testbenchStdout(output); got != want {
+		t.Errorf("testbenchStdout() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckStdout(t *testing.T) {
+	tests := []struct {
+		name     string
+		checker  string
+		actual   string
+		expected string
+		passed   bool
+		message  string
+	}{
+		{
+			name:     "exact match ignores trailing newlines",
+			checker:  protocol.CheckerExact,
+			actual:   "a\nb\n\n",
+			expected: "a\r\nb",
+			passed:   true,
+		},
+		{
+			name:     "exact mismatch",
+			checker:  protocol.CheckerExact,
+			actual:   "a\nc\n",
+			expected: "a\nb\n",
+			message:  `line 2: got "c", expected "b"`,
+		},
+		{
+			name:     "exact missing line",
+			checker:  protocol.CheckerExact,
+			actual:   "a\n",
+			expected: "a\nb\n",
+			message:  `line 2: output ended, expected "b"`,
+		},
+		{
+			name:     "exact extra line",
+			checker:  protocol.CheckerExact,
+			actual:   "a\nb\n",
+			expected: "a\n",
+			message:  `line 2: unexpected extra output "b"`,
+		},
+		{
+			name:     "exact is whitespace sensitive",
+			checker:  protocol.CheckerExact,
+			actual:   "a  b\n",
+			expected: "a b\n",
+			message:  `line 1: got "a  b", expected "a b"`,
+		},
+		{
+			name:     "whitespace ignores spacing",
+			checker:  protocol.CheckerWhitespace,
+			actual:   "  a\tb\n\nc ",
+			expected: "a b c",
+			passed:   true,
+		},
+		{
+			name:     "whitespace mismatch",
+			checker:  protocol.CheckerWhitespace,
+			actual:   "a b",
+			expected: "a b c",
+			message:  `token 3: output ended, expected "c"`,
+		},
+		{
+			name:     "lines in any order",
+			checker:  protocol.CheckerLines,
+			actual:   "b\n  a \n\na\n",
+			expected: "a\na\nb\n",
+			passed:   true,
+		},
+		{
+			name:     "lines missing",
+			checker:  protocol.CheckerLines,
+			actual:   "a\n",
+			expected: "a\na\nb\n",
+			message:  `2 expected line(s) missing, first "a"`,
+		},
+		{
+			name:     "lines extra",
+			checker:  protocol.CheckerLines,
+			actual:   "a\nb\nc\n",
+			expected: "b\na\n",
+			message:  `1 unexpected line(s), first "c"`,
+		},
+		{
+			name:     "numeric compares values across radixes",
+			checker:  protocol.CheckerNumeric,
+			actual:   "sum=31, carry=1",
+			expected: "sum = 0x1f , carry = 8'b1",
+			passed:   true,
+		},
+		{
+			name:     "numeric mismatch",
+			checker:  protocol.CheckerNumeric,
+			actual:   "sum=30",
+			expected: "sum=0x1f",
+			message:  `token 3: got "30", expected "0x1f"`,
+		},
+		{
+			name:     "numeric x is not a number",
+			checker:  protocol.CheckerNumeric,
+			actual:   "q=x",
+			expected: "q=0",
+			message:  `token 3: got "x", expected "0"`,
+		},
+		{
+			name:     "unknown checker",
+			checker:  "fuzzy",
+			actual:   "a",
+			expected: "a",
+			message:  `unknown checker "fuzzy"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed, message := checkStdout(tt.checker, tt.actual, tt.expected)
+			if passed != tt.passed || message != tt.message {
+				t.Errorf("checkStdout() = (%t, %q), want (%t, %q)", passed, message, tt.passed, tt.message)
+			}
+		})
+	}
+}
+
+func TestNumericTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"sum=0x1f,", []string{"sum", "=", "0x1f", ","}},
+		{"  a:(1) [2]{3};  ", []string{"a", ":", "(", "1", ")", "[", "2", "]", "{", "3", "}", ";"}},
+		{"8'hff -3", []string{"8'hff", "-3"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := numericTokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("numericTokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string // 十进制取值，为空表示不是数值
+	}{
+		{"42", "42"},
+		{"-42", "-42"},
+		{"1_000", "1000"},
+		{"0x1F", "31"},
+		{"0b1010", "10"},
+		{"0o17", "15"},
+		{"8'hff", "255"},
+		{"'b1010", "10"},
+		{"4'sd7", "7"},
+		{"16'O17", "15"},
+		{"0", "0"},
+		{"0x", ""},
+		{"8'hxz", ""},
+		{"4'b10x1", ""},
+		{"8'q1", ""},
+		{"a8'h1", ""},
+		{"8'", ""},
+		{"--1", ""},
+		{"+1", ""},
+		{"abc", ""},
+	}
+	for _, tt := range tests {
+		value, ok := parseNumber(tt.token)
+		got := ""
+		if ok {
+			got = value.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseNumber(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,605 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+// 生成的testbench中使用的名称，参考设计模块、testbench 模块和参考设计实例名还要加上每个任务随机的后缀
+const (
+	equivTestbenchModule   = "equiv_tb"
+	equivReferenceInstance = "golden"
+	equivHalfPeriod        = 5 // 时钟半周期，单位 ns
+)
+
+// errReferencesJudgeNames 提交设计引用了参考设计或testbench内部的名称
+var errReferencesJudgeNames = errors.New("design must not reference the reference design or the equivalence testbench")
+
+var (
+	lineCommentRegex  = regexp.MustCompile(`//[^\n]*`)
+	blockCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	moduleNameRegex   = regexp.MustCompile(`\bmodule\s+(\w+)`)
+	portDeclRegex     = regexp.MustCompile(`\b(input|output|inout)\b([^;]*);`)
+	portRangeRegex    = regexp.MustCompile(`^\[\s*(\d+)\s*:\s*(\d+)\s*\]`)
+	identifierRegex   = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// port 模块端口
+type port struct {
+	name   string
+	output bool
+	width  int
+}
+
+// equivalenceDesign 解析参考设计得到的顶层模块信息
+type equivalenceDesign struct {
+	top     string
+	inputs  []port
+	outputs []port
+}
+
+// stripComments 删除Verilog代码中的注释
+func stripComments(code string) string {
+	code = blockCommentRegex.ReplaceAllString(code, "")
+	return lineCommentRegex.ReplaceAllString(code, "")
+}
+
+// parseTopModule 解析顶层模块的端口，top 为空时使用第一个模块
+// 只支持数字形式的位宽，例如 [7:0]；参数化位宽和 inout 端口会返回错误
+func parseTopModule(code, top string) (*equivalenceDesign, error) {
+	code = stripComments(code)
+
+	start := -1
+	for _, loc := range moduleNameRegex.FindAllStringSubmatchIndex(code, -1) {
+		name := code[loc[2]:loc[3]]
+		if top == "" || name == top {
+			top = name
+			start = loc[1]
+			break
+		}
+	}
+	if start < 0 {
+		if top == "" {
+			return nil, fmt.Errorf("reference design contains no module")
+		}
+		return nil, fmt.Errorf("module %s not found in reference design", top)
+	}
+
+	end := strings.Index(code[start:], "endmodule")
+	if end < 0 {
+		return nil, fmt.Errorf("module %s has no endmodule", top)
+	}
+	body := code[start : start+end]
+
+	header, body, err := splitModuleHeader(body)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %v", top, err)
+	}
+
+	// 端口列表中带方向时为 ANSI 风格，否则从模块体中的声明获取方向和位宽
+	var decls []string
+	if portDeclRegex.MatchString(header + ";") {
+		decls = []string{header}
+	} else {
+		for _, m := range portDeclRegex.FindAllStringSubmatch(body, -1) {
+			decls = append(decls, m[0])
+		}
+	}
+
+	design := &equivalenceDesign{top: top}
+	for _, decl := range decls {
+		ports, err := parsePortDecl(strings.TrimSuffix(decl, ";"))
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %v", top, err)
+		}
+		for _, p := range ports {
+			if p.output {
+				design.outputs = append(design.outputs, p)
+			} else {
+				design.inputs = append(design.inputs, p)
+			}
+		}
+	}
+	if len(design.outputs) == 0 {
+		return nil, fmt.Errorf("module %s has no output ports", top)
+	}
+	return design, nil
+}
+
+// splitModuleHeader 跳过参数列表，返回端口列表和之后的模块体
+func splitModuleHeader(code string) (string, string, error) {
+	rest := strings.TrimSpace(code)
+	if strings.HasPrefix(rest, "#") {
+		_, after, err := matchParens(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			return "", "", err
+		}
+		rest = strings.TrimSpace(after)
+	}
+	if !strings.HasPrefix(rest, "(") {
+		// 没有端口列表的模块
+		return "", rest, nil
+	}
+	return matchParens(rest)
+}
+
+// matchParens 返回以左括号开头的代码中括号内的内容和右括号之后的部分
+func matchParens(code string) (string, string, error) {
+	if !strings.HasPrefix(code, "(") {
+		return "", "", fmt.Errorf("expected '('")
+	}
+	depth := 0
+	for i, ch := range code {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return code[1:i], code[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unbalanced parentheses in module header")
+}
+
+// parsePortDecl 解析逗号分隔的端口声明，方向和位宽沿用前一个声明
+func parsePortDecl(decl string) ([]port, error) {
+	var ports []port
+	direction := ""
+	width := 1
+	for _, item := range strings.Split(decl, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		fields := strings.Fields(item)
+		if len(fields) > 0 {
+			switch fields[0] {
+			case "input", "output", "inout":
+				direction = fields[0]
+				width = 1
+				item = strings.TrimSpace(strings.TrimPrefix(item, fields[0]))
+			}
+		}
+		if direction == "" {
+			continue
+		}
+		if direction == "inout" {
+			return nil, fmt.Errorf("inout ports are not supported")
+		}
+
+		// 跳过数据类型关键字
+		for {
+			trimmed := item
+			for _, kw := range []string{"wire", "reg", "logic", "signed", "unsigned"} {
+				if f := strings.Fields(trimmed); len(f) > 0 && f[0] == kw {
+					trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, kw))
+				}
+			}
+			if trimmed == item {
+				break
+			}
+			item = trimmed
+		}
+
+		if strings.HasPrefix(item, "[") {
+			m := portRangeRegex.FindStringSubmatch(item)
+			if m == nil {
+				return nil, fmt.Errorf("unsupported port range in %q, only numeric ranges like [7:0] are supported", item)
+			}
+			msb, _ := strconv.Atoi(m[1])
+			lsb, _ := strconv.Atoi(m[2])
+			width = msb - lsb + 1
+			if lsb > msb {
+				width = lsb - msb + 1
+			}
+			item = strings.TrimSpace(item[len(m[0]):])
+		}
+
+		// 去掉初始值
+		name, _, _ := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !identifierRegex.MatchString(name) {
+			return nil, fmt.Errorf("unsupported port declaration %q", item)
+		}
+		ports = append(ports, port{name: name, output: direction == "output", width: width})
+	}
+	return ports, nil
+}
+
+// renameReferenceModules 为参考设计中的全部模块名加上后缀，避免与提交设计重名
+// 只改写 module 声明和例化处的模块名，与模块同名的线网、变量和端口保持不变
+// 后缀每个任务随机生成，提交设计无法通过模块名例化参考设计
+func renameReferenceModules(code, suffix string) string {
+	code = stripComments(code)
+	var names []string
+	for _, m := range moduleNameRegex.FindAllStringSubmatch(code, -1) {
+		names = append(names, regexp.QuoteMeta(m[1]))
+	}
+	if len(names) == 0 {
+		return code
+	}
+	alternatives := strings.Join(names, "|")
+	// 例化时模块名之后是参数列表 #(...)，或实例名加端口列表或实例数组的范围
+	declaration := regexp.MustCompile(`\b(module\s+)(` + alternatives + `)\b`)
+	instantiation := regexp.MustCompile(`\b(` + alternatives + `)(\s*#|\s+[A-Za-z_]\w*\s*[(\[])`)
+	code = declaration.ReplaceAllString(code, "${1}${2}"+suffix)
+	return instantiation.ReplaceAllString(code, "${1}"+suffix+"${2}")
+}
+
+// equivalencePlan 生成testbench需要的全部参数
+type equivalencePlan struct {
+	design     *equivalenceDesign
+	eq         protocol.Equivalence
+	driven     []port // 由激励驱动的输入
+	held       []port
+	totalBits  int
+	exhaustive bool
+	cycles     int
+	marker     string // 判定行的随机标记，见 newMarker
+	suffix     string // 参考设计模块、testbench 模块和参考设计实例名的随机后缀
+}
+
+// newReferenceSuffix 生成每个任务随机的名称后缀，测试中可以替换为固定值
+var newReferenceSuffix = func() string {
+	return "__ref_" + newMarker()
+}
+
+// testbenchModule 返回testbench的模块名
+func (p *equivalencePlan) testbenchModule() string {
+	return equivTestbenchModule + p.suffix
+}
+
+// checkDesign 拒绝引用参考设计模块或testbench内部名称的提交设计
+// 这些名称都带有随机后缀，正常的设计不会包含它
+func (p *equivalencePlan) checkDesign(code string) error {
+	if strings.Contains(code, p.suffix) {
+		return errReferencesJudgeNames
+	}
+	return nil
+}
+
+// planEquivalence 校验配置并确定激励方式
+func planEquivalence(design *equivalenceDesign, eq protocol.Equivalence) (*equivalencePlan, error) {
+	if eq.Cycles <= 0 {
+		eq.Cycles = protocol.DefaultEquivalenceCycles
+	}
+	if eq.ResetCycles <= 0 {
+		eq.ResetCycles = 1
+	}
+	if eq.ExhaustiveBits <= 0 {
+		eq.ExhaustiveBits = protocol.DefaultExhaustiveBits
+	}
+	if eq.Reset != "" && eq.Clock == "" {
+		return nil, fmt.Errorf("reset %s requires a clock", eq.Reset)
+	}
+
+	inputs := map[string]port{}
+	for _, p := range design.inputs {
+		inputs[p.name] = p
+	}
+	for _, name := range []string{eq.Clock, eq.Reset} {
+		if name == "" {
+			continue
+		}
+		p, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not an input of module %s", name, design.top)
+		}
+		if p.width != 1 {
+			return nil, fmt.Errorf("clock and reset inputs must be 1 bit wide, %s is %d bits", name, p.width)
+		}
+	}
+	for name, value := range eq.Hold {
+		p, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("held input %s is not an input of module %s", name, design.top)
+		}
+		if p.width < 64 && value>>uint(p.width) != 0 {
+			return nil, fmt.Errorf("held value %d does not fit in %d-bit input %s", value, p.width, name)
+		}
+	}
+
+	plan := &equivalencePlan{design: design, eq: eq, cycles: eq.Cycles}
+	for _, p := range design.inputs {
+		switch {
+		case p.name == eq.Clock || p.name == eq.Reset:
+		case hasKey(eq.Hold, p.name):
+			plan.held = append(plan.held, p)
+		default:
+			plan.driven = append(plan.driven, p)
+			plan.totalBits += p.width
+		}
+	}
+	plan.exhaustive = len(plan.driven) > 0 && plan.totalBits <= eq.ExhaustiveBits
+	if plan.exhaustive && eq.Clock == "" {
+		// 组合逻辑穷举全部输入组合即可
+		plan.cycles = 1 << uint(plan.totalBits)
+	}
+	return plan, nil
+}
+
+// hasKey 判断 map 中是否存在键
+func hasKey(m map[string]uint64, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// verilogRange 返回位宽对应的声明范围，1位时为空
+func verilogRange(width int) string {
+	if width == 1 {
+		return ""
+	}
+	return fmt.Sprintf("[%d:0] ", width-1)
+}
+
+// randomExpr 返回生成指定位宽随机数的表达式
+func randomExpr(width int) string {
+	parts := make([]string, (width+31)/32)
+	for i := range parts {
+		parts[i] = "$random(equiv_seed)"
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// testbench 生成同时例化提交设计和参考设计并逐周期比较输出的testbench
+func (p *equivalencePlan) testbench() string {
+	var b strings.Builder
+	eq := p.eq
+	top := p.design.top
+
+	fmt.Fprintf(&b, "`timescale 1ns/1ps\nmodule %s;\n", p.testbenchModule())
+	for _, in := range p.design.inputs {
+		fmt.Fprintf(&b, "  reg %s%s;\n", verilogRange(in.width), in.name)
+	}
+	for _, out := range p.design.outputs {
+		fmt.Fprintf(&b, "  wire %sdut_out_%s, ref_out_%s;\n", verilogRange(out.width), out.name, out.name)
+	}
+	b.WriteString("  integer equiv_seed;\n  integer equiv_cycle;\n  reg equiv_mismatch;\n\n")
+
+	for _, inst := range []struct{ module, name, prefix string }{
+		{top, "dut", "dut_out_"},
+		{top + p.suffix, equivReferenceInstance + p.suffix, "ref_out_"},
+	} {
+		var conns []string
+		for _, in := range p.design.inputs {
+			conns = append(conns, fmt.Sprintf(".%s(%s)", in.name, in.name))
+		}
+		for _, out := range p.design.outputs {
+			conns = append(conns, fmt.Sprintf(".%s(%s%s)", out.name, inst.prefix, out.name))
+		}
+		fmt.Fprintf(&b, "  %s %s (%s);\n", inst.module, inst.name, strings.Join(conns, ", "))
+	}
+	b.WriteString("\n")
+
+	if eq.Clock != "" {
+		fmt.Fprintf(&b, "  initial %s = 1'b0;\n  always #%d %s = ~%s;\n\n", eq.Clock, equivHalfPeriod, eq.Clock, eq.Clock)
+	}
+
+	// 驱动一个周期的激励
+	b.WriteString("  task equiv_drive;\n  begin\n")
+	if len(p.driven) > 0 {
+		indent := "    "
+		if p.exhaustive {
+			names := make([]string, len(p.driven))
+			for i, in := range p.driven {
+				names[i] = in.name
+			}
+			fmt.Fprintf(&b, "    if (equiv_cycle < %d)\n      {%s} = equiv_cycle;\n    else begin\n", 1<<uint(p.totalBits), strings.Join(names, ", "))
+			indent = "      "
+		}
+		for _, in := range p.driven {
+			fmt.Fprintf(&b, "%s%s = %s;\n", indent, in.name, randomExpr(in.width))
+		}
+		if p.exhaustive {
+			b.WriteString("    end\n")
+		}
+	}
+	b.WriteString("  end\n  endtask\n\n")
+
+	// 比较全部输出，不一致时打印输入和输出后结束仿真
+	b.WriteString("  task equiv_check;\n  begin\n    equiv_mismatch = 1'b0;\n")
+	for _, out := range p.design.outputs {
+		fmt.Fprintf(&b, "    if (dut_out_%s !== ref_out_%s) begin\n", out.name, out.name)
+		fmt.Fprintf(&b, "      equiv_mismatch = 1'b1;\n      $display(\"EQUIV_OUTPUT %s %s dut=%%h ref=%%h\", dut_out_%s, ref_out_%s);\n    end\n", p.marker, out.name, out.name, out.name)
+	}
+	fmt.Fprintf(&b, "    if (equiv_mismatch) begin\n      $display(\"EQUIV_MISMATCH %s cycle=%%0d\", equiv_cycle);\n", p.marker)
+	for _, in := range p.design.inputs {
+		if in.name == eq.Clock {
+			continue
+		}
+		fmt.Fprintf(&b, "      $display(\"EQUIV_INPUT %s %s=%%h\", %s);\n", p.marker, in.name, in.name)
+	}
+	b.WriteString("      $finish;\n    end\n  end\n  endtask\n\n")
+
+	b.WriteString("  initial begin\n")
+	fmt.Fprintf(&b, "    equiv_seed = %d;\n", eq.Seed)
+	for _, in := range p.held {
+		fmt.Fprintf(&b, "    %s = %d'd%d;\n", in.name, in.width, eq.Hold[in.name])
+	}
+	for _, in := range p.driven {
+		fmt.Fprintf(&b, "    %s = 0;\n", in.name)
+	}
+	if eq.Reset != "" {
+		active, inactive := "1'b1", "1'b0"
+		if eq.ResetActiveLow {
+			active, inactive = inactive, active
+		}
+		fmt.Fprintf(&b, "    %s = %s;\n    repeat (%d) @(posedge %s);\n    #1 %s = %s;\n", eq.Reset, active, eq.ResetCycles, eq.Clock, eq.Reset, inactive)
+	}
+	fmt.Fprintf(&b, "    for (equiv_cycle = 0; equiv_cycle < %d; equiv_cycle = equiv_cycle + 1) begin\n", p.cycles)
+	b.WriteString("      equiv_drive;\n")
+	if eq.Clock != "" {
+		fmt.Fprintf(&b, "      @(posedge %s);\n      #1;\n", eq.Clock)
+	} else {
+		fmt.Fprintf(&b, "      #%d;\n", 2*equivHalfPeriod)
+	}
+	fmt.Fprintf(&b, "      equiv_check;\n    end\n    $display(\"EQUIV_PASS %s\");\n    $finish;\n  end\nendmodule\n", p.marker)
+	return b.String()
+}
+
+// description 返回等价性检查在判题结果中的描述
+func (p *equivalencePlan) description() string {
+	if p.exhaustive && p.eq.Clock == "" {
+		return fmt.Sprintf("exhaustive equivalence (%d input bits)", p.totalBits)
+	}
+	return fmt.Sprintf("random equivalence (seed=%d, cycles=%d)", p.eq.Seed, p.cycles)
+}
+
+// parseEquivalenceOutput 解析仿真输出，返回是否通过以及首个不一致处的说明
+// 只接受带有标记 marker 的判定行：不一致的输出在 EQUIV_MISMATCH 之前，输入在其后；
+// 第一个 EQUIV_MISMATCH 之后只读取输入，之后的其他行（包括 EQUIV_PASS）都不再生效，
+// 得出结论前出现不带标记的 EQUIV_ 行时返回 errForgedVerdict
+func parseEquivalenceOutput(output, marker string) (bool, string, error) {
+	var cycle string
+	var inputs, outputs []string
+	for _, line := range strings.Split(output, "\n") {
+		fields, ok, err := markedFields(line, "EQUIV_", marker)
+		if cycle != "" {
+			if err != nil || (ok && (fields[0] != "EQUIV_INPUT" || len(fields) != 2)) {
+				break
+			}
+			if ok {
+				inputs = append(inputs, fields[1])
+			}
+			continue
+		}
+		if err != nil {
+			return false, "", err
+		}
+		if !ok {
+			continue
+		}
+		switch {
+		case fields[0] == "EQUIV_PASS" && len(fields) == 1:
+			return true, "", nil
+		case fields[0] == "EQUIV_MISMATCH" && len(fields) == 2 && strings.HasPrefix(fields[1], "cycle="):
+			cycle = strings.TrimPrefix(fields[1], "cycle=")
+		case fields[0] == "EQUIV_OUTPUT" && len(fields) == 4 && strings.HasPrefix(fields[2], "dut=") && strings.HasPrefix(fields[3], "ref="):
+			outputs = append(outputs, fmt.Sprintf("%s = %s, expected %s", fields[1], strings.TrimPrefix(fields[2], "dut="), strings.TrimPrefix(fields[3], "ref=")))
+		default:
+			return false, "", fmt.Errorf("invalid equivalence verdict %q", line)
+		}
+	}
+	if cycle == "" {
+		return false, "", fmt.Errorf("equivalence testbench finished without a verdict")
+	}
+	return false, fmt.Sprintf("first mismatch at cycle %s (inputs: %s): %s",
+		cycle, strings.Join(inputs, " "), strings.Join(outputs, "; ")), nil
+}
+
+// runEquivalence 生成等价性检查testbench，与参考设计一起编译并仿真
+// 启用形式化证明时先用 Yosys 证明，只有无法得出结论时才仿真
+func (j *Judge) runEquivalence(ctx context.Context, tempDir string, req *protocol.JudgeRequest, timeLimit int, compileTimeout, formalTimeout time.Duration, output sandbox.Limits) CaseResult {
+	eq := req.Equivalence
+	caseResult := CaseResult{Description: "equivalence"}
+
+	design, err := parseTopModule(eq.Reference, eq.TopModule)
+	if err != nil {
+		caseResult.Status = protocol.StatusSystemError
+		caseResult.ErrorMessage = fmt.Sprintf("Invalid equivalence reference: %v", err)
+		return caseResult
+	}
+	plan, err := planEquivalence(design, *eq)
+	if err != nil {
+		caseResult.Status = protocol.StatusSystemError
+		caseResult.ErrorMessage = fmt.Sprintf("Invalid equivalence config: %v", err)
+		return caseResult
+	}
+	caseResult.Description = plan.description()
+	plan.marker = newMarker()
+	plan.suffix = newReferenceSuffix()
+
+	// 形式化证明和仿真都会把提交设计与参考设计放在一起读入，必须先于两者检查
+	if err := plan.checkDesign(req.Code); err != nil {
+		caseResult.Status = protocol.StatusCompileError
+		caseResult.ErrorMessage = err.Error()
+		return caseResult
+	}
+
+	files := map[string]string{
+		"design.v":    req.Code,
+		"reference.v": renameReferenceModules(eq.Reference, plan.suffix),
+		"equiv_tb.v":  plan.testbench(),
+	}
+	var paths []string
+	for _, name := range []string{"design.v", "reference.v", "equiv_tb.v"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			caseResult.Status = protocol.StatusSystemError
+			caseResult.ErrorMessage = fmt.Sprintf("failed to write %s: %v", name, err)
+			return caseResult
+		}
+		paths = append(paths, path)
+	}
+
+	if eq.Formal {
+		outcome := j.runFormal(ctx, tempDir, plan, req.Language, formalTimeout, output)
+		switch outcome.verdict {
+		case formalProven:
+			caseResult.Description = "formal equivalence (yosys)"
+			caseResult.Status = protocol.StatusAccepted
+			caseResult.RunTime = outcome.runTime
+			return caseResult
+		case formalCounterexample:
+			caseResult.Description = "formal equivalence (yosys)"
+			caseResult.Status = protocol.StatusWrongAnswer
+			caseResult.ErrorMessage = outcome.message
+			caseResult.RunTime = outcome.runTime
+			return caseResult
+		default:
+			// 无法证明时回退到仿真，在描述中保留原因便于出题人排查
+			caseResult.Description += ", formal check inconclusive: " + outcome.message
+		}
+	}
+
+	if err := j.compileFiles(ctx, tempDir, paths, req.Language, compileTimeout, output, "-s", plan.testbenchModule()); err != nil {
+		caseResult.Status = compileFailureStatus(err)
+		caseResult.ErrorMessage = err.Error()
+		return caseResult
+	}
+
+	sim := j.simulate(ctx, tempDir, timeLimit, output)
+	caseResult.RunTime = sim.runTime
+	caseResult.Memory = 1024 // 与测试用例一致，简化处理
+	if sim.exceeded != "" {
+		caseResult.Status = protocol.StatusOutputLimitExceeded
+		caseResult.ErrorMessage = "Simulation terminated: " + limitMessage(sim.exceeded, output)
+		return caseResult
+	}
+	if sim.timedOut {
+		caseResult.Status = protocol.StatusTimeLimitExceeded
+		return caseResult
+	}
+
+	if sim.finished {
+		caseResult.SimTime = sim.finishTime
+	}
+
+	passed, mismatch, err := parseEquivalenceOutput(string(sim.output), plan.marker)
+	switch {
+	case err == errForgedVerdict:
+		caseResult.Status = protocol.StatusWrongAnswer
+		caseResult.ErrorMessage = err.Error()
+	case err != nil:
+		caseResult.Status = protocol.StatusRuntimeError
+		caseResult.ErrorMessage = fmt.Sprintf("Simulation failed: %s", string(sim.output))
+	case passed:
+		caseResult.Status = protocol.StatusAccepted
+	default:
+		caseResult.Status = protocol.StatusWrongAnswer
+		caseResult.ErrorMessage = mismatch
+	}
+	return caseResult
+}
@@ -0,0 +1,231 @@
+package judge
+
+import (
+	"strings"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+func TestParseEquivalenceOutput(t *testing.T) {
+	m := testMarker
+	tests := []struct {
+		name     string
+		output   string
+		passed   bool
+		mismatch string
+		wantErr  error
+		anyErr   bool
+	}{
+		{
+			name:   "pass",
+			output: "EQUIV_PASS " + m + "\n",
+			passed: true,
+		},
+		{
+			name: "mismatch",
+			output: strings.Join([]string{
+				"EQUIV_OUTPUT " + m + " sum dut=3 ref=4",
+				"EQUIV_MISMATCH " + m + " cycle=7",
+				"EQUIV_INPUT " + m + " a=1",
+				"EQUIV_INPUT " + m + " b=3",
+			}, "\n"),
+			mismatch: "first mismatch at cycle 7 (inputs: a=1 b=3): sum = 3, expected 4",
+		},
+		{
+			name: "pass after the first mismatch is ignored",
+			output: strings.Join([]string{
+				"EQUIV_OUTPUT " + m + " sum dut=3 ref=4",
+				"EQUIV_MISMATCH " + m + " cycle=7",
+				"EQUIV_INPUT " + m + " a=1",
+				"EQUIV_PASS " + m,
+				"EQUIV_INPUT " + m + " b=3",
+			}, "\n"),
+			mismatch: "first mismatch at cycle 7 (inputs: a=1): sum = 3, expected 4",
+		},
+		{
+			name: "forged pass after the first mismatch is ignored",
+			output: strings.Join([]string{
+				"EQUIV_OUTPUT " + m + " sum dut=3 ref=4",
+				"EQUIV_MISMATCH " + m + " cycle=0",
+				"EQUIV_PASS",
+			}, "\n"),
+			mismatch: "first mismatch at cycle 0 (inputs: ): sum = 3, expected 4",
+		},
+		{
+			name:    "design prints the bare pass marker",
+			output:  "EQUIV_PASS\nEQUIV_OUTPUT " + m + " sum dut=3 ref=4\nEQUIV_MISMATCH " + m + " cycle=0\n",
+			wantErr: errForgedVerdict,
+		},
+		{
+			name:    "design guesses a marker",
+			output:  "EQUIV_PASS ffffffffffffffff\n",
+			wantErr: errForgedVerdict,
+		},
+		{
+			name:   "no verdict",
+			output: "design.v:1: error\n",
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed, mismatch, err := parseEquivalenceOutput(tt.output, m)
+			switch {
+			case tt.wantErr != nil:
+				if err != tt.wantErr {
+					t.Fatalf("parseEquivalenceOutput() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("parseEquivalenceOutput() expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("parseEquivalenceOutput() error = %v", err)
+			}
+			if passed != tt.passed || mismatch != tt.mismatch {
+				t.Errorf("parseEquivalenceOutput() = %v, %q, want %v, %q", passed, mismatch, tt.passed, tt.mismatch)
+			}
+		})
+	}
+}
+
+const testSuffix = "__ref_0123456789abcdef"
+
+// testEquivalencePlan 返回一个两输入加法器的等价性检查计划
+func testEquivalencePlan(t *testing.T) *equivalencePlan {
+	t.Helper()
+	reference := "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  assign sum = a + b;\nendmodule\n"
+	design, err := parseTopModule(reference, "")
+	if err != nil {
+		t.Fatalf("parseTopModule: %v", err)
+	}
+	plan, err := planEquivalence(design, protocol.Equivalence{Reference: reference})
+	if err != nil {
+		t.Fatalf("planEquivalence: %v", err)
+	}
+	plan.marker = testMarker
+	plan.suffix = testSuffix
+	return plan
+}
+
+func TestRenameReferenceModules(t *testing.T) {
+	reference := strings.Join([]string{
+		"// module commented_out",
+		"module full_adder(input a, input b, input cin, output s, output cout);",
+		"  assign {cout, s} = a + b + cin;",
+		"endmodule",
+		"module adder(input [1:0] a, input [1:0] b, output [2:0] sum);",
+		"  wire c;",
+		"  full_adder fa0 (a[0], b[0], 1'b0, sum[0], c);",
+		"  full_adder fa1 (a[1], b[1], c, sum[1], sum[2]);",
+		"  wire full_adder_carry;",
+		"endmodule",
+	}, "\n")
+
+	got := renameReferenceModules(reference, testSuffix)
+	for _, want := range []string{
+		"module full_adder" + testSuffix + "(",
+		"module adder" + testSuffix + "(",
+		"full_adder" + testSuffix + " fa0",
+		"full_adder" + testSuffix + " fa1",
+		"wire full_adder_carry;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renamed reference missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "commented_out") {
+		t.Errorf("comments should be stripped:\n%s", got)
+	}
+}
+
+// TestRenameReferenceModulesKeepsNets 与模块同名的线网、变量和端口不是模块引用，不能改名
+func TestRenameReferenceModulesKeepsNets(t *testing.T) {
+	reference := strings.Join([]string{
+		"module full_adder(input a, input b, output s);",
+		"  assign s = a ^ b;",
+		"endmodule",
+		"module adder(input [1:0] a, input [1:0] b, output [1:0] full_adder);",
+		"  wire adder;",
+		"  reg [1:0] adder2 [0:1];",
+		"  assign adder = a[0];",
+		"  full_adder #(.W(1)) fa0 (.a(adder), .b(b[0]), .s(full_adder[0]));",
+		"  full_adder fa1[0:0] (a[1], b[1], full_adder[1]);",
+		"endmodule",
+		"module adder2;",
+		"  adder",
+		"    u (.a(2'b0), .b(2'b0), .full_adder());",
+		"endmodule",
+	}, "\n")
+
+	got := renameReferenceModules(reference, testSuffix)
+	want := strings.Join([]string{
+		"module full_adder" + testSuffix + "(input a, input b, output s);",
+		"  assign s = a ^ b;",
+		"endmodule",
+		"module adder" + testSuffix + "(input [1:0] a, input [1:0] b, output [1:0] full_adder);",
+		"  wire adder;",
+		"  reg [1:0] adder2 [0:1];",
+		"  assign adder = a[0];",
+		"  full_adder" + testSuffix + " #(.W(1)) fa0 (.a(adder), .b(b[0]), .s(full_adder[0]));",
+		"  full_adder" + testSuffix + " fa1[0:0] (a[1], b[1], full_adder[1]);",
+		"endmodule",
+		"module adder2" + testSuffix + ";",
+		"  adder" + testSuffix,
+		"    u (.a(2'b0), .b(2'b0), .full_adder());",
+		"endmodule",
+	}, "\n")
+	if got != want {
+		t.Errorf("renameReferenceModules() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEquivalenceTestbenchUsesJobSuffix(t *testing.T) {
+	tb := testEquivalencePlan(t).testbench()
+	for _, want := range []string{
+		"module equiv_tb" + testSuffix + ";",
+		"adder" + testSuffix + " golden" + testSuffix + " (",
+		"adder dut (",
+	} {
+		if !strings.Contains(tb, want) {
+			t.Errorf("testbench missing %q:\n%s", want, tb)
+		}
+	}
+	if strings.Contains(tb, "__equiv_ref") {
+		t.Errorf("testbench still uses the fixed reference suffix:\n%s", tb)
+	}
+}
+
+func TestEquivalenceCheckDesign(t *testing.T) {
+	plan := testEquivalencePlan(t)
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{
+			name: "own implementation",
+			code: "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  assign sum = a + b;\nendmodule\n",
+		},
+		{
+			name:    "instantiates the reference",
+			code:    "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  adder" + testSuffix + " r (a, b, sum);\nendmodule\n",
+			wantErr: errReferencesJudgeNames,
+		},
+		{
+			name:    "reads the reference instance",
+			code:    "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  assign sum = equiv_tb" + testSuffix + ".golden" + testSuffix + ".sum;\nendmodule\n",
+			wantErr: errReferencesJudgeNames,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := plan.checkDesign(tt.code); err != tt.wantErr {
+				t.Errorf("checkDesign() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+// 形式化等价证明的结论
+const (
+	formalProven         = "proven"
+	formalCounterexample = "counterexample"
+	formalInconclusive   = "inconclusive"
+)
+
+// formalMiterModule Yosys 生成的比较电路名称
+const formalMiterModule = "equiv_miter"
+
+// satModelRegex 匹配 sat -show-inputs/-show-outputs 打印的模型行，时序问题带有时间步
+var satModelRegex = regexp.MustCompile(`^\s*(?:(\d+)\s+)?\\(\S+)\s+\S+\s+(\S+)\s+\S+\s*$`)
+
+// formalOutcome 形式化等价证明的结果
+type formalOutcome struct {
+	verdict string
+	message string // 反例说明或无法得出结论的原因
+	runTime int    // 毫秒
+}
+
+// formalScript 生成 Yosys 脚本：构造参考设计与提交设计的比较电路，并用SAT证明两者输出始终一致
+// 时序电路从全零初始状态开始做时序归纳，复位输入在前 reset_cycles 步保持有效
+func (p *equivalencePlan) formalScript(designFile, referenceFile, language string) string {
+	eq := p.eq
+	readFlags := ""
+	if language == "systemverilog" {
+		readFlags = " -sv"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "read_verilog%s %s\n", readFlags, designFile)
+	fmt.Fprintf(&b, "read_verilog%s %s\n", readFlags, referenceFile)
+	b.WriteString("hierarchy -check\nproc\nasync2sync\nmemory\nopt_clean\n")
+	fmt.Fprintf(&b, "miter -equiv -flatten -make_outputs -ignore_gold_x %s%s %s %s\n",
+		p.design.top, p.suffix, p.design.top, formalMiterModule)
+	fmt.Fprintf(&b, "hierarchy -top %s\nopt -fast\n", formalMiterModule)
+
+	args := []string{"sat", "-verify", "-prove", "trigger", "0", "-show-inputs", "-show-outputs"}
+	for _, in := range p.held {
+		args = append(args, "-set", "in_"+in.name, fmt.Sprintf("%d'd%d", in.width, eq.Hold[in.name]))
+	}
+	if eq.Clock != "" {
+		depth := eq.FormalDepth
+		if depth <= 0 {
+			depth = protocol.DefaultFormalDepth
+		}
+		seq := 1
+		if eq.Reset != "" {
+			// 复位期间不比较输出，与仿真一致
+			seq = eq.ResetCycles + 1
+			active := "1"
+			if eq.ResetActiveLow {
+				active = "0"
+			}
+			for step := 1; step <= eq.ResetCycles; step++ {
+				args = append(args, "-set-at", strconv.Itoa(step), "in_"+eq.Reset, active)
+			}
+		}
+		args = append(args, "-tempinduct", "-set-init-zero", "-seq", strconv.Itoa(seq), "-maxsteps", strconv.Itoa(depth))
+	}
+	args = append(args, formalMiterModule)
+	b.WriteString(strings.Join(args, " ") + "\n")
+	return b.String()
+}
+
+// runFormal 使用 Yosys 证明提交设计与参考设计等价
+// Yosys 未配置、超时、不支持的语法或归纳证明达到最大步数时返回 formalInconclusive
+func (j *Judge) runFormal(ctx context.Context, tempDir string, plan *equivalencePlan, language string, timeout time.Duration, output sandbox.Limits) formalOutcome {
+	if j.simulator.FormalPath == "" {
+		return formalOutcome{verdict: formalInconclusive, message: "formal checking is not configured"}
+	}
+
+	scriptFile := filepath.Join(tempDir, "formal.ys")
+	script := plan.formalScript("design.v", "reference.v", language)
+	if err := os.WriteFile(scriptFile, []byte(script), 0644); err != nil {
+		return formalOutcome{verdict: formalInconclusive, message: fmt.Sprintf("failed to write formal script: %v", err)}
+	}
+
+	formalCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	run := j.sandbox.Run(formalCtx, tempDir, output, j.simulator.FormalPath, "-Q", "-T", "-s", scriptFile)
+	outcome := formalOutcome{runTime: int(time.Since(startTime).Milliseconds())}
+	if run.Exceeded != "" {
+		outcome.verdict = formalInconclusive
+		outcome.message = "yosys " + limitMessage(run.Exceeded, output)
+		return outcome
+	}
+	if formalCtx.Err() == context.DeadlineExceeded {
+		outcome.verdict = formalInconclusive
+		outcome.message = fmt.Sprintf("formal check timed out after %v", timeout)
+		return outcome
+	}
+
+	outcome.verdict, outcome.message = parseFormalOutput(string(run.Output), plan)
+	if outcome.verdict == formalInconclusive && outcome.message == "" && run.Err != nil {
+		outcome.message = fmt.Sprintf("yosys failed: %v", run.Err)
+	}
+	return outcome
+}
+
+// parseFormalOutput 解析 Yosys sat 命令的输出
+func parseFormalOutput(output string, plan *equivalencePlan) (string, string) {
+	switch {
+	case strings.Contains(output, "model found") && strings.Contains(output, "FAIL!"):
+		return formalCounterexample, formalCounterexampleMessage(output, plan)
+	case strings.Contains(output, "SUCCESS!"):
+		return formalProven, ""
+	case strings.Contains(output, "Reached maximum number of time steps"):
+		return formalInconclusive, "induction did not converge within the maximum depth"
+	}
+
+	// 取最后一条错误信息作为原因，例如不支持的语法或提交设计缺少顶层模块
+	reason := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "ERROR:") {
+			reason = strings.TrimSpace(line)
+		}
+	}
+	return formalInconclusive, reason
+}
+
+// formalCounterexampleMessage 根据SAT模型生成反例说明，时序电路只给出最后一步的取值
+func formalCounterexampleMessage(output string, plan *equivalencePlan) string {
+	values := map[string]string{}
+	lastStep := 0
+	for _, line := range strings.Split(output, "\n") {
+		m := satModelRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		step := 0
+		if m[1] != "" {
+			step, _ = strconv.Atoi(m[1])
+		}
+		if step > lastStep {
+			lastStep = step
+			values = map[string]string{}
+		}
+		if step == lastStep {
+			values[m[2]] = m[3]
+		}
+	}
+
+	var inputs, outputs []string
+	for _, in := range plan.design.inputs {
+		if in.name == plan.eq.Clock {
+			continue
+		}
+		if value, ok := values["in_"+in.name]; ok {
+			inputs = append(inputs, fmt.Sprintf("%s=%s", in.name, value))
+		}
+	}
+	for _, out := range plan.design.outputs {
+		gate, gold := values["gate_"+out.name], values["gold_"+out.name]
+		if gate != gold {
+			outputs = append(outputs, fmt.Sprintf("%s = %s, expected %s", out.name, gate, gold))
+		}
+	}
+	sort.Strings(outputs)
+
+	where := "formal counterexample"
+	if lastStep > 0 {
+		where = fmt.Sprintf("formal counterexample at step %d", lastStep)
+	}
+	return fmt.Sprintf("%s (inputs: %s): %s", where, strings.Join(inputs, " "), strings.Join(outputs, "; "))
+}
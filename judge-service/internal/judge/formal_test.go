@@ -0,0 +1,48 @@
+package judge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+func TestFormalScriptUsesJobSuffix(t *testing.T) {
+	script := testEquivalencePlan(t).formalScript("design.v", "reference.v", "verilog")
+	want := "miter -equiv -flatten -make_outputs -ignore_gold_x adder" + testSuffix + " adder " + formalMiterModule
+	if !strings.Contains(script, want) {
+		t.Errorf("formal script missing %q:\n%s", want, script)
+	}
+	if strings.Contains(script, "__equiv_ref") {
+		t.Errorf("formal script still uses the fixed reference suffix:\n%s", script)
+	}
+}
+
+func TestFormalRejectsDesignInstantiatingReference(t *testing.T) {
+	saved := newReferenceSuffix
+	newReferenceSuffix = func() string { return testSuffix }
+	defer func() { newReferenceSuffix = saved }()
+
+	reference := "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  assign sum = a + b;\nendmodule\n"
+	req := &protocol.JudgeRequest{
+		Language: "verilog",
+		Code:     "module adder(input [3:0] a, input [3:0] b, output [4:0] sum);\n  adder" + testSuffix + " r (a, b, sum);\nendmodule\n",
+		Equivalence: &protocol.Equivalence{
+			Reference: reference,
+			Formal:    true,
+		},
+	}
+
+	// 没有沙箱，一旦运行 Yosys 或编译就会失败，结果只能来自运行前的检查
+	j := &Judge{simulator: config.SimulatorConfig{FormalPath: "yosys"}}
+	result := j.runEquivalence(context.Background(), t.TempDir(), req, 1000, time.Second, time.Second, sandbox.Limits{})
+	if result.Status != protocol.StatusCompileError {
+		t.Fatalf("status = %s, want %s", result.Status, protocol.StatusCompileError)
+	}
+	if result.ErrorMessage != errReferencesJudgeNames.Error() {
+		t.Errorf("error message = %q, want %q", result.ErrorMessage, errReferencesJudgeNames.Error())
+	}
+}
@@ -0,0 +1,195 @@
+package judge
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"verilog-oj/protocol"
+)
+
+// 生成的testbench中的名称，使用题目中的模块名和端口名不能使用的保留前缀
+const (
+	traceTestbenchModule = protocol.ReservedNamePrefix + "trace_tb" // I/O 序列生成的testbench模块名
+	dutInstance          = protocol.ReservedNamePrefix + "dut"      // 真值表和 I/O 序列testbench中被测模块的实例名
+)
+
+// testbenchFor 返回测试用例使用的testbench，I/O 序列测试用例使用生成的testbench
+func testbenchFor(testCase protocol.TestCase) string {
+	if testCase.Trace != nil {
+		return ioTraceTestbench(testCase.Trace)
+	}
+	return testCase.Testbench
+}
+
+// ioTraceTestbench 生成逐周期施加输入的testbench，输出由 compareTrace 从VCD中采样比较
+// 时钟从低电平开始，复位周期之后每个周期在下降沿施加输入，半个周期后为上升沿
+func ioTraceTestbench(trace *protocol.IOTrace) string {
+	half := trace.TracePeriod() / 2
+	resetActive, resetInactive := "1'b1", "1'b0"
+	if trace.ResetActiveLow {
+		resetActive, resetInactive = resetInactive, resetActive
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "`timescale 1ns/1ps\nmodule %s;\n", traceTestbenchModule)
+	var conns []string
+	for _, port := range trace.Ports {
+		kind := "reg"
+		if port.Direction == protocol.PortOutput {
+			kind = "wire"
+		}
+		fmt.Fprintf(&b, "  %s %s%s;\n", kind, verilogRange(port.PortWidth()), port.Name)
+		conns = append(conns, fmt.Sprintf(".%s(%s)", port.Name, port.Name))
+	}
+	fmt.Fprintf(&b, "\n  %s %s (%s);\n\n", trace.TopModule, dutInstance, strings.Join(conns, ", "))
+
+	b.WriteString("  initial begin\n")
+	fmt.Fprintf(&b, "    $dumpfile(\"output.vcd\");\n    $dumpvars(1, %s);\n", traceTestbenchModule)
+	for _, port := range trace.Ports {
+		if port.Direction == protocol.PortInput && port.Name != trace.Reset {
+			fmt.Fprintf(&b, "    %s = %d'b0;\n", port.Name, port.PortWidth())
+		}
+	}
+	clockCycle := fmt.Sprintf("#%d; %s = 1'b1; #%d; %s = 1'b0;", half, trace.Clock, half, trace.Clock)
+	reset := ""
+	if trace.Reset != "" {
+		reset = resetActive
+		fmt.Fprintf(&b, "    %s = %s;\n", trace.Reset, reset)
+		fmt.Fprintf(&b, "    repeat (%d) begin %s end\n", trace.TraceResetCycles(), clockCycle)
+	}
+
+	for i, cycle := range trace.Cycles {
+		fmt.Fprintf(&b, "    // cycle %d\n", i+1)
+		if trace.Reset != "" {
+			value := resetInactive
+			if raw, ok := cycle[trace.Reset]; ok {
+				bits, _ := protocol.TruthTableBits(raw, 1, false)
+				value = "1'b" + bits
+			}
+			if value != reset {
+				fmt.Fprintf(&b, "    %s = %s;\n", trace.Reset, value)
+				reset = value
+			}
+		}
+		for _, port := range trace.Ports {
+			raw, ok := cycle[port.Name]
+			if !ok || port.Direction != protocol.PortInput || port.Name == trace.Reset {
+				continue
+			}
+			// 取值已由 Validate 校验
+			bits, _ := protocol.TruthTableBits(raw, port.PortWidth(), false)
+			fmt.Fprintf(&b, "    %s = %d'b%s;\n", port.Name, len(bits), bits)
+		}
+		fmt.Fprintf(&b, "    %s\n", clockCycle)
+	}
+	b.WriteString("    $finish;\n  end\nendmodule\n")
+	return b.String()
+}
+
+// traceSampleTimes 返回每个周期的采样时刻（皮秒），取采样沿之前 1ps，此时输出已稳定且尚未受该时钟沿影响
+func traceSampleTimes(trace *protocol.IOTrace) []int64 {
+	period := int64(trace.TracePeriod()) * 1000
+	offset := period // SampleNegedge：下一个下降沿
+	if trace.SampleEdge == protocol.SamplePosedge {
+		offset = period / 2
+	}
+	start := int64(trace.TraceResetCycles()) * period
+	times := make([]int64, len(trace.Cycles))
+	for i := range trace.Cycles {
+		times[i] = start + int64(i)*period + offset - 1
+	}
+	return times
+}
+
+// compareTrace 在每个周期的采样时刻比较VCD中的输出与期望值，返回第一个不符合的周期（从1开始）和说明，全部符合时返回 0
+func compareTrace(actualVCDFile string, trace *protocol.IOTrace) (int, string, error) {
+	wave, err := traceWaveform(actualVCDFile, trace)
+	if err != nil {
+		return 0, "", err
+	}
+
+	inputs := make(map[string]string) // 当前周期各输入的取值，省略的输入保持上一周期的取值
+	for i, at := range traceSampleTimes(trace) {
+		cycle := trace.Cycles[i]
+		var outputs []string
+		for _, port := range trace.Ports {
+			raw, ok := cycle[port.Name]
+			if port.Direction == protocol.PortInput {
+				if port.Name == trace.Reset {
+					// 复位省略时为无效电平，只显示本周期设置的复位
+					delete(inputs, port.Name)
+				}
+				if ok {
+					inputs[port.Name], _ = protocol.TruthTableBits(raw, port.PortWidth(), false)
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+			expected, _ := protocol.TruthTableBits(raw, port.PortWidth(), true)
+			actual := traceBits(valueAt(wave.changes[traceTestbenchModule+"."+port.Name], at), port.PortWidth())
+			if !bitsMatch(expected, actual) {
+				outputs = append(outputs, fmt.Sprintf("%s = %s, expected %s", port.Name, actual, expected))
+			}
+		}
+		if len(outputs) == 0 {
+			continue
+		}
+
+		var shown []string
+		for _, port := range trace.Ports {
+			if port.Direction == protocol.PortInput && port.Name != trace.Clock {
+				if value, ok := inputs[port.Name]; ok {
+					shown = append(shown, fmt.Sprintf("%s=%s", port.Name, value))
+				}
+			}
+		}
+		return i + 1, fmt.Sprintf("cycle %d (inputs: %s): %s", i+1, strings.Join(shown, " "), strings.Join(outputs, "; ")), nil
+	}
+	return 0, "", nil
+}
+
+// traceWaveform 读取生成的testbench输出的VCD中被测模块端口的波形
+func traceWaveform(vcdFile string, trace *protocol.IOTrace) (*waveform, error) {
+	file, err := os.Open(vcdFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	only := make(map[string]bool, len(trace.Ports))
+	for _, port := range trace.Ports {
+		only[traceTestbenchModule+"."+port.Name] = true
+	}
+	wave, err := parseWaveform(file, only)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output VCD: %v", err)
+	}
+	return wave, nil
+}
+
+// traceBits 将VCD中的取值转换为 width 位的二进制串，未知值为全 x
+func traceBits(value string, width int) string {
+	bits := strings.TrimPrefix(value, "b")
+	if len(bits) != width {
+		if len(bits) == 1 && width > 1 {
+			// 标量形式的取值扩展到全部位
+			return strings.Repeat(bits, width)
+		}
+		return strings.Repeat("x", width)
+	}
+	return bits
+}
+
+// bitsMatch 比较期望值与实际值，期望值中的 - 表示不检查该位，实际值中的 x、z 与 0、1 都不相等
+func bitsMatch(expected, actual string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != '-' && expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+// testTraceVCD 计数器的输出：复位一个周期后每个周期加一
+const testTraceVCD = `$timescale 1ps $end
+$scope module __oj_trace_tb $end
+$var reg 1 ! clk $end
+$var reg 1 " rst $end
+$var reg 1 # en $end
+$var wire 2 $ q [1:0] $end
+$upscope $end
+$enddefinitions $end
+#0
+0!
+1"
+0#
+b0 $
+#10000
+0"
+1#
+#15000
+b1 $
+#25000
+b10 $
+#35000
+bx $
+`
+
+func testIOTrace(cycles ...map[string]string) *protocol.IOTrace {
+	return &protocol.IOTrace{
+		TopModule: "counter",
+		Ports: []protocol.Port{
+			{Name: "clk", Direction: protocol.PortInput},
+			{Name: "rst", Direction: protocol.PortInput},
+			{Name: "en", Direction: protocol.PortInput},
+			{Name: "q", Direction: protocol.PortOutput, Width: 2},
+		},
+		Clock:  "clk",
+		Reset:  "rst",
+		Cycles: cycles,
+	}
+}
+
+func TestTraceSampleTimes(t *testing.T) {
+	trace := testIOTrace(map[string]string{}, map[string]string{})
+	if got, want := traceSampleTimes(trace), []int64{19999, 29999}; !reflect.DeepEqual(got, want) {
+		t.Errorf("negedge sample times = %v, want %v", got, want)
+	}
+	trace.SampleEdge = protocol.SamplePosedge
+	trace.Period = 20
+	trace.ResetCycles = 2
+	if got, want := traceSampleTimes(trace), []int64{49999, 69999}; !reflect.DeepEqual(got, want) {
+		t.Errorf("posedge sample times = %v, want %v", got, want)
+	}
+}
+
+func TestCompareTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		trace   *protocol.IOTrace
+		cycle   int
+		message string
+	}{
+		{
+			name: "all cycles match",
+			trace: testIOTrace(
+				map[string]string{"en": "1", "q": "1"},
+				map[string]string{"q": "2"},
+			),
+		},
+		{
+			name: "don't-care outputs and omitted outputs are not checked",
+			trace: testIOTrace(
+				map[string]string{"en": "1", "q": "0b-1"},
+				map[string]string{},
+				map[string]string{"q": "-"},
+			),
+		},
+		{
+			name: "mismatch reports inputs carried over from earlier cycles",
+			trace: testIOTrace(
+				map[string]string{"en": "1", "q": "1"},
+				map[string]string{"q": "3"},
+			),
+			cycle:   2,
+			message: "cycle 2 (inputs: en=1): q = 10, expected 11",
+		},
+		{
+			name: "reset is shown only in the cycle that sets it",
+			trace: testIOTrace(
+				map[string]string{"en": "1", "rst": "1", "q": "0"},
+			),
+			cycle:   1,
+			message: "cycle 1 (inputs: rst=1 en=1): q = 01, expected 00",
+		},
+		{
+			name: "unknown output never matches",
+			trace: testIOTrace(
+				map[string]string{"en": "1"},
+				map[string]string{},
+				map[string]string{"q": "0b--"},
+				map[string]string{"q": "0"},
+			),
+			cycle:   4,
+			message: "cycle 4 (inputs: en=1): q = xx, expected 00",
+		},
+	}
+	path := filepath.Join(t.TempDir(), "output.vcd")
+	if err := os.WriteFile(path, []byte(testTraceVCD), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cycle, message, err := compareTrace(path, tt.trace)
+			if err != nil {
+				t.Fatalf("compareTrace() error = %v", err)
+			}
+			if cycle != tt.cycle || message != tt.message {
+				t.Errorf("compareTrace() = (%d, %q), want (%d, %q)", cycle, message, tt.cycle, tt.message)
+			}
+		})
+	}
+}
+
+func TestCompareTraceInvalidVCD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.vcd")
+	if err := os.WriteFile(path, []byte("$scope module __oj_trace_tb $end"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := compareTrace(path, testIOTrace(map[string]string{"q": "0"})); err == nil {
+		t.Error("compareTrace() should fail on a VCD without $enddefinitions")
+	}
+}
+
+func TestTraceBits(t *testing.T) {
+	tests := []struct {
+		value string
+		width int
+		want  string
+	}{
+		{"b0101", 4, "0101"},
+		{"1", 1, "1"},
+		{"x", 4, "xxxx"},
+		{"z", 2, "zz"},
+		{"b01", 4, "xxxx"},
+		{"r1.5", 2, "xx"},
+	}
+	for _, tt := range tests {
+		if got := traceBits(tt.value, tt.width); got != tt.want {
+			t.Errorf("traceBits(%q, %d) = %q, want %q", tt.value, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestBitsMatch(t *testing.T) {
+	tests := []struct {
+		expected, actual string
+		want             bool
+	}{
+		{"0101", "0101", true},
+		{"0101", "0100", false},
+		{"-1-1", "0111", true},
+		{"----", "xzxz", true},
+		{"0", "x", false},
+		{"1", "z", false},
+		{"01", "001", false},
+	}
+	for _, tt := range tests {
+		if got := bitsMatch(tt.expected, tt.actual); got != tt.want {
+			t.Errorf("bitsMatch(%q, %q) = %t, want %t", tt.expected, tt.actual, got, tt.want)
+		}
+	}
+}
@@ -2,90 +2,250 @@ package judge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/metrics"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
 )
 
-// JudgeRequest 判题请求结构
-type JudgeRequest struct {
-	SubmissionID string      `json:"submission_id"`
-	Code         string      `json:"code"`
-	Language     string      `json:"language"`
-	TimeLimit    int         `json:"time_limit"`    // 毫秒
-	MemoryLimit  int         `json:"memory_limit"`  // MB
-	TestCases    []TestCase  `json:"test_cases"`
+// Judge 判题器结构
+type Judge struct {
+	workDir   string
+	simulator config.SimulatorConfig
+	languages map[string]config.LanguageConfig
+	sandbox   *sandbox.Sandbox
+
+	mu       sync.RWMutex
+	limits   config.LimitsConfig
+	versions map[string]string // 自检检测到的工具版本，参与结果缓存键
 }
 
-// TestCase Verilog测试用例结构
-type TestCase struct {
-	Testbench    string `json:"testbench"`     // testbench代码
-	ExpectedVCD  string `json:"expected_vcd"`  // 期望的VCD文件内容或关键信号值
-	Description  string `json:"description"`   // 测试用例描述
-	SimTime      int    `json:"sim_time"`      // 仿真时间（时间单位）
+// NewJudge 创建新的判题器，沙箱不可用且未配置 insecure 回退时返回错误
+func NewJudge(cfg *config.JudgeConfig) (*Judge, error) {
+	sb, err := sandbox.New(cfg.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+	return &Judge{
+		workDir:   cfg.WorkDir,
+		simulator: cfg.Simulator,
+		languages: cfg.Languages,
+		sandbox:   sb,
+		limits:    cfg.Limits,
+	}, nil
 }
 
-// JudgeResult 判题结果结构
-type JudgeResult struct {
-	SubmissionID  string    `json:"submission_id"`
-	Status        string    `json:"status"`
-	Score         int       `json:"score"`
-	RunTime       int       `json:"run_time"`       // 毫秒
-	Memory        int       `json:"memory"`         // KB
-	ErrorMessage  string    `json:"error_message"`
-	PassedTests   int       `json:"passed_tests"`
-	TotalTests    int       `json:"total_tests"`
-	JudgedAt      time.Time `json:"judged_at"`
+// SetLimits 更新资源限制，只影响之后开始的判题任务
+func (j *Judge) SetLimits(limits config.LimitsConfig) {
+	j.mu.Lock()
+	j.limits = limits
+	j.mu.Unlock()
 }
 
-// Judge 判题器结构
-type Judge struct {
-	workDir string
+// currentLimits 返回当前资源限制的快照
+func (j *Judge) currentLimits() config.LimitsConfig {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.limits
 }
 
-// NewJudge 创建新的判题器
-func NewJudge(workDir string) *Judge {
-	return &Judge{
-		workDir: workDir,
+// effectiveLimit 未指定时使用默认值，超过上限时截断
+func effectiveLimit(requested, defaultValue, maxValue int) int {
+	if requested <= 0 {
+		return defaultValue
+	}
+	if requested > maxValue {
+		return maxValue
+	}
+	return requested
+}
+
+// outputLimits 将配置中的输出限制转换为沙箱的限制
+func outputLimits(limits config.LimitsConfig) sandbox.Limits {
+	return sandbox.Limits{
+		Output:   int64(limits.OutputLimit) << 10,
+		FileSize: int64(limits.VCDLimit) << 20,
+		DiskSize: int64(limits.DiskLimit) << 20,
+	}
+}
+
+// limitMessage 说明超出了哪一项输出限制
+func limitMessage(exceeded string, limits sandbox.Limits) string {
+	switch exceeded {
+	case sandbox.LimitOutput:
+		return fmt.Sprintf("stdout/stderr exceeded the output limit of %d KB", limits.Output>>10)
+	case sandbox.LimitFileSize:
+		return fmt.Sprintf("a file in the work directory (usually output.vcd) exceeded the VCD size limit of %d MB", limits.FileSize>>20)
+	default:
+		return fmt.Sprintf("work directory exceeded the disk limit of %d MB", limits.DiskSize>>20)
+	}
+}
+
+// outputLimitError 编译时超出输出限制
+type outputLimitError struct {
+	message string
+}
+
+func (e *outputLimitError) Error() string {
+	return e.message
+}
+
+// compileFailureStatus 编译失败对应的判题状态，超出输出限制时为 output_limit_exceeded
+func compileFailureStatus(err error) string {
+	var limitErr *outputLimitError
+	if errors.As(err, &limitErr) {
+		return protocol.StatusOutputLimitExceeded
+	}
+	return protocol.StatusCompileError
+}
+
+// CaseResult 单个测试用例的判题结果
+type CaseResult = protocol.CaseResult
+
+// Report 判题的完整报告
+type Report struct {
+	Result  *protocol.JudgeResult `json:"result"`
+	Cases   []CaseResult          `json:"cases"`
+	WorkDir string                `json:"work_dir,omitempty"` // 仅保留工作目录时返回
+}
+
+// RunOptions 判题选项
+type RunOptions struct {
+	// KeepWorkDir 判题结束后保留工作目录，每个测试用例的文件位于 case_<序号> 子目录，等价性检查位于 equivalence 子目录
+	KeepWorkDir bool
+	// Progress 接收编译和每个测试用例的进度事件，为 nil 时不报告进度
+	Progress ProgressFunc
+}
+
+// ProgressFunc 判题进度回调，在判题的 goroutine 中同步调用
+type ProgressFunc func(event *protocol.JudgeEvent)
+
+// report 报告一个进度事件
+func (o RunOptions) report(submissionID, eventType string, fill func(event *protocol.JudgeEvent)) {
+	if o.Progress == nil {
+		return
+	}
+	event := protocol.NewEvent(submissionID, eventType)
+	if fill != nil {
+		fill(event)
 	}
+	o.Progress(event)
 }
 
-// Judge 执行判题
-func (j *Judge) Judge(ctx context.Context, req *JudgeRequest) (*JudgeResult, error) {
-	result := &JudgeResult{
-		SubmissionID: req.SubmissionID,
-		TotalTests:   len(req.TestCases),
-		JudgedAt:     time.Now(),
+// reportCase 报告单个测试用例的判题结果，记录的实际输出可能很大，只在最终结果中返回
+func (o RunOptions) reportCase(submissionID string, caseResult CaseResult, totalCases int) {
+	caseResult.Captured = nil
+	o.report(submissionID, protocol.EventCaseFinished, func(event *protocol.JudgeEvent) {
+		event.CaseIndex = caseResult.Index
+		event.TotalCases = totalCases
+		event.Case = &caseResult
+	})
+}
+
+// Judge 执行判题，结果中包含每个测试用例的结果，progress 为 nil 时不报告进度
+func (j *Judge) Judge(ctx context.Context, req *protocol.JudgeRequest, progress ProgressFunc) (*protocol.JudgeResult, error) {
+	report, err := j.Run(ctx, req, RunOptions{Progress: progress})
+	if err != nil {
+		return nil, err
 	}
+	report.Result.Cases = report.Cases
+	return report.Result, nil
+}
+
+// Run 执行判题并返回每个测试用例的结果
+func (j *Judge) Run(ctx context.Context, req *protocol.JudgeRequest, opts RunOptions) (*Report, error) {
+	totalTests := len(req.TestCases)
+	runEquivalence := req.Equivalence != nil && !req.CaptureOutputs
+	if runEquivalence {
+		// 等价性检查作为最后一个测试用例
+		totalTests++
+	}
+	if req.TruthTable != nil {
+		// 真值表在测试用例之后、等价性检查之前作为一个测试用例
+		totalTests++
+	}
+	result := &protocol.JudgeResult{
+		ProtocolVersion: protocol.Version,
+		SubmissionID:    req.SubmissionID,
+		TotalTests:      totalTests,
+		JudgedAt:        time.Now(),
+	}
+	report := &Report{Result: result, Cases: []CaseResult{}}
+
+	// 整个任务使用同一份限制，重新加载配置不影响正在进行的判题
+	limits := j.currentLimits()
+	timeLimit := effectiveLimit(req.TimeLimit, limits.DefaultTimeLimit, limits.MaxTimeLimit)
+	memoryLimit := effectiveLimit(req.MemoryLimit, limits.DefaultMemoryLimit, limits.MaxMemoryLimit)
+	compileTimeout := time.Duration(limits.CompileTimeout) * time.Millisecond
+	output := outputLimits(limits)
 
 	// 创建临时工作目录
 	tempDir, err := j.createTempDir(req.SubmissionID)
 	if err != nil {
-		result.Status = "system_error"
+		result.Status = protocol.StatusSystemError
 		result.ErrorMessage = fmt.Sprintf("Failed to create temp directory: %v", err)
-		return result, nil
+		return report, nil
+	}
+	if opts.KeepWorkDir {
+		report.WorkDir = tempDir
+	} else {
+		defer os.RemoveAll(tempDir)
 	}
-	defer os.RemoveAll(tempDir)
 
 	// 编译代码 - 注意：这里需要从测试用例中获取testbench
 	// 暂时使用第一个测试用例的testbench进行编译检查
-	if len(req.TestCases) == 0 {
-		result.Status = "system_error"
+	if totalTests == 0 {
+		result.Status = protocol.StatusSystemError
 		result.ErrorMessage = "No test cases provided"
-		return result, nil
+		return report, nil
 	}
-	if err := j.compileVerilog(tempDir, req.Code, req.TestCases[0].Testbench); err != nil {
-		result.Status = "compile_error"
+	opts.report(req.SubmissionID, protocol.EventCompiling, nil)
+	if err := j.checkDesign(ctx, tempDir, req.Code, req.Language, compileTimeout, output); err != nil {
+		result.Status = compileFailureStatus(err)
 		result.ErrorMessage = err.Error()
-		return result, nil
+		return report, nil
+	}
+	if len(req.TestCases) > 0 {
+		if err := j.compileVerilog(ctx, tempDir, req.Code, testbenchFor(req.TestCases[0]), req.Language, compileTimeout, output, 0, ""); err != nil {
+			result.Status = compileFailureStatus(err)
+			result.ErrorMessage = err.Error()
+			return report, nil
+		}
+	}
+
+	// 检查程序在测试用例之前编译一次，编译失败说明题目数据有误
+	// 生成期望输出时只记录实际输出，不需要检查程序
+	check := outputCheck{checker: req.Checker}
+	if req.CaptureOutputs {
+		check.capture = captureMode(req)
+	} else if req.SpecialJudge != nil && len(req.TestCases) > 0 {
+		checkerDir := filepath.Join(tempDir, "special_judge")
+		if err := os.MkdirAll(checkerDir, 0755); err != nil {
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = fmt.Sprintf("Failed to create special judge directory: %v", err)
+			return report, nil
+		}
+		checkerTimeLimit := effectiveLimit(req.SpecialJudge.TimeLimit, limits.DefaultCheckerTimeLimit, limits.MaxCheckerTimeLimit)
+		check.special, err = j.compileSpecialJudge(ctx, checkerDir, req.SpecialJudge,
+			time.Duration(limits.CheckerCompileTimeout)*time.Millisecond, time.Duration(checkerTimeLimit)*time.Millisecond, output)
+		if err != nil {
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = fmt.Sprintf("Special judge: %v", err)
+			return report, nil
+		}
 	}
 
 	// 运行测试用例
 	passed := 0
+	partialScore := 0 // 检查程序给出的部分分之和
 	totalRunTime := 0
 	maxMemory := 0
 
@@ -93,45 +253,146 @@ func (j *Judge) Judge(ctx context.Context, req *JudgeRequest) (*JudgeResult, err
 		// 检查上下文是否取消
 		select {
 		case <-ctx.Done():
-			result.Status = "system_error"
+			result.Status = protocol.StatusSystemError
 			result.ErrorMessage = "Judge timeout"
-			return result, nil
+			return report, nil
 		default:
 		}
 
+		// 每个测试用例使用独立的子目录，便于保留工作目录时排查
+		caseDir := filepath.Join(tempDir, fmt.Sprintf("case_%d", i+1))
+		if err := os.MkdirAll(caseDir, 0755); err != nil {
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = fmt.Sprintf("Failed to create test case directory: %v", err)
+			return report, nil
+		}
+
 		// 运行单个测试用例
-		testResult, err := j.runSingleTest(ctx, tempDir, testCase, req.Code, req.TimeLimit, req.MemoryLimit)
+		opts.report(req.SubmissionID, protocol.EventRunning, func(event *protocol.JudgeEvent) {
+			event.CaseIndex = i + 1
+			event.TotalCases = totalTests
+		})
+		// 生成的 I/O 序列 testbench 总会在序列结束时 $finish，不需要默认预算
+		simBudget := testCase.SimTime
+		if simBudget <= 0 && testCase.Trace == nil {
+			simBudget = limits.DefaultSimTime
+		}
+		caseResult, err := j.runSingleTest(ctx, caseDir, testCase, check, req.Code, req.Language, timeLimit, memoryLimit, compileTimeout, output, simBudget)
 		if err != nil {
-			result.Status = "system_error"
+			result.Status = protocol.StatusSystemError
 			result.ErrorMessage = fmt.Sprintf("Test case %d failed: %v", i+1, err)
-			return result, nil
+			return report, nil
+		}
+
+		caseResult.Index = i + 1
+		caseResult.Description = testCase.Description
+		report.Cases = append(report.Cases, caseResult)
+		opts.reportCase(req.SubmissionID, caseResult, totalTests)
+
+		totalRunTime += caseResult.RunTime
+		if caseResult.Memory > maxMemory {
+			maxMemory = caseResult.Memory
+		}
+
+		if caseResult.Status == protocol.StatusAccepted {
+			passed++
+		} else {
+			partialScore += caseResult.Score
+			if result.Status == "" {
+				// 设置第一个失败的状态
+				result.Status = caseResult.Status
+				result.ErrorMessage = caseResult.ErrorMessage
+			}
+		}
+	}
+
+	if req.TruthTable != nil {
+		select {
+		case <-ctx.Done():
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = "Judge timeout"
+			return report, nil
+		default:
+		}
+
+		caseDir := filepath.Join(tempDir, "truth_table")
+		if err := os.MkdirAll(caseDir, 0755); err != nil {
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = fmt.Sprintf("Failed to create truth table directory: %v", err)
+			return report, nil
+		}
+
+		index := len(req.TestCases) + 1
+		opts.report(req.SubmissionID, protocol.EventRunning, func(event *protocol.JudgeEvent) {
+			event.CaseIndex = index
+			event.TotalCases = totalTests
+		})
+		caseResult := j.runTruthTable(ctx, caseDir, req, timeLimit, compileTimeout, output)
+		caseResult.Index = index
+		report.Cases = append(report.Cases, caseResult)
+		opts.reportCase(req.SubmissionID, caseResult, totalTests)
+
+		totalRunTime += caseResult.RunTime
+		if caseResult.Memory > maxMemory {
+			maxMemory = caseResult.Memory
 		}
+		if caseResult.Status == protocol.StatusAccepted {
+			passed++
+		} else if result.Status == "" {
+			result.Status = caseResult.Status
+			result.ErrorMessage = caseResult.ErrorMessage
+		}
+	}
 
-		totalRunTime += testResult.RunTime
-		if testResult.Memory > maxMemory {
-			maxMemory = testResult.Memory
+	if runEquivalence {
+		select {
+		case <-ctx.Done():
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = "Judge timeout"
+			return report, nil
+		default:
 		}
 
-		if testResult.Status == "accepted" {
+		caseDir := filepath.Join(tempDir, "equivalence")
+		if err := os.MkdirAll(caseDir, 0755); err != nil {
+			result.Status = protocol.StatusSystemError
+			result.ErrorMessage = fmt.Sprintf("Failed to create equivalence directory: %v", err)
+			return report, nil
+		}
+
+		opts.report(req.SubmissionID, protocol.EventRunning, func(event *protocol.JudgeEvent) {
+			event.CaseIndex = totalTests
+			event.TotalCases = totalTests
+		})
+		formalTimeout := effectiveLimit(req.Equivalence.FormalTimeout, limits.DefaultFormalTimeout, limits.MaxFormalTimeout)
+		caseResult := j.runEquivalence(ctx, caseDir, req, timeLimit, compileTimeout, time.Duration(formalTimeout)*time.Millisecond, output)
+		caseResult.Index = totalTests
+		report.Cases = append(report.Cases, caseResult)
+		opts.reportCase(req.SubmissionID, caseResult, totalTests)
+
+		totalRunTime += caseResult.RunTime
+		if caseResult.Memory > maxMemory {
+			maxMemory = caseResult.Memory
+		}
+		if caseResult.Status == protocol.StatusAccepted {
 			passed++
 		} else if result.Status == "" {
-			// 设置第一个失败的状态
-			result.Status = testResult.Status
-			result.ErrorMessage = testResult.ErrorMessage
+			result.Status = caseResult.Status
+			result.ErrorMessage = caseResult.ErrorMessage
 		}
 	}
 
 	result.PassedTests = passed
 	result.RunTime = totalRunTime
 	result.Memory = maxMemory
-	result.Score = (passed * 100) / len(req.TestCases)
+	result.Score = (passed*100 + partialScore) / totalTests
 
-	if passed == len(req.TestCases) {
-		result.Status = "accepted"
+	if passed == totalTests {
+		result.Status = protocol.StatusAccepted
 		result.ErrorMessage = ""
 	}
 
-	return result, nil
+	return report, nil
 }
 
 // createTempDir 创建临时工作目录
@@ -140,8 +401,8 @@ func (j *Judge) createTempDir(submissionID string) (string, error) {
 	return tempDir, os.MkdirAll(tempDir, 0755)
 }
 
-// compileVerilog 编译Verilog代码和testbench
-func (j *Judge) compileVerilog(tempDir, designCode, testbenchCode string) error {
+// compileVerilog 编译Verilog代码和testbench，simBudget 大于 0 时一起编译仿真时间预算模块，预算用完的标记行带有 budgetMarker
+func (j *Judge) compileVerilog(ctx context.Context, tempDir, designCode, testbenchCode, language string, timeout time.Duration, output sandbox.Limits, simBudget int, budgetMarker string) error {
 	// 写入设计文件
 	designFile := filepath.Join(tempDir, "design.v")
 	if err := os.WriteFile(designFile, []byte(designCode), 0644); err != nil {
@@ -154,74 +415,247 @@ func (j *Judge) compileVerilog(tempDir, designCode, testbenchCode string) error
 		return fmt.Errorf("failed to write testbench file: %v", err)
 	}
 
-	// 使用iverilog编译设计和testbench
-	cmd := exec.Command("iverilog", "-o", filepath.Join(tempDir, "simulation"), designFile, testbenchFile)
-	cmd.Dir = tempDir
-	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("compilation failed: %s", string(output))
+	files := []string{designFile, testbenchFile}
+	if simBudget > 0 {
+		budgetFile := filepath.Join(tempDir, simBudgetFile)
+		if err := os.WriteFile(budgetFile, []byte(simBudgetSource(simBudget, budgetMarker)), 0644); err != nil {
+			return fmt.Errorf("failed to write simulation budget file: %v", err)
+		}
+		files = append(files, budgetFile)
+	}
+
+	return j.compileFiles(ctx, tempDir, files, language, timeout, output)
+}
+
+// compileFiles 使用iverilog将源文件编译为 tempDir/simulation，extraArgs 附加在语言参数之后
+// 每个源文件之前都放一个 defaultTimescale 文件，没有声明时间单位的文件不受文件顺序影响
+// 超出输出限制时返回 *outputLimitError
+func (j *Judge) compileFiles(ctx context.Context, tempDir string, files []string, language string, timeout time.Duration, output sandbox.Limits, extraArgs ...string) error {
+	timescaleFile := filepath.Join(tempDir, defaultTimescaleFile)
+	if err := os.WriteFile(timescaleFile, []byte(defaultTimescale), 0644); err != nil {
+		return fmt.Errorf("failed to write timescale file: %v", err)
+	}
+
+	// 使用iverilog编译设计和testbench，未配置的语言不附加额外参数
+	compileCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append([]string{}, j.languages[language].CompileFlags...)
+	args = append(args, extraArgs...)
+	args = append(args, "-o", filepath.Join(tempDir, "simulation"))
+	for _, file := range files {
+		args = append(args, timescaleFile, file)
+	}
+
+	startTime := time.Now()
+	run := j.sandbox.Run(compileCtx, tempDir, output, j.simulator.CompilerPath, args...)
+	metrics.ObserveSince(metrics.CompileDuration, startTime)
+	if run.Exceeded != "" {
+		return &outputLimitError{message: "compilation failed: " + limitMessage(run.Exceeded, output)}
+	}
+	if compileCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("compilation timed out after %v", timeout)
+	}
+	if run.Err != nil {
+		if len(run.Output) == 0 {
+			// 编译器无法启动时没有输出，例如 iverilog 不在 PATH 中
+			return fmt.Errorf("compilation failed: %v", run.Err)
+		}
+		// 去掉工作目录前缀，编译错误只引用 design.v 等文件名，相同代码的错误信息与工作目录无关
+		return fmt.Errorf("compilation failed: %s", strings.ReplaceAll(string(run.Output), tempDir+string(filepath.Separator), ""))
 	}
 
 	return nil
 }
 
-// runSingleTest 运行单个Verilog测试用例
-func (j *Judge) runSingleTest(ctx context.Context, tempDir string, testCase TestCase, designCode string, timeLimit, memoryLimit int) (*JudgeResult, error) {
-	result := &JudgeResult{}
-	
+// simulation 一次仿真的运行结果
+type simulation struct {
+	output     []byte
+	runTime    int // 毫秒
+	timedOut   bool
+	exceeded   string // 超出的输出限制，见 limitMessage
+	finishTime int64  // $finish 时的仿真时间（纳秒），finished 为 false 时无效
+	finished   bool
+	err        error
+}
+
+// simulate 使用vvp运行 tempDir/simulation，超过时间限制或输出限制时终止
+func (j *Judge) simulate(ctx context.Context, tempDir string, timeLimit int, output sandbox.Limits) simulation {
+	executable := filepath.Join(tempDir, "simulation")
+
+	// 设置超时，超时后终止vvp进程
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(timeLimit)*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	run := j.sandbox.Run(timeoutCtx, tempDir, output, j.simulator.RuntimePath, executable)
+	metrics.ObserveSince(metrics.SimulationDuration, startTime)
+
+	sim := simulation{
+		output:   run.Output,
+		runTime:  int(time.Since(startTime).Milliseconds()),
+		timedOut: timeoutCtx.Err() == context.DeadlineExceeded,
+		exceeded: run.Exceeded,
+		err:      run.Err,
+	}
+	sim.finishTime, sim.finished = parseFinishTime(string(run.Output))
+	return sim
+}
+
+// runSingleTest 运行单个Verilog测试用例，simBudget 为仿真时间预算（纳秒），0 表示不限制
+// check 为输出的判定方式，I/O 序列测试用例总是按序列比较
+// 返回的结果不包含序号和描述
+func (j *Judge) runSingleTest(ctx context.Context, tempDir string, testCase protocol.TestCase, check outputCheck, designCode, language string, timeLimit, memoryLimit int, compileTimeout time.Duration, output sandbox.Limits, simBudget int) (CaseResult, error) {
+	var result CaseResult
+
+	if testCase.Trace != nil {
+		if err := testCase.Trace.Validate(); err != nil {
+			return result, fmt.Errorf("invalid I/O trace: %v", err)
+		}
+	}
+
 	// 为每个测试用例重新编译（因为testbench可能不同）
-	if err := j.compileVerilog(tempDir, designCode, testCase.Testbench); err != nil {
-		result.Status = "compile_error"
+	budgetMarker := newMarker()
+	if err := j.compileVerilog(ctx, tempDir, designCode, testbenchFor(testCase), language, compileTimeout, output, simBudget, budgetMarker); err != nil {
+		result.Status = compileFailureStatus(err)
 		result.ErrorMessage = err.Error()
 		return result, nil
 	}
-	
+
 	// 执行仿真
-	executable := filepath.Join(tempDir, "simulation")
 	vcdFile := filepath.Join(tempDir, "output.vcd")
-	
-	cmd := exec.Command("vvp", executable)
-	cmd.Dir = tempDir
-	
-	// 设置超时
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(timeLimit)*time.Millisecond)
-	defer cancel()
-	
-	startTime := time.Now()
-	output, err := cmd.CombinedOutput()
-	runTime := int(time.Since(startTime).Milliseconds())
-	
-	result.RunTime = runTime
+	sim := j.simulate(ctx, tempDir, timeLimit, output)
+
+	result.RunTime = sim.runTime
 	result.Memory = 1024 // 简化处理，实际应该获取真实内存使用
-	
+
+	if sim.exceeded != "" {
+		result.Status = protocol.StatusOutputLimitExceeded
+		result.ErrorMessage = "Simulation terminated: " + limitMessage(sim.exceeded, output)
+		return result, nil
+	}
+
 	// 检查超时
-	if timeoutCtx.Err() == context.DeadlineExceeded {
-		result.Status = "time_limit_exceeded"
+	if sim.timedOut {
+		result.Status = protocol.StatusTimeLimitExceeded
 		return result, nil
 	}
-	
-	if err != nil {
-		result.Status = "runtime_error"
-		result.ErrorMessage = fmt.Sprintf("Simulation failed: %s", string(output))
+
+	if sim.err != nil {
+		result.Status = protocol.StatusRuntimeError
+		result.ErrorMessage = fmt.Sprintf("Simulation failed: %s", string(sim.output))
+		return result, nil
+	}
+
+	// 记录仿真时间和时钟周期数，testbench 没有通过 $finish 结束时使用VCD的最后一个时间戳
+	clock := testCase.Clock
+	if testCase.Trace != nil {
+		clock = testCase.Trace.Clock
+	}
+	if clock == "" {
+		clock = protocol.DefaultClock
+	}
+	summary, vcdErr := summarizeVCD(vcdFile, clock)
+	result.SimTime = summary.endTime
+	if sim.finished {
+		result.SimTime = sim.finishTime
+	}
+	result.Cycles = summary.cycles
+
+	if simBudget > 0 && simBudgetExceeded(string(sim.output), budgetMarker) {
+		result.Status = protocol.StatusSimTimeExceeded
+		result.ErrorMessage = fmt.Sprintf("testbench did not finish within the simulation time budget of %d ns", simBudget)
+		return result, nil
+	}
+
+	if check.capture != "" {
+		if os.IsNotExist(vcdErr) && (check.capture == captureVCD || testCase.Trace != nil) {
+			result.Status = protocol.StatusRuntimeError
+			result.ErrorMessage = "VCD file not generated"
+			return result, nil
+		}
+		captured, err := captureOutput(vcdFile, testCase, check.capture, string(sim.output))
+		if err != nil {
+			return result, err
+		}
+		result.Status = protocol.StatusAccepted
+		result.Captured = captured
+		return result, nil
+	}
+
+	// 检查程序和标准输出比较都不要求 testbench 生成VCD
+	if check.special != nil && testCase.Trace == nil {
+		expected := testCase.ExpectedOutput
+		if expected == "" {
+			expected = testCase.ExpectedVCD
+		}
+		verdict, err := j.runSpecialJudge(ctx, tempDir, check.special, testbenchStdout(string(sim.output)), expected, output)
+		if err != nil {
+			return result, fmt.Errorf("special judge: %v", err)
+		}
+		result.Status = verdict.Status
+		result.ErrorMessage = verdict.Message
+		if verdict.Status == protocol.StatusWrongAnswer {
+			result.Score = verdict.Score
+			if result.ErrorMessage == "" {
+				result.ErrorMessage = "rejected by the special judge"
+			}
+		}
+		return result, nil
+	}
+	if protocol.IsStdoutChecker(check.checker) && testCase.Trace == nil {
+		if ok, message := checkStdout(check.checker, testbenchStdout(string(sim.output)), testCase.ExpectedOutput); ok {
+			result.Status = protocol.StatusAccepted
+		} else {
+			result.Status = protocol.StatusWrongAnswer
+			result.ErrorMessage = "stdout mismatch (" + check.checker + "): " + message
+		}
 		return result, nil
 	}
 
 	// 检查VCD文件是否生成
-	if _, err := os.Stat(vcdFile); os.IsNotExist(err) {
-		result.Status = "runtime_error"
+	if os.IsNotExist(vcdErr) {
+		result.Status = protocol.StatusRuntimeError
 		result.ErrorMessage = "VCD file not generated"
 		return result, nil
 	}
 
-	// 比较VCD输出
+	// I/O 序列在每个周期的采样时刻比较输出，期望输出为完整VCD时逐信号比较波形，否则按匹配模式检查
+	if testCase.Trace != nil {
+		cycle, message, err := compareTrace(vcdFile, testCase.Trace)
+		if err != nil {
+			return result, err
+		}
+		if cycle == 0 {
+			result.Status = protocol.StatusAccepted
+		} else {
+			result.Status = protocol.StatusWrongAnswer
+			result.ErrorMessage = message
+			result.Row = cycle
+		}
+		return result, nil
+	}
+	if isVCD(testCase.ExpectedVCD) {
+		diff, err := compareWaveform(vcdFile, testCase.ExpectedVCD, testCase.Sample)
+		if err != nil {
+			return result, err
+		}
+		if diff == nil {
+			result.Status = protocol.StatusAccepted
+		} else {
+			result.Status = protocol.StatusWrongAnswer
+			result.ErrorMessage = mismatchMessage(diff)
+			result.Mismatch = diff
+		}
+		return result, nil
+	}
 	if j.compareVCD(vcdFile, testCase.ExpectedVCD) {
-		result.Status = "accepted"
+		result.Status = protocol.StatusAccepted
 	} else {
-		result.Status = "wrong_answer"
+		result.Status = protocol.StatusWrongAnswer
 		result.ErrorMessage = "VCD output does not match expected results"
 	}
-	
+
 	return result, nil
 }
 
@@ -243,7 +677,7 @@ func (j *Judge) matchVCDPattern(vcdContent, pattern string) bool {
 	if strings.HasPrefix(pattern, "{") {
 		return j.checkSignalValues(vcdContent, pattern)
 	}
-	
+
 	// 否则作为正则表达式处理
 	matched, err := regexp.MatchString(pattern, vcdContent)
 	return err == nil && matched
@@ -253,7 +687,7 @@ func (j *Judge) matchVCDPattern(vcdContent, pattern string) bool {
 func (j *Judge) checkSignalValues(vcdContent, signalPattern string) bool {
 	// 简化实现：检查关键信号在特定时间点的值
 	// 实际实现中可以解析JSON格式的期望值并与VCD中的信号值比较
-	
+
 	// 示例：检查是否包含特定的信号变化模式
 	lines := strings.Split(vcdContent, "\n")
 	for _, line := range lines {
@@ -267,7 +701,7 @@ func (j *Judge) checkSignalValues(vcdContent, signalPattern string) bool {
 			// 这里可以实现具体的信号值检查逻辑
 		}
 	}
-	
+
 	// 简化处理：如果VCD文件包含期望的模式字符串则认为匹配
 	return strings.Contains(vcdContent, signalPattern)
 }
@@ -276,13 +710,13 @@ func (j *Judge) checkSignalValues(vcdContent, signalPattern string) bool {
 func (j *Judge) parseVCDSignals(vcdContent string) map[string][]string {
 	signals := make(map[string][]string)
 	lines := strings.Split(vcdContent, "\n")
-	
+
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
 			continue
 		}
-		
+
 		// 解析信号值变化
 		if len(line) > 1 && (line[0] == '0' || line[0] == '1' || line[0] == 'x' || line[0] == 'z') {
 			value := string(line[0])
@@ -290,6 +724,6 @@ func (j *Judge) parseVCDSignals(vcdContent string) map[string][]string {
 			signals[signalID] = append(signals[signalID], value)
 		}
 	}
-	
+
 	return signals
-}
\ No newline at end of file
+}
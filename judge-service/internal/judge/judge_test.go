@@ -0,0 +1,26 @@
+package judge
+
+import (
+	"os/exec"
+	"testing"
+	"verilog-oj/judge-service/internal/config"
+)
+
+// newSimulatorJudge 返回使用本机 iverilog 和 vvp 的判题器，未安装时跳过测试
+// 测试环境通常无法创建命名空间，沙箱回退为直接运行
+func newSimulatorJudge(t *testing.T) *Judge {
+	t.Helper()
+	for _, tool := range []string{"iverilog", "vvp"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	cfg := config.Default()
+	cfg.WorkDir = t.TempDir()
+	cfg.Sandbox.Insecure = true
+	j, err := NewJudge(cfg)
+	if err != nil {
+		t.Fatalf("NewJudge() error = %v", err)
+	}
+	return j
+}
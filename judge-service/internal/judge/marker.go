@@ -0,0 +1,35 @@
+package judge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errForgedVerdict 仿真输出中出现了不带本次标记的判定行，只可能由提交的设计输出，判为 wrong_answer
+var errForgedVerdict = errors.New("simulation output contains a verdict line that was not printed by the judge testbench")
+
+// newMarker 返回一次判题使用的随机标记
+// 生成的testbench只输出带标记的判定行，标记不出现在提交的设计能读取的任何地方，设计无法伪造判定
+func newMarker() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// markedFields 拆分以 prefix 开头的判定行，返回标记之后的字段
+// 不是判定行时 ok 为 false；是判定行但不带 marker 时返回 errForgedVerdict
+func markedFields(line, prefix, marker string) (fields []string, ok bool, err error) {
+	fields = strings.Fields(line)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], prefix) {
+		return nil, false, nil
+	}
+	if len(fields) < 2 || fields[1] != marker {
+		return nil, false, errForgedVerdict
+	}
+	return append([]string{fields[0]}, fields[2:]...), true, nil
+}
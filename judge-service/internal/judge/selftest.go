@@ -0,0 +1,273 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/protocol"
+)
+
+// 自检
+const (
+	// MinCompilerMajor 支持的最低 iverilog 主版本，更早的版本不支持 -g2012
+	MinCompilerMajor = 10
+	// versionTimeout 查询工具版本的超时时间
+	versionTimeout = 10 * time.Second
+)
+
+// selfTestReference 自检使用的参考设计：4位加法器
+const selfTestReference = `module adder(input [3:0] a, input [3:0] b, output [4:0] sum);
+  assign sum = a + b;
+endmodule
+`
+
+// selfTestBadDesign 自检使用的错误设计，丢失了进位，必须判为 wrong_answer
+const selfTestBadDesign = `module adder(input [3:0] a, input [3:0] b, output [4:0] sum);
+  assign sum = {1'b0, a ^ b};
+endmodule
+`
+
+// selfTestGoChecker 自检编译的最小 Go 检查程序
+const selfTestGoChecker = `package main
+
+func main() {}
+`
+
+// formalDescription 形式化证明成功时等价性检查的描述，见 runEquivalence
+const formalDescription = "formal equivalence (yosys)"
+
+// versionRegex 工具版本输出中的版本号，例如 "Icarus Verilog version 12.0 (stable)"、"Yosys 0.33 (git sha1 ...)"
+var versionRegex = regexp.MustCompile(`(\d+(?:\.\d+)+)`)
+
+// SelfTestCheck 单项自检结果
+type SelfTestCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// SelfTestReport 自检报告，Capabilities 只包含通过自检的语言和功能
+type SelfTestReport struct {
+	Capabilities protocol.Capabilities `json:"capabilities"`
+	Checks       []SelfTestCheck       `json:"checks"`
+}
+
+// add 记录一项自检结果
+func (r *SelfTestReport) add(name string, err error) bool {
+	check := SelfTestCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	return err == nil
+}
+
+// SelfTest 检测仿真工具版本，并用内置的正确设计和错误设计验证每个语言的编译、仿真和判定
+// 工具版本不符合要求或没有任何语言通过时返回错误，此时节点不应拉取任务
+func (j *Judge) SelfTest(ctx context.Context) (*SelfTestReport, error) {
+	report := &SelfTestReport{
+		Capabilities: protocol.Capabilities{
+			ProtocolVersion: protocol.Version,
+			Node:            nodeName(),
+			Languages:       []string{},
+			Checkers:        []string{},
+			Sandbox:         j.sandbox.Isolated(),
+			Versions:        map[string]string{},
+			UpdatedAt:       time.Now(),
+		},
+	}
+
+	compilerVersion, err := toolVersion(ctx, j.simulator.CompilerPath, "-V")
+	if err == nil {
+		report.Capabilities.Versions["iverilog"] = compilerVersion
+		err = checkCompilerVersion(compilerVersion)
+	}
+	toolsOK := report.add("iverilog", err)
+
+	runtimeVersion, err := toolVersion(ctx, j.simulator.RuntimePath, "-V")
+	if err == nil {
+		report.Capabilities.Versions["vvp"] = runtimeVersion
+		if runtimeVersion != compilerVersion {
+			err = fmt.Errorf("vvp version %s does not match iverilog version %s", runtimeVersion, compilerVersion)
+		}
+	}
+	toolsOK = report.add("vvp", err) && toolsOK
+
+	languages := make([]string, 0, len(j.languages))
+	for language := range j.languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		if report.add("language "+language, j.selfTestLanguage(ctx, language)) {
+			report.Capabilities.Languages = append(report.Capabilities.Languages, language)
+		}
+	}
+
+	// Yosys 是可选的，失败时只是不发布 formal 能力
+	if j.simulator.FormalPath != "" && len(report.Capabilities.Languages) > 0 {
+		formalVersion, err := toolVersion(ctx, j.simulator.FormalPath, "-V")
+		if err == nil {
+			report.Capabilities.Versions["yosys"] = formalVersion
+			err = j.selfTestFormal(ctx, report.Capabilities.Languages[0])
+		}
+		report.Capabilities.Formal = report.add("yosys", err)
+	}
+
+	// Verilog 检查程序由 iverilog 编译，不依赖语言配置
+	if toolsOK {
+		report.Capabilities.Checkers = append(report.Capabilities.Checkers, protocol.SpecialJudgeVerilog)
+	}
+
+	// Go 同样是可选的，只用于编译出题人的检查程序；能编译检查程序时才发布 go 检查程序能力，
+	// 后端据此只把 Go 检查程序的任务推送给这些节点
+	if j.simulator.GoPath != "" {
+		goVersion, err := toolVersion(ctx, j.simulator.GoPath, "version")
+		if err == nil {
+			report.Capabilities.Versions["go"] = goVersion
+			err = j.selfTestChecker(ctx)
+		}
+		if report.add("go", err) {
+			report.Capabilities.Checkers = append(report.Capabilities.Checkers, protocol.SpecialJudgeGo)
+		}
+	}
+
+	j.mu.Lock()
+	j.versions = report.Capabilities.Versions
+	j.mu.Unlock()
+
+	switch {
+	case !toolsOK:
+		return report, fmt.Errorf("self-test failed: simulator version check failed")
+	case len(report.Capabilities.Languages) == 0:
+		return report, fmt.Errorf("self-test failed: no language passed")
+	}
+	return report, nil
+}
+
+// selfTestLanguage 正确设计必须通过，错误设计必须判为 wrong_answer
+func (j *Judge) selfTestLanguage(ctx context.Context, language string) error {
+	cases := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"known-good", selfTestReference, protocol.StatusAccepted},
+		{"known-bad", selfTestBadDesign, protocol.StatusWrongAnswer},
+	}
+	for _, c := range cases {
+		report, err := j.selfTestRun(ctx, language, c.name, c.code, false)
+		if err != nil {
+			return err
+		}
+		if result := report.Result; result.Status != c.want {
+			return fmt.Errorf("%s design: got %s, want %s: %s", c.name, result.Status, c.want, firstLine(result.ErrorMessage))
+		}
+	}
+	return nil
+}
+
+// selfTestFormal 正确设计必须由 Yosys 证明等价，而不是回退到仿真
+func (j *Judge) selfTestFormal(ctx context.Context, language string) error {
+	report, err := j.selfTestRun(ctx, language, "formal", selfTestReference, true)
+	if err != nil {
+		return err
+	}
+	if len(report.Cases) == 0 || report.Cases[0].Status != protocol.StatusAccepted || report.Cases[0].Description != formalDescription {
+		return fmt.Errorf("formal check did not prove the known-good design: %s", caseDescription(report))
+	}
+	return nil
+}
+
+// selfTestChecker 在沙箱中编译最小的 Go 检查程序，go_path 指向的工具链无法构建时失败
+// 直接调用 buildGoChecker，不使用检查程序缓存，每次自检都真正运行一次 go build
+func (j *Judge) selfTestChecker(ctx context.Context) error {
+	dir, err := j.createTempDir("selftest-checker")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	limits := j.currentLimits()
+	return j.buildGoChecker(ctx, dir, selfTestGoChecker, filepath.Join(dir, checkerProgram),
+		time.Duration(limits.CheckerCompileTimeout)*time.Millisecond, outputLimits(limits))
+}
+
+// selfTestRun 以参考设计的等价性检查判一次 code
+func (j *Judge) selfTestRun(ctx context.Context, language, name, code string, formal bool) (*Report, error) {
+	request := &protocol.JudgeRequest{
+		ProtocolVersion: protocol.Version,
+		SubmissionID:    fmt.Sprintf("selftest-%s-%s", language, name),
+		Code:            code,
+		Language:        language,
+		Equivalence: &protocol.Equivalence{
+			Reference: selfTestReference,
+			TopModule: "adder",
+			Formal:    formal,
+		},
+	}
+	report, err := j.Run(ctx, request, RunOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s design: %v", name, err)
+	}
+	return report, nil
+}
+
+// caseDescription 返回第一个测试用例的描述，形式化证明无法得出结论时其中包含原因
+func caseDescription(report *Report) string {
+	if len(report.Cases) == 0 {
+		return ""
+	}
+	return report.Cases[0].Description
+}
+
+// toolVersion 运行 path args 并从输出中读取版本号，部分工具打印版本后以非零状态退出，因此只看输出
+func toolVersion(ctx context.Context, path string, args ...string) (string, error) {
+	versionCtx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(versionCtx, path, args...).CombinedOutput()
+	if match := versionRegex.FindString(string(output)); match != "" {
+		return match, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return "", fmt.Errorf("%s: no version in output %q", path, firstLine(string(output)))
+}
+
+// checkCompilerVersion 检查 iverilog 主版本不低于 MinCompilerMajor
+func checkCompilerVersion(version string) error {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return fmt.Errorf("invalid iverilog version %q", version)
+	}
+	if major < MinCompilerMajor {
+		return fmt.Errorf("iverilog %s is too old, version %d or later is required", version, MinCompilerMajor)
+	}
+	return nil
+}
+
+// nodeName 返回节点标识：主机名加进程号
+func nodeName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// firstLine 返回消息的第一行
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
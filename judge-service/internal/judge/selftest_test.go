@@ -0,0 +1,142 @@
+package judge
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/protocol"
+)
+
+// fakeTool 写入一个只打印 output 的假工具，用于在没有安装仿真工具时模拟版本输出
+func fakeTool(t *testing.T, name, output string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho '"+output+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestSelfTestMissingTools 工具缺失时对应的检查失败，能力中不发布依赖该工具的语言和检查程序
+func TestSelfTestMissingTools(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name      string
+		simulator func() config.SimulatorConfig
+		passed    []string // 通过的检查
+		checkers  []string
+		wantErr   string
+	}{
+		{
+			name: "all tools missing",
+			simulator: func() config.SimulatorConfig {
+				return config.SimulatorConfig{CompilerPath: missing, RuntimePath: missing, FormalPath: missing, GoPath: missing}
+			},
+			checkers: []string{},
+			wantErr:  "simulator version check failed",
+		},
+		{
+			// 版本检查通过但无法编译设计：发布 Verilog 检查程序能力，不发布任何语言和 go 检查程序
+			name: "go missing and no language passes",
+			simulator: func() config.SimulatorConfig {
+				return config.SimulatorConfig{
+					CompilerPath: fakeTool(t, "iverilog", "Icarus Verilog version 12.0 (stable)"),
+					RuntimePath:  fakeTool(t, "vvp", "Icarus Verilog runtime version 12.0 (stable)"),
+					FormalPath:   missing,
+					GoPath:       missing,
+				}
+			},
+			passed:   []string{"iverilog", "vvp"},
+			checkers: []string{protocol.SpecialJudgeVerilog},
+			wantErr:  "no language passed",
+		},
+		{
+			name: "iverilog too old",
+			simulator: func() config.SimulatorConfig {
+				return config.SimulatorConfig{
+					CompilerPath: fakeTool(t, "iverilog", "Icarus Verilog version 0.9.7"),
+					RuntimePath:  fakeTool(t, "vvp", "Icarus Verilog runtime version 0.9.7"),
+					GoPath:       missing,
+				}
+			},
+			passed:   []string{"vvp"},
+			checkers: []string{},
+			wantErr:  "simulator version check failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.WorkDir = t.TempDir()
+			cfg.Sandbox.Insecure = true
+			cfg.Simulator = tt.simulator()
+			j, err := NewJudge(cfg)
+			if err != nil {
+				t.Fatalf("NewJudge() error = %v", err)
+			}
+
+			report, err := j.SelfTest(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("SelfTest() error = %v, want %q", err, tt.wantErr)
+			}
+
+			var passed []string
+			names := map[string]bool{}
+			for _, check := range report.Checks {
+				names[check.Name] = true
+				if check.Passed {
+					passed = append(passed, check.Name)
+				} else if check.Message == "" {
+					t.Errorf("failed check %s has no message", check.Name)
+				}
+			}
+			if strings.Join(passed, ",") != strings.Join(tt.passed, ",") {
+				t.Errorf("passed checks = %v, want %v", passed, tt.passed)
+			}
+			for _, name := range []string{"iverilog", "vvp", "language systemverilog", "language verilog", "go"} {
+				if !names[name] {
+					t.Errorf("report has no %s check", name)
+				}
+			}
+			// 没有语言通过时不检查 Yosys
+			if names["yosys"] {
+				t.Error("yosys checked although no language passed")
+			}
+
+			capabilities := report.Capabilities
+			if len(capabilities.Languages) != 0 || capabilities.Formal {
+				t.Errorf("capabilities languages = %v, formal = %v, want none", capabilities.Languages, capabilities.Formal)
+			}
+			if strings.Join(capabilities.Checkers, ",") != strings.Join(tt.checkers, ",") {
+				t.Errorf("capabilities checkers = %v, want %v", capabilities.Checkers, tt.checkers)
+			}
+			if _, ok := capabilities.Versions["go"]; ok {
+				t.Errorf("capabilities versions = %v, want no go version", capabilities.Versions)
+			}
+		})
+	}
+}
+
+func TestCheckCompilerVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		ok      bool
+	}{
+		{"12.0", true},
+		{"10.3", true},
+		{"0.9.7", false},
+		{"x.1", false},
+	} {
+		if err := checkCompilerVersion(tt.version); (err == nil) != tt.ok {
+			t.Errorf("checkCompilerVersion(%q) error = %v, want ok %v", tt.version, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package judge
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 仿真时间预算
+const (
+	simBudgetModule = "judge_sim_budget"
+	simBudgetFile   = "sim_budget.v"
+	// simBudgetMarker 仿真时间预算用完时 simBudgetModule 输出的标记，后跟本次判题的随机标记
+	simBudgetMarker = "JUDGE_SIM_TIME_EXCEEDED"
+)
+
+// 默认时间单位
+const (
+	defaultTimescaleFile = "timescale.v"
+	// defaultTimescale 编译时放在每个源文件之前，没有声明 `timescale 的文件使用纳秒，与仿真时间预算和 SimTime 的单位一致
+	// 否则时间单位取决于文件顺序：没有声明的文件沿用前一个文件的声明，第一个文件使用默认的 1s
+	defaultTimescale = "`timescale 1ns/1ps\n"
+)
+
+// simBudgetSource 生成仿真时间预算模块，它作为独立的顶层模块与 testbench 一起编译
+// 仿真时间超过 budget 纳秒时输出带随机标记 marker 的标记行并调用 $finish；在 budget 时刻正好结束的 testbench 不算超出
+func simBudgetSource(budget int, marker string) string {
+	return fmt.Sprintf("`timescale 1ns/1ps\nmodule %s;\n  initial begin\n    #%d.001;\n    $display(\"%s %s\");\n    $finish;\n  end\nendmodule\n",
+		simBudgetModule, budget, simBudgetMarker, marker)
+}
+
+// simBudgetExceeded 返回仿真输出中是否有带随机标记 marker 的预算用完标记行，提交的设计输出的不带标记的行不算
+func simBudgetExceeded(output, marker string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == simBudgetMarker && fields[1] == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// finishTimeRegex vvp 执行 $finish 时输出的仿真时间，例如 "tb.v:12: $finish called at 150000 (1ps)"
+var finishTimeRegex = regexp.MustCompile(`\$finish called at (\d+) \((\d+)(s|ms|us|ns|ps|fs)\)`)
+
+// picosecondsPerUnit 时间单位对应的皮秒数，fs 不足一皮秒，在 toNanoseconds 中单独换算
+var picosecondsPerUnit = map[string]int64{
+	"s":  1e12,
+	"ms": 1e9,
+	"us": 1e6,
+	"ns": 1e3,
+	"ps": 1,
+}
+
+// toNanoseconds 将 value 个 scale unit 换算为纳秒
+func toNanoseconds(value, scale int64, unit string) int64 {
+	if unit == "fs" {
+		return value * scale / 1e6
+	}
+	return value * scale * picosecondsPerUnit[unit] / 1e3
+}
+
+// parseFinishTime 从仿真输出中读取 $finish 时的仿真时间（纳秒）
+// vvp 在仿真结束时输出这一行，因此只接受唯一一行且位于输出末尾（之后只有 vvp 的提示）的 $finish 信息；
+// 提交的设计可以用 $display 输出相同格式的行，出现多行或之后还有其他输出时不采用
+// testbench 使用 $finish(0)、没有调用 $finish 或输出被伪造时返回 false
+func parseFinishTime(output string) (int64, bool) {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	last := len(lines) - 1
+	for last >= 0 && (strings.TrimSpace(lines[last]) == "" || hasVVPPrefix(strings.TrimSpace(lines[last]))) {
+		last--
+	}
+	if last < 0 {
+		return 0, false
+	}
+	match := finishTimeRegex.FindStringSubmatch(lines[last])
+	if match == nil {
+		return 0, false
+	}
+	for _, line := range lines[:last] {
+		if finishTimeRegex.MatchString(line) {
+			return 0, false
+		}
+	}
+
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	scale, _ := strconv.ParseInt(match[2], 10, 64)
+	return toNanoseconds(value, scale, match[3]), true
+}
+
+// vcdSummary VCD文件中的仿真时间和时钟周期数
+type vcdSummary struct {
+	endTime int64 // 最后一个时间戳（纳秒）
+	cycles  int   // 时钟信号的上升沿数
+}
+
+// timescaleRegex VCD头部 $timescale 的值，数字和单位之间可以有空白
+var timescaleRegex = regexp.MustCompile(`^(\d+)\s*(s|ms|us|ns|ps|fs)$`)
+
+// summarizeVCD 逐行读取VCD文件，统计最后的时间戳和名为 clock 的信号的上升沿数
+// 有多个同名信号时使用第一个声明的信号，通常是 testbench 顶层的时钟
+func summarizeVCD(path, clock string) (vcdSummary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return vcdSummary{}, err
+	}
+	defer file.Close()
+
+	var summary vcdSummary
+	var (
+		scale       int64 = 1
+		unit              = "s"
+		clockID     string
+		clockValue  byte = 'x'
+		lastTime    int64
+		inHeader    = true
+		timescale   []string // $timescale 与 $end 之间的内容，可能跨行
+		inTimescale bool
+	)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if inHeader {
+			fields := strings.Fields(line)
+			switch {
+			case inTimescale || fields[0] == "$timescale":
+				inTimescale = true
+				for _, field := range fields {
+					if field == "$end" {
+						inTimescale = false
+					} else if field != "$timescale" {
+						timescale = append(timescale, field)
+					}
+				}
+				if !inTimescale {
+					if match := timescaleRegex.FindStringSubmatch(strings.Join(timescale, "")); match != nil {
+						scale, _ = strconv.ParseInt(match[1], 10, 64)
+						unit = match[2]
+					}
+				}
+			case fields[0] == "$var" && len(fields) >= 5 && clockID == "":
+				// $var wire 1 ! clk $end
+				if fields[4] == clock && fields[2] == "1" {
+					clockID = fields[3]
+				}
+			case fields[0] == "$enddefinitions":
+				inHeader = false
+			}
+			continue
+		}
+
+		switch line[0] {
+		case '#':
+			if value, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				lastTime = value
+			}
+		case '0', '1', 'x', 'X', 'z', 'Z':
+			if clockID != "" && line[1:] == clockID {
+				if line[0] == '1' && clockValue != '1' {
+					summary.cycles++
+				}
+				clockValue = line[0]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return vcdSummary{}, err
+	}
+
+	summary.endTime = toNanoseconds(lastTime, scale, unit)
+	return summary, nil
+}
@@ -0,0 +1,211 @@
+package judge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+func TestSimBudgetSource(t *testing.T) {
+	source := simBudgetSource(1500, testMarker)
+	for _, want := range []string{
+		"`timescale 1ns/1ps\n",
+		"module " + simBudgetModule + ";",
+		"#1500.001;",
+		`$display("` + simBudgetMarker + ` ` + testMarker + `");`,
+		"$finish;",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("budget source does not contain %q:\n%s", want, source)
+		}
+	}
+}
+
+func TestSimBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{"marked line", "tick\n" + simBudgetMarker + " " + testMarker + "\nsim_budget.v:5: $finish called at 1500001 (1ps)\n", true},
+		{"marked line with CRLF", simBudgetMarker + " " + testMarker + "\r\n", true},
+		{"no marker", "tick\ntb.v:9: $finish called at 100000 (1ps)\n", false},
+		{"design prints the bare marker", simBudgetMarker + "\n", false},
+		{"design guesses a marker", simBudgetMarker + " deadbeefdeadbeef\n", false},
+		{"marker inside other output", "x " + simBudgetMarker + " " + testMarker + "\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := simBudgetExceeded(tt.output, testMarker); got != tt.want {
+				t.Errorf("simBudgetExceeded() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFinishTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   int64
+		ok     bool
+	}{
+		{"picoseconds", "VCD info: dumpfile output.vcd opened for output.\ntb.v:12: $finish called at 150000 (1ps)\n", 150, true},
+		{"nanoseconds", "tb.v:12: $finish called at 42 (1ns)", 42, true},
+		{"scaled unit", "tb.v:12: $finish called at 3 (10us)\r\n", 30000, true},
+		{"seconds", "tb.v:12: $finish called at 2 (1s)\n", 2e9, true},
+		{"femtoseconds", "tb.v:12: $finish called at 5000000 (1fs)\n", 5, true},
+		{"vvp messages after finish", "tb.v:12: $finish called at 20 (1ns)\nVCD warning: closing\n\n", 20, true},
+		{"no finish", "done\n", 0, false},
+		{"empty output", "", 0, false},
+		{"output after finish", "tb.v:12: $finish called at 20 (1ns)\nforged\n", 0, false},
+		{"design prints a fake finish line first", "design.v:1: $finish called at 1 (1ns)\ntb.v:12: $finish called at 20 (1ns)\n", 0, false},
+		{"design prints a fake finish line only", "ok\ntb.v:3: $finish called at 1 (1s) extra\nmore\n", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseFinishTime(tt.output)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseFinishTime() = (%d, %t), want (%d, %t)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSummarizeVCD(t *testing.T) {
+	tests := []struct {
+		name    string
+		vcd     string
+		clock   string
+		endTime int64
+		cycles  int
+	}{
+		{
+			name: "clock rising edges and split timescale",
+			vcd: `$timescale
+	1 ps
+$end
+$scope module tb $end
+$var reg 1 ! clk $end
+$scope module dut $end
+$var wire 1 " clk $end
+$upscope $end
+$upscope $end
+$enddefinitions $end
+#0
+x!
+0"
+#5000
+1!
+1"
+#10000
+0!
+#15000
+1!
+#20000
+0!
+#25000
+`,
+			clock:   "clk",
+			endTime: 25,
+			cycles:  2,
+		},
+		{
+			name: "first rise from x counts and vectors are ignored",
+			vcd: `$timescale 1ns $end
+$scope module tb $end
+$var wire 2 # clock [1:0] $end
+$var reg 1 $ clock $end
+$upscope $end
+$enddefinitions $end
+#0
+b11 #
+x$
+#3
+1$
+#4
+1$
+`,
+			clock:   "clock",
+			endTime: 4,
+			cycles:  1,
+		},
+		{
+			name: "no clock",
+			vcd: `$timescale 10ns $end
+$scope module tb $end
+$var reg 1 ! q $end
+$upscope $end
+$enddefinitions $end
+#7
+1!
+`,
+			clock:   "clk",
+			endTime: 70,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output.vcd")
+			if err := os.WriteFile(path, []byte(tt.vcd), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			summary, err := summarizeVCD(path, tt.clock)
+			if err != nil {
+				t.Fatalf("summarizeVCD() error = %v", err)
+			}
+			if summary.endTime != tt.endTime || summary.cycles != tt.cycles {
+				t.Errorf("summarizeVCD() = %+v, want endTime %d, cycles %d", summary, tt.endTime, tt.cycles)
+			}
+		})
+	}
+
+	if _, err := summarizeVCD(filepath.Join(t.TempDir(), "missing.vcd"), "clk"); !os.IsNotExist(err) {
+		t.Errorf("summarizeVCD() on a missing file error = %v, want not exist", err)
+	}
+}
+
+// TestSimBudgetTimescale 没有声明 `timescale 的 testbench 按纳秒计时，与仿真时间预算的单位一致
+func TestSimBudgetTimescale(t *testing.T) {
+	j := newSimulatorJudge(t)
+	design := "module buffer(input a, output y);\n  assign y = a;\nendmodule\n"
+	testbench := "module tb;\n  reg a;\n  wire y;\n  buffer u (.a(a), .y(y));\n  initial begin\n    a = 1;\n    #10;\n    $display(\"y=%b\", y);\n    $finish;\n  end\nendmodule\n"
+	tests := []struct {
+		name    string
+		design  string
+		budget  int
+		status  string
+		simTime int64
+	}{
+		{"within budget", design, 20, protocol.StatusAccepted, 10},
+		{"exceeds budget", design, 5, protocol.StatusSimTimeExceeded, 5},
+		// 设计声明的时间单位不影响 testbench
+		{"design declares another timescale", "`timescale 1ps/1ps\n" + design, 20, protocol.StatusAccepted, 10},
+		{"design prints a fake budget marker", "module buffer(input a, output y);\n  assign y = a;\n  initial $display(\"" + simBudgetMarker + "\");\nendmodule\n", 20, protocol.StatusWrongAnswer, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := j.Judge(context.Background(), &protocol.JudgeRequest{
+				SubmissionID: "1",
+				Code:         tt.design,
+				Language:     "verilog",
+				TimeLimit:    5000,
+				MemoryLimit:  128,
+				Checker:      protocol.CheckerExact,
+				TestCases:    []protocol.TestCase{{Testbench: testbench, ExpectedOutput: "y=1\n", SimTime: tt.budget}},
+			}, nil)
+			if err != nil {
+				t.Fatalf("Judge() error = %v", err)
+			}
+			if result.Status != tt.status {
+				t.Fatalf("status = %s, want %s (%s)", result.Status, tt.status, result.ErrorMessage)
+			}
+			if len(result.Cases) == 1 && result.Cases[0].SimTime != tt.simTime {
+				t.Errorf("sim time = %d ns, want %d", result.Cases[0].SimTime, tt.simTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package judge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+// 检查程序在工作目录中的文件名
+const (
+	checkerGoSource      = "checker.go"
+	checkerVerilogSource = "checker.v"
+	checkerProgram       = "checker" // Go 检查程序编译出的可执行文件，Verilog 检查程序编译出的 vvp 文件
+)
+
+// checkerCacheDir 工作目录下缓存编译好的 Go 检查程序的目录，文件名为 goCheckerKey
+// 同一题目的每次提交都使用同一个检查程序，缓存后只有第一次需要冷启动的 go build
+const checkerCacheDir = "checkers"
+
+// outputCheck 测试用例输出的判定方式
+type outputCheck struct {
+	checker string        // protocol.Checkers 之一，special 为 nil 时使用
+	special *specialJudge // 编译好的检查程序
+	capture string        // 生成期望输出时记录的内容（captureVCD 或 captureStdout），为空时判定输出
+}
+
+// specialJudge 编译好的检查程序
+type specialJudge struct {
+	language  string
+	program   string        // 编译产物的路径，运行前复制到测试用例的工作目录
+	timeLimit time.Duration // 每次运行的时间限制
+}
+
+// compileSpecialJudge 在 dir 中编译检查程序，检查程序的错误属于题目数据的问题，调用方应判为系统错误
+func (j *Judge) compileSpecialJudge(ctx context.Context, dir string, spj *protocol.SpecialJudge, timeout, timeLimit time.Duration, output sandbox.Limits) (*specialJudge, error) {
+	compiled := &specialJudge{language: spj.Language, program: filepath.Join(dir, checkerProgram), timeLimit: timeLimit}
+	switch spj.Language {
+	case protocol.SpecialJudgeGo:
+		if j.simulator.GoPath == "" {
+			return nil, fmt.Errorf("Go checkers are not supported on this judge node: simulator.go_path is empty")
+		}
+		// 编译好的检查程序按工具链版本和源码缓存在工作目录中，命中时直接使用
+		cached := ""
+		if version, err := j.goVersion(ctx); err == nil {
+			cached = filepath.Join(j.workDir, checkerCacheDir, goCheckerKey(j.simulator.GoPath, version, spj.Source))
+			if info, err := os.Stat(cached); err == nil && info.Mode().IsRegular() {
+				compiled.program = cached
+				return compiled, nil
+			}
+		}
+
+		if err := j.buildGoChecker(ctx, dir, spj.Source, compiled.program, timeout, output); err != nil {
+			return nil, err
+		}
+		// 缓存失败只影响之后的编译时间
+		if cached != "" {
+			if err := installFile(compiled.program, cached); err != nil {
+				log.Printf("Failed to cache Go checker: %v", err)
+			}
+		}
+	case protocol.SpecialJudgeVerilog:
+		source := filepath.Join(dir, checkerVerilogSource)
+		if err := os.WriteFile(source, []byte(spj.Source), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write checker: %v", err)
+		}
+		// compileFiles 总是输出到 dir/simulation
+		if err := j.compileFiles(ctx, dir, []string{source}, protocol.SpecialJudgeVerilog, timeout, output); err != nil {
+			return nil, fmt.Errorf("checker %v", err)
+		}
+		if err := os.Rename(filepath.Join(dir, "simulation"), compiled.program); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported checker language %q", spj.Language)
+	}
+	return compiled, nil
+}
+
+// buildGoChecker 在 dir 中用 go_path 编译 Go 检查程序，可执行文件写入 program
+func (j *Judge) buildGoChecker(ctx context.Context, dir, source, program string, timeout time.Duration, output sandbox.Limits) error {
+	sourcePath := filepath.Join(dir, checkerGoSource)
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		return fmt.Errorf("failed to write checker: %v", err)
+	}
+
+	compileCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	run := j.sandbox.Run(compileCtx, dir, output, j.simulator.GoPath, "build", "-o", program, sourcePath)
+	switch {
+	case run.Exceeded != "":
+		return fmt.Errorf("checker compilation failed: %s", limitMessage(run.Exceeded, output))
+	case compileCtx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("checker compilation timed out after %v", timeout)
+	case run.Err != nil:
+		return fmt.Errorf("checker compilation failed: %v: %s", run.Err, run.Output)
+	}
+	return nil
+}
+
+// goVersion 返回 go_path 指向的工具链版本，优先使用自检检测到的版本
+func (j *Judge) goVersion(ctx context.Context) (string, error) {
+	j.mu.RLock()
+	version := j.versions["go"]
+	j.mu.RUnlock()
+	if version != "" {
+		return version, nil
+	}
+	return toolVersion(ctx, j.simulator.GoPath, "version")
+}
+
+// goCheckerKey 返回编译好的 Go 检查程序的缓存键：工具链路径、版本和源码的 SHA-256
+// 升级工具链或修改检查程序后键随之变化，旧的可执行文件不再使用
+func goCheckerKey(goPath, version, source string) string {
+	sum := sha256.Sum256([]byte(goPath + "\x00" + version + "\x00" + source))
+	return hex.EncodeToString(sum[:])
+}
+
+// installFile 将 src 复制到 dst，先写入同目录的临时文件再重命名，并发的判题任务不会读到写了一半的文件
+func installFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// runSpecialJudge 在测试用例的工作目录中运行检查程序，stdout 为 testbench 的标准输出
+// 检查程序超时、崩溃或没有给出有效结论时返回错误
+func (j *Judge) runSpecialJudge(ctx context.Context, tempDir string, spj *specialJudge, stdout, expected string, output sandbox.Limits) (*protocol.Verdict, error) {
+	for _, file := range []struct{ name, content string }{
+		{protocol.SpecialJudgeStdoutFile, stdout},
+		{protocol.SpecialJudgeExpectedFile, expected},
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, file.name), []byte(file.content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", file.name, err)
+		}
+	}
+	program, err := os.ReadFile(spj.program)
+	if err != nil {
+		return nil, err
+	}
+	programPath := filepath.Join(tempDir, checkerProgram)
+	if err := os.WriteFile(programPath, program, 0755); err != nil {
+		return nil, fmt.Errorf("failed to copy checker: %v", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, spj.timeLimit)
+	defer cancel()
+	var run sandbox.Result
+	if spj.language == protocol.SpecialJudgeGo {
+		run = j.sandbox.Run(runCtx, tempDir, output, programPath,
+			protocol.SpecialJudgeStdoutFile, protocol.SpecialJudgeVCDFile, protocol.SpecialJudgeExpectedFile)
+	} else {
+		run = j.sandbox.Run(runCtx, tempDir, output, j.simulator.RuntimePath, programPath,
+			"+stdout="+protocol.SpecialJudgeStdoutFile, "+vcd="+protocol.SpecialJudgeVCDFile, "+expected="+protocol.SpecialJudgeExpectedFile)
+	}
+	switch {
+	case run.Exceeded != "":
+		return nil, fmt.Errorf("checker terminated: %s", limitMessage(run.Exceeded, output))
+	case runCtx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("checker exceeded its time limit of %v", spj.timeLimit)
+	case run.Err != nil:
+		return nil, fmt.Errorf("checker failed: %v: %s", run.Err, firstLine(string(run.Output)))
+	}
+
+	verdict, err := protocol.ParseVerdict(testbenchStdout(string(run.Output)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid checker output: %v", err)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,157 @@
+package judge
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+// TestSpecialJudgeVerdict runSpecialJudge 去掉 vvp 的提示行后按 protocol.ParseVerdict 解析检查程序的输出
+func TestSpecialJudgeVerdict(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *protocol.Verdict
+	}{
+		{
+			name:   "verilog checker with simulator noise",
+			output: "VCD info: dumpfile checker.vcd opened for output.\nVERDICT accepted\nchecker.v:20: $finish called at 0 (1s)\n",
+			want:   &protocol.Verdict{Status: protocol.StatusAccepted, Score: 100},
+		},
+		{
+			name:   "partial score and message",
+			output: "VERDICT wrong_answer\r\nSCORE 40\r\nMESSAGE sum wrong at 15 ns\r\n** VVP Stop(0) **\r\n",
+			want:   &protocol.Verdict{Status: protocol.StatusWrongAnswer, Score: 40, Message: "sum wrong at 15 ns"},
+		},
+		{
+			name:   "finish line is not part of the verdict",
+			output: "VERDICT wrong_answer\nchecker.v:20: $finish called at 0 (1s)\n",
+			want:   &protocol.Verdict{Status: protocol.StatusWrongAnswer},
+		},
+		{
+			name:   "missing verdict",
+			output: "VCD info: dumpfile checker.vcd opened for output.\nchecker.v:20: $finish called at 0 (1s)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protocol.ParseVerdict(testbenchStdout(tt.output))
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("ParseVerdict() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVerdict() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVerdict() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoCheckerKey(t *testing.T) {
+	base := goCheckerKey("/usr/local/go/bin/go", "1.22.3", "package main\n")
+	for name, key := range map[string]string{
+		"source":    goCheckerKey("/usr/local/go/bin/go", "1.22.3", "package main\n\nfunc main() {}\n"),
+		"version":   goCheckerKey("/usr/local/go/bin/go", "1.23.0", "package main\n"),
+		"toolchain": goCheckerKey("/usr/bin/go", "1.22.3", "package main\n"),
+	} {
+		if key == base {
+			t.Errorf("changing the %s should change the key", name)
+		}
+	}
+	if goCheckerKey("/usr/local/go/bin/go", "1.22.3", "package main\n") != base {
+		t.Error("goCheckerKey() is not deterministic")
+	}
+}
+
+// TestGoCheckerCache 缓存命中时直接使用工作目录中的可执行文件，不再运行 go build
+func TestGoCheckerCache(t *testing.T) {
+	source := "package main\n\nfunc main() {}\n"
+	j := &Judge{
+		workDir:   t.TempDir(),
+		simulator: config.SimulatorConfig{GoPath: "go-not-installed"},
+		versions:  map[string]string{"go": "1.22.3"},
+	}
+	spj := &protocol.SpecialJudge{Language: protocol.SpecialJudgeGo, Source: source}
+	cached := filepath.Join(j.workDir, checkerCacheDir, goCheckerKey(j.simulator.GoPath, "1.22.3", source))
+	if err := installFile(writeTempFile(t, "cached checker"), cached); err != nil {
+		t.Fatalf("installFile() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	compiled, err := j.compileSpecialJudge(context.Background(), dir, spj, time.Second, time.Second, sandbox.Limits{})
+	if err != nil {
+		t.Fatalf("compileSpecialJudge() error = %v", err)
+	}
+	if compiled.program != cached {
+		t.Errorf("program = %s, want the cached %s", compiled.program, cached)
+	}
+	if _, err := os.Stat(filepath.Join(dir, checkerGoSource)); !os.IsNotExist(err) {
+		t.Error("a cache hit should not write the checker source")
+	}
+	if info, err := os.Stat(cached); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("cached checker mode = %v, %v, want 0755", info.Mode(), err)
+	}
+}
+
+// TestGoCheckerBuildOnce 第一次编译后写入缓存，之后的任务直接使用
+func TestGoCheckerBuildOnce(t *testing.T) {
+	if testing.Short() {
+		t.Skip("go build takes several seconds with an empty build cache")
+	}
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not installed")
+	}
+	cfg := config.Default()
+	cfg.WorkDir = t.TempDir()
+	cfg.Sandbox.Insecure = true
+	cfg.Simulator.GoPath = goPath
+	j, err := NewJudge(cfg)
+	if err != nil {
+		t.Fatalf("NewJudge() error = %v", err)
+	}
+	spj := &protocol.SpecialJudge{Language: protocol.SpecialJudgeGo, Source: "package main\n\nfunc main() {}\n"}
+
+	first := t.TempDir()
+	compiled, err := j.compileSpecialJudge(context.Background(), first, spj, time.Minute, time.Second, sandbox.Limits{})
+	if err != nil {
+		t.Fatalf("compileSpecialJudge() error = %v", err)
+	}
+	if compiled.program != filepath.Join(first, checkerProgram) {
+		t.Errorf("first compilation program = %s, want the freshly built binary", compiled.program)
+	}
+	entries, err := os.ReadDir(filepath.Join(cfg.WorkDir, checkerCacheDir))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("checker cache has %d entries (%v), want 1", len(entries), err)
+	}
+
+	compiled, err = j.compileSpecialJudge(context.Background(), t.TempDir(), spj, time.Minute, time.Second, sandbox.Limits{})
+	if err != nil {
+		t.Fatalf("compileSpecialJudge() error = %v", err)
+	}
+	if want := filepath.Join(cfg.WorkDir, checkerCacheDir, entries[0].Name()); compiled.program != want {
+		t.Errorf("second compilation program = %s, want the cached %s", compiled.program, want)
+	}
+}
+
+// writeTempFile 将 content 写入临时文件并返回路径
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
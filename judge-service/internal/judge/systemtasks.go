@@ -0,0 +1,108 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"verilog-oj/judge-service/internal/sandbox"
+)
+
+// preprocessedDesign 预处理后的提交设计在工作目录中的文件名
+const preprocessedDesign = "design.pre.v"
+
+// forbiddenConstructRegex 提交的设计中禁止使用的系统任务和编译指令
+// 包括执行命令、文件读写、波形输出（可以伪造判题用的VCD）和 `include；testbench 与参考设计由出题人提供，不做检查
+var forbiddenConstructRegex = regexp.MustCompile(
+	`(\$(?:system|fopen[rwa]?|fclose|fflush|f(?:write|display|strobe|monitor)[bho]?|fgetc|fgets|fread|fscanf|fseek|ftell|rewind|ungetc|s?readmem[bh]|writemem[bh]|dump\w*|sdf_annotate)\b)` +
+		"|(`include\\b)")
+
+// checkDesign 在编译前拒绝使用了禁止的系统任务和编译指令的设计
+// 先检查原始代码，`include 在预处理前就被拒绝，不会读取任何文件；再检查 iverilog -E 预处理后的代码，
+// 宏展开和拼接出的系统任务只在预处理后出现
+// 这一检查只是尽早给出明确的错误信息，不是安全边界：文件系统、网络和进程的隔离由沙箱保证，
+// 以 sandbox.insecure 运行时沙箱不隔离，这一检查无法阻止所有绕过方式，不应在该模式下判不可信的代码
+func (j *Judge) checkDesign(ctx context.Context, tempDir, code, language string, timeout time.Duration, output sandbox.Limits) error {
+	if err := checkDesignCode(code); err != nil {
+		return err
+	}
+
+	designFile := filepath.Join(tempDir, "design.v")
+	if err := os.WriteFile(designFile, []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write design file: %v", err)
+	}
+	preprocessed := filepath.Join(tempDir, preprocessedDesign)
+	defer os.Remove(preprocessed)
+
+	preprocessCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	args := append([]string{}, j.languages[language].CompileFlags...)
+	args = append(args, "-E", "-o", preprocessed, designFile)
+	run := j.sandbox.Run(preprocessCtx, tempDir, output, j.simulator.CompilerPath, args...)
+	switch {
+	case run.Exceeded != "":
+		return &outputLimitError{message: "preprocessing failed: " + limitMessage(run.Exceeded, output)}
+	case preprocessCtx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("preprocessing timed out after %v", timeout)
+	case run.Err != nil && len(run.Output) == 0:
+		return fmt.Errorf("preprocessing failed: %v", run.Err)
+	case run.Err != nil:
+		return fmt.Errorf("compilation failed: %s", strings.ReplaceAll(string(run.Output), tempDir+string(filepath.Separator), ""))
+	}
+
+	expanded, err := os.ReadFile(preprocessed)
+	if err != nil {
+		return fmt.Errorf("failed to read preprocessed design: %v", err)
+	}
+	return checkDesignCode(string(expanded))
+}
+
+// checkDesignCode 检查提交的设计是否使用了禁止的系统任务，注释和字符串中的内容不检查
+func checkDesignCode(code string) error {
+	match := forbiddenConstructRegex.FindString(stripCommentsAndStrings(code))
+	if match == "" {
+		return nil
+	}
+	if strings.HasPrefix(match, "`") {
+		return fmt.Errorf("compiler directive %s is not allowed in submitted code", match)
+	}
+	return fmt.Errorf("system task %s is not allowed in submitted code", match)
+}
+
+// stripCommentsAndStrings 删除注释，并将字符串字面量替换为空字符串
+func stripCommentsAndStrings(code string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); i++ {
+		switch {
+		case strings.HasPrefix(code[i:], "//"):
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end - 1
+		case strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += end + 3
+			b.WriteByte(' ')
+		case code[i] == '"':
+			j := i + 1
+			for j < len(code) && code[j] != '"' && code[j] != '\n' {
+				if code[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			b.WriteString(`""`)
+			i = j
+		default:
+			b.WriteByte(code[i])
+		}
+	}
+	return b.String()
+}
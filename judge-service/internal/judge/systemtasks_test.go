@@ -0,0 +1,142 @@
+package judge
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/sandbox"
+)
+
+func TestCheckDesignCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		wantErr string
+	}{
+		{
+			name: "plain design",
+			code: "module m(input a, output b); assign b = a; initial $display(\"ok\"); endmodule",
+		},
+		{
+			name:    "system task",
+			code:    "module m; initial $system(\"ls\"); endmodule",
+			wantErr: "system task $system",
+		},
+		{
+			name:    "file write",
+			code:    "module m; integer f; initial f = $fopenw(\"x\"); endmodule",
+			wantErr: "system task $fopenw",
+		},
+		{
+			name:    "waveform dump",
+			code:    "module m; initial $dumpvars; endmodule",
+			wantErr: "system task $dumpvars",
+		},
+		{
+			name:    "include",
+			code:    "`include \"/etc/passwd\"\nmodule m; endmodule",
+			wantErr: "compiler directive `include",
+		},
+		{
+			name: "names in comments and strings",
+			code: "module m; // $system\n/* $fopen `include */ initial $display(\"$system `include\"); endmodule",
+		},
+		{
+			// iverilog -E 展开宏拼接后得到的代码
+			name:    "task assembled by a macro after preprocessing",
+			code:    "`line 1 \"design.v\" 0\nmodule m; initial $system (\"id\"); endmodule",
+			wantErr: "system task $system",
+		},
+		{
+			name: "similar user task name",
+			code: "module m; task systematic; endtask initial systematic; endmodule",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDesignCode(tt.code)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkDesignCode() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkDesignCode() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakePreprocessor 在工作目录 dir 中写入一个假 iverilog，把 expanded 作为预处理结果写入 -o 指定的文件
+// 放在工作目录中是因为沙箱内只能看到工作目录
+func fakePreprocessor(t *testing.T, dir, expanded string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	script := "#!/bin/sh\n" +
+		"while [ $# -gt 0 ]; do\n" +
+		"  if [ \"$1\" = -o ]; then cat > \"$2\" <<'EOF'\n" + expanded + "EOF\n" +
+		"    exit 0\n" +
+		"  fi\n" +
+		"  shift\n" +
+		"done\n" +
+		"echo 'missing -o' >&2; exit 1\n"
+	compiler := filepath.Join(dir, "fake-iverilog")
+	if err := os.WriteFile(compiler, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return compiler
+}
+
+// TestCheckDesignPreprocessed 原始代码通过检查时，仍检查预处理后的代码
+func TestCheckDesignPreprocessed(t *testing.T) {
+	// 原始代码中看不到系统任务名，只有宏拼接后才出现
+	code := "`define CALL(t) $``t\nmodule m; initial `CALL(system)(\"id\"); endmodule\n"
+	if err := checkDesignCode(code); err != nil {
+		t.Fatalf("checkDesignCode() on the raw code error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name     string
+		expanded string
+		wantErr  string
+	}{
+		{
+			name:     "expanded system task",
+			expanded: "`line 1 \"design.v\" 0\n\nmodule m; initial $system(\"id\"); endmodule\n",
+			wantErr:  "system task $system",
+		},
+		{
+			name:     "clean expansion",
+			expanded: "`line 1 \"design.v\" 0\n\nmodule m; initial $display(\"id\"); endmodule\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := config.Default()
+			cfg.WorkDir = t.TempDir()
+			cfg.Sandbox.Insecure = true
+			cfg.Simulator.CompilerPath = fakePreprocessor(t, tempDir, tt.expanded)
+			j, err := NewJudge(cfg)
+			if err != nil {
+				t.Fatalf("NewJudge() error = %v", err)
+			}
+
+			err = j.checkDesign(context.Background(), tempDir, code, "verilog", 10*time.Second, sandbox.Limits{})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkDesign() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkDesign() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(tempDir, preprocessedDesign)); !os.IsNotExist(err) {
+				t.Errorf("preprocessed design left in the work directory: %v", err)
+			}
+		})
+	}
+}
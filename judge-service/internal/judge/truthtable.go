@@ -0,0 +1,226 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"verilog-oj/judge-service/internal/sandbox"
+	"verilog-oj/protocol"
+)
+
+// truthTestbenchModule 真值表测试生成的testbench的模块名，使用题目中的模块名和端口名不能使用的保留前缀
+const truthTestbenchModule = protocol.ReservedNamePrefix + "truth_tb"
+
+// truthTableTestbench 生成逐行施加输入的testbench，每一行输出全部输出端口的取值（TRUTH_ROW <标记> <行号> <端口> <取值>），
+// 全部行结束后输出 TRUTH_END <标记>，输出行都带有本次判题的随机标记 marker
+// testbench 不做判定，取值由 compareTruthTable 与真值表比较：提交的设计即使改写了testbench中的变量也只能改变输出的取值
+func truthTableTestbench(table *protocol.TruthTable, marker string) string {
+	delay := table.Delay
+	if delay <= 0 {
+		delay = protocol.DefaultTruthTableDelay
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "`timescale 1ns/1ps\nmodule %s;\n", truthTestbenchModule)
+	var conns []string
+	for _, port := range table.Ports {
+		kind := "reg"
+		if port.Direction == protocol.PortOutput {
+			kind = "wire"
+		}
+		fmt.Fprintf(&b, "  %s %s%s;\n", kind, verilogRange(port.PortWidth()), port.Name)
+		conns = append(conns, fmt.Sprintf(".%s(%s)", port.Name, port.Name))
+	}
+	fmt.Fprintf(&b, "\n  %s %s (%s);\n\n", table.TopModule, dutInstance, strings.Join(conns, ", "))
+
+	b.WriteString("  initial begin\n")
+	for i, row := range table.Rows {
+		fmt.Fprintf(&b, "    // row %d\n", i+1)
+		for _, port := range table.Ports {
+			if port.Direction != protocol.PortInput {
+				continue
+			}
+			// 取值已由 Validate 校验
+			bits, _ := protocol.TruthTableBits(row[port.Name], port.PortWidth(), false)
+			fmt.Fprintf(&b, "    %s = %d'b%s;\n", port.Name, len(bits), bits)
+		}
+		fmt.Fprintf(&b, "    #%d;\n", delay)
+		for _, port := range table.Ports {
+			if port.Direction == protocol.PortOutput {
+				fmt.Fprintf(&b, "    $display(\"TRUTH_ROW %s %d %s %%b\", %s);\n", marker, i+1, port.Name, port.Name)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "    $display(\"TRUTH_END %s\");\n    $finish;\n  end\nendmodule\n", marker)
+	return b.String()
+}
+
+// parseTruthTableOutput 解析仿真输出，返回每一行输出端口的二进制取值
+// 只接受带有标记 marker 的输出行，出现不带标记的 TRUTH_ 行时返回 errForgedVerdict
+func parseTruthTableOutput(output, marker string, rows int) ([]map[string]string, error) {
+	values := make([]map[string]string, rows)
+	for _, line := range strings.Split(output, "\n") {
+		fields, ok, err := markedFields(line, "TRUTH_", marker)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		switch {
+		case fields[0] == "TRUTH_END" && len(fields) == 1:
+			for i, row := range values {
+				if row == nil {
+					return nil, fmt.Errorf("truth table row %d produced no outputs", i+1)
+				}
+			}
+			return values, nil
+		case fields[0] == "TRUTH_ROW" && len(fields) == 4:
+			row, err := strconv.Atoi(fields[1])
+			if err != nil || row < 1 || row > rows {
+				return nil, fmt.Errorf("invalid truth table output %q", line)
+			}
+			if values[row-1] == nil {
+				values[row-1] = map[string]string{}
+			}
+			values[row-1][fields[2]] = strings.ToLower(fields[3])
+		default:
+			return nil, fmt.Errorf("invalid truth table output %q", line)
+		}
+	}
+	return nil, fmt.Errorf("truth table testbench finished without reaching the last row")
+}
+
+// compareTruthTable 逐行比较输出端口的取值，返回第一个不符合的行号（全部符合时为 0）和该行不符合的输出
+// 行中省略的输出和不检查的位不比较，x、z 与 0、1 都不相等
+func compareTruthTable(table *protocol.TruthTable, values []map[string]string) (int, map[string]string) {
+	for i, row := range table.Rows {
+		mismatched := map[string]string{}
+		for _, port := range table.Ports {
+			value, ok := row[port.Name]
+			if port.Direction != protocol.PortOutput || !ok {
+				continue
+			}
+			expected, _ := protocol.TruthTableBits(value, port.PortWidth(), true)
+			actual := values[i][port.Name]
+			if !bitsMatch(expected, actual) {
+				mismatched[port.Name] = actual
+			}
+		}
+		if len(mismatched) > 0 {
+			return i + 1, mismatched
+		}
+	}
+	return 0, nil
+}
+
+// capturedRows 将每一行输出端口的取值转换为生成期望输出时使用的取值
+func capturedRows(values []map[string]string) []map[string]string {
+	rows := make([]map[string]string, len(values))
+	for i, row := range values {
+		rows[i] = make(map[string]string, len(row))
+		for name, bits := range row {
+			rows[i][name] = protocol.CapturedValue(bits)
+		}
+	}
+	return rows
+}
+
+// truthTableMessage 说明第一个不符合的行的输入、实际输出和期望输出，期望中不检查的位显示为 -
+func truthTableMessage(table *protocol.TruthTable, row int, actual map[string]string) string {
+	var inputs, outputs []string
+	for _, port := range table.Ports {
+		value := table.Rows[row-1][port.Name]
+		if port.Direction == protocol.PortInput {
+			bits, _ := protocol.TruthTableBits(value, port.PortWidth(), false)
+			inputs = append(inputs, fmt.Sprintf("%s=%s", port.Name, bits))
+			continue
+		}
+		got, ok := actual[port.Name]
+		if !ok {
+			continue
+		}
+		expected, _ := protocol.TruthTableBits(value, port.PortWidth(), true)
+		outputs = append(outputs, fmt.Sprintf("%s = %s, expected %s", port.Name, got, expected))
+	}
+	return fmt.Sprintf("row %d (inputs: %s): %s", row, strings.Join(inputs, " "), strings.Join(outputs, "; "))
+}
+
+// runTruthTable 生成真值表testbench，与提交的设计一起编译并仿真
+func (j *Judge) runTruthTable(ctx context.Context, tempDir string, req *protocol.JudgeRequest, timeLimit int, compileTimeout time.Duration, output sandbox.Limits) CaseResult {
+	table := req.TruthTable
+	caseResult := CaseResult{Description: fmt.Sprintf("truth table (%d rows)", len(table.Rows))}
+
+	if err := table.Validate(); err != nil {
+		caseResult.Status = protocol.StatusSystemError
+		caseResult.ErrorMessage = fmt.Sprintf("Invalid truth table: %v", err)
+		return caseResult
+	}
+
+	marker := newMarker()
+	var paths []string
+	for _, file := range []struct{ name, content string }{
+		{"design.v", req.Code},
+		{"truth_tb.v", truthTableTestbench(table, marker)},
+	} {
+		path := filepath.Join(tempDir, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			caseResult.Status = protocol.StatusSystemError
+			caseResult.ErrorMessage = fmt.Sprintf("failed to write %s: %v", file.name, err)
+			return caseResult
+		}
+		paths = append(paths, path)
+	}
+
+	if err := j.compileFiles(ctx, tempDir, paths, req.Language, compileTimeout, output, "-s", truthTestbenchModule); err != nil {
+		caseResult.Status = compileFailureStatus(err)
+		caseResult.ErrorMessage = err.Error()
+		return caseResult
+	}
+
+	sim := j.simulate(ctx, tempDir, timeLimit, output)
+	caseResult.RunTime = sim.runTime
+	caseResult.Memory = 1024 // 与测试用例一致，简化处理
+	if sim.exceeded != "" {
+		caseResult.Status = protocol.StatusOutputLimitExceeded
+		caseResult.ErrorMessage = "Simulation terminated: " + limitMessage(sim.exceeded, output)
+		return caseResult
+	}
+	if sim.timedOut {
+		caseResult.Status = protocol.StatusTimeLimitExceeded
+		return caseResult
+	}
+	if sim.finished {
+		caseResult.SimTime = sim.finishTime
+	}
+
+	values, err := parseTruthTableOutput(string(sim.output), marker, len(table.Rows))
+	switch {
+	case err == errForgedVerdict:
+		caseResult.Status = protocol.StatusWrongAnswer
+		caseResult.ErrorMessage = err.Error()
+		return caseResult
+	case err != nil:
+		caseResult.Status = protocol.StatusRuntimeError
+		caseResult.ErrorMessage = fmt.Sprintf("Simulation failed: %s", string(sim.output))
+		return caseResult
+	}
+
+	if req.CaptureOutputs {
+		caseResult.Status = protocol.StatusAccepted
+		caseResult.Captured = &protocol.CapturedOutput{Rows: capturedRows(values)}
+		return caseResult
+	}
+	if row, actual := compareTruthTable(table, values); row != 0 {
+		caseResult.Status = protocol.StatusWrongAnswer
+		caseResult.Row = row
+		caseResult.ErrorMessage = truthTableMessage(table, row, actual)
+		return caseResult
+	}
+	caseResult.Status = protocol.StatusAccepted
+	return caseResult
+}
@@ -0,0 +1,247 @@
+package judge
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+const testMarker = "0123456789abcdef"
+
+func testTruthTable() *protocol.TruthTable {
+	return &protocol.TruthTable{
+		TopModule: "half_adder",
+		Ports: []protocol.Port{
+			{Name: "a", Direction: protocol.PortInput},
+			{Name: "b", Direction: protocol.PortInput},
+			{Name: "s", Direction: protocol.PortOutput},
+			{Name: "c", Direction: protocol.PortOutput},
+		},
+		Rows: []map[string]string{
+			{"a": "0", "b": "0", "s": "0", "c": "0"},
+			{"a": "1", "b": "1", "s": "0", "c": "-"},
+		},
+	}
+}
+
+func TestTruthTableTestbench(t *testing.T) {
+	tb := truthTableTestbench(testTruthTable(), testMarker)
+	for _, want := range []string{
+		`$display("TRUTH_ROW ` + testMarker + ` 1 s %b", s);`,
+		`$display("TRUTH_ROW ` + testMarker + ` 2 c %b", c);`,
+		`$display("TRUTH_END ` + testMarker + `");`,
+		"module __oj_truth_tb;",
+		"half_adder __oj_dut (.a(a), .b(b), .s(s), .c(c));",
+	} {
+		if !strings.Contains(tb, want) {
+			t.Errorf("testbench does not contain %q:\n%s", want, tb)
+		}
+	}
+	// testbench 不做判定，没有设计可以改写的判定变量
+	for _, unwanted := range []string{"!==", "TRUTH_PASS", "TRUTH_FAIL", "reg " + protocol.ReservedNamePrefix} {
+		if strings.Contains(tb, unwanted) {
+			t.Errorf("testbench should not contain %q:\n%s", unwanted, tb)
+		}
+	}
+}
+
+func TestTruthTableTestbenchNames(t *testing.T) {
+	// 与旧版生成的名称相同的端口名不会与testbench中的名称冲突
+	table := &protocol.TruthTable{
+		TopModule: "truth_tb",
+		Ports: []protocol.Port{
+			{Name: "dut", Direction: protocol.PortInput},
+			{Name: "truth_fail", Direction: protocol.PortOutput},
+		},
+		Rows: []map[string]string{{"dut": "1", "truth_fail": "0"}},
+	}
+	if err := table.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tb := truthTableTestbench(table, testMarker)
+	for _, want := range []string{
+		"module __oj_truth_tb;",
+		"  reg dut;\n",
+		"  wire truth_fail;\n",
+		"truth_tb __oj_dut (.dut(dut), .truth_fail(truth_fail));",
+	} {
+		if strings.Count(tb, want) != 1 {
+			t.Errorf("testbench should contain %q once:\n%s", want, tb)
+		}
+	}
+}
+
+func TestParseTruthTableOutput(t *testing.T) {
+	m := testMarker
+	tests := []struct {
+		name    string
+		output  string
+		want    []map[string]string
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name: "all rows",
+			output: "VCD info: dumpfile\nTRUTH_ROW " + m + " 1 s 0\nTRUTH_ROW " + m + " 1 c 0\n" +
+				"TRUTH_ROW " + m + " 2 s X\nTRUTH_ROW " + m + " 2 c 1\nTRUTH_END " + m + "\n",
+			want: []map[string]string{{"s": "0", "c": "0"}, {"s": "x", "c": "1"}},
+		},
+		{
+			name:   "simulation stopped before the last row",
+			output: "TRUTH_ROW " + m + " 1 s 0\nTRUTH_END " + m + "\n",
+			anyErr: true,
+		},
+		{
+			name:   "row out of range",
+			output: "TRUTH_ROW " + m + " 3 s 0\n",
+			anyErr: true,
+		},
+		{
+			name:    "design prints the bare end marker",
+			output:  "TRUTH_END\n",
+			wantErr: errForgedVerdict,
+		},
+		{
+			name:    "design guesses a marker",
+			output:  "TRUTH_ROW deadbeefdeadbeef 1 s 0\n",
+			wantErr: errForgedVerdict,
+		},
+		{
+			name:    "design prints an old verdict line",
+			output:  "  TRUTH_PASS\nTRUTH_END " + m + "\n",
+			wantErr: errForgedVerdict,
+		},
+		{
+			name:   "no output",
+			output: "ERROR: design.v:3: syntax error\n",
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := parseTruthTableOutput(tt.output, testMarker, 2)
+			switch {
+			case tt.wantErr != nil:
+				if err != tt.wantErr {
+					t.Fatalf("parseTruthTableOutput() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("parseTruthTableOutput() expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("parseTruthTableOutput() error = %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("parseTruthTableOutput() = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareTruthTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []map[string]string
+		row    int
+		actual map[string]string
+	}{
+		{
+			name:   "all rows match",
+			values: []map[string]string{{"s": "0", "c": "0"}, {"s": "0", "c": "1"}},
+		},
+		{
+			name:   "don't-care output is not checked",
+			values: []map[string]string{{"s": "0", "c": "0"}, {"s": "0", "c": "x"}},
+		},
+		{
+			name:   "first mismatching row",
+			values: []map[string]string{{"s": "1", "c": "1"}, {"s": "1", "c": "1"}},
+			row:    1,
+			actual: map[string]string{"s": "1", "c": "1"},
+		},
+		{
+			name:   "unknown output never matches",
+			values: []map[string]string{{"s": "0", "c": "0"}, {"s": "z", "c": "0"}},
+			row:    2,
+			actual: map[string]string{"s": "z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, actual := compareTruthTable(testTruthTable(), tt.values)
+			if row != tt.row || !reflect.DeepEqual(actual, tt.actual) {
+				t.Errorf("compareTruthTable() = %d, %v, want %d, %v", row, actual, tt.row, tt.actual)
+			}
+		})
+	}
+
+	message := truthTableMessage(testTruthTable(), 1, map[string]string{"s": "1"})
+	if want := "row 1 (inputs: a=0 b=0): s = 1, expected 0"; message != want {
+		t.Errorf("truthTableMessage() = %q, want %q", message, want)
+	}
+}
+
+func TestCapturedRows(t *testing.T) {
+	got := capturedRows([]map[string]string{{"s": "0", "c": "0"}, {"s": "z", "c": "1"}})
+	want := []map[string]string{{"s": "0b0", "c": "0b0"}, {"s": "0bx", "c": "0b1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("capturedRows() = %v, want %v", got, want)
+	}
+}
+
+// TestTruthTableDesignCannotForgeVerdict 错误的设计改写testbench中的变量或输出判定行都不能通过
+func TestTruthTableDesignCannotForgeVerdict(t *testing.T) {
+	j := newSimulatorJudge(t)
+	wrong := "module half_adder(input a, input b, output s, output c);\n  assign s = 1'b1;\n  assign c = 1'b1;\n"
+	tests := []struct {
+		name   string
+		code   string
+		status string
+	}{
+		{
+			name:   "correct design",
+			code:   "module half_adder(input a, input b, output s, output c);\n  assign s = a ^ b;\n  assign c = a & b;\nendmodule\n",
+			status: protocol.StatusAccepted,
+		},
+		{
+			name:   "force the old verdict flag",
+			code:   wrong + "  initial force __oj_truth_tb.__oj_truth_fail = 1'b0;\nendmodule\n",
+			status: protocol.StatusCompileError,
+		},
+		{
+			name:   "print forged lines and finish early",
+			code:   wrong + "  initial begin\n    $display(\"TRUTH_END\");\n    $display(\"TRUTH_PASS\");\n    $finish;\n  end\nendmodule\n",
+			status: protocol.StatusWrongAnswer,
+		},
+		{
+			name:   "wrong outputs",
+			code:   wrong + "endmodule\n",
+			status: protocol.StatusWrongAnswer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := j.Judge(context.Background(), &protocol.JudgeRequest{
+				SubmissionID: "1",
+				Code:         tt.code,
+				Language:     "verilog",
+				TimeLimit:    5000,
+				MemoryLimit:  128,
+				TruthTable:   testTruthTable(),
+			}, nil)
+			if err != nil {
+				t.Fatalf("Judge() error = %v", err)
+			}
+			if result.Status != tt.status {
+				t.Errorf("status = %s, want %s (%s)", result.Status, tt.status, result.ErrorMessage)
+			}
+		})
+	}
+}
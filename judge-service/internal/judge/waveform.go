@@ -0,0 +1,295 @@
+package judge
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"verilog-oj/protocol"
+)
+
+// unknownValue 信号在第一次变化之前的取值
+const unknownValue = "x"
+
+// waveform VCD中信号的取值变化，时间单位为皮秒
+type waveform struct {
+	names   []string // 按声明顺序排列的层次化信号名
+	widths  map[string]int
+	changes map[string][]valueChange
+}
+
+// valueChange 信号在某一时刻变为 value
+type valueChange struct {
+	time  int64
+	value string
+}
+
+// isVCD 返回期望输出是否为完整的VCD文件，否则按匹配模式处理
+func isVCD(expected string) bool {
+	return strings.Contains(expected, "$enddefinitions")
+}
+
+// parseWaveform 解析VCD文件，只记录 testbench 顶层模块中声明的信号
+// only 不为 nil 时进一步只记录其中的信号
+func parseWaveform(r io.Reader, only map[string]bool) (*waveform, error) {
+	w := &waveform{widths: map[string]int{}, changes: map[string][]valueChange{}}
+	ids := map[string][]string{} // 标识符 -> 信号名，同一标识符可以对应多个信号
+	var (
+		scopes    []string
+		scale     int64 = 1
+		unit            = "s"
+		now       int64
+		inHeader  = true
+		vectorVal string // 向量和实数取值后的下一个词为标识符
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(bufio.ScanWords)
+	// directive 读取到 $end 为止的参数
+	directive := func() []string {
+		var args []string
+		for scanner.Scan() && scanner.Text() != "$end" {
+			args = append(args, scanner.Text())
+		}
+		return args
+	}
+
+	for scanner.Scan() {
+		word := scanner.Text()
+		if inHeader {
+			switch word {
+			case "$scope":
+				args := directive()
+				if len(args) >= 2 {
+					scopes = append(scopes, args[1])
+				}
+			case "$upscope":
+				directive()
+				if len(scopes) > 0 {
+					scopes = scopes[:len(scopes)-1]
+				}
+			case "$var":
+				// $var wire 4 " sum [3:0] $end
+				args := directive()
+				if len(args) < 4 || len(scopes) != 1 {
+					continue
+				}
+				name := scopes[0] + "." + args[3]
+				if only != nil && !only[name] {
+					continue
+				}
+				width, _ := strconv.Atoi(args[1])
+				if _, ok := w.widths[name]; !ok {
+					w.names = append(w.names, name)
+				}
+				w.widths[name] = width
+				ids[args[2]] = append(ids[args[2]], name)
+			case "$timescale":
+				if match := timescaleRegex.FindStringSubmatch(strings.Join(directive(), "")); match != nil {
+					scale, _ = strconv.ParseInt(match[1], 10, 64)
+					unit = match[2]
+				}
+			case "$enddefinitions":
+				directive()
+				inHeader = false
+			default:
+				if strings.HasPrefix(word, "$") {
+					directive()
+				}
+			}
+			continue
+		}
+
+		if vectorVal != "" {
+			w.record(ids[word], now, vectorVal)
+			vectorVal = ""
+			continue
+		}
+		switch word[0] {
+		case '$':
+			if word == "$comment" {
+				directive()
+			}
+		case '#':
+			value, err := strconv.ParseInt(word[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", word)
+			}
+			now = toPicoseconds(value, scale, unit)
+		case 'b', 'B', 'r', 'R':
+			vectorVal = strings.ToLower(word)
+		case '0', '1', 'x', 'X', 'z', 'Z':
+			w.record(ids[word[1:]], now, strings.ToLower(word[:1]))
+		}
+		// $dumpvars、$dumpall 等关键字和 $end 不影响取值
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inHeader {
+		return nil, fmt.Errorf("missing $enddefinitions")
+	}
+	return w, nil
+}
+
+// record 记录一组信号的取值变化
+func (w *waveform) record(names []string, time int64, value string) {
+	for _, name := range names {
+		w.changes[name] = append(w.changes[name], valueChange{time: time, value: normalizeValue(value, w.widths[name])})
+	}
+}
+
+// normalizeValue 将取值统一为标量 0/1/x/z 或按位宽补齐的 b 开头二进制，实数保持原样
+// VCD 允许省略向量的高位：最高位为 x 或 z 时用它补齐，否则补 0
+func normalizeValue(value string, width int) string {
+	if value[0] != 'b' {
+		return value
+	}
+	bits := value[1:]
+	if width <= 1 {
+		if bits == "" {
+			return unknownValue
+		}
+		return bits[len(bits)-1:]
+	}
+	if len(bits) < width {
+		pad := "0"
+		if bits != "" && (bits[0] == 'x' || bits[0] == 'z') {
+			pad = bits[:1]
+		}
+		bits = strings.Repeat(pad, width-len(bits)) + bits
+	}
+	return "b" + bits
+}
+
+// valueAt 返回信号在 time 时刻的取值，同一时刻有多次变化时取最后一次
+func valueAt(changes []valueChange, time int64) string {
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].time > time })
+	if i == 0 {
+		return unknownValue
+	}
+	return changes[i-1].value
+}
+
+// toPicoseconds 将 value 个 scale unit 换算为皮秒
+func toPicoseconds(value, scale int64, unit string) int64 {
+	if unit == "fs" {
+		return value * scale / 1e3
+	}
+	return value * scale * picosecondsPerUnit[unit]
+}
+
+// compareWaveform 将输出的VCD文件与期望的VCD逐信号比较，一致时返回 nil
+// 只比较期望VCD中 testbench 顶层模块声明的信号，设计内部信号的实现可以与参考设计不同
+// sample 为 false 时报告只保留第一个不一致的信号且不含前后取值
+func compareWaveform(actualVCDFile, expectedVCD string, sample bool) (*protocol.WaveformDiff, error) {
+	expected, err := parseWaveform(strings.NewReader(expectedVCD), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected VCD: %v", err)
+	}
+	if len(expected.names) == 0 {
+		return nil, fmt.Errorf("invalid expected VCD: no signals declared in the testbench top module")
+	}
+
+	file, err := os.Open(actualVCDFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	only := make(map[string]bool, len(expected.names))
+	for _, name := range expected.names {
+		only[name] = true
+	}
+	actual, err := parseWaveform(file, only)
+	if err != nil {
+		// 提交的设计无法影响VCD格式，解析失败按不一致处理
+		actual = &waveform{widths: map[string]int{}, changes: map[string][]valueChange{}}
+	}
+
+	mismatches := []protocol.SignalMismatch{}
+	for _, name := range expected.names {
+		if mismatch, ok := diffSignal(name, expected, actual); ok {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(mismatches, func(i, k int) bool { return mismatches[i].Time < mismatches[k].Time })
+
+	diff := &protocol.WaveformDiff{TotalSignals: len(mismatches)}
+	if !sample {
+		first := mismatches[0]
+		first.Window = nil
+		diff.Signals = []protocol.SignalMismatch{first}
+		diff.Truncated = true
+		return diff, nil
+	}
+	if len(mismatches) > protocol.MismatchSignals {
+		mismatches = mismatches[:protocol.MismatchSignals]
+	}
+	diff.Signals = mismatches
+	return diff, nil
+}
+
+// diffSignal 找出信号第一次不一致的时刻，以及前后各 protocol.MismatchWindow 个变化时刻的取值
+func diffSignal(name string, expected, actual *waveform) (protocol.SignalMismatch, bool) {
+	want := expected.changes[name]
+	if _, declared := actual.widths[name]; !declared {
+		return protocol.SignalMismatch{Signal: name, Expected: valueAt(want, 0)}, true
+	}
+	got := actual.changes[name]
+
+	times := make([]int64, 0, len(want)+len(got))
+	for _, change := range want {
+		times = append(times, change.time)
+	}
+	for _, change := range got {
+		times = append(times, change.time)
+	}
+	sort.Slice(times, func(i, k int) bool { return times[i] < times[k] })
+	unique := times[:0]
+	for _, time := range times {
+		if len(unique) == 0 || time != unique[len(unique)-1] {
+			unique = append(unique, time)
+		}
+	}
+	times = unique
+
+	for i, time := range times {
+		expectedValue, actualValue := valueAt(want, time), valueAt(got, time)
+		if expectedValue == actualValue {
+			continue
+		}
+		mismatch := protocol.SignalMismatch{
+			Signal:   name,
+			Time:     time / 1e3,
+			Expected: expectedValue,
+			Actual:   actualValue,
+		}
+		start, end := max(0, i-protocol.MismatchWindow), min(len(times), i+protocol.MismatchWindow+1)
+		for _, t := range times[start:end] {
+			mismatch.Window = append(mismatch.Window, protocol.WaveformSample{
+				Time:     t / 1e3,
+				Expected: valueAt(want, t),
+				Actual:   valueAt(got, t),
+			})
+		}
+		return mismatch, true
+	}
+	return protocol.SignalMismatch{}, false
+}
+
+// mismatchMessage 用第一个不一致的信号概括波形比较报告
+func mismatchMessage(diff *protocol.WaveformDiff) string {
+	first := diff.Signals[0]
+	if first.Actual == "" {
+		return fmt.Sprintf("waveform mismatch: signal %s is missing from the output VCD (%d signal(s) differ)", first.Signal, diff.TotalSignals)
+	}
+	return fmt.Sprintf("waveform mismatch: %s at %d ns: expected %s, got %s (%d signal(s) differ)",
+		first.Signal, first.Time, first.Expected, first.Actual, diff.TotalSignals)
+}
@@ -0,0 +1,230 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+// testVCD 顶层模块 tb 中声明 clk 和 sum，设计内部信号 dut.internal 不参与比较
+const testVCD = `$date today $end
+$timescale 1ns $end
+$scope module tb $end
+$var wire 1 ! clk $end
+$var wire 4 " sum [3:0] $end
+$scope module dut $end
+$var wire 1 # internal $end
+$upscope $end
+$upscope $end
+$enddefinitions $end
+#0
+$dumpvars
+0!
+b0 "
+0#
+$end
+#5
+1!
+b101 "
+1#
+#10
+0!
+bx "
+`
+
+func TestParseWaveform(t *testing.T) {
+	w, err := parseWaveform(strings.NewReader(testVCD), nil)
+	if err != nil {
+		t.Fatalf("parseWaveform() error = %v", err)
+	}
+	if want := []string{"tb.clk", "tb.sum"}; !reflect.DeepEqual(w.names, want) {
+		t.Errorf("names = %q, want %q", w.names, want)
+	}
+	if want := map[string]int{"tb.clk": 1, "tb.sum": 4}; !reflect.DeepEqual(w.widths, want) {
+		t.Errorf("widths = %v, want %v", w.widths, want)
+	}
+	wantSum := []valueChange{{0, "b0000"}, {5000, "b0101"}, {10000, "bxxxx"}}
+	if got := w.changes["tb.sum"]; !reflect.DeepEqual(got, wantSum) {
+		t.Errorf("tb.sum changes = %v, want %v", got, wantSum)
+	}
+	if _, ok := w.changes["tb.dut.internal"]; ok {
+		t.Error("signals below the testbench top module should not be recorded")
+	}
+
+	only, err := parseWaveform(strings.NewReader(testVCD), map[string]bool{"tb.sum": true})
+	if err != nil {
+		t.Fatalf("parseWaveform(only) error = %v", err)
+	}
+	if want := []string{"tb.sum"}; !reflect.DeepEqual(only.names, want) {
+		t.Errorf("names with only = %q, want %q", only.names, want)
+	}
+}
+
+func TestParseWaveformTimescale(t *testing.T) {
+	vcd := "$timescale 10 ps $end $scope module tb $end $var reg 1 ! q $end $upscope $end $enddefinitions $end #3 1!"
+	w, err := parseWaveform(strings.NewReader(vcd), nil)
+	if err != nil {
+		t.Fatalf("parseWaveform() error = %v", err)
+	}
+	if want := []valueChange{{30, "1"}}; !reflect.DeepEqual(w.changes["tb.q"], want) {
+		t.Errorf("tb.q changes = %v, want %v", w.changes["tb.q"], want)
+	}
+}
+
+func TestParseWaveformErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		vcd  string
+		want string
+	}{
+		{"missing enddefinitions", "$scope module tb $end $var wire 1 ! q $end", "missing $enddefinitions"},
+		{"invalid timestamp", "$enddefinitions $end #abc", `invalid timestamp "#abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWaveform(strings.NewReader(tt.vcd), nil)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parseWaveform() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		value string
+		width int
+		want  string
+	}{
+		{"1", 1, "1"},
+		{"z", 1, "z"},
+		{"b1", 1, "1"},
+		{"b", 1, "x"},
+		{"b0110", 1, "0"},
+		{"b101", 4, "b0101"},
+		{"bx1", 4, "bxxx1"},
+		{"bz", 3, "bzzz"},
+		{"b", 2, "b00"},
+		{"b1111", 4, "b1111"},
+		{"r1.5", 64, "r1.5"},
+	}
+	for _, tt := range tests {
+		if got := normalizeValue(tt.value, tt.width); got != tt.want {
+			t.Errorf("normalizeValue(%q, %d) = %q, want %q", tt.value, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestCompareWaveform(t *testing.T) {
+	tests := []struct {
+		name    string
+		actual  string
+		sample  bool
+		want    *protocol.WaveformDiff
+		message string
+	}{
+		{
+			name:   "identical",
+			actual: testVCD,
+			sample: true,
+		},
+		{
+			name:   "internal signals are ignored",
+			actual: strings.Replace(testVCD, "1#", "0#", 1),
+			sample: true,
+		},
+		{
+			name:   "mismatch with window",
+			actual: strings.Replace(testVCD, `b101 "`, `b100 "`, 1),
+			sample: true,
+			want: &protocol.WaveformDiff{
+				TotalSignals: 1,
+				Signals: []protocol.SignalMismatch{{
+					Signal:   "tb.sum",
+					Time:     5,
+					Expected: "b0101",
+					Actual:   "b0100",
+					Window: []protocol.WaveformSample{
+						{Time: 0, Expected: "b0000", Actual: "b0000"},
+						{Time: 5, Expected: "b0101", Actual: "b0100"},
+						{Time: 10, Expected: "bxxxx", Actual: "bxxxx"},
+					},
+				}},
+			},
+			message: "waveform mismatch: tb.sum at 5 ns: expected b0101, got b0100 (1 signal(s) differ)",
+		},
+		{
+			name:   "without samples only the first mismatch is kept",
+			actual: strings.Replace(strings.Replace(testVCD, `b101 "`, `b100 "`, 1), "0!\nbx", "1!\nbx", 1),
+			want: &protocol.WaveformDiff{
+				TotalSignals: 2,
+				Truncated:    true,
+				Signals: []protocol.SignalMismatch{{
+					Signal:   "tb.sum",
+					Time:     5,
+					Expected: "b0101",
+					Actual:   "b0100",
+				}},
+			},
+			message: "waveform mismatch: tb.sum at 5 ns: expected b0101, got b0100 (2 signal(s) differ)",
+		},
+		{
+			name:   "missing signal",
+			actual: strings.Replace(testVCD, `$var wire 4 " sum [3:0] $end`, "", 1),
+			sample: true,
+			want: &protocol.WaveformDiff{
+				TotalSignals: 1,
+				Signals:      []protocol.SignalMismatch{{Signal: "tb.sum", Expected: "b0000"}},
+			},
+			message: "waveform mismatch: signal tb.sum is missing from the output VCD (1 signal(s) differ)",
+		},
+		{
+			name:   "unparsable output counts as mismatch",
+			actual: "not a vcd",
+			want: &protocol.WaveformDiff{
+				TotalSignals: 2,
+				Truncated:    true,
+				Signals:      []protocol.SignalMismatch{{Signal: "tb.clk", Expected: "0"}},
+			},
+			message: "waveform mismatch: signal tb.clk is missing from the output VCD (2 signal(s) differ)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output.vcd")
+			if err := os.WriteFile(path, []byte(tt.actual), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			diff, err := compareWaveform(path, testVCD, tt.sample)
+			if err != nil {
+				t.Fatalf("compareWaveform() error = %v", err)
+			}
+			if !reflect.DeepEqual(diff, tt.want) {
+				t.Fatalf("compareWaveform() = %+v, want %+v", diff, tt.want)
+			}
+			if diff != nil {
+				if got := mismatchMessage(diff); got != tt.message {
+					t.Errorf("mismatchMessage() = %q, want %q", got, tt.message)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareWaveformInvalidExpected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.vcd")
+	if err := os.WriteFile(path, []byte(testVCD), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"no definitions",
+		"$scope module tb $end $scope module dut $end $var wire 1 ! q $end $upscope $end $upscope $end $enddefinitions $end",
+	} {
+		if _, err := compareWaveform(path, expected, true); err == nil {
+			t.Errorf("compareWaveform(%q) should reject the expected VCD", expected)
+		}
+	}
+}
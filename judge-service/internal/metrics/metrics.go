@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "judge"
+
+// 判题指标
+var (
+	// JobsTotal 按判题结果统计的任务数
+	JobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Number of judge jobs processed, partitioned by verdict.",
+	}, []string{"status"})
+
+	// CompileDuration iverilog 编译耗时
+	CompileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "compile_duration_seconds",
+		Help:      "Time spent compiling designs with iverilog.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	})
+
+	// SimulationDuration vvp 仿真耗时
+	SimulationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "simulation_duration_seconds",
+		Help:      "Time spent running a single test case with vvp.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	// JobsInFlight 正在判题的任务数
+	JobsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs_in_flight",
+		Help:      "Number of judge jobs currently being processed.",
+	})
+
+	// QueueRetries 拉取队列失败后的重试次数
+	QueueRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_retries_total",
+		Help:      "Number of times the worker retried after failing to pop from the queue.",
+	})
+
+	// CacheLookups 按是否命中统计的判题结果缓存查找次数
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of judge result cache lookups, partitioned by outcome (hit or miss).",
+	}, []string{"outcome"})
+
+	// LastJobTimestamp 最近一次完成判题的时间
+	LastJobTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_job_timestamp_seconds",
+		Help:      "Unix time of the last completed judge job.",
+	})
+)
+
+// ObserveJob 记录一次完成的判题
+func ObserveJob(status string) {
+	JobsTotal.WithLabelValues(status).Inc()
+	LastJobTimestamp.SetToCurrentTime()
+}
+
+// ObserveSince 将 start 至今的耗时记录到直方图
+func ObserveSince(h prometheus.Observer, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// RegisterQueueDepth 注册队列深度指标，每次抓取时通过 depth 查询
+func RegisterQueueDepth(depth func(ctx context.Context) (int64, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of judge requests waiting in the queue.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		n, err := depth(ctx)
+		if err != nil {
+			log.Printf("Failed to read queue depth: %v", err)
+			return -1
+		}
+		return float64(n)
+	})
+}
+
+// RegisterWorkDirUsage 注册判题临时目录磁盘占用指标
+func RegisterWorkDirUsage(workDir string) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workdir_bytes",
+		Help:      "Disk space used by the judge temporary work directory.",
+	}, func() float64 {
+		return float64(dirSize(workDir))
+	})
+}
+
+// dirSize 统计目录下所有文件的大小，判题过程中文件可能被删除，忽略遍历错误
+func dirSize(root string) int64 {
+	var total int64
+	_ = filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, infoErr := d.Info(); infoErr == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,401 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"verilog-oj/protocol"
+)
+
+// ErrQueueClosed 队列已关闭
+var ErrQueueClosed = errors.New("queue closed")
+
+// maxEventLogs 进程内队列最多保留进度事件的提交数，超出后丢弃最早的提交
+const maxEventLogs = 1024
+
+// maxCachedResults 进程内队列最多缓存的判题结果数，超出后丢弃最早缓存的结果
+const maxCachedResults = 1024
+
+// MemoryQueue 进程内判题队列，用于单机部署和不依赖Redis的测试
+// 任务和结果只保存在内存中，进程退出后丢失
+type MemoryQueue struct {
+	requests chan []byte
+
+	mu          sync.Mutex
+	closed      bool
+	closing     chan struct{}
+	subscribers map[*subscriber]struct{}
+
+	cancelled     map[string]struct{}      // 已取消但还未发布结果的提交
+	cancelWatches map[chan string]struct{} // 取消通知的订阅者
+
+	eventLogs   map[string]*eventLog
+	eventOrder  []string // 按创建顺序排列的提交ID，用于淘汰
+	lastEventID int64
+
+	cache      map[string]cachedResult
+	cacheOrder []string // 按缓存顺序排列的缓存键，用于淘汰
+}
+
+// cachedResult 缓存的判题结果
+type cachedResult struct {
+	data    []byte
+	expires time.Time
+}
+
+// eventLog 单个提交的进度事件
+type eventLog struct {
+	events   []*protocol.JudgeEvent
+	finished bool
+	updated  chan struct{} // 追加事件时关闭并替换，用于唤醒订阅者
+}
+
+// subscriber 结果订阅者
+type subscriber struct {
+	submissionID string
+	results      chan *protocol.JudgeResult
+}
+
+// NewMemoryQueue 创建进程内队列，capacity 为最多等待的任务数
+func NewMemoryQueue(capacity int) *MemoryQueue {
+	return &MemoryQueue{
+		requests:      make(chan []byte, capacity),
+		closing:       make(chan struct{}),
+		subscribers:   make(map[*subscriber]struct{}),
+		cancelled:     make(map[string]struct{}),
+		cancelWatches: make(map[chan string]struct{}),
+		eventLogs:     make(map[string]*eventLog),
+		cache:         make(map[string]cachedResult),
+	}
+}
+
+// Push 校验并推送判题请求，队列已满时阻塞直到 ctx 取消
+// 请求按协议编码后保存，与Redis实现的校验行为一致，也避免调用方之后修改请求
+func (mq *MemoryQueue) Push(ctx context.Context, request *protocol.JudgeRequest) error {
+	data, err := protocol.EncodeRequest(request)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case mq.requests <- data:
+		return mq.PublishEvent(ctx, protocol.NewEvent(request.SubmissionID, protocol.EventQueued))
+	case <-mq.closing:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pop 取出一个判题任务，没有任务时阻塞直到 ctx 取消
+func (mq *MemoryQueue) Pop(ctx context.Context) (*Delivery, error) {
+	select {
+	case data := <-mq.requests:
+		request, err := protocol.DecodeRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Delivery{Request: request, Lane: DefaultLane}, nil
+	case <-mq.closing:
+		return nil, ErrQueueClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Ack 进程内队列取出即移除，无需确认
+func (mq *MemoryQueue) Ack(ctx context.Context, delivery *Delivery) error {
+	return nil
+}
+
+// PublishResult 将结果发送给匹配的订阅者，订阅者处理不及时时丢弃该结果
+func (mq *MemoryQueue) PublishResult(ctx context.Context, result *protocol.JudgeResult) error {
+	if _, err := protocol.EncodeResult(result); err != nil {
+		return err
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	delete(mq.cancelled, result.SubmissionID)
+	for sub := range mq.subscribers {
+		if sub.submissionID != "" && sub.submissionID != result.SubmissionID {
+			continue
+		}
+		select {
+		case sub.results <- result:
+		default:
+			log.Printf("Dropped judge result for submission %s: subscriber is not keeping up", result.SubmissionID)
+		}
+	}
+	return nil
+}
+
+// PublishEvent 记录进度事件并唤醒该提交的事件订阅者
+func (mq *MemoryQueue) PublishEvent(ctx context.Context, event *protocol.JudgeEvent) error {
+	if _, err := protocol.EncodeEvent(event); err != nil {
+		return err
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	entry := mq.eventLogLocked(event.SubmissionID)
+	mq.lastEventID++
+	stored := *event
+	stored.ID = strconv.FormatInt(mq.lastEventID, 10)
+	entry.events = append(entry.events, &stored)
+	if event.Type == protocol.EventFinished {
+		entry.finished = true
+	}
+	close(entry.updated)
+	entry.updated = make(chan struct{})
+	return nil
+}
+
+// eventLogLocked 返回提交的事件记录，不存在时创建，调用方需持有 mq.mu
+func (mq *MemoryQueue) eventLogLocked(submissionID string) *eventLog {
+	if entry, ok := mq.eventLogs[submissionID]; ok {
+		return entry
+	}
+
+	if len(mq.eventOrder) >= maxEventLogs {
+		oldest := mq.eventOrder[0]
+		mq.eventOrder = mq.eventOrder[1:]
+		if evicted, ok := mq.eventLogs[oldest]; ok {
+			close(evicted.updated)
+			delete(mq.eventLogs, oldest)
+		}
+	}
+	entry := &eventLog{updated: make(chan struct{})}
+	mq.eventLogs[submissionID] = entry
+	mq.eventOrder = append(mq.eventOrder, submissionID)
+	return entry
+}
+
+// ReadEvents 返回提交在 afterID 之后的进度事件，afterID 为空时返回全部
+func (mq *MemoryQueue) ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	entry, ok := mq.eventLogs[submissionID]
+	if !ok {
+		return []*protocol.JudgeEvent{}, nil
+	}
+	return eventsAfter(entry.events, afterID), nil
+}
+
+// SubscribeEvents 订阅提交的进度事件：先补发 afterID 之后的事件，再推送新事件，收到 finished 事件后关闭通道
+func (mq *MemoryQueue) SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	mq.mu.Lock()
+	closed := mq.closed
+	mq.mu.Unlock()
+	if closed {
+		return nil, ErrQueueClosed
+	}
+
+	eventChan := make(chan *protocol.JudgeEvent, 16)
+	go func() {
+		defer close(eventChan)
+
+		lastID := afterID
+		for {
+			mq.mu.Lock()
+			entry := mq.eventLogLocked(submissionID)
+			pending := eventsAfter(entry.events, lastID)
+			finished := entry.finished
+			updated := entry.updated
+			mq.mu.Unlock()
+
+			for _, event := range pending {
+				select {
+				case eventChan <- event:
+				case <-ctx.Done():
+					return
+				}
+				lastID = event.ID
+			}
+			if finished {
+				return
+			}
+
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				return
+			case <-mq.closing:
+				return
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
+
+// eventsAfter 返回ID大于 afterID 的事件，进程内队列的事件ID是递增的整数
+func eventsAfter(events []*protocol.JudgeEvent, afterID string) []*protocol.JudgeEvent {
+	after, _ := strconv.ParseInt(afterID, 10, 64)
+	result := []*protocol.JudgeEvent{}
+	for _, event := range events {
+		if id, _ := strconv.ParseInt(event.ID, 10, 64); id > after {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// SubscribeResults 订阅判题结果，指定提交ID时只接收一次结果
+func (mq *MemoryQueue) SubscribeResults(ctx context.Context, submissionID string) (<-chan *protocol.JudgeResult, error) {
+	sub := &subscriber{
+		submissionID: submissionID,
+		results:      make(chan *protocol.JudgeResult, 256),
+	}
+
+	mq.mu.Lock()
+	if mq.closed {
+		mq.mu.Unlock()
+		return nil, ErrQueueClosed
+	}
+	mq.subscribers[sub] = struct{}{}
+	mq.mu.Unlock()
+
+	resultChan := make(chan *protocol.JudgeResult, 1)
+	go func() {
+		defer close(resultChan)
+		defer mq.unsubscribe(sub)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-mq.closing:
+				return
+			case result := <-sub.results:
+				select {
+				case resultChan <- result:
+				case <-ctx.Done():
+					return
+				}
+				if submissionID != "" {
+					return // 只接收一次结果
+				}
+			}
+		}
+	}()
+
+	return resultChan, nil
+}
+
+func (mq *MemoryQueue) unsubscribe(sub *subscriber) {
+	mq.mu.Lock()
+	delete(mq.subscribers, sub)
+	mq.mu.Unlock()
+}
+
+// Cancel 记录取消标记并通知订阅者
+// channel 中的任务无法移除，等待中的任务由判题节点取出后直接发布 cancelled 结果，因此总是返回 false
+func (mq *MemoryQueue) Cancel(ctx context.Context, submissionID string) (bool, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if mq.closed {
+		return false, ErrQueueClosed
+	}
+	mq.cancelled[submissionID] = struct{}{}
+	for watch := range mq.cancelWatches {
+		select {
+		case watch <- submissionID:
+		default:
+			log.Printf("Dropped cancellation for submission %s: subscriber is not keeping up", submissionID)
+		}
+	}
+	return false, nil
+}
+
+// Cancelled 返回提交是否已被取消
+func (mq *MemoryQueue) Cancelled(ctx context.Context, submissionID string) (bool, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	_, ok := mq.cancelled[submissionID]
+	return ok, nil
+}
+
+// SubscribeCancels 订阅取消通知，ctx 取消或队列关闭后关闭通道
+func (mq *MemoryQueue) SubscribeCancels(ctx context.Context) (<-chan string, error) {
+	watch := make(chan string, 16)
+	mq.mu.Lock()
+	if mq.closed {
+		mq.mu.Unlock()
+		return nil, ErrQueueClosed
+	}
+	mq.cancelWatches[watch] = struct{}{}
+	mq.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-mq.closing:
+		}
+		mq.mu.Lock()
+		delete(mq.cancelWatches, watch)
+		close(watch)
+		mq.mu.Unlock()
+	}()
+	return watch, nil
+}
+
+// CachedResult 读取缓存的判题结果，返回的结果是独立的副本
+func (mq *MemoryQueue) CachedResult(ctx context.Context, key string) (*protocol.JudgeResult, error) {
+	mq.mu.Lock()
+	entry, ok := mq.cache[key]
+	mq.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, nil
+	}
+	return protocol.DecodeResult(entry.data)
+}
+
+// CacheResult 保存判题结果，超过 maxCachedResults 时淘汰最早缓存的结果
+func (mq *MemoryQueue) CacheResult(ctx context.Context, key string, result *protocol.JudgeResult, ttl time.Duration) error {
+	data, err := protocol.EncodeResult(result)
+	if err != nil {
+		return err
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if _, ok := mq.cache[key]; !ok {
+		mq.cacheOrder = append(mq.cacheOrder, key)
+		if len(mq.cacheOrder) > maxCachedResults {
+			delete(mq.cache, mq.cacheOrder[0])
+			mq.cacheOrder = mq.cacheOrder[1:]
+		}
+	}
+	mq.cache[key] = cachedResult{data: data, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// RecordDuration 进程内队列不估算排队时间，不记录耗时
+func (mq *MemoryQueue) RecordDuration(ctx context.Context, duration time.Duration) error {
+	return nil
+}
+
+// Len 返回等待判题的任务数
+func (mq *MemoryQueue) Len(ctx context.Context) (int64, error) {
+	return int64(len(mq.requests)), nil
+}
+
+// Health 进程内队列始终可用
+func (mq *MemoryQueue) Health(ctx context.Context) error {
+	return nil
+}
+
+// Close 关闭队列，阻塞中的 Push、Pop 和订阅会返回
+func (mq *MemoryQueue) Close() error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if !mq.closed {
+		mq.closed = true
+		close(mq.closing)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"verilog-oj/protocol"
+)
+
+func testRequest(submissionID string) *protocol.JudgeRequest {
+	return &protocol.JudgeRequest{
+		SubmissionID: submissionID,
+		Code:         "module adder(input a, input b, output s); assign s = a ^ b; endmodule",
+		Language:     "verilog",
+		TimeLimit:    1000,
+		MemoryLimit:  128,
+		TestCases:    []protocol.TestCase{{Testbench: "module tb; endmodule", ExpectedVCD: "s"}},
+	}
+}
+
+func testResult(submissionID string) *protocol.JudgeResult {
+	return &protocol.JudgeResult{
+		SubmissionID: submissionID,
+		Status:       protocol.StatusAccepted,
+		Score:        100,
+		PassedTests:  1,
+		TotalTests:   1,
+		JudgedAt:     time.Now(),
+	}
+}
+
+func TestMemoryQueuePushPop(t *testing.T) {
+	ctx := context.Background()
+	mq := NewMemoryQueue(1)
+	defer mq.Close()
+
+	invalid := testRequest("1")
+	invalid.TestCases = nil
+	if err := mq.Push(ctx, invalid); err == nil {
+		t.Error("Push() should reject an invalid request")
+	}
+
+	request := testRequest("1")
+	if err := mq.Push(ctx, request); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	request.Code = "changed after push"
+	if n, _ := mq.Len(ctx); n != 1 {
+		t.Errorf("Len() = %d, want 1", n)
+	}
+
+	// 队列已满时 Push 阻塞到 ctx 取消
+	full, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := mq.Push(full, testRequest("2")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Push() on a full queue error = %v, want DeadlineExceeded", err)
+	}
+
+	delivery, err := mq.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if delivery.Request.SubmissionID != "1" || delivery.Lane != DefaultLane {
+		t.Errorf("Pop() = %s on lane %q, want 1 on lane %q", delivery.Request.SubmissionID, delivery.Lane, DefaultLane)
+	}
+	if delivery.Request.Code == "changed after push" {
+		t.Error("Pop() returned the caller's request instead of the encoded copy")
+	}
+
+	events, _ := mq.ReadEvents(ctx, "1", "")
+	if len(events) != 1 || events[0].Type != protocol.EventQueued {
+		t.Errorf("events after Push() = %v, want one queued event", events)
+	}
+}
+
+func TestMemoryQueueClose(t *testing.T) {
+	ctx := context.Background()
+	mq := NewMemoryQueue(0)
+	mq.Close()
+
+	if _, err := mq.Pop(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Pop() error = %v, want ErrQueueClosed", err)
+	}
+	if err := mq.Push(ctx, testRequest("1")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Push() error = %v, want ErrQueueClosed", err)
+	}
+	if _, err := mq.SubscribeResults(ctx, ""); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("SubscribeResults() error = %v, want ErrQueueClosed", err)
+	}
+	if _, err := mq.Cancel(ctx, "1"); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Cancel() error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestMemoryQueueResults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq := NewMemoryQueue(1)
+	defer mq.Close()
+
+	one, err := mq.SubscribeResults(ctx, "1")
+	if err != nil {
+		t.Fatalf("SubscribeResults(1) error = %v", err)
+	}
+	all, err := mq.SubscribeResults(ctx, "")
+	if err != nil {
+		t.Fatalf("SubscribeResults() error = %v", err)
+	}
+
+	for _, id := range []string{"2", "1", "1"} {
+		if err := mq.PublishResult(ctx, testResult(id)); err != nil {
+			t.Fatalf("PublishResult(%s) error = %v", id, err)
+		}
+	}
+	invalid := testResult("3")
+	invalid.Status = "passed"
+	if err := mq.PublishResult(ctx, invalid); err == nil {
+		t.Error("PublishResult() should reject an invalid result")
+	}
+
+	var got []string
+	for result := range one {
+		got = append(got, result.SubmissionID)
+	}
+	if len(got) != 1 || got[0] != "1" {
+		t.Errorf("single submission subscriber received %v, want [1]", got)
+	}
+
+	for _, want := range []string{"2", "1", "1"} {
+		select {
+		case result := <-all:
+			if result.SubmissionID != want {
+				t.Errorf("subscriber received %s, want %s", result.SubmissionID, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for result %s", want)
+		}
+	}
+}
+
+func TestMemoryQueueEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq := NewMemoryQueue(1)
+	defer mq.Close()
+
+	finished := protocol.NewEvent("1", protocol.EventFinished)
+	finished.Result = testResult("1")
+	for _, event := range []*protocol.JudgeEvent{
+		protocol.NewEvent("1", protocol.EventQueued),
+		protocol.NewEvent("2", protocol.EventQueued),
+		finished,
+	} {
+		if err := mq.PublishEvent(ctx, event); err != nil {
+			t.Fatalf("PublishEvent(%s) error = %v", event.Type, err)
+		}
+	}
+
+	tests := []struct {
+		submissionID string
+		afterID      string
+		want         []string
+	}{
+		{"1", "", []string{"1", "3"}},
+		{"1", "1", []string{"3"}},
+		{"1", "3", nil},
+		{"2", "", []string{"2"}},
+		{"unknown", "", nil},
+	}
+	for _, tt := range tests {
+		events, err := mq.ReadEvents(ctx, tt.submissionID, tt.afterID)
+		if err != nil {
+			t.Fatalf("ReadEvents(%s, %q) error = %v", tt.submissionID, tt.afterID, err)
+		}
+		var ids []string
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("ReadEvents(%s, %q) IDs = %v, want %v", tt.submissionID, tt.afterID, ids, tt.want)
+		}
+	}
+
+	// 订阅先补发已有事件，收到 finished 事件后关闭
+	events, err := mq.SubscribeEvents(ctx, "1", "1")
+	if err != nil {
+		t.Fatalf("SubscribeEvents() error = %v", err)
+	}
+	var types []string
+	for event := range events {
+		types = append(types, event.Type)
+	}
+	if len(types) != 1 || types[0] != protocol.EventFinished {
+		t.Errorf("SubscribeEvents() delivered %v, want [finished]", types)
+	}
+}
+
+func TestMemoryQueueCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq := NewMemoryQueue(1)
+	defer mq.Close()
+
+	watch, err := mq.SubscribeCancels(ctx)
+	if err != nil {
+		t.Fatalf("SubscribeCancels() error = %v", err)
+	}
+	if removed, err := mq.Cancel(ctx, "1"); err != nil || removed {
+		t.Errorf("Cancel() = %t, %v, want false, nil", removed, err)
+	}
+	select {
+	case id := <-watch:
+		if id != "1" {
+			t.Errorf("cancel notification for %s, want 1", id)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the cancel notification")
+	}
+
+	if cancelled, _ := mq.Cancelled(ctx, "1"); !cancelled {
+		t.Error("Cancelled() = false after Cancel()")
+	}
+	if err := mq.PublishResult(ctx, testResult("1")); err != nil {
+		t.Fatalf("PublishResult() error = %v", err)
+	}
+	if cancelled, _ := mq.Cancelled(ctx, "1"); cancelled {
+		t.Error("Cancelled() = true after the result was published")
+	}
+}
+
+func TestMemoryQueueCache(t *testing.T) {
+	ctx := context.Background()
+	mq := NewMemoryQueue(1)
+	defer mq.Close()
+
+	if err := mq.CacheResult(ctx, "fresh", testResult("1"), time.Minute); err != nil {
+		t.Fatalf("CacheResult() error = %v", err)
+	}
+	if err := mq.CacheResult(ctx, "expired", testResult("2"), -time.Second); err != nil {
+		t.Fatalf("CacheResult() error = %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want string // 命中时结果的提交ID，为空表示未命中
+	}{
+		{"fresh", "1"},
+		{"expired", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		result, err := mq.CachedResult(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("CachedResult(%s) error = %v", tt.key, err)
+		}
+		got := ""
+		if result != nil {
+			got = result.SubmissionID
+		}
+		if got != tt.want {
+			t.Errorf("CachedResult(%s) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	// 返回的是副本，修改不影响缓存
+	first, _ := mq.CachedResult(ctx, "fresh")
+	first.Status = protocol.StatusWrongAnswer
+	if second, _ := mq.CachedResult(ctx, "fresh"); second.Status != protocol.StatusAccepted {
+		t.Errorf("cached status = %q after modifying a returned copy, want accepted", second.Status)
+	}
+
+	for i := 0; i <= maxCachedResults; i++ {
+		if err := mq.CacheResult(ctx, "key"+strconv.Itoa(i), testResult("3"), time.Minute); err != nil {
+			t.Fatalf("CacheResult() error = %v", err)
+		}
+	}
+	if result, _ := mq.CachedResult(ctx, "fresh"); result != nil {
+		t.Error("the oldest cached result should be evicted past maxCachedResults")
+	}
+}
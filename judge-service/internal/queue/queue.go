@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"time"
+	"verilog-oj/protocol"
+)
+
+// DefaultLane 默认通道，直接使用队列名作为键
+const DefaultLane = "default"
+
+// Queue 判题任务队列
+// Pop 取出的任务在 Ack 之前视为未完成，实现可以据此在判题服务异常退出后找回任务
+type Queue interface {
+	// Push 推送判题请求
+	Push(ctx context.Context, request *protocol.JudgeRequest) error
+	// Pop 取出一个判题任务，队列暂时为空时返回 nil, nil
+	// 消息不符合协议时返回 *protocol.MessageError，调用方应拒绝该任务而不是重试
+	Pop(ctx context.Context) (*Delivery, error)
+	// Ack 确认任务已处理完成
+	Ack(ctx context.Context, delivery *Delivery) error
+	// PublishResult 发布判题结果
+	PublishResult(ctx context.Context, result *protocol.JudgeResult) error
+	// PublishEvent 发布判题进度事件，事件按提交保存一段时间，供客户端断线重连后补发
+	PublishEvent(ctx context.Context, event *protocol.JudgeEvent) error
+	// SubscribeResults 订阅判题结果，submissionID 为空时订阅所有提交的结果
+	SubscribeResults(ctx context.Context, submissionID string) (<-chan *protocol.JudgeResult, error)
+	// Cancel 取消判题任务：等待中的任务从队列移除并返回 true，同时记录带 cancelled 结果的 finished 事件；
+	// 已被取出的任务记录取消标记并通知判题节点，由判题节点终止判题并发布 cancelled 结果
+	Cancel(ctx context.Context, submissionID string) (bool, error)
+	// Cancelled 返回提交的判题任务是否已被取消
+	Cancelled(ctx context.Context, submissionID string) (bool, error)
+	// SubscribeCancels 订阅取消通知，通道中为被取消的提交ID，ctx 取消后关闭
+	SubscribeCancels(ctx context.Context) (<-chan string, error)
+	// CachedResult 返回缓存键对应的判题结果，未命中时返回 nil, nil
+	CachedResult(ctx context.Context, key string) (*protocol.JudgeResult, error)
+	// CacheResult 以缓存键保存判题结果，ttl 后过期
+	CacheResult(ctx context.Context, key string, result *protocol.JudgeResult, ttl time.Duration) error
+	// RecordDuration 记录一次判题任务的耗时，供后端估算排队时间
+	RecordDuration(ctx context.Context, duration time.Duration) error
+	// Len 返回等待判题的任务数
+	Len(ctx context.Context) (int64, error)
+	// Health 健康检查
+	Health(ctx context.Context) error
+	// Close 关闭队列
+	Close() error
+}
+
+// Delivery 从队列取出的判题任务
+type Delivery struct {
+	Request *protocol.JudgeRequest
+	Lane    string
+
+	payload string // 原始消息，Redis 实现确认时使用
+}
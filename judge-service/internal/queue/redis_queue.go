@@ -4,16 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
-	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/protocol"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	// eventStreamMaxLen 每个提交最多保留的进度事件数
+	eventStreamMaxLen = 1000
+	// eventStreamTTL 进度事件的保留时间
+	eventStreamTTL = 24 * time.Hour
+)
+
+// resultCachePrefix 判题结果缓存的键前缀，键为 judge_cache:<缓存键>
+const resultCachePrefix = "judge_cache:"
+
+const (
+	// popTimeout Pop 等待任务的最长时间
+	popTimeout = 10 * time.Second
+	// popInterval 所有通道为空时的轮询间隔
+	popInterval = 200 * time.Millisecond
+)
+
+// enqueueScript 推送任务并写入等待索引，见 protocol.EnqueueScript
+var enqueueScript = redis.NewScript(protocol.EnqueueScript)
+
 // RedisQueue Redis消息队列实现
+// 取出的任务会暂存在 <queue_name>:processing 列表中，Ack 后才移除
 type RedisQueue struct {
 	client    *redis.Client
 	queueName string
+
+	mu    sync.RWMutex
+	lanes map[string]int // 通道名 -> 权重
 }
 
 // NewRedisQueue 创建Redis队列
@@ -27,89 +54,394 @@ func NewRedisQueue(host string, port int, password string, db int, queueName str
 	return &RedisQueue{
 		client:    rdb,
 		queueName: queueName,
+		lanes:     map[string]int{DefaultLane: 1},
 	}
 }
 
-// Push 推送判题请求到队列
-func (rq *RedisQueue) Push(ctx context.Context, request *judge.JudgeRequest) error {
-	data, err := json.Marshal(request)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %v", err)
+// SetLanes 设置各通道的拉取权重，可在运行时调用
+func (rq *RedisQueue) SetLanes(lanes map[string]int) {
+	copied := make(map[string]int, len(lanes))
+	for lane, weight := range lanes {
+		copied[lane] = weight
+	}
+
+	rq.mu.Lock()
+	rq.lanes = copied
+	rq.mu.Unlock()
+}
+
+// laneKey 返回通道对应的Redis键
+func (rq *RedisQueue) laneKey(lane string) string {
+	if lane == DefaultLane {
+		return rq.queueName
+	}
+	return rq.queueName + ":" + lane
+}
+
+// processingKey 返回已取出但未确认的任务列表键
+func (rq *RedisQueue) processingKey() string {
+	return rq.queueName + ":processing"
+}
+
+// laneOrder 按权重随机排列通道，Pop 会优先从排在前面的通道取任务
+func (rq *RedisQueue) laneOrder() []string {
+	rq.mu.RLock()
+	weights := make(map[string]int, len(rq.lanes))
+	for lane, weight := range rq.lanes {
+		weights[lane] = weight
+	}
+	rq.mu.RUnlock()
+
+	names := make([]string, 0, len(weights))
+	total := 0
+	for lane, weight := range weights {
+		names = append(names, lane)
+		total += weight
+	}
+	sort.Strings(names)
+
+	order := make([]string, 0, len(names))
+	for len(names) > 0 {
+		pick := rand.Intn(total)
+		for i, lane := range names {
+			pick -= weights[lane]
+			if pick < 0 {
+				order = append(order, lane)
+				total -= weights[lane]
+				names = append(names[:i], names[i+1:]...)
+				break
+			}
+		}
 	}
+	return order
+}
 
-	return rq.client.LPush(ctx, rq.queueName, data).Err()
+// eventStreamKey 返回提交的进度事件流键，与后端约定为 judge_events:<submission_id>
+func eventStreamKey(submissionID string) string {
+	return "judge_events:" + submissionID
 }
 
-// Pop 从队列弹出判题请求
-func (rq *RedisQueue) Pop(ctx context.Context) (*judge.JudgeRequest, error) {
-	result, err := rq.client.BRPop(ctx, 10*time.Second, rq.queueName).Result()
+// Push 推送判题请求到队列，并记录 queued 事件
+func (rq *RedisQueue) Push(ctx context.Context, request *protocol.JudgeRequest) error {
+	data, err := protocol.EncodeRequest(request)
 	if err != nil {
-		if err == redis.Nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	event, err := protocol.EncodeEvent(protocol.NewEvent(request.SubmissionID, protocol.EventQueued))
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = rq.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		rq.addEvent(ctx, pipe, request.SubmissionID, event)
+		enqueueScript.Eval(ctx, pipe, []string{rq.queueName, protocol.QueueIndexKey(rq.queueName), protocol.QueueSeqKey}, request.SubmissionID, data)
+		return nil
+	})
+	return err
+}
+
+// addEvent 追加进度事件并刷新事件流的过期时间
+func (rq *RedisQueue) addEvent(ctx context.Context, pipe redis.Pipeliner, submissionID string, data []byte) {
+	key := eventStreamKey(submissionID)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: eventStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	})
+	pipe.Expire(ctx, key, eventStreamTTL)
+}
+
+// Pop 按通道权重取出判题任务并移入处理中列表，等待 popTimeout 后仍无任务时返回 nil, nil
+func (rq *RedisQueue) Pop(ctx context.Context) (*Delivery, error) {
+	deadline := time.Now().Add(popTimeout)
+	for {
+		for _, lane := range rq.laneOrder() {
+			payload, err := rq.client.RPopLPush(ctx, rq.laneKey(lane), rq.processingKey()).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to pop from queue: %v", err)
+			}
+
+			request, err := protocol.DecodeRequest([]byte(payload))
+			if err != nil {
+				// 不符合协议的消息不会被处理，直接从处理中列表移除
+				rq.client.LRem(ctx, rq.processingKey(), 1, payload)
+				return nil, err
+			}
+			return &Delivery{Request: request, Lane: lane, payload: payload}, nil
+		}
+
+		if time.Now().After(deadline) {
 			return nil, nil // 队列为空
 		}
-		return nil, fmt.Errorf("failed to pop from queue: %v", err)
+		select {
+		case <-time.After(popInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
+}
 
-	if len(result) < 2 {
-		return nil, fmt.Errorf("invalid result from queue")
-	}
+// Ack 从处理中列表移除已完成的任务
+func (rq *RedisQueue) Ack(ctx context.Context, delivery *Delivery) error {
+	return rq.client.LRem(ctx, rq.processingKey(), 1, delivery.payload).Err()
+}
 
-	var request judge.JudgeRequest
-	if err := json.Unmarshal([]byte(result[1]), &request); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+// PublishResult 将判题结果追加到 protocol.ResultStream，并在 judge_result_<submission_id> 频道通知订阅者
+// 后端从结果流读取，频道只用于 SubscribeResults，没有订阅者时通知丢失不影响结果写回
+func (rq *RedisQueue) PublishResult(ctx context.Context, result *protocol.JudgeResult) error {
+	data, err := protocol.EncodeResult(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
 	}
 
-	return &request, nil
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: protocol.ResultStream,
+			MaxLen: protocol.ResultStreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{"result": data},
+		})
+		pipe.Publish(ctx, fmt.Sprintf("judge_result_%s", result.SubmissionID), data)
+		return nil
+	})
+	return err
 }
 
-// PublishResult 发布判题结果
-func (rq *RedisQueue) PublishResult(ctx context.Context, result *judge.JudgeResult) error {
-	data, err := json.Marshal(result)
+// PublishEvent 将进度事件追加到提交的事件流
+func (rq *RedisQueue) PublishEvent(ctx context.Context, event *protocol.JudgeEvent) error {
+	data, err := protocol.EncodeEvent(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal result: %v", err)
+		return fmt.Errorf("failed to encode event: %w", err)
 	}
 
-	resultChannel := fmt.Sprintf("judge_result_%s", result.SubmissionID)
-	return rq.client.Publish(ctx, resultChannel, data).Err()
+	_, err = rq.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		rq.addEvent(ctx, pipe, event.SubmissionID, data)
+		return nil
+	})
+	return err
 }
 
-// SubscribeResults 订阅判题结果
-func (rq *RedisQueue) SubscribeResults(ctx context.Context, submissionID string) (<-chan *judge.JudgeResult, error) {
-	resultChannel := fmt.Sprintf("judge_result_%s", submissionID)
-	pubsub := rq.client.Subscribe(ctx, resultChannel)
+// SubscribeResults 订阅判题结果，submissionID 为空时订阅所有提交的结果
+func (rq *RedisQueue) SubscribeResults(ctx context.Context, submissionID string) (<-chan *protocol.JudgeResult, error) {
+	var pubsub *redis.PubSub
+	if submissionID == "" {
+		pubsub = rq.client.PSubscribe(ctx, "judge_result_*")
+	} else {
+		pubsub = rq.client.Subscribe(ctx, fmt.Sprintf("judge_result_%s", submissionID))
+	}
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe results: %v", err)
+	}
 
-	resultChan := make(chan *judge.JudgeResult, 1)
+	resultChan := make(chan *protocol.JudgeResult, 1)
 
 	go func() {
 		defer close(resultChan)
 		defer pubsub.Close()
 
 		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+
+			result, err := protocol.DecodeResult([]byte(msg.Payload))
+			if err != nil {
+				continue
+			}
+
 			select {
+			case resultChan <- result:
 			case <-ctx.Done():
 				return
-			default:
-				msg, err := pubsub.ReceiveMessage(ctx)
-				if err != nil {
-					continue
-				}
+			}
+			if submissionID != "" {
+				return // 只接收一次结果
+			}
+		}
+	}()
 
-				var result judge.JudgeResult
-				if err := json.Unmarshal([]byte(msg.Payload), &result); err != nil {
-					continue
-				}
+	return resultChan, nil
+}
+
+// Cancel 从各通道中移除提交的等待中任务，找不到时记录取消标记并通知正在判题的节点
+func (rq *RedisQueue) Cancel(ctx context.Context, submissionID string) (bool, error) {
+	for _, lane := range rq.laneOrder() {
+		removed, err := rq.removeQueued(ctx, rq.laneKey(lane), submissionID)
+		if err != nil || removed {
+			return removed, err
+		}
+	}
+
+	_, err := rq.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, protocol.CancelKey(submissionID), 1, protocol.CancelTTL)
+		pipe.Publish(ctx, protocol.CancelChannel, submissionID)
+		return nil
+	})
+	return false, err
+}
+
+// removeQueued 从列表中移除提交的任务，并记录带 cancelled 结果的 finished 事件
+func (rq *RedisQueue) removeQueued(ctx context.Context, key, submissionID string) (bool, error) {
+	payloads, err := rq.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return false, err
+	}
+	for _, payload := range payloads {
+		if queuedSubmissionID(payload) != submissionID {
+			continue
+		}
+		var removed *redis.IntCmd
+		_, err := rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			removed = pipe.LRem(ctx, key, 1, payload)
+			pipe.ZRem(ctx, protocol.QueueIndexKey(key), submissionID)
+			return nil
+		})
+		if err != nil || removed.Val() == 0 {
+			// 任务已被取出，由判题节点处理取消
+			return false, err
+		}
 
-				select {
-				case resultChan <- &result:
-				case <-ctx.Done():
+		event := protocol.NewEvent(submissionID, protocol.EventFinished)
+		event.Result = protocol.NewCancelledResult(submissionID)
+		return true, rq.PublishEvent(ctx, event)
+	}
+	return false, nil
+}
+
+// queuedSubmissionID 读取队列消息中的提交ID，消息无法解析时返回空字符串
+func queuedSubmissionID(payload string) string {
+	var envelope struct {
+		SubmissionID string `json:"submission_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		return ""
+	}
+	return envelope.SubmissionID
+}
+
+// Cancelled 返回提交是否有取消标记
+func (rq *RedisQueue) Cancelled(ctx context.Context, submissionID string) (bool, error) {
+	n, err := rq.client.Exists(ctx, protocol.CancelKey(submissionID)).Result()
+	return n > 0, err
+}
+
+// SubscribeCancels 订阅取消通知
+func (rq *RedisQueue) SubscribeCancels(ctx context.Context) (<-chan string, error) {
+	pubsub := rq.client.Subscribe(ctx, protocol.CancelChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe cancellations: %v", err)
+	}
+
+	cancels := make(chan string, 16)
+	go func() {
+		defer close(cancels)
+		defer pubsub.Close()
+
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
 					return
 				}
-				return // 只接收一次结果
+				continue
+			}
+			select {
+			case cancels <- msg.Payload:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
+	return cancels, nil
+}
 
-	return resultChan, nil
+// CachedResult 读取缓存的判题结果，缓存内容不符合协议时视为未命中
+func (rq *RedisQueue) CachedResult(ctx context.Context, key string) (*protocol.JudgeResult, error) {
+	data, err := rq.client.Get(ctx, resultCachePrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, err := protocol.DecodeResult(data)
+	if err != nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// CacheResult 保存判题结果，多个判题节点共享同一份缓存
+func (rq *RedisQueue) CacheResult(ctx context.Context, key string, result *protocol.JudgeResult, ttl time.Duration) error {
+	data, err := protocol.EncodeResult(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	return rq.client.Set(ctx, resultCachePrefix+key, data, ttl).Err()
+}
+
+// RecordDuration 追加判题耗时记录，只保留最近 protocol.JudgeDurationSamples 条
+func (rq *RedisQueue) RecordDuration(ctx context.Context, duration time.Duration) error {
+	_, err := rq.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, protocol.JudgeDurationsKey, duration.Milliseconds())
+		pipe.LTrim(ctx, protocol.JudgeDurationsKey, 0, protocol.JudgeDurationSamples-1)
+		return nil
+	})
+	return err
+}
+
+// Len 返回所有通道中等待判题的请求数
+func (rq *RedisQueue) Len(ctx context.Context) (int64, error) {
+	var total int64
+	for _, lane := range rq.laneOrder() {
+		n, err := rq.client.LLen(ctx, rq.laneKey(lane)).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Register 在节点注册表中发布或刷新判题节点的能力，应每隔 protocol.NodeHeartbeatInterval 调用一次
+// 同时清理超过 protocol.NodeTTL 未刷新的节点
+func (rq *RedisQueue) Register(ctx context.Context, capabilities *protocol.Capabilities) error {
+	registered := *capabilities
+	registered.UpdatedAt = time.Now()
+	data, err := protocol.EncodeCapabilities(&registered)
+	if err != nil {
+		return fmt.Errorf("failed to encode capabilities: %w", err)
+	}
+	if err := rq.client.HSet(ctx, protocol.NodeRegistryKey, registered.Node, data).Err(); err != nil {
+		return err
+	}
+
+	nodes, err := rq.client.HGetAll(ctx, protocol.NodeRegistryKey).Result()
+	if err != nil {
+		return err
+	}
+	for node, payload := range nodes {
+		other, err := protocol.DecodeCapabilities([]byte(payload))
+		if err != nil || !other.Alive(registered.UpdatedAt) {
+			rq.client.HDel(ctx, protocol.NodeRegistryKey, node)
+		}
+	}
+	return nil
+}
+
+// Unregister 从节点注册表中移除判题节点
+func (rq *RedisQueue) Unregister(ctx context.Context, node string) error {
+	return rq.client.HDel(ctx, protocol.NodeRegistryKey, node).Err()
 }
 
 // Close 关闭连接
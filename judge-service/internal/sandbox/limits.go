@@ -0,0 +1,128 @@
+package sandbox
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Limits 一次运行的输出限制，值为 0 的字段不限制
+type Limits struct {
+	Output   int64 // 字节，stdout 和 stderr 的合计大小
+	FileSize int64 // 字节，工作目录中单个文件的大小，主要限制 VCD 文件
+	DiskSize int64 // 字节，工作目录中所有文件的合计大小
+}
+
+// 超出的限制，见 Result.Exceeded
+const (
+	LimitOutput   = "output"
+	LimitFileSize = "file_size"
+	LimitDisk     = "disk"
+)
+
+// usagePollInterval 运行期间检查工作目录大小的间隔
+const usagePollInterval = 50 * time.Millisecond
+
+// tripwire 记录第一个超出的限制并终止运行
+type tripwire struct {
+	mu     sync.Mutex
+	reason string
+	stop   func()
+}
+
+// trip 记录超出的限制，只有第一次调用生效
+func (t *tripwire) trip(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reason != "" {
+		return
+	}
+	t.reason = reason
+	t.stop()
+}
+
+// exceeded 返回超出的限制，未超出时为空
+func (t *tripwire) exceeded() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reason
+}
+
+// cappedBuffer 保存 stdout 和 stderr 的前 limit 字节，超出时触发 tripwire
+// 超出后的写入仍返回成功，避免工具在被终止前因为管道关闭而报告其他错误
+type cappedBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	limit int64
+	wire  *tripwire
+}
+
+// Write 实现 io.Writer
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && int64(len(b.data)+len(p)) > b.limit {
+		b.data = append(b.data, p[:b.limit-int64(len(b.data))]...)
+		b.wire.trip(LimitOutput)
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// Bytes 返回已保存的输出
+func (b *cappedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
+
+// checkUsage 检查工作目录中的文件大小，返回超出的限制，未超出或无法读取时为空
+func checkUsage(dir string, limits Limits) string {
+	if limits.FileSize <= 0 && limits.DiskSize <= 0 {
+		return ""
+	}
+	var total int64
+	exceeded := ""
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if limits.FileSize > 0 && info.Size() > limits.FileSize {
+			exceeded = LimitFileSize
+			return filepath.SkipAll
+		}
+		total += info.Size()
+		if limits.DiskSize > 0 && total > limits.DiskSize {
+			exceeded = LimitDisk
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return exceeded
+}
+
+// watchUsage 定期检查工作目录，超出限制时触发 tripwire，done 关闭后返回
+func watchUsage(dir string, limits Limits, wire *tripwire, done <-chan struct{}) {
+	if limits.FileSize <= 0 && limits.DiskSize <= 0 {
+		return
+	}
+	ticker := time.NewTicker(usagePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if reason := checkUsage(dir, limits); reason != "" {
+				wire.trip(reason)
+				return
+			}
+		}
+	}
+}
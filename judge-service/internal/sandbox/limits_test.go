@@ -0,0 +1,131 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTripwire(t *testing.T) {
+	stops := 0
+	wire := &tripwire{stop: func() { stops++ }}
+	if got := wire.exceeded(); got != "" {
+		t.Errorf("exceeded() = %q before trip", got)
+	}
+	wire.trip(LimitDisk)
+	wire.trip(LimitOutput)
+	if got := wire.exceeded(); got != LimitDisk {
+		t.Errorf("exceeded() = %q, want the first limit %q", got, LimitDisk)
+	}
+	if stops != 1 {
+		t.Errorf("stop called %d times, want 1", stops)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int64
+		writes   []string
+		want     string
+		exceeded string
+	}{
+		{"unlimited", 0, []string{"hello ", "world"}, "hello world", ""},
+		{"exactly at the limit", 5, []string{"he", "llo"}, "hello", ""},
+		{"write crosses the limit", 5, []string{"hel", "lo world"}, "hello", LimitOutput},
+		{"writes after the limit are dropped", 3, []string{"abcdef", "ghi"}, "abc", LimitOutput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wire := &tripwire{stop: func() {}}
+			buffer := &cappedBuffer{limit: tt.limit, wire: wire}
+			for _, write := range tt.writes {
+				// 超出限制后仍报告写入成功
+				if n, err := buffer.Write([]byte(write)); n != len(write) || err != nil {
+					t.Errorf("Write(%q) = %d, %v, want %d, nil", write, n, err, len(write))
+				}
+			}
+			if got := string(buffer.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+			if got := wire.exceeded(); got != tt.exceeded {
+				t.Errorf("exceeded() = %q, want %q", got, tt.exceeded)
+			}
+		})
+	}
+}
+
+func TestCheckUsage(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"output.vcd": 600, "sub/a.txt": 300, "sub/b.txt": 200} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		dir    string
+		limits Limits
+		want   string
+	}{
+		{"no limits", dir, Limits{}, ""},
+		{"output limit only", dir, Limits{Output: 1}, ""},
+		{"within limits", dir, Limits{FileSize: 600, DiskSize: 1100}, ""},
+		{"file too large", dir, Limits{FileSize: 599}, LimitFileSize},
+		{"disk usage counts nested files", dir, Limits{DiskSize: 1099}, LimitDisk},
+		{"missing directory", filepath.Join(dir, "missing"), Limits{FileSize: 1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkUsage(tt.dir, tt.limits); got != tt.want {
+				t.Errorf("checkUsage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunLimits 超出限制的工具被终止，Result.Exceeded 给出超出的限制
+// 使用不隔离的沙箱，与测试环境能否创建命名空间无关
+func TestRunLimits(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	s := &Sandbox{}
+
+	tests := []struct {
+		name     string
+		script   string
+		limits   Limits
+		exceeded string
+	}{
+		{"within limits", "echo ok; echo data > out.txt", Limits{Output: 100, FileSize: 100, DiskSize: 100}, ""},
+		{"output", "while true; do echo 0123456789; done", Limits{Output: 1000}, LimitOutput},
+		{"file size", "head -c 4096 /dev/zero > output.vcd", Limits{FileSize: 1024}, LimitFileSize},
+		{"disk", "head -c 800 /dev/zero > a; head -c 800 /dev/zero > b", Limits{FileSize: 1024, DiskSize: 1024}, LimitDisk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.Run(context.Background(), t.TempDir(), tt.limits, "sh", "-c", tt.script)
+			if result.Exceeded != tt.exceeded {
+				t.Fatalf("Exceeded = %q, want %q (err %v)", result.Exceeded, tt.exceeded, result.Err)
+			}
+			if tt.exceeded == "" {
+				if result.Err != nil || strings.TrimSpace(string(result.Output)) != "ok" {
+					t.Errorf("Run() = %q, %v, want ok", result.Output, result.Err)
+				}
+				return
+			}
+			if int64(len(result.Output)) > tt.limits.Output && tt.limits.Output > 0 {
+				t.Errorf("output is %d bytes, want at most %d", len(result.Output), tt.limits.Output)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package sandbox 在隔离环境中运行 iverilog、vvp 和 yosys
+//
+// 每次运行都会重新执行当前程序作为沙箱的 init 进程（PID 1），它位于独立的 mount、PID、网络、IPC 和 UTS 命名空间中：
+// 根文件系统重新挂载为只读，工作目录和 /tmp 挂载为 tmpfs，工作目录的文件在运行前复制进去、结束后复制回来。
+// 工具以非特权用户运行，并设置 no_new_privs 和 seccomp 系统调用白名单。
+//
+// 输出限制在运行期间生效：stdout 和 stderr 由宿主机截断，单个文件的大小由 RLIMIT_FSIZE 限制，
+// 工作目录 tmpfs 的大小由磁盘限制决定，init 进程定期检查工作目录并在超出时终止工具。
+//
+// 重新执行依赖本包的 init 函数，导入本包的程序（判题服务、内嵌判题的后端）都可以作为沙箱的入口。
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+	"verilog-oj/judge-service/internal/config"
+)
+
+// 重新执行时使用的 argv[0]，用于区分沙箱的两个阶段
+const (
+	initStage = "judge-sandbox-init"
+	execStage = "judge-sandbox-exec"
+)
+
+// specEnv 传递运行参数的环境变量
+const specEnv = "JUDGE_SANDBOX_SPEC"
+
+// toolEnv 沙箱内工具的环境变量，HOME 在运行时设置为工作目录
+var toolEnv = []string{
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	"TMPDIR=/tmp",
+	"LANG=C",
+}
+
+func init() {
+	if len(os.Args) == 0 {
+		return
+	}
+	switch os.Args[0] {
+	case initStage:
+		os.Exit(runInit())
+	case execStage:
+		os.Exit(runExec())
+	}
+}
+
+// spec 一次沙箱运行的参数
+type spec struct {
+	Dir       string   `json:"dir"`
+	UID       int      `json:"uid"`
+	GID       int      `json:"gid"`
+	TmpfsSize int      `json:"tmpfs_size"` // MB，/tmp 的大小
+	FileSize  int64    `json:"file_size"`  // 字节，0 表示不限制
+	DiskSize  int64    `json:"disk_size"`  // 字节，0 时工作目录与 /tmp 大小相同
+	Path      string   `json:"path"`
+	Args      []string `json:"args"`
+	Env       []string `json:"env"`
+}
+
+// Sandbox 运行仿真工具的沙箱
+type Sandbox struct {
+	cfg      config.SandboxConfig
+	isolated bool // false 表示命名空间不可用时的不安全回退，工具直接运行
+}
+
+// New 检查沙箱是否可用
+// 当前环境无法创建命名空间时，只有配置了 insecure 才回退为直接运行，否则返回错误
+func New(cfg config.SandboxConfig) (*Sandbox, error) {
+	s := &Sandbox{cfg: cfg, isolated: true}
+	if err := s.probe(); err != nil {
+		if !cfg.Insecure {
+			return nil, fmt.Errorf("sandbox unavailable: %v (set sandbox.insecure or JUDGE_SANDBOX_INSECURE=true to run simulators without isolation)", err)
+		}
+		log.Printf("WARNING: sandbox unavailable, running simulators WITHOUT isolation: %v", err)
+		s.isolated = false
+	}
+	return s, nil
+}
+
+// Isolated 返回工具是否在沙箱中运行
+func (s *Sandbox) Isolated() bool {
+	return s.isolated
+}
+
+// waitDelay 工具被终止后等待其子进程关闭输出管道的时间
+const waitDelay = time.Second
+
+// Result 一次运行的结果
+type Result struct {
+	Output   []byte // stdout 和 stderr，超出输出限制时截断
+	Err      error  // 与 exec.Cmd.Run 返回的错误相同，因超出限制被终止时不为 nil
+	Exceeded string // 超出的限制（LimitOutput、LimitFileSize 或 LimitDisk），未超出时为空
+}
+
+// Run 在沙箱中运行工具并等待结束，dir 是工作目录，工具只能写入该目录和 /tmp
+// 结束后 dir 中新建或修改的普通文件会复制回宿主机，ctx 取消或超出 limits 时终止沙箱内的所有进程
+func (s *Sandbox) Run(ctx context.Context, dir string, limits Limits, name string, args ...string) Result {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wire := &tripwire{stop: cancel}
+	output := &cappedBuffer{limit: limits.Output, wire: wire}
+
+	cmd := s.command(runCtx, dir, limits, name, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = waitDelay
+	if err := cmd.Start(); err != nil {
+		return Result{Err: err}
+	}
+
+	// 沙箱中的文件在结束后才复制回来，由 init 进程在沙箱内检查；不隔离时在宿主机检查
+	done := make(chan struct{})
+	if !s.isolated {
+		go watchUsage(dir, limits, wire, done)
+	}
+	err := cmd.Wait()
+	close(done)
+
+	// 复制回来的文件保留了超出限制时的大小，结束后再检查一次，覆盖两次检查之间结束的运行
+	if reason := checkUsage(dir, limits); reason != "" {
+		wire.trip(reason)
+	}
+	return Result{Output: output.Bytes(), Err: err, Exceeded: wire.exceeded()}
+}
+
+// command 创建在沙箱中运行的命令，沙箱不可用或找不到工具时直接运行
+func (s *Sandbox) command(ctx context.Context, dir string, limits Limits, name string, args ...string) *exec.Cmd {
+	path, err := exec.LookPath(name)
+	if !s.isolated || err != nil {
+		// 找不到工具时直接返回原命令，运行时报告与未使用沙箱时相同的错误
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+		return cmd
+	}
+
+	return s.isolatedCommand(ctx, spec{
+		Dir:       dir,
+		UID:       s.cfg.UID,
+		GID:       s.cfg.GID,
+		TmpfsSize: s.cfg.TmpfsSize,
+		FileSize:  limits.FileSize,
+		DiskSize:  limits.DiskSize,
+		Path:      path,
+		Args:      append([]string{name}, args...),
+		Env:       append(append([]string{}, toolEnv...), "HOME="+dir),
+	})
+}
+
+// probe 在沙箱中运行 true，确认命名空间、挂载和 seccomp 都可用
+func (s *Sandbox) probe() error {
+	dir, err := os.MkdirTemp("", "judge-sandbox-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := exec.LookPath("true"); err != nil {
+		return err
+	}
+	result := s.Run(context.Background(), dir, Limits{}, "true")
+	if result.Err != nil {
+		if len(result.Output) > 0 {
+			return fmt.Errorf("%v: %s", result.Err, result.Output)
+		}
+		return result.Err
+	}
+	return nil
+}
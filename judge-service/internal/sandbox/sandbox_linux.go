@@ -0,0 +1,345 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setupFailed 沙箱初始化失败时 init 进程的退出码
+const setupFailed = 125
+
+// diskSlack 工作目录 tmpfs 在磁盘限制之外多留的空间，使超出限制的写入能被检查到，而不是只返回 ENOSPC
+const diskSlack = 1 << 20
+
+// isolatedCommand 创建在新命名空间中运行 init 阶段的命令
+func (s *Sandbox) isolatedCommand(ctx context.Context, sp spec) *exec.Cmd {
+	data, _ := json.Marshal(sp)
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{initStage}
+	cmd.Env = []string{specEnv + "=" + string(data)}
+	cmd.Dir = sp.Dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		// 判题服务退出时终止沙箱，init 进程退出后内核会终止命名空间内的所有进程
+		Pdeathsig: syscall.SIGKILL,
+	}
+	return cmd
+}
+
+// readSpec 从环境变量读取运行参数
+func readSpec() (*spec, error) {
+	var sp spec
+	if err := json.Unmarshal([]byte(os.Getenv(specEnv)), &sp); err != nil {
+		return nil, fmt.Errorf("invalid sandbox spec: %v", err)
+	}
+	return &sp, nil
+}
+
+// runInit 沙箱的 init 进程：准备文件系统，运行工具并等待结束，然后把工作目录的文件复制回宿主机
+func runInit() int {
+	sp, err := readSpec()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return setupFailed
+	}
+
+	hostDir, err := setupFilesystem(sp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return setupFailed
+	}
+	defer hostDir.Close()
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Args = []string{execStage}
+	cmd.Env = []string{specEnv + "=" + os.Getenv(specEnv)}
+	cmd.Dir = sp.Dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return setupFailed
+	}
+
+	// 超出文件或磁盘限制时终止工具，宿主机根据复制回去的文件判断超出的限制
+	wire := &tripwire{stop: func() { cmd.Process.Kill() }}
+	done := make(chan struct{})
+	go watchUsage(sp.Dir, Limits{FileSize: sp.FileSize, DiskSize: sp.DiskSize}, wire, done)
+	runErr := cmd.Wait()
+	close(done)
+
+	if err := copyTree(sp.Dir, procFDPath(hostDir), -1, -1); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: failed to copy results: %v\n", err)
+		return setupFailed
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+		return 0
+	case errors.As(runErr, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	default:
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", runErr)
+		return setupFailed
+	}
+}
+
+// setupFilesystem 将根文件系统重新挂载为只读，在工作目录和 /tmp 挂载 tmpfs，并复制工作目录的文件
+// 返回指向宿主机工作目录的文件，用于结束后复制结果
+func setupFilesystem(sp *spec) (*os.File, error) {
+	// 挂载变化不传播回宿主机
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return nil, fmt.Errorf("failed to make mounts private: %v", err)
+	}
+
+	// 工作目录单独绑定挂载，根目录改为只读后仍可以通过它写回结果
+	if err := syscall.Mount(sp.Dir, sp.Dir, "", syscall.MS_BIND, ""); err != nil {
+		return nil, fmt.Errorf("failed to bind work dir: %v", err)
+	}
+	hostDir, err := os.Open(sp.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := remountReadOnly(sp.Dir); err != nil {
+		hostDir.Close()
+		return nil, err
+	}
+
+	// 先挂载 /tmp，工作目录位于 /tmp 下时（默认的 /tmp/judge）在新的 /tmp 中重新创建路径
+	tmpfsOptions := fmt.Sprintf("size=%dm", sp.TmpfsSize)
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, tmpfsOptions+",mode=1777"); err != nil {
+		hostDir.Close()
+		return nil, fmt.Errorf("failed to mount tmpfs on /tmp: %v", err)
+	}
+	if err := os.MkdirAll(sp.Dir, 0755); err != nil {
+		hostDir.Close()
+		return nil, err
+	}
+	workDirOptions := tmpfsOptions
+	if sp.DiskSize > 0 {
+		workDirOptions = fmt.Sprintf("size=%d", sp.DiskSize+diskSlack)
+	}
+	if err := syscall.Mount("tmpfs", sp.Dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, workDirOptions+",mode=0755"); err != nil {
+		hostDir.Close()
+		return nil, fmt.Errorf("failed to mount tmpfs on work dir: %v", err)
+	}
+	if err := os.Chown(sp.Dir, sp.UID, sp.GID); err != nil {
+		hostDir.Close()
+		return nil, err
+	}
+
+	if err := copyTree(procFDPath(hostDir), sp.Dir, sp.UID, sp.GID); err != nil {
+		hostDir.Close()
+		return nil, fmt.Errorf("failed to copy work dir: %v", err)
+	}
+
+	if err := syscall.Sethostname([]byte("sandbox")); err != nil {
+		hostDir.Close()
+		return nil, fmt.Errorf("failed to set hostname: %v", err)
+	}
+
+	// 新的 proc 只包含沙箱内的进程
+	if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		hostDir.Close()
+		return nil, fmt.Errorf("failed to mount proc: %v", err)
+	}
+	return hostDir, nil
+}
+
+// remountReadOnly 将除工作目录外的所有挂载点重新挂载为只读
+func remountReadOnly(workDir string) error {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		target := unescapeMountPath(fields[4])
+		if target == workDir {
+			continue
+		}
+
+		flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND | syscall.MS_RDONLY)
+		for _, option := range strings.Split(fields[5], ",") {
+			switch option {
+			case "nosuid":
+				flags |= syscall.MS_NOSUID
+			case "nodev":
+				flags |= syscall.MS_NODEV
+			case "noexec":
+				flags |= syscall.MS_NOEXEC
+			case "noatime":
+				flags |= syscall.MS_NOATIME
+			case "nodiratime":
+				flags |= syscall.MS_NODIRATIME
+			case "relatime":
+				flags |= syscall.MS_RELATIME
+			}
+		}
+		if err := syscall.Mount("", target, "", flags, ""); err != nil {
+			// 被其他挂载覆盖或已经卸载的挂载点无法访问，可以忽略
+			if errors.Is(err, syscall.ENOENT) {
+				continue
+			}
+			return fmt.Errorf("failed to remount %s read-only: %v", target, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// unescapeMountPath 还原 mountinfo 中以八进制转义的空白字符
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// procFDPath 返回文件描述符在 /proc 中的路径，工作目录被 tmpfs 覆盖后仍可通过它访问宿主机上的目录
+func procFDPath(file *os.File) string {
+	return fmt.Sprintf("/proc/self/fd/%d", file.Fd())
+}
+
+// copyTree 复制目录中的普通文件和子目录，忽略符号链接等其他类型
+// uid 不为 -1 时修改复制出的文件的属主
+func copyTree(src, dst string, uid, gid int) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		info, err := os.Lstat(srcPath)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.Mkdir(dstPath, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+			if uid != -1 {
+				if err := os.Chown(dstPath, uid, gid); err != nil {
+					return err
+				}
+			}
+			if err := copyTree(srcPath, dstPath, uid, gid); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := copyFile(srcPath, dstPath, info.Mode().Perm(), uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyFile 复制单个文件，不跟随目标路径上的符号链接
+func copyFile(src, dst string, perm os.FileMode, uid, gid int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if uid != -1 {
+		return os.Chown(dst, uid, gid)
+	}
+	return nil
+}
+
+// runExec 沙箱的 exec 阶段：限制文件大小，切换到非特权用户，设置 no_new_privs 和 seccomp 后执行工具
+// 只在返回错误时返回，成功时当前进程被工具替换
+func runExec() int {
+	sp, err := readSpec()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return setupFailed
+	}
+
+	// seccomp 过滤器只作用于安装它的线程，exec 也必须在该线程上调用
+	runtime.LockOSThread()
+
+	if sp.FileSize > 0 {
+		// 写入超过限制时工具收到 SIGXFSZ，文件停在限制加一字节，宿主机据此判断超出了文件大小限制
+		limit := uint64(sp.FileSize + 1)
+		if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: failed to limit file size: %v\n", err)
+			return setupFailed
+		}
+	}
+	if err := dropPrivileges(sp.UID, sp.GID); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: failed to drop privileges: %v\n", err)
+		return setupFailed
+	}
+	if err := installSeccomp(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: failed to install seccomp filter: %v\n", err)
+		return setupFailed
+	}
+
+	err = syscall.Exec(sp.Path, sp.Args, sp.Env)
+	fmt.Fprintf(os.Stderr, "sandbox: failed to execute %s: %v\n", sp.Path, err)
+	return setupFailed
+}
+
+// dropPrivileges 清除附加组并切换到指定的用户和组
+func dropPrivileges(uid, gid int) error {
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	return syscall.Setuid(uid)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// isolatedCommand 非 Linux 系统没有命名空间，New 会在探测时失败，只能配置 insecure 回退
+func (s *Sandbox) isolatedCommand(ctx context.Context, sp spec) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, sp.Path, sp.Args[1:]...)
+	cmd.Dir = sp.Dir
+	cmd.Err = errors.New("sandbox requires Linux namespaces")
+	return cmd
+}
+
+func runInit() int { return 1 }
+
+func runExec() int { return 1 }
@@ -0,0 +1,27 @@
+package sandbox
+
+import "syscall"
+
+// auditArch AUDIT_ARCH_X86_64
+const auditArch = 0xc000003e
+
+// syscallNrLimit 大于等于该值的调用号属于 x32 ABI
+const syscallNrLimit = 0x40000000
+
+const (
+	sysNewfstatat = syscall.SYS_NEWFSTATAT
+	sysStatx      = 332
+	sysGetrandom  = 318
+	sysRseq       = 334
+	sysRenameat2  = 316
+)
+
+// allowedSyscalls 白名单，x86_64 另有旧式的路径类调用，glibc 和 shell 仍会使用
+var allowedSyscalls = append(commonSyscalls,
+	syscall.SYS_OPEN, syscall.SYS_STAT, syscall.SYS_LSTAT, syscall.SYS_ACCESS, syscall.SYS_READLINK,
+	syscall.SYS_GETDENTS, syscall.SYS_MKDIR, syscall.SYS_RMDIR, syscall.SYS_UNLINK, syscall.SYS_RENAME,
+	syscall.SYS_CHMOD, syscall.SYS_RENAMEAT, syscall.SYS_PIPE, syscall.SYS_DUP2, syscall.SYS_POLL, syscall.SYS_SELECT,
+	syscall.SYS_EPOLL_CREATE, syscall.SYS_EPOLL_WAIT, syscall.SYS_EVENTFD,
+	syscall.SYS_FORK, syscall.SYS_VFORK, syscall.SYS_GETPGRP, syscall.SYS_ARCH_PRCTL,
+	syscall.SYS_TIME, syscall.SYS_ALARM, syscall.SYS_PAUSE,
+)
@@ -0,0 +1,20 @@
+package sandbox
+
+import "syscall"
+
+// auditArch AUDIT_ARCH_AARCH64
+const auditArch = 0xc00000b7
+
+// syscallNrLimit arm64 没有需要额外排除的调用号范围
+const syscallNrLimit = 0
+
+const (
+	sysNewfstatat = syscall.SYS_FSTATAT
+	sysStatx      = 291
+	sysGetrandom  = syscall.SYS_GETRANDOM
+	sysRseq       = 293
+	sysRenameat2  = 276
+)
+
+// allowedSyscalls 白名单
+var allowedSyscalls = commonSyscalls
@@ -0,0 +1,17 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// 其他架构没有维护白名单，installSeccomp 会返回错误，只能配置 insecure 回退
+const (
+	auditArch      = 0
+	syscallNrLimit = 0
+
+	sysNewfstatat = 0
+	sysStatx      = 0
+	sysGetrandom  = 0
+	sysRseq       = 0
+	sysRenameat2  = 0
+)
+
+var allowedSyscalls []uintptr
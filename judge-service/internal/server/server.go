@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+	"verilog-oj/protocol"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Check 就绪检查项，返回 nil 表示通过
+type Check func(ctx context.Context) error
+
+// Server 判题服务的健康检查与指标HTTP服务
+type Server struct {
+	httpServer *http.Server
+	checks     map[string]Check
+
+	mu           sync.RWMutex
+	capabilities *protocol.Capabilities
+}
+
+// New 创建HTTP服务，checks 为 /readyz 需要执行的检查项
+func New(addr string, checks map[string]Check) *Server {
+	s := &Server{checks: checks}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/capabilities", s.handleCapabilities)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start 在后台启动HTTP服务
+func (s *Server) Start() {
+	go func() {
+		log.Printf("Health and metrics server listening on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Health and metrics server stopped: %v", err)
+		}
+	}()
+}
+
+// Shutdown 关闭HTTP服务
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// handleHealthz 存活检查：进程能响应即视为存活
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// handleReadyz 就绪检查：所有依赖可用时才返回200
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := http.StatusOK
+	results := make(map[string]string, len(names))
+	for _, name := range names {
+		if err := s.checks[name](ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "ok"
+	}
+
+	body := map[string]interface{}{"status": "ready", "checks": results}
+	if status != http.StatusOK {
+		body["status"] = "not_ready"
+	}
+	writeJSON(w, status, body)
+}
+
+// SetCapabilities 设置 /capabilities 返回的节点能力
+func (s *Server) SetCapabilities(capabilities *protocol.Capabilities) {
+	s.mu.Lock()
+	s.capabilities = capabilities
+	s.mu.Unlock()
+}
+
+// handleCapabilities 返回节点自检后发布的能力，自检完成前返回503
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	capabilities := s.capabilities
+	s.mu.RUnlock()
+
+	if capabilities == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "self_test_pending"})
+		return
+	}
+	writeJSON(w, http.StatusOK, capabilities)
+}
+
+// BinaryCheck 检查可执行文件是否在 PATH 中
+func BinaryCheck(name string) Check {
+	return func(ctx context.Context) error {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("%s not found in PATH", name)
+		}
+		return nil
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verilog-oj/protocol"
+)
+
+// get 请求 path 并解码JSON响应
+func get(t *testing.T, s *Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s: invalid JSON %q: %v", path, w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func TestHealthz(t *testing.T) {
+	s := New(":0", map[string]Check{"redis": func(ctx context.Context) error { return errors.New("down") }})
+	// 依赖不可用不影响存活检查
+	if code, body := get(t, s, "/healthz"); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("GET /healthz = %d %v, want 200 ok", code, body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "no checks",
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+			wantChecks: map[string]string{},
+		},
+		{
+			name:       "all checks pass",
+			checks:     map[string]Check{"redis": ok, "iverilog": ok},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+			wantChecks: map[string]string{"redis": "ok", "iverilog": "ok"},
+		},
+		{
+			name: "one check fails",
+			checks: map[string]Check{
+				"redis":    ok,
+				"iverilog": func(ctx context.Context) error { return errors.New("iverilog not found in PATH") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not_ready",
+			wantChecks: map[string]string{"redis": "ok", "iverilog": "iverilog not found in PATH"},
+		},
+		{
+			name:       "missing binary",
+			checks:     map[string]Check{"tool": BinaryCheck("verilog-oj-missing-tool")},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not_ready",
+			wantChecks: map[string]string{"tool": "verilog-oj-missing-tool not found in PATH"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, New(":0", tt.checks), "/readyz")
+			if code != tt.wantCode || body["status"] != tt.wantStatus {
+				t.Fatalf("GET /readyz = %d %v, want %d %s", code, body, tt.wantCode, tt.wantStatus)
+			}
+			checks, _ := body["checks"].(map[string]interface{})
+			if len(checks) != len(tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", checks, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				if checks[name] != want {
+					t.Errorf("checks[%s] = %v, want %q", name, checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	s := New(":0", nil)
+	// 自检完成前不可用
+	if code, body := get(t, s, "/capabilities"); code != http.StatusServiceUnavailable || body["status"] != "self_test_pending" {
+		t.Errorf("GET /capabilities before self-test = %d %v, want 503 self_test_pending", code, body)
+	}
+
+	s.SetCapabilities(&protocol.Capabilities{ProtocolVersion: protocol.Version, Languages: []string{"verilog"}})
+	code, body := get(t, s, "/capabilities")
+	if code != http.StatusOK {
+		t.Fatalf("GET /capabilities after self-test = %d %v, want 200", code, body)
+	}
+	if languages, _ := body["languages"].([]interface{}); len(languages) != 1 || languages[0] != "verilog" {
+		t.Errorf("languages = %v, want [verilog]", body["languages"])
+	}
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter 可在运行时调整上限的并发限制器
+// 调低上限时不会打断已占用名额的任务，只是在它们释放前不再发放新名额
+type Limiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+// NewLimiter 创建并发限制器
+func NewLimiter(limit int) *Limiter {
+	l := &Limiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire 占用一个名额，ctx 取消时返回错误
+func (l *Limiter) Acquire(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		l.cond.Broadcast()
+		l.mu.Unlock()
+	})
+	defer stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= l.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.active++
+	return nil
+}
+
+// Release 释放一个名额
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	l.active--
+	l.cond.Broadcast()
+	l.mu.Unlock()
+}
+
+// SetLimit 调整并发上限
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.cond.Broadcast()
+	l.mu.Unlock()
+}
+
+// Limit 返回当前并发上限
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
@@ -0,0 +1,321 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/judge-service/internal/metrics"
+	"verilog-oj/judge-service/internal/queue"
+	"verilog-oj/protocol"
+)
+
+// maxRetries 连续拉取队列失败的最大次数
+const maxRetries = 10
+
+// cancelRetryDelay 订阅取消通知失败后的重试间隔
+const cancelRetryDelay = 5 * time.Second
+
+// Worker 从队列拉取判题任务并按并发上限执行
+type Worker struct {
+	judger  *judge.Judge
+	queue   queue.Queue
+	limiter *Limiter
+
+	// 判题任务使用独立的上下文，停止拉取后仍可等待正在进行的任务完成
+	jobCtx    context.Context
+	jobCancel context.CancelFunc
+	jobs      sync.WaitGroup
+
+	mu       sync.Mutex
+	running  map[string]context.CancelFunc // 提交ID -> 终止该判题任务
+	cacheTTL time.Duration                 // 判题结果缓存的保留时间，0 表示不使用缓存
+}
+
+// New 创建判题工作器
+func New(judger *judge.Judge, q queue.Queue, concurrency int) *Worker {
+	jobCtx, jobCancel := context.WithCancel(context.Background())
+	return &Worker{
+		judger:    judger,
+		queue:     q,
+		limiter:   NewLimiter(concurrency),
+		jobCtx:    jobCtx,
+		jobCancel: jobCancel,
+		running:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Concurrency 返回当前的并发判题数
+func (w *Worker) Concurrency() int {
+	return w.limiter.Limit()
+}
+
+// SetConcurrency 调整并发判题数，正在进行的任务不受影响
+func (w *Worker) SetConcurrency(concurrency int) {
+	w.limiter.SetLimit(concurrency)
+}
+
+// SetResultCache 设置判题结果缓存的保留时间，0 表示不读取也不写入缓存
+func (w *Worker) SetResultCache(ttl time.Duration) {
+	w.mu.Lock()
+	w.cacheTTL = ttl
+	w.mu.Unlock()
+}
+
+// resultCacheTTL 返回当前判题结果缓存的保留时间
+func (w *Worker) resultCacheTTL() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cacheTTL
+}
+
+// Run 循环拉取判题任务，直到 ctx 取消或连续失败次数过多
+func (w *Worker) Run(ctx context.Context) {
+	retryCount := 0
+	go w.watchCancels(ctx)
+
+	for {
+		if err := w.limiter.Acquire(ctx); err != nil {
+			return
+		}
+
+		// 从队列获取判题请求
+		delivery, err := w.queue.Pop(ctx)
+		var msgErr *protocol.MessageError
+		if errors.As(err, &msgErr) {
+			w.limiter.Release()
+			// 协议不匹配的任务直接拒绝，避免按错误的字段语义判题
+			w.rejectRequest(ctx, msgErr)
+			continue
+		}
+		if err != nil {
+			w.limiter.Release()
+			if ctx.Err() != nil || errors.Is(err, queue.ErrQueueClosed) {
+				return
+			}
+
+			retryCount++
+			metrics.QueueRetries.Inc()
+			log.Printf("Failed to pop from queue (retry %d/%d): %v", retryCount, maxRetries, err)
+
+			// 超过最大重试次数则退出
+			if retryCount >= maxRetries {
+				log.Printf("Max retries exceeded, shutting down worker")
+				return
+			}
+
+			// 重试延迟：线性退避，最大30秒
+			retryDelay := time.Duration(retryCount) * time.Second
+			if retryDelay > 30*time.Second {
+				retryDelay = 30 * time.Second
+			}
+
+			log.Printf("Waiting %v before retry...", retryDelay)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		// 连接成功，重置重试计数
+		retryCount = 0
+
+		if delivery == nil {
+			// 队列为空，继续轮询
+			w.limiter.Release()
+			continue
+		}
+
+		w.jobs.Add(1)
+		go func() {
+			defer w.jobs.Done()
+			defer w.limiter.Release()
+			w.process(delivery)
+		}()
+	}
+}
+
+// Drain 等待正在进行的任务完成，超过 timeout 后取消剩余任务
+func (w *Worker) Drain(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		w.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("In-flight judge jobs did not finish within %v, cancelling", timeout)
+		w.jobCancel()
+		<-done
+	}
+	w.jobCancel()
+}
+
+// watchCancels 接收取消通知并终止对应的判题任务，直到 ctx 取消
+func (w *Worker) watchCancels(ctx context.Context) {
+	for {
+		cancels, err := w.queue.SubscribeCancels(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, queue.ErrQueueClosed) {
+				return
+			}
+			log.Printf("Failed to subscribe cancellations, retrying in %v: %v", cancelRetryDelay, err)
+			select {
+			case <-time.After(cancelRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		for submissionID := range cancels {
+			w.mu.Lock()
+			cancel, ok := w.running[submissionID]
+			w.mu.Unlock()
+			if ok {
+				log.Printf("Cancelling submission: %s", submissionID)
+				cancel()
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// startJob 登记正在进行的判题任务，返回的上下文在收到该提交的取消通知时取消
+func (w *Worker) startJob(submissionID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(w.jobCtx)
+	w.mu.Lock()
+	w.running[submissionID] = cancel
+	w.mu.Unlock()
+	return ctx, func() {
+		w.mu.Lock()
+		delete(w.running, submissionID)
+		w.mu.Unlock()
+		cancel()
+	}
+}
+
+// process 执行单个判题任务，发布结果后确认任务
+// 结果发布失败时不确认，任务保留在队列的未确认记录中
+func (w *Worker) process(delivery *queue.Delivery) {
+	request := delivery.Request
+	log.Printf("Processing submission: %s", request.SubmissionID)
+
+	// 先登记再检查取消标记，取出任务到开始判题之间收到的取消不会丢失
+	ctx, finish := w.startJob(request.SubmissionID)
+	defer finish()
+	cancelled, err := w.queue.Cancelled(ctx, request.SubmissionID)
+	if err != nil {
+		log.Printf("Failed to check cancellation for submission %s: %v", request.SubmissionID, err)
+	}
+
+	// 相同代码、测试数据和判题环境已有结果时直接使用缓存
+	cacheTTL := w.resultCacheTTL()
+	var cacheKey string
+	var result *protocol.JudgeResult
+	if !cancelled && cacheTTL > 0 {
+		cacheKey = w.judger.CacheKey(request)
+		result, err = w.cachedResult(ctx, request.SubmissionID, cacheKey), nil
+	}
+	cached := result != nil
+
+	// 执行判题
+	startTime := time.Now()
+	if !cancelled && !cached {
+		metrics.JobsInFlight.Inc()
+		result, err = w.judger.Judge(ctx, request, func(event *protocol.JudgeEvent) {
+			w.publishEvent(w.jobCtx, event)
+		})
+		metrics.JobsInFlight.Dec()
+		// 只有该任务的上下文被取消时才是取消，服务退出时 w.jobCtx 也会被取消
+		cancelled = ctx.Err() != nil && w.jobCtx.Err() == nil
+	}
+	if cancelled {
+		result, err = protocol.NewCancelledResult(request.SubmissionID), nil
+	}
+	if err != nil {
+		log.Printf("Judge failed for submission %s: %v", request.SubmissionID, err)
+		return
+	}
+	// 缓存命中几乎不耗时，不计入排队时间的估算
+	if !cancelled && !cached {
+		if err := w.queue.RecordDuration(w.jobCtx, time.Since(startTime)); err != nil {
+			log.Printf("Failed to record judge duration for submission %s: %v", request.SubmissionID, err)
+		}
+		if cacheKey != "" && judge.Cacheable(result) {
+			if err := w.queue.CacheResult(w.jobCtx, cacheKey, result, cacheTTL); err != nil {
+				log.Printf("Failed to cache result for submission %s: %v", request.SubmissionID, err)
+			}
+		}
+	}
+	metrics.ObserveJob(result.Status)
+	w.publishFinished(w.jobCtx, result)
+
+	// 发布结果
+	if err := w.queue.PublishResult(w.jobCtx, result); err != nil {
+		log.Printf("Failed to publish result for submission %s: %v", request.SubmissionID, err)
+		return
+	}
+
+	if err := w.queue.Ack(w.jobCtx, delivery); err != nil {
+		log.Printf("Failed to ack submission %s: %v", request.SubmissionID, err)
+	}
+
+	log.Printf("Completed submission: %s, Status: %s, Score: %d, Cached: %t",
+		result.SubmissionID, result.Status, result.Score, result.Cached)
+}
+
+// cachedResult 查找缓存的判题结果，命中时改写为该提交的结果并标记为 cached，未命中或出错时返回 nil
+func (w *Worker) cachedResult(ctx context.Context, submissionID, key string) *protocol.JudgeResult {
+	result, err := w.queue.CachedResult(ctx, key)
+	if err != nil {
+		log.Printf("Failed to read result cache for submission %s: %v", submissionID, err)
+		return nil
+	}
+	if result == nil {
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+		return nil
+	}
+	metrics.CacheLookups.WithLabelValues("hit").Inc()
+	result.SubmissionID = submissionID
+	result.JudgedAt = time.Now()
+	result.Cached = true
+	return result
+}
+
+// rejectRequest 拒绝不符合协议的判题请求，并在可能时回报系统错误
+func (w *Worker) rejectRequest(ctx context.Context, msgErr *protocol.MessageError) {
+	log.Printf("Rejected judge request: %v", msgErr)
+	metrics.ObserveJob("rejected")
+	if msgErr.SubmissionID == "" {
+		return
+	}
+
+	result := protocol.NewRejectedResult(msgErr.SubmissionID, msgErr)
+	w.publishFinished(ctx, result)
+	if err := w.queue.PublishResult(ctx, result); err != nil {
+		log.Printf("Failed to publish rejection for submission %s: %v", msgErr.SubmissionID, err)
+	}
+}
+
+// publishFinished 发布带有最终结果的 finished 事件
+func (w *Worker) publishFinished(ctx context.Context, result *protocol.JudgeResult) {
+	event := protocol.NewEvent(result.SubmissionID, protocol.EventFinished)
+	event.Result = result
+	w.publishEvent(ctx, event)
+}
+
+// publishEvent 发布进度事件，失败只记录日志，不影响判题
+func (w *Worker) publishEvent(ctx context.Context, event *protocol.JudgeEvent) {
+	if err := w.queue.PublishEvent(ctx, event); err != nil {
+		log.Printf("Failed to publish %s event for submission %s: %v", event.Type, event.SubmissionID, err)
+	}
+}
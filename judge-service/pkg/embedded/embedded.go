@@ -0,0 +1,112 @@
+// Package embedded 在其他进程内运行判题服务，任务通过进程内队列传递，不依赖Redis
+//
+// 适用于单机部署和集成测试，宿主机仍需安装 iverilog 和 vvp，启动时的自检未通过会返回错误。
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"verilog-oj/judge-service/internal/config"
+	"verilog-oj/judge-service/internal/judge"
+	"verilog-oj/judge-service/internal/queue"
+	"verilog-oj/judge-service/internal/worker"
+	"verilog-oj/protocol"
+)
+
+// queueCapacity 进程内队列最多等待的任务数
+const queueCapacity = 1024
+
+// Judge 内嵌的判题服务
+type Judge struct {
+	queue  *queue.MemoryQueue
+	worker *worker.Worker
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start 加载判题配置并启动内嵌判题服务
+// configPath 与独立部署的 -config 含义相同，为空时只使用默认值和环境变量；队列相关配置会被忽略
+func Start(configPath string) (*Judge, error) {
+	cfg, err := config.LoadJudgeConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.WorkDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %v", err)
+	}
+
+	judger, err := judge.NewJudge(cfg)
+	if err != nil {
+		return nil, err
+	}
+	selfTestCtx, selfTestCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer selfTestCancel()
+	if _, err := judger.SelfTest(selfTestCtx); err != nil {
+		return nil, err
+	}
+
+	mq := queue.NewMemoryQueue(queueCapacity)
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Judge{
+		queue:  mq,
+		worker: worker.New(judger, mq, cfg.Concurrency),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	j.worker.SetResultCache(cfg.Cache.TTLDuration())
+
+	go func() {
+		defer close(j.done)
+		j.worker.Run(ctx)
+	}()
+	log.Printf("Embedded judge started with concurrency %d", cfg.Concurrency)
+	return j, nil
+}
+
+// Push 推送判题请求
+func (j *Judge) Push(ctx context.Context, request *protocol.JudgeRequest) error {
+	return j.queue.Push(ctx, request)
+}
+
+// Cancel 取消判题任务，进程内队列无法移除等待中的任务，总是返回 false，由判题服务发布 cancelled 结果
+// language 只用于与Redis实现保持一致的接口
+func (j *Judge) Cancel(ctx context.Context, submissionID, language string) (bool, error) {
+	return j.queue.Cancel(ctx, submissionID)
+}
+
+// ConsumeResults 将所有判题结果交给 handle 处理，直到 ctx 取消或判题服务关闭
+func (j *Judge) ConsumeResults(ctx context.Context, handle func(*protocol.JudgeResult) error) error {
+	results, err := j.queue.SubscribeResults(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for result := range results {
+		if err := handle(result); err != nil {
+			log.Printf("failed to apply judge result for submission %s: %v", result.SubmissionID, err)
+		}
+	}
+	return nil
+}
+
+// SubscribeEvents 订阅提交的判题进度事件，先补发 afterID 之后的事件，收到 finished 事件后关闭通道
+func (j *Judge) SubscribeEvents(ctx context.Context, submissionID, afterID string) (<-chan *protocol.JudgeEvent, error) {
+	return j.queue.SubscribeEvents(ctx, submissionID, afterID)
+}
+
+// ReadEvents 返回提交在 afterID 之后已记录的判题进度事件
+func (j *Judge) ReadEvents(ctx context.Context, submissionID, afterID string) ([]*protocol.JudgeEvent, error) {
+	return j.queue.ReadEvents(ctx, submissionID, afterID)
+}
+
+// Close 停止拉取任务，等待正在进行的判题完成后关闭队列
+func (j *Judge) Close() error {
+	j.cancel()
+	<-j.done
+	j.worker.Drain(5 * time.Second)
+	return j.queue.Close()
+}
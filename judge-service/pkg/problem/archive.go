@@ -0,0 +1,177 @@
+package problem
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"verilog-oj/protocol"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxArchiveFiles zip 题目包允许的最大文件数
+const MaxArchiveFiles = 10000
+
+// Entry 批量读取时的单个题目包，读取失败时 Err 不为空
+type Entry struct {
+	Path    string // 题目包在压缩包或目录中的位置，位于根目录时为 "."
+	Package *Package
+	Err     error
+}
+
+// LoadZip 读取zip压缩包中的所有题目包
+func LoadZip(data []byte) ([]Entry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %v", err)
+	}
+	if len(reader.File) > MaxArchiveFiles {
+		return nil, fmt.Errorf("zip archive contains more than %d files", MaxArchiveFiles)
+	}
+	return Discover(reader)
+}
+
+// Discover 查找并读取 fsys 中的题目包
+// 根目录包含 problem.yaml 时视为单个题目包，否则读取每个包含 problem.yaml 的一级子目录；
+// 所有题目包共用 MaxTotalSize 的读取上限，超出后剩余的题目包读取失败
+func Discover(fsys fs.FS) ([]Entry, error) {
+	remaining := int64(MaxTotalSize)
+	if _, err := fs.Stat(fsys, MetadataFile); err == nil {
+		pkg, err := loadFS(&packageReader{fsys: fsys, remaining: &remaining})
+		return []Entry{{Path: ".", Package: pkg, Err: err}}, nil
+	}
+
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(fsys, path.Join(dir.Name(), MetadataFile)); err != nil {
+			continue
+		}
+
+		sub, err := fs.Sub(fsys, dir.Name())
+		if err != nil {
+			entries = append(entries, Entry{Path: dir.Name(), Err: err})
+			continue
+		}
+		pkg, err := loadFS(&packageReader{fsys: sub, remaining: &remaining})
+		entries = append(entries, Entry{Path: dir.Name(), Package: pkg, Err: err})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no %s found", MetadataFile)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// WriteZip 以标准目录结构写出zip格式的题目包
+func (p *Package) WriteZip(w io.Writer) error {
+	meta := p.Metadata
+	meta.Description = ""
+	meta.Statement = ""
+	meta.Reference = ""
+	meta.Starter = ""
+	meta.Attachments = nil
+	meta.TestCases = nil
+	meta.TruthTable = nil
+	meta.SpecialJudge = nil
+
+	files := make(map[string][]byte)
+	var order []string
+	addFile := func(name string, content []byte) string {
+		files[name] = content
+		order = append(order, name)
+		return name
+	}
+
+	if p.Description != "" {
+		meta.Statement = addFile("statement.md", []byte(p.Description))
+	}
+	if p.Reference != "" {
+		meta.Reference = addFile("reference.v", []byte(p.Reference))
+	}
+	if p.Starter != "" {
+		meta.Starter = addFile("starter.v", []byte(p.Starter))
+	}
+	for i, testCase := range p.TestCases {
+		spec := CaseSpec{
+			Sample:      testCase.Sample,
+			Description: testCase.Description,
+			SimTime:     testCase.SimTime,
+			Clock:       testCase.Clock,
+		}
+		if testCase.Trace != nil {
+			trace, err := MarshalIOTrace(testCase.Trace)
+			if err != nil {
+				return err
+			}
+			spec.Trace = addFile(fmt.Sprintf("tests/%d.trace.yaml", i+1), trace)
+			meta.TestCases = append(meta.TestCases, spec)
+			continue
+		}
+		spec.Testbench = addFile(fmt.Sprintf("tests/%d_tb.v", i+1), []byte(testCase.Testbench))
+		if testCase.Expected != "" {
+			spec.Expected = addFile(fmt.Sprintf("tests/%d.expected", i+1), []byte(testCase.Expected))
+		}
+		meta.TestCases = append(meta.TestCases, spec)
+	}
+	if p.TruthTable != nil {
+		var table bytes.Buffer
+		if err := WriteTruthTableCSV(&table, p.TruthTable); err != nil {
+			return err
+		}
+		spec := &TruthTableSpec{TopModule: p.TruthTable.TopModule, Delay: p.TruthTable.Delay}
+		for _, port := range p.TruthTable.Ports {
+			spec.Ports = append(spec.Ports, PortSpec{Name: port.Name, Direction: port.Direction, Width: port.Width})
+		}
+		spec.Table = addFile("tests/truth_table.csv", table.Bytes())
+		meta.TruthTable = spec
+	}
+	if spj := p.SpecialJudge; spj != nil {
+		name := "checker.go"
+		if spj.Language == protocol.SpecialJudgeVerilog {
+			name = "checker.v"
+		}
+		meta.SpecialJudge = &SpecialJudgeSpec{Source: addFile(name, []byte(spj.Source)), TimeLimit: spj.TimeLimit}
+	}
+	for _, attachment := range p.Attachments {
+		name := addFile("attachments/"+path.Base(attachment.Name), attachment.Content)
+		meta.Attachments = append(meta.Attachments, name)
+	}
+
+	metaData, err := yaml.Marshal(&meta)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writeZipFile(zw, MetadataFile, metaData); err != nil {
+		return err
+	}
+	for _, name := range order {
+		if err := writeZipFile(zw, name, files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, content []byte) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(content)
+	return err
+}
@@ -0,0 +1,81 @@
+package problem
+
+import (
+	"bytes"
+	"fmt"
+	"verilog-oj/protocol"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IOTraceSpec I/O 序列文件的内容，字段含义见 protocol.IOTrace
+//
+//	top_module: detector
+//	clock: clk
+//	reset: rst_n
+//	reset_active_low: true
+//	ports:
+//	  - {name: clk, direction: input}
+//	  - {name: rst_n, direction: input}
+//	  - {name: din, direction: input}
+//	  - {name: found, direction: output}
+//	cycles:
+//	  - {din: 1, found: 0}
+//	  - {din: 0, found: 1}
+type IOTraceSpec struct {
+	TopModule      string              `yaml:"top_module"`
+	Ports          []PortSpec          `yaml:"ports"`
+	Clock          string              `yaml:"clock"`
+	Reset          string              `yaml:"reset,omitempty"`
+	ResetActiveLow bool                `yaml:"reset_active_low,omitempty"`
+	ResetCycles    int                 `yaml:"reset_cycles,omitempty"`
+	Period         int                 `yaml:"period,omitempty"`
+	SampleEdge     string              `yaml:"sample_edge,omitempty"`
+	Cycles         []map[string]string `yaml:"cycles"`
+}
+
+// ParseIOTrace 解析并校验 YAML 格式的 I/O 序列文件
+func ParseIOTrace(data []byte) (*protocol.IOTrace, error) {
+	var spec IOTraceSpec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid I/O trace: %v", err)
+	}
+
+	trace := &protocol.IOTrace{
+		TopModule:      spec.TopModule,
+		Clock:          spec.Clock,
+		Reset:          spec.Reset,
+		ResetActiveLow: spec.ResetActiveLow,
+		ResetCycles:    spec.ResetCycles,
+		Period:         spec.Period,
+		SampleEdge:     spec.SampleEdge,
+		Cycles:         spec.Cycles,
+	}
+	for _, port := range spec.Ports {
+		trace.Ports = append(trace.Ports, protocol.Port{Name: port.Name, Direction: port.Direction, Width: port.Width})
+	}
+	if err := trace.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid I/O trace: %v", err)
+	}
+	return trace, nil
+}
+
+// MarshalIOTrace 以 YAML 格式写出 I/O 序列
+func MarshalIOTrace(trace *protocol.IOTrace) ([]byte, error) {
+	spec := IOTraceSpec{
+		TopModule:      trace.TopModule,
+		Clock:          trace.Clock,
+		Reset:          trace.Reset,
+		ResetActiveLow: trace.ResetActiveLow,
+		ResetCycles:    trace.ResetCycles,
+		Period:         trace.Period,
+		SampleEdge:     trace.SampleEdge,
+		Cycles:         trace.Cycles,
+	}
+	for _, port := range trace.Ports {
+		spec.Ports = append(spec.Ports, PortSpec{Name: port.Name, Direction: port.Direction, Width: port.Width})
+	}
+	return yaml.Marshal(&spec)
+}
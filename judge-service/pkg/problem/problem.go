@@ -0,0 +1,422 @@
+// Package problem 读写题目包
+//
+// 题目包是一个包含 problem.yaml 的目录或zip文件，其余文件路径均相对于该目录：
+//
+//	adder/
+//	├── problem.yaml
+//	├── statement.md
+//	├── reference.v
+//	├── starter.v
+//	├── checker.go
+//	├── tests/
+//	│   ├── 1_tb.v
+//	│   ├── 1.expected
+//	│   └── truth_table.csv
+//	└── attachments/
+//	    └── timing.png
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"verilog-oj/protocol"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataFile 题目包的元数据文件名
+const MetadataFile = "problem.yaml"
+
+// MaxFileSize 题目包内单个文件的大小上限
+const MaxFileSize = 16 << 20
+
+// MaxTotalSize 一次读取的总字节数上限：Load、LoadFS 读取的题目包，或 Discover、LoadZip 读取的全部题目包
+// 防止压缩率很高的zip文件或多个测试用例重复引用同一个大文件占用过多内存
+const MaxTotalSize = 256 << 20
+
+// Metadata problem.yaml 的内容
+type Metadata struct {
+	Title       string     `yaml:"title"`
+	Description string     `yaml:"description,omitempty"`
+	InputDesc   string     `yaml:"input_desc,omitempty"`
+	OutputDesc  string     `yaml:"output_desc,omitempty"`
+	Difficulty  string     `yaml:"difficulty,omitempty"` // Easy, Medium, Hard
+	Category    string     `yaml:"category,omitempty"`
+	Tags        []string   `yaml:"tags,omitempty"`
+	TimeLimit   int        `yaml:"time_limit,omitempty"`   // 毫秒，0 表示使用判题服务默认值
+	MemoryLimit int        `yaml:"memory_limit,omitempty"` // MB，0 表示使用判题服务默认值
+	Language    string     `yaml:"language,omitempty"`     // 默认 verilog
+	Statement   string     `yaml:"statement,omitempty"`    // 题面markdown文件，提供时覆盖 description
+	Reference   string     `yaml:"reference,omitempty"`    // 参考答案文件
+	Starter     string     `yaml:"starter,omitempty"`      // 初始代码文件
+	Attachments []string   `yaml:"attachments,omitempty"`  // 附件文件
+	Checker     string     `yaml:"checker,omitempty"`      // 测试用例的输出比较方式，省略时比较VCD，见 protocol.Checkers
+	TestCases   []CaseSpec `yaml:"test_cases"`
+
+	// Equivalence 与参考答案的随机激励等价性检查，需要提供 reference
+	Equivalence *EquivalenceSpec `yaml:"equivalence,omitempty"`
+	// TruthTable 由端口列表和真值表生成 testbench 的测试
+	TruthTable *TruthTableSpec `yaml:"truth_table,omitempty"`
+	// SpecialJudge 判定测试用例输出的检查程序，配置后 checker 不起作用
+	SpecialJudge *SpecialJudgeSpec `yaml:"special_judge,omitempty"`
+}
+
+// SpecialJudgeSpec problem.yaml 中的检查程序配置，字段含义见 protocol.SpecialJudge
+type SpecialJudgeSpec struct {
+	Source    string `yaml:"source"`             // 检查程序源文件
+	Language  string `yaml:"language,omitempty"` // go 或 verilog，省略时按扩展名 .go、.v 判断
+	TimeLimit int    `yaml:"time_limit,omitempty"`
+}
+
+// SpecialJudgeLanguage 返回检查程序的语言，未指定时按源文件扩展名判断，无法判断时返回空字符串
+func (s *SpecialJudgeSpec) SpecialJudgeLanguage() string {
+	if s.Language != "" {
+		return s.Language
+	}
+	switch strings.ToLower(path.Ext(s.Source)) {
+	case ".go":
+		return protocol.SpecialJudgeGo
+	case ".v":
+		return protocol.SpecialJudgeVerilog
+	}
+	return ""
+}
+
+// EquivalenceSpec problem.yaml 中的等价性检查配置，字段含义见 protocol.Equivalence
+type EquivalenceSpec struct {
+	TopModule      string            `yaml:"top_module,omitempty"`
+	Seed           int               `yaml:"seed,omitempty"`
+	Cycles         int               `yaml:"cycles,omitempty"`
+	Clock          string            `yaml:"clock,omitempty"`
+	Reset          string            `yaml:"reset,omitempty"`
+	ResetActiveLow bool              `yaml:"reset_active_low,omitempty"`
+	ResetCycles    int               `yaml:"reset_cycles,omitempty"`
+	Hold           map[string]uint64 `yaml:"hold,omitempty"`
+	ExhaustiveBits int               `yaml:"exhaustive_bits,omitempty"`
+	Formal         bool              `yaml:"formal,omitempty"`
+	FormalTimeout  int               `yaml:"formal_timeout,omitempty"`
+	FormalDepth    int               `yaml:"formal_depth,omitempty"`
+}
+
+// Protocol 转换为判题协议中的等价性检查配置
+func (e *EquivalenceSpec) Protocol(reference string) *protocol.Equivalence {
+	return &protocol.Equivalence{
+		Reference:      reference,
+		TopModule:      e.TopModule,
+		Seed:           e.Seed,
+		Cycles:         e.Cycles,
+		Clock:          e.Clock,
+		Reset:          e.Reset,
+		ResetActiveLow: e.ResetActiveLow,
+		ResetCycles:    e.ResetCycles,
+		Hold:           e.Hold,
+		ExhaustiveBits: e.ExhaustiveBits,
+		Formal:         e.Formal,
+		FormalTimeout:  e.FormalTimeout,
+		FormalDepth:    e.FormalDepth,
+	}
+}
+
+// CaseSpec problem.yaml 中的测试用例条目
+type CaseSpec struct {
+	Testbench   string `yaml:"testbench,omitempty"` // testbench 文件
+	Expected    string `yaml:"expected,omitempty"`  // 期望输出文件（VCD匹配模式，checker 比较标准输出时为期望的标准输出）
+	Trace       string `yaml:"trace,omitempty"`     // I/O 序列文件，代替 testbench 和 expected
+	Sample      bool   `yaml:"sample,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	SimTime     int    `yaml:"sim_time,omitempty"` // 仿真时间预算（纳秒），省略时使用判题服务默认值
+	Clock       string `yaml:"clock,omitempty"`    // 统计时钟周期数的信号名，省略时为 clk
+}
+
+// TestCase 读取后的测试用例
+type TestCase struct {
+	Testbench   string
+	Expected    string
+	Sample      bool
+	Description string
+	SimTime     int
+	Clock       string
+	Trace       *protocol.IOTrace // I/O 序列测试用例，此时没有 Testbench 和 Expected
+}
+
+// Attachment 题目附件
+type Attachment struct {
+	Name    string // 附件文件名，不含目录
+	Content []byte
+}
+
+// Package 读取后的题目包
+type Package struct {
+	Metadata    Metadata
+	Description string // 题面，来自 statement 文件或 description 字段
+	Reference   string // 参考答案代码，未提供时为空
+	Starter     string // 初始代码，未提供时为空
+	TestCases   []TestCase
+	TruthTable  *protocol.TruthTable // 真值表测试，未配置时为 nil
+	Attachments []Attachment
+
+	SpecialJudge *protocol.SpecialJudge // 检查程序，未配置时为 nil
+}
+
+// Load 读取目录形式的题目包
+func Load(dir string) (*Package, error) {
+	return LoadFS(os.DirFS(dir))
+}
+
+// LoadFS 从文件系统读取题目包，fsys 的根目录即题目包目录
+func LoadFS(fsys fs.FS) (*Package, error) {
+	remaining := int64(MaxTotalSize)
+	return loadFS(&packageReader{fsys: fsys, remaining: &remaining})
+}
+
+// loadFS 用 r 读取题目包
+func loadFS(r *packageReader) (*Package, error) {
+	data, err := r.readFile(MetadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta Metadata
+	decoder := yaml.NewDecoder(strings.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&meta); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %v", MetadataFile, err)
+	}
+	if err := meta.Validate(); err != nil {
+		return nil, err
+	}
+
+	pkg := &Package{Metadata: meta, Description: meta.Description}
+	if meta.Statement != "" {
+		if pkg.Description, err = r.readFile(meta.Statement); err != nil {
+			return nil, err
+		}
+	}
+	if meta.Reference != "" {
+		if pkg.Reference, err = r.readFile(meta.Reference); err != nil {
+			return nil, err
+		}
+	}
+	if meta.Starter != "" {
+		if pkg.Starter, err = r.readFile(meta.Starter); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range meta.Attachments {
+		content, err := r.readFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("attachment: %v", err)
+		}
+		pkg.Attachments = append(pkg.Attachments, Attachment{Name: path.Base(name), Content: []byte(content)})
+	}
+
+	for i, spec := range meta.TestCases {
+		testCase := TestCase{Sample: spec.Sample, Description: spec.Description, SimTime: spec.SimTime, Clock: spec.Clock}
+		if spec.Trace != "" {
+			data, err := r.readFile(spec.Trace)
+			if err != nil {
+				return nil, fmt.Errorf("test case %d: %v", i+1, err)
+			}
+			if testCase.Trace, err = ParseIOTrace([]byte(data)); err != nil {
+				return nil, fmt.Errorf("test case %d: %s: %v", i+1, spec.Trace, err)
+			}
+			pkg.TestCases = append(pkg.TestCases, testCase)
+			continue
+		}
+		if testCase.Testbench, err = r.readFile(spec.Testbench); err != nil {
+			return nil, fmt.Errorf("test case %d: %v", i+1, err)
+		}
+		if spec.Expected != "" {
+			if testCase.Expected, err = r.readFile(spec.Expected); err != nil {
+				return nil, fmt.Errorf("test case %d: %v", i+1, err)
+			}
+		}
+		pkg.TestCases = append(pkg.TestCases, testCase)
+	}
+
+	if spec := meta.TruthTable; spec != nil {
+		data, err := r.readFile(spec.Table)
+		if err != nil {
+			return nil, fmt.Errorf("truth table: %v", err)
+		}
+		rows, err := ParseTruthTable(TruthTableFormat(spec.Table), []byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("truth table %s: %v", spec.Table, err)
+		}
+		pkg.TruthTable = spec.Protocol(rows)
+		if err := pkg.TruthTable.Validate(); err != nil {
+			return nil, fmt.Errorf("truth table %s: %v", spec.Table, err)
+		}
+	}
+
+	if spec := meta.SpecialJudge; spec != nil {
+		source, err := r.readFile(spec.Source)
+		if err != nil {
+			return nil, fmt.Errorf("special judge: %v", err)
+		}
+		pkg.SpecialJudge = &protocol.SpecialJudge{Language: spec.SpecialJudgeLanguage(), Source: source, TimeLimit: spec.TimeLimit}
+		if err := pkg.SpecialJudge.Validate(); err != nil {
+			return nil, fmt.Errorf("special judge %s: %v", spec.Source, err)
+		}
+	}
+	return pkg, nil
+}
+
+// Validate 校验元数据
+func (m *Metadata) Validate() error {
+	if m.Title == "" {
+		return fmt.Errorf("invalid %s: title is required", MetadataFile)
+	}
+	if m.TimeLimit < 0 || m.MemoryLimit < 0 {
+		return fmt.Errorf("invalid %s: time_limit and memory_limit must not be negative", MetadataFile)
+	}
+	if len(m.TestCases) == 0 && m.Equivalence == nil && m.TruthTable == nil {
+		return fmt.Errorf("invalid %s: at least one test case, an equivalence check or a truth table is required", MetadataFile)
+	}
+	if !protocol.ValidChecker(m.Checker) {
+		return fmt.Errorf("invalid %s: checker must be one of %s", MetadataFile, strings.Join(protocol.Checkers, ", "))
+	}
+	if m.Equivalence != nil {
+		if m.Reference == "" {
+			return fmt.Errorf("invalid %s: equivalence requires a reference", MetadataFile)
+		}
+		e := m.Equivalence
+		if e.Cycles < 0 || e.ResetCycles < 0 || e.ExhaustiveBits < 0 || e.FormalTimeout < 0 || e.FormalDepth < 0 {
+			return fmt.Errorf("invalid %s: equivalence cycles, reset_cycles, exhaustive_bits, formal_timeout and formal_depth must not be negative", MetadataFile)
+		}
+	}
+	if t := m.TruthTable; t != nil {
+		if TruthTableFormat(t.Table) == "" {
+			return fmt.Errorf("invalid %s: truth_table.table must be a .csv, .yaml or .yml file", MetadataFile)
+		}
+		if t.Delay < 0 {
+			return fmt.Errorf("invalid %s: truth_table.delay must not be negative", MetadataFile)
+		}
+	}
+	if s := m.SpecialJudge; s != nil {
+		if s.Source == "" {
+			return fmt.Errorf("invalid %s: special_judge.source is required", MetadataFile)
+		}
+		if s.SpecialJudgeLanguage() == "" {
+			return fmt.Errorf("invalid %s: special_judge.language is required when the source is not a .go or .v file", MetadataFile)
+		}
+		if s.TimeLimit < 0 {
+			return fmt.Errorf("invalid %s: special_judge.time_limit must not be negative", MetadataFile)
+		}
+	}
+	for i, spec := range m.TestCases {
+		if (spec.Testbench == "") == (spec.Trace == "") {
+			return fmt.Errorf("invalid %s: test case %d needs either a testbench or a trace", MetadataFile, i+1)
+		}
+		if spec.Trace != "" && spec.Expected != "" {
+			return fmt.Errorf("invalid %s: test case %d: a trace already contains the expected outputs", MetadataFile, i+1)
+		}
+		if spec.SimTime < 0 {
+			return fmt.Errorf("invalid %s: test case %d sim_time must not be negative", MetadataFile, i+1)
+		}
+	}
+	seen := make(map[string]bool, len(m.Attachments))
+	for _, name := range m.Attachments {
+		base := path.Base(name)
+		if seen[base] {
+			return fmt.Errorf("invalid %s: duplicate attachment name %q", MetadataFile, base)
+		}
+		seen[base] = true
+	}
+	return nil
+}
+
+// packageReader 读取题目包内的文件，并限制一次读取的总字节数
+type packageReader struct {
+	fsys      fs.FS
+	remaining *int64 // 剩余可读取的字节数，Discover 读取的各题目包共用
+}
+
+// readFile 读取题目包内的文件，路径必须位于题目包目录内
+// 文件声明的大小不可信（zip 文件头可以伪造），因此读取时同样限制字节数
+func (r *packageReader) readFile(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if !fs.ValidPath(cleaned) {
+		return "", fmt.Errorf("invalid path %q: must be relative to the problem directory", name)
+	}
+
+	file, err := r.fsys.Open(cleaned)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if info.Size() > MaxFileSize {
+		return "", fmt.Errorf("%s exceeds the %d byte file size limit", name, MaxFileSize)
+	}
+	if info.Size() > *r.remaining {
+		return "", fmt.Errorf("%s exceeds the %d byte total size limit of the problem package", name, MaxTotalSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if len(data) > MaxFileSize {
+		return "", fmt.Errorf("%s exceeds the %d byte file size limit", name, MaxFileSize)
+	}
+	if int64(len(data)) > *r.remaining {
+		return "", fmt.Errorf("%s exceeds the %d byte total size limit of the problem package", name, MaxTotalSize)
+	}
+	*r.remaining -= int64(len(data))
+	return string(data), nil
+}
+
+// JudgeRequest 使用题目包的测试用例、真值表和等价性检查构造判题请求
+func (p *Package) JudgeRequest(submissionID, code, language string) *protocol.JudgeRequest {
+	if language == "" {
+		language = p.Metadata.Language
+	}
+	if language == "" {
+		language = "verilog"
+	}
+
+	request := &protocol.JudgeRequest{
+		ProtocolVersion: protocol.Version,
+		SubmissionID:    submissionID,
+		Code:            code,
+		Language:        language,
+		TimeLimit:       p.Metadata.TimeLimit,
+		MemoryLimit:     p.Metadata.MemoryLimit,
+		TestCases:       []protocol.TestCase{},
+		TruthTable:      p.TruthTable,
+		Checker:         p.Metadata.Checker,
+		SpecialJudge:    p.SpecialJudge,
+	}
+	if p.Metadata.Equivalence != nil && p.Reference != "" {
+		request.Equivalence = p.Metadata.Equivalence.Protocol(p.Reference)
+	}
+	for i, testCase := range p.TestCases {
+		description := testCase.Description
+		if description == "" {
+			description = fmt.Sprintf("test case #%d", i+1)
+		}
+		protocolCase := protocol.TestCase{
+			Testbench:   testCase.Testbench,
+			ExpectedVCD: testCase.Expected,
+			Description: description,
+			SimTime:     testCase.SimTime,
+			Clock:       testCase.Clock,
+			Sample:      testCase.Sample,
+			Trace:       testCase.Trace,
+		}
+		if protocol.IsStdoutChecker(p.Metadata.Checker) {
+			protocolCase.ExpectedVCD, protocolCase.ExpectedOutput = "", testCase.Expected
+		}
+		request.TestCases = append(request.TestCases, protocolCase)
+	}
+	return request
+}
@@ -0,0 +1,161 @@
+package problem
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testMetadata 只有一个测试用例的 problem.yaml，测试用例引用 testbench 文件 times 次
+func testMetadata(testbench string, times int) string {
+	var b strings.Builder
+	b.WriteString("title: Adder\ntest_cases:\n")
+	for i := 0; i < times; i++ {
+		fmt.Fprintf(&b, "  - testbench: %s\n    expected: tests/1.expected\n", testbench)
+	}
+	return b.String()
+}
+
+func TestLoadFSSizeLimits(t *testing.T) {
+	small := []byte("module tb; endmodule\n")
+	large := bytes.Repeat([]byte{'a'}, MaxFileSize+1)
+	// 总大小刚好超过 MaxTotalSize 所需的引用次数
+	repeats := MaxTotalSize/(MaxFileSize-1) + 1
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string // 为空表示读取成功
+	}{
+		{
+			name: "within limits",
+			files: fstest.MapFS{
+				MetadataFile:       {Data: []byte(testMetadata("tests/1_tb.v", 1))},
+				"tests/1_tb.v":     {Data: small},
+				"tests/1.expected": {Data: []byte("s\n")},
+			},
+		},
+		{
+			name: "oversized metadata",
+			files: fstest.MapFS{
+				MetadataFile: {Data: append([]byte(testMetadata("tests/1_tb.v", 1)+"#"), large...)},
+			},
+			want: "problem.yaml exceeds the 16777216 byte file size limit",
+		},
+		{
+			name: "oversized testbench",
+			files: fstest.MapFS{
+				MetadataFile:       {Data: []byte(testMetadata("tests/1_tb.v", 1))},
+				"tests/1_tb.v":     {Data: large},
+				"tests/1.expected": {Data: []byte("s\n")},
+			},
+			want: "tests/1_tb.v exceeds the 16777216 byte file size limit",
+		},
+		{
+			name: "file referenced until the total limit",
+			files: fstest.MapFS{
+				MetadataFile:       {Data: []byte(testMetadata("tests/1_tb.v", repeats))},
+				"tests/1_tb.v":     {Data: large[:MaxFileSize-1]},
+				"tests/1.expected": {Data: []byte("s\n")},
+			},
+			want: "tests/1_tb.v exceeds the 268435456 byte total size limit",
+		},
+		{
+			name: "path outside the package",
+			files: fstest.MapFS{
+				MetadataFile: {Data: []byte(testMetadata("../tb.v", 1))},
+			},
+			want: `invalid path "../tb.v"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := LoadFS(tt.files)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("LoadFS() error = %v", err)
+				}
+				if len(pkg.TestCases) != 1 || pkg.TestCases[0].Testbench != string(small) {
+					t.Errorf("LoadFS() test cases = %+v", pkg.TestCases)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadFS() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// lyingFS 返回的文件信息声明的大小小于实际内容，模拟伪造了文件头的zip文件
+type lyingFS struct{ fstest.MapFS }
+
+type lyingFile struct{ fs.File }
+
+type lyingInfo struct{ fs.FileInfo }
+
+func (f lyingFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	return lyingFile{file}, err
+}
+
+func (f lyingFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	return lyingInfo{info}, err
+}
+
+func (lyingInfo) Size() int64 { return 1 }
+
+func TestLoadFSIgnoresDeclaredSize(t *testing.T) {
+	files := lyingFS{fstest.MapFS{
+		MetadataFile:       {Data: []byte(testMetadata("tests/1_tb.v", 1))},
+		"tests/1_tb.v":     {Data: bytes.Repeat([]byte{'a'}, MaxFileSize+1)},
+		"tests/1.expected": {Data: []byte("s\n")},
+	}}
+	if _, err := LoadFS(files); err == nil || !strings.Contains(err.Error(), "tests/1_tb.v exceeds the 16777216 byte file size limit") {
+		t.Errorf("LoadFS() error = %v, want the file size limit", err)
+	}
+}
+
+// TestLoadZipTotalSize 一个压缩包中的所有题目包共用读取上限
+func TestLoadZipTotalSize(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	// 每个题目包略小于 MaxFileSize，前 MaxTotalSize/MaxFileSize 个题目包可以读取
+	testbench := bytes.Repeat([]byte{'a'}, MaxFileSize-1024)
+	packages := MaxTotalSize/MaxFileSize + 1
+	for i := 1; i <= packages; i++ {
+		for name, content := range map[string][]byte{
+			MetadataFile:       []byte(testMetadata("tests/1_tb.v", 1)),
+			"tests/1_tb.v":     testbench,
+			"tests/1.expected": []byte("s\n"),
+		} {
+			if err := writeZipFile(zw, fmt.Sprintf("p%02d/%s", i, name), content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := LoadZip(archive.Bytes())
+	if err != nil {
+		t.Fatalf("LoadZip() error = %v", err)
+	}
+	if len(entries) != packages {
+		t.Fatalf("LoadZip() returned %d entries, want %d", len(entries), packages)
+	}
+	for i, entry := range entries[:packages-1] {
+		if entry.Err != nil {
+			t.Errorf("package %d error = %v", i+1, entry.Err)
+		}
+	}
+	if err := entries[packages-1].Err; err == nil || !strings.Contains(err.Error(), "total size limit") {
+		t.Errorf("last package error = %v, want the total size limit", err)
+	}
+}
@@ -0,0 +1,138 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"verilog-oj/protocol"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 真值表文件格式
+const (
+	TruthTableCSV  = "csv"
+	TruthTableYAML = "yaml"
+)
+
+// TruthTableSpec problem.yaml 中的真值表配置，字段含义见 protocol.TruthTable
+type TruthTableSpec struct {
+	TopModule string     `yaml:"top_module"`
+	Ports     []PortSpec `yaml:"ports"`
+	Table     string     `yaml:"table"` // 真值表文件，按扩展名 .csv、.yaml 或 .yml 解析
+	Delay     int        `yaml:"delay,omitempty"`
+}
+
+// PortSpec problem.yaml 中的端口声明
+type PortSpec struct {
+	Name      string `yaml:"name"`
+	Direction string `yaml:"direction"` // input 或 output
+	Width     int    `yaml:"width,omitempty"`
+}
+
+// TruthTableFormat 根据文件扩展名返回真值表格式，不支持的扩展名返回空字符串
+func TruthTableFormat(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return TruthTableCSV
+	case ".yaml", ".yml":
+		return TruthTableYAML
+	}
+	return ""
+}
+
+// ParseTruthTable 解析真值表文件，返回每一行端口名到取值的映射
+// CSV 的第一行为端口名，空单元格表示该行省略这一列，# 开头的行为注释；
+// YAML 为以端口名为键的映射列表
+func ParseTruthTable(format string, data []byte) ([]map[string]string, error) {
+	switch format {
+	case TruthTableCSV:
+		return parseTruthTableCSV(data)
+	case TruthTableYAML:
+		var rows []map[string]string
+		if err := yaml.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("invalid truth table: %v", err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unsupported truth table format %q, expected %s or %s", format, TruthTableCSV, TruthTableYAML)
+}
+
+// parseTruthTableCSV 解析 CSV 格式的真值表
+func parseTruthTableCSV(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid truth table: missing header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid truth table: %v", err)
+	}
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return nil, fmt.Errorf("invalid truth table: empty or duplicate column %q in header", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid truth table: %v", err)
+		}
+		row := make(map[string]string, len(record))
+		for i, value := range record {
+			if value = strings.TrimSpace(value); value != "" {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// WriteTruthTableCSV 以 CSV 格式写出真值表，列按端口声明顺序排列
+func WriteTruthTableCSV(w io.Writer, table *protocol.TruthTable) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(table.Ports))
+	for i, port := range table.Ports {
+		header[i] = port.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		record := make([]string, len(header))
+		for i, name := range header {
+			record[i] = row[name]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Protocol 转换为判题协议中的真值表
+func (t *TruthTableSpec) Protocol(rows []map[string]string) *protocol.TruthTable {
+	table := &protocol.TruthTable{TopModule: t.TopModule, Rows: rows, Delay: t.Delay}
+	for _, port := range t.Ports {
+		table.Ports = append(table.Ports, protocol.Port{Name: port.Name, Direction: port.Direction, Width: port.Width})
+	}
+	return table
+}
@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CapturedOutput 生成期望输出时参考设计的实际输出，见 JudgeRequest.CaptureOutputs
+type CapturedOutput struct {
+	// Expected testbench 测试用例的期望数据：Checker 为 CheckerVCD 时为完整VCD，比较标准输出或配置了检查程序时为标准输出
+	Expected string `json:"expected,omitempty"`
+	// Rows I/O 序列每个周期或真值表每一行的输出端口取值，格式见 CapturedValue
+	Rows []map[string]string `json:"rows,omitempty"`
+}
+
+// CapturedValue 将采样得到的二进制串转换为真值表和 I/O 序列中的取值，x、z 位表示为 x，即不检查该位
+func CapturedValue(bits string) string {
+	return "0b" + strings.Map(func(r rune) rune {
+		switch r {
+		case '0', '1':
+			return r
+		}
+		return 'x'
+	}, strings.ToLower(bits))
+}
+
+// WithOutputs 返回输出替换为 outputs 的 I/O 序列副本，outputs 的每一项对应一个周期，输入保持不变
+func (t *IOTrace) WithOutputs(outputs []map[string]string) (*IOTrace, error) {
+	cycles, err := replaceOutputs(t.Ports, t.Cycles, outputs)
+	if err != nil {
+		return nil, err
+	}
+	result := *t
+	result.Cycles = cycles
+	return &result, nil
+}
+
+// WithOutputs 返回输出替换为 outputs 的真值表副本，outputs 的每一项对应一行，输入保持不变
+func (t *TruthTable) WithOutputs(outputs []map[string]string) (*TruthTable, error) {
+	rows, err := replaceOutputs(t.Ports, t.Rows, outputs)
+	if err != nil {
+		return nil, err
+	}
+	result := *t
+	result.Rows = rows
+	return &result, nil
+}
+
+// replaceOutputs 复制每一行的输入，输出改为 outputs 中对应行的取值
+func replaceOutputs(ports []Port, rows, outputs []map[string]string) ([]map[string]string, error) {
+	if len(outputs) != len(rows) {
+		return nil, fmt.Errorf("captured %d row(s), expected %d", len(outputs), len(rows))
+	}
+	directions := make(map[string]string, len(ports))
+	for _, port := range ports {
+		directions[port.Name] = port.Direction
+	}
+
+	result := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		replaced := make(map[string]string, len(row))
+		for name, value := range row {
+			if directions[name] != PortOutput {
+				replaced[name] = value
+			}
+		}
+		for name, value := range outputs[i] {
+			if directions[name] != PortOutput {
+				return nil, fmt.Errorf("row %d: %q is not an output port", i+1, name)
+			}
+			replaced[name] = value
+		}
+		result[i] = replaced
+	}
+	return result, nil
+}
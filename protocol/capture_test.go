@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapturedValue(t *testing.T) {
+	tests := map[string]string{
+		"0":    "0b0",
+		"0101": "0b0101",
+		"1xz0": "0b1xx0",
+		"XZ":   "0bxx",
+	}
+	for bits, want := range tests {
+		if got := CapturedValue(bits); got != want {
+			t.Errorf("CapturedValue(%q) = %q, want %q", bits, got, want)
+		}
+	}
+
+	// 生成的取值必须能作为期望输出使用，x 位不检查
+	if bits, err := TruthTableBits(CapturedValue("1xz0"), 4, true); err != nil || bits != "1--0" {
+		t.Errorf("TruthTableBits(CapturedValue) = %q, %v", bits, err)
+	}
+}
+
+func TestTruthTableWithOutputs(t *testing.T) {
+	table := validTruthTable()
+	outputs := []map[string]string{
+		{"sum": "0b00101", "zero": "0b0"},
+		{"sum": "0b00000", "zero": "0b1"},
+		{"sum": "0b10000", "zero": "0b0"},
+	}
+
+	replaced, err := table.WithOutputs(outputs)
+	if err != nil {
+		t.Fatalf("WithOutputs() error = %v", err)
+	}
+	want := []map[string]string{
+		{"a": "3", "b": "0x2", "sum": "0b00101", "zero": "0b0"},
+		{"a": "0", "b": "0", "sum": "0b00000", "zero": "0b1"},
+		{"a": "15", "b": "1", "sum": "0b10000", "zero": "0b0"},
+	}
+	if !reflect.DeepEqual(replaced.Rows, want) {
+		t.Errorf("WithOutputs() rows = %v, want %v", replaced.Rows, want)
+	}
+	if table.Rows[2]["sum"] != "0b1_00??" {
+		t.Error("WithOutputs() modified the original table")
+	}
+	if err := replaced.Validate(); err != nil {
+		t.Errorf("Validate() after WithOutputs() error = %v", err)
+	}
+
+	if _, err := table.WithOutputs(outputs[:2]); err == nil || !strings.Contains(err.Error(), "captured 2 row(s), expected 3") {
+		t.Errorf("WithOutputs() with missing rows error = %v", err)
+	}
+	outputs[0] = map[string]string{"a": "1"}
+	if _, err := table.WithOutputs(outputs); err == nil || !strings.Contains(err.Error(), "not an output") {
+		t.Errorf("WithOutputs() with an input error = %v", err)
+	}
+}
+
+func TestIOTraceWithOutputs(t *testing.T) {
+	trace := validIOTrace()
+	outputs := []map[string]string{
+		{"state": "0b01", "found": "0b0"},
+		{"state": "0b10", "found": "0b0"},
+		{"state": "0b00", "found": "0b1"},
+		{"state": "0b00", "found": "0bx"},
+	}
+
+	replaced, err := trace.WithOutputs(outputs)
+	if err != nil {
+		t.Fatalf("WithOutputs() error = %v", err)
+	}
+	if got := replaced.Cycles[1]; !reflect.DeepEqual(got, map[string]string{"din": "0", "state": "0b10", "found": "0b0"}) {
+		t.Errorf("WithOutputs() cycle 2 = %v", got)
+	}
+	if got := replaced.Cycles[3]; got["rst_n"] != "0" || got["found"] != "0bx" {
+		t.Errorf("WithOutputs() cycle 4 = %v", got)
+	}
+	if err := replaced.Validate(); err != nil {
+		t.Errorf("Validate() after WithOutputs() error = %v", err)
+	}
+}
+
+func TestResultCaptured(t *testing.T) {
+	result := &JudgeResult{
+		SubmissionID: "capture-1",
+		Status:       StatusAccepted,
+		Score:        100,
+		PassedTests:  2,
+		TotalTests:   2,
+		JudgedAt:     time.Now(),
+		Cases: []CaseResult{
+			{Index: 1, Status: StatusAccepted, Captured: &CapturedOutput{Expected: "sum = 5\n"}},
+			{Index: 2, Status: StatusAccepted, Captured: &CapturedOutput{Rows: []map[string]string{{"sum": "0b00101"}}}},
+		},
+	}
+
+	data, err := EncodeResult(result)
+	if err != nil {
+		t.Fatalf("EncodeResult() error = %v", err)
+	}
+	decoded, err := DecodeResult(data)
+	if err != nil {
+		t.Fatalf("DecodeResult() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Cases[0].Captured, result.Cases[0].Captured) || !reflect.DeepEqual(decoded.Cases[1].Captured, result.Cases[1].Captured) {
+		t.Errorf("unexpected decoded captured outputs: %+v, %+v", decoded.Cases[0].Captured, decoded.Cases[1].Captured)
+	}
+
+	request := validRequest()
+	request.CaptureOutputs = true
+	data, err = EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	if decodedRequest, err := DecodeRequest(data); err != nil || !decodedRequest.CaptureOutputs {
+		t.Errorf("DecodeRequest() capture_outputs lost, err = %v", err)
+	}
+}
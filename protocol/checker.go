@@ -0,0 +1,32 @@
+package protocol
+
+// 输出比较方式
+// CheckerVCD 比较 testbench 输出的VCD，其余比较方式比较 testbench 的标准输出（$display 等）与 ExpectedOutput
+const (
+	CheckerVCD        = "vcd"        // 按期望VCD或VCD匹配模式比较波形，默认值
+	CheckerExact      = "exact"      // 逐字节相同，只忽略换行符的差异和末尾的空行
+	CheckerWhitespace = "whitespace" // 按空白分隔的记号逐个比较，忽略空白的数量和种类
+	CheckerLines      = "lines"      // 去掉首尾空白后的行作为多重集合比较，忽略行的顺序和空行
+	CheckerNumeric    = "numeric"    // 按记号比较，数值记号按数值比较，十进制、十六进制和二进制的同一数值视为相同
+)
+
+// Checkers 全部输出比较方式
+var Checkers = []string{CheckerVCD, CheckerExact, CheckerWhitespace, CheckerLines, CheckerNumeric}
+
+// ValidChecker 判断比较方式是否有效，空字符串表示默认的 CheckerVCD
+func ValidChecker(checker string) bool {
+	if checker == "" {
+		return true
+	}
+	for _, c := range Checkers {
+		if c == checker {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStdoutChecker 判断比较方式是否比较标准输出
+func IsStdoutChecker(checker string) bool {
+	return checker != "" && checker != CheckerVCD && ValidChecker(checker)
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import "testing"
+
+func TestValidChecker(t *testing.T) {
+	tests := []struct {
+		checker string
+		valid   bool
+		stdout  bool
+	}{
+		{checker: "", valid: true},
+		{checker: CheckerVCD, valid: true},
+		{checker: CheckerExact, valid: true, stdout: true},
+		{checker: CheckerWhitespace, valid: true, stdout: true},
+		{checker: CheckerLines, valid: true, stdout: true},
+		{checker: CheckerNumeric, valid: true, stdout: true},
+		{checker: "regex"},
+	}
+
+	for _, tt := range tests {
+		if got := ValidChecker(tt.checker); got != tt.valid {
+			t.Errorf("ValidChecker(%q) = %t, want %t", tt.checker, got, tt.valid)
+		}
+		if got := IsStdoutChecker(tt.checker); got != tt.stdout {
+			t.Errorf("IsStdoutChecker(%q) = %t, want %t", tt.checker, got, tt.stdout)
+		}
+	}
+}
+
+func TestRequestChecker(t *testing.T) {
+	request := validRequest()
+	request.Checker = CheckerNumeric
+	request.TestCases[0].ExpectedVCD = ""
+	request.TestCases[0].ExpectedOutput = "sum = 0x1f\n"
+
+	data, err := EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	if decoded.Checker != CheckerNumeric || decoded.TestCases[0].ExpectedOutput != "sum = 0x1f\n" {
+		t.Errorf("unexpected decoded request: %+v", decoded)
+	}
+
+	request.Checker = "regex"
+	if _, err := EncodeRequest(request); err == nil {
+		t.Error("EncodeRequest() accepted an unknown checker")
+	}
+}
@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReservedNamePrefix 判题服务生成的 testbench 中模块、实例和变量名使用的前缀
+// 题目中的模块名和端口名不能以它开头，避免与生成的名称冲突
+const ReservedNamePrefix = "__oj_"
+
+// identifierRegex 模块名和端口名必须是Verilog简单标识符
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// verilogKeywords Verilog-2005 和 SystemVerilog（IEEE 1800-2017）的保留关键字
+var verilogKeywords = makeSet(strings.Fields(`
+	accept_on alias always always_comb always_ff always_latch and assert assign assume automatic
+	before begin bind bins binsof bit break buf bufif0 bufif1 byte
+	case casex casez cell chandle checker class clocking cmos config const constraint context continue
+	cover covergroup coverpoint cross deassign default defparam design disable dist do
+	edge else end endcase endchecker endclass endclocking endconfig endfunction endgenerate endgroup
+	endinterface endmodule endpackage endprimitive endprogram endproperty endsequence endspecify
+	endtable endtask enum event eventually expect export extends extern
+	final first_match for force foreach forever fork forkjoin function
+	generate genvar global highz0 highz1 if iff ifnone ignore_bins illegal_bins implements implies
+	import incdir include initial inout input inside instance int integer interconnect interface
+	intersect join join_any join_none large let liblist library local localparam logic longint
+	macromodule matches medium modport module nand negedge nettype new nexttime nmos nor
+	noshowcancelled not notif0 notif1 null or output package packed parameter pmos posedge primitive
+	priority program property protected pull0 pull1 pulldown pullup pulsestyle_ondetect
+	pulsestyle_onevent pure rand randc randcase randsequence rcmos real realtime ref reg
+	reject_on release repeat restrict return rnmos rpmos rtran rtranif0 rtranif1
+	s_always s_eventually s_nexttime s_until s_until_with scalared sequence shortint shortreal
+	showcancelled signed small soft solve specify specparam static string strong strong0 strong1
+	struct super supply0 supply1 sync_accept_on sync_reject_on table tagged task this throughout time
+	timeprecision timeunit tran tranif0 tranif1 tri tri0 tri1 triand trior trireg type typedef union
+	unique unique0 unsigned until until_with untyped use uwire var vectored virtual void wait
+	wait_order wand weak weak0 weak1 while wildcard wire with within wor xnor xor
+`))
+
+// makeSet 返回由 items 组成的集合
+func makeSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// checkIdentifier 校验题目中的模块名或端口名：必须是简单标识符，不能是关键字，也不能使用 ReservedNamePrefix
+func checkIdentifier(name string) error {
+	switch {
+	case !identifierRegex.MatchString(name):
+		return fmt.Errorf("not a simple Verilog identifier")
+	case verilogKeywords[name]:
+		return fmt.Errorf("%s is a Verilog keyword", name)
+	case strings.HasPrefix(name, ReservedNamePrefix):
+		return fmt.Errorf("names starting with %s are reserved for the judge", ReservedNamePrefix)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package protocol
+
+import "fmt"
+
+// IOTrace 逐周期的输入输出序列，用于时序电路的测试用例
+// 判题服务根据端口列表生成带时钟的 testbench，每个周期开始时施加输入，在采样沿之前采样输出并与期望值比较
+type IOTrace struct {
+	TopModule      string              `json:"top_module"`                 // 被测模块名
+	Ports          []Port              `json:"ports"`                      // 被测模块的全部端口，包括时钟和复位
+	Clock          string              `json:"clock"`                      // 时钟输入名，由 testbench 驱动
+	Reset          string              `json:"reset,omitempty"`            // 复位输入名，省略表示不复位
+	ResetActiveLow bool                `json:"reset_active_low,omitempty"` // 复位低电平有效
+	ResetCycles    int                 `json:"reset_cycles,omitempty"`     // 序列开始前复位保持的周期数，省略时为 1
+	Period         int                 `json:"period,omitempty"`           // 时钟周期（纳秒，偶数），省略时为 DefaultTracePeriod
+	SampleEdge     string              `json:"sample_edge,omitempty"`      // SamplePosedge 或 SampleNegedge，省略时为 SampleNegedge
+	Cycles         []map[string]string `json:"cycles"`                     // 每个周期的端口名 -> 取值，取值格式见 TruthTableBits
+}
+
+// 输出采样沿
+// 每个周期从时钟下降沿开始：施加输入，半个周期后为上升沿，再过半个周期为下一个下降沿。
+// SamplePosedge 在上升沿之前采样，输出反映当前状态和本周期的输入；
+// SampleNegedge 在下一个下降沿之前采样，输出反映上升沿更新后的状态
+const (
+	SamplePosedge = "posedge"
+	SampleNegedge = "negedge"
+)
+
+// I/O 序列限制
+const (
+	DefaultTracePeriod  = 10
+	MaxTraceCycles      = 65536
+	MaxTraceResetCycles = 1000
+)
+
+// TracePeriod 返回时钟周期，未指定时为 DefaultTracePeriod
+func (t *IOTrace) TracePeriod() int {
+	if t.Period <= 0 {
+		return DefaultTracePeriod
+	}
+	return t.Period
+}
+
+// TraceResetCycles 返回序列开始前复位保持的周期数，没有复位时为 0
+func (t *IOTrace) TraceResetCycles() int {
+	if t.Reset == "" {
+		return 0
+	}
+	if t.ResetCycles <= 0 {
+		return 1
+	}
+	return t.ResetCycles
+}
+
+// Validate 校验端口声明、时钟和复位，以及每个周期的列和取值
+// 时钟不能出现在周期中；复位可以出现，省略时为无效电平；其他输入省略时保持上一周期的取值，
+// 第一个周期必须给出全部其他输入；输出省略表示该周期不检查
+func (t *IOTrace) Validate() error {
+	if err := checkIdentifier(t.TopModule); err != nil {
+		return fmt.Errorf("invalid top module name %q: %v", t.TopModule, err)
+	}
+	if t.Period < 0 || t.Period%2 != 0 {
+		return fmt.Errorf("period must be a positive even number of nanoseconds, got %d", t.Period)
+	}
+	if t.ResetCycles < 0 || t.ResetCycles > MaxTraceResetCycles {
+		return fmt.Errorf("reset_cycles must be between 0 and %d, got %d", MaxTraceResetCycles, t.ResetCycles)
+	}
+	switch t.SampleEdge {
+	case "", SamplePosedge, SampleNegedge:
+	default:
+		return fmt.Errorf("sample_edge must be %s or %s, got %q", SamplePosedge, SampleNegedge, t.SampleEdge)
+	}
+
+	ports, err := validatePorts(t.Ports)
+	if err != nil {
+		return err
+	}
+	for _, signal := range []struct{ role, name string }{{"clock", t.Clock}, {"reset", t.Reset}} {
+		if signal.name == "" && signal.role == "reset" {
+			continue
+		}
+		port, ok := ports[signal.name]
+		if !ok || port.Direction != PortInput || port.PortWidth() != 1 {
+			return fmt.Errorf("%s %q must be a declared 1-bit input", signal.role, signal.name)
+		}
+	}
+	if t.Clock == t.Reset {
+		return fmt.Errorf("clock and reset must be different ports")
+	}
+
+	if len(t.Cycles) == 0 {
+		return fmt.Errorf("at least one cycle is required")
+	}
+	if len(t.Cycles) > MaxTraceCycles {
+		return fmt.Errorf("at most %d cycles are allowed, got %d", MaxTraceCycles, len(t.Cycles))
+	}
+	for i, cycle := range t.Cycles {
+		if _, ok := cycle[t.Clock]; ok {
+			return fmt.Errorf("cycle %d: clock %s is driven by the testbench and must not be set", i+1, t.Clock)
+		}
+		for name, value := range cycle {
+			port, ok := ports[name]
+			if !ok {
+				return fmt.Errorf("cycle %d: column %s is not a declared port", i+1, name)
+			}
+			if _, err := TruthTableBits(value, port.PortWidth(), port.Direction == PortOutput); err != nil {
+				return fmt.Errorf("cycle %d: %s: %v", i+1, name, err)
+			}
+		}
+	}
+	for _, port := range t.Ports {
+		if port.Direction != PortInput || port.Name == t.Clock || port.Name == t.Reset {
+			continue
+		}
+		if _, ok := t.Cycles[0][port.Name]; !ok {
+			return fmt.Errorf("cycle 1: missing input %s", port.Name)
+		}
+	}
+	return nil
+}
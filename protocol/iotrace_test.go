@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func validIOTrace() *IOTrace {
+	return &IOTrace{
+		TopModule: "detector",
+		Ports: []Port{
+			{Name: "clk", Direction: PortInput},
+			{Name: "rst_n", Direction: PortInput},
+			{Name: "din", Direction: PortInput},
+			{Name: "state", Direction: PortOutput, Width: 2},
+			{Name: "found", Direction: PortOutput},
+		},
+		Clock:          "clk",
+		Reset:          "rst_n",
+		ResetActiveLow: true,
+		SampleEdge:     SampleNegedge,
+		Cycles: []map[string]string{
+			{"din": "1", "state": "1", "found": "0"},
+			{"din": "0", "state": "0b1x"},
+			{"state": "0", "found": "1"},
+			{"rst_n": "0", "found": "-"},
+		},
+	}
+}
+
+func TestIOTraceValidate(t *testing.T) {
+	if err := validIOTrace().Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(trace *IOTrace)
+		wantErr string
+	}{
+		{name: "时钟未声明", modify: func(trace *IOTrace) { trace.Clock = "clock" }, wantErr: `clock "clock" must be a declared 1-bit input`},
+		{name: "复位是输出", modify: func(trace *IOTrace) { trace.Reset = "found" }, wantErr: `reset "found" must be a declared 1-bit input`},
+		{name: "周期中设置时钟", modify: func(trace *IOTrace) { trace.Cycles[1]["clk"] = "1" }, wantErr: "cycle 2: clock clk is driven by the testbench"},
+		{name: "第一个周期缺少输入", modify: func(trace *IOTrace) { delete(trace.Cycles[0], "din") }, wantErr: "cycle 1: missing input din"},
+		{name: "未声明的列", modify: func(trace *IOTrace) { trace.Cycles[2]["dout"] = "1" }, wantErr: "cycle 3: column dout is not a declared port"},
+		{name: "奇数周期", modify: func(trace *IOTrace) { trace.Period = 5 }, wantErr: "even number"},
+		{name: "非法采样沿", modify: func(trace *IOTrace) { trace.SampleEdge = "both" }, wantErr: "sample_edge"},
+		{name: "没有周期", modify: func(trace *IOTrace) { trace.Cycles = nil }, wantErr: "at least one cycle"},
+		{name: "模块名使用保留前缀", modify: func(trace *IOTrace) { trace.TopModule = ReservedNamePrefix + "trace_tb" }, wantErr: "reserved for the judge"},
+		{name: "端口名是关键字", modify: func(trace *IOTrace) { trace.Ports[len(trace.Ports)-1].Name = "output" }, wantErr: "output is a Verilog keyword"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := validIOTrace()
+			tt.modify(trace)
+			err := trace.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestIOTrace(t *testing.T) {
+	request := validRequest()
+	request.TestCases = append(request.TestCases, TestCase{Description: "trace", Trace: validIOTrace()})
+
+	data, err := EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	trace := decoded.TestCases[len(decoded.TestCases)-1].Trace
+	if trace == nil || trace.Clock != "clk" || len(trace.Cycles) != 4 || trace.Cycles[1]["state"] != "0b1x" {
+		t.Errorf("unexpected decoded trace: %+v", trace)
+	}
+
+	// 没有序列的测试用例仍然需要 testbench
+	request.TestCases = append(request.TestCases, TestCase{Description: "empty"})
+	if _, err := EncodeRequest(request); err == nil {
+		t.Error("EncodeRequest() accepted a test case without testbench or trace")
+	}
+}
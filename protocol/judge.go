@@ -0,0 +1,320 @@
+// Package protocol 定义后端与判题服务之间共享的判题协议
+//
+// 后端负责构造 JudgeRequest 并推送到队列，判题服务消费请求并发布 JudgeResult，
+// 判题过程中的进度以 JudgeEvent 写入每个提交的事件日志。
+// 两端都使用本包中的结构体和 JSON Schema 进行编解码和校验，保证字段语义一致。
+package protocol
+
+import "time"
+
+// Version 当前判题协议版本
+const Version = 1
+
+// SupportedVersions 当前代码能够处理的协议版本
+var SupportedVersions = []int{Version}
+
+// 判题状态常量
+const (
+	StatusAccepted            = "accepted"
+	StatusWrongAnswer         = "wrong_answer"
+	StatusTimeLimitExceeded   = "time_limit_exceeded"
+	StatusMemoryLimitExceeded = "memory_limit_exceeded"
+	StatusOutputLimitExceeded = "output_limit_exceeded" // 输出、VCD文件或工作目录超出大小限制
+	StatusSimTimeExceeded     = "sim_time_exceeded"     // 仿真时间达到预算时 testbench 仍未结束
+	StatusRuntimeError        = "runtime_error"
+	StatusCompileError        = "compile_error"
+	StatusSystemError         = "system_error"
+	StatusCancelled           = "cancelled" // 提交者或管理员取消了判题
+)
+
+// JudgeRequest 判题请求结构
+type JudgeRequest struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	SubmissionID    string       `json:"submission_id"`
+	Code            string       `json:"code"`
+	Language        string       `json:"language"`
+	TimeLimit       int          `json:"time_limit"`   // 毫秒
+	MemoryLimit     int          `json:"memory_limit"` // MB
+	TestCases       []TestCase   `json:"test_cases"`
+	Equivalence     *Equivalence `json:"equivalence,omitempty"` // 与参考设计的等价性检查，省略时只运行测试用例
+	TruthTable      *TruthTable  `json:"truth_table,omitempty"` // 真值表测试，在测试用例之后、等价性检查之前运行
+	Checker         string       `json:"checker,omitempty"`     // 测试用例的输出比较方式，省略时为 CheckerVCD，对 I/O 序列测试用例不起作用
+	// SpecialJudge 出题人提供的检查程序，配置后代替 Checker 判定测试用例的输出，对 I/O 序列测试用例不起作用
+	SpecialJudge *SpecialJudge `json:"special_judge,omitempty"`
+	// CaptureOutputs 运行参考设计生成期望输出：不比较测试用例和真值表的输出，在每个结果的 Captured 中返回实际输出，
+	// 不运行等价性检查和检查程序
+	CaptureOutputs bool `json:"capture_outputs,omitempty"`
+}
+
+// TestCase Verilog测试用例结构
+type TestCase struct {
+	Testbench   string `json:"testbench"`    // testbench代码
+	ExpectedVCD string `json:"expected_vcd"` // 期望的VCD文件内容或关键信号值
+	// ExpectedOutput 期望的 testbench 标准输出，请求的 Checker 比较标准输出时使用，此时 ExpectedVCD 为空
+	ExpectedOutput string `json:"expected_output,omitempty"`
+	Description    string `json:"description,omitempty"` // 测试用例描述
+	SimTime        int    `json:"sim_time,omitempty"`    // 仿真时间预算（纳秒），省略时使用判题服务默认值
+	Clock          string `json:"clock,omitempty"`       // 统计时钟周期数的信号名，省略时为 DefaultClock
+	Sample         bool   `json:"sample,omitempty"`      // 样例测试用例，波形比较报告完整返回，其他测试用例只返回第一处不一致
+
+	// Trace 逐周期的输入输出序列，提供时 Testbench 和 ExpectedVCD 为空，由判题服务生成 testbench 并按序列比较输出
+	Trace *IOTrace `json:"trace,omitempty"`
+}
+
+// DefaultClock 测试用例未指定时统计时钟周期数使用的信号名
+const DefaultClock = "clk"
+
+// Equivalence 随机激励等价性检查配置
+// 判题服务自动生成同时例化参考设计和提交设计的testbench，以相同激励驱动并逐周期比较全部输出
+type Equivalence struct {
+	Reference      string            `json:"reference"`                  // 参考设计代码
+	TopModule      string            `json:"top_module,omitempty"`       // 顶层模块名，省略时使用参考设计中的第一个模块
+	Seed           int               `json:"seed,omitempty"`             // 随机种子
+	Cycles         int               `json:"cycles,omitempty"`           // 随机激励周期数，省略时为 DefaultEquivalenceCycles
+	Clock          string            `json:"clock,omitempty"`            // 时钟输入名，省略表示组合逻辑
+	Reset          string            `json:"reset,omitempty"`            // 复位输入名
+	ResetActiveLow bool              `json:"reset_active_low,omitempty"` // 复位低电平有效
+	ResetCycles    int               `json:"reset_cycles,omitempty"`     // 复位保持的时钟周期数，省略时为 1
+	Hold           map[string]uint64 `json:"hold,omitempty"`             // 保持固定取值的输入
+	ExhaustiveBits int               `json:"exhaustive_bits,omitempty"`  // 激励总位宽不超过该值时穷举全部输入组合，省略时为 DefaultExhaustiveBits
+
+	// Formal 先用 Yosys 形式化证明等价，证明成功直接通过，找到反例直接判错
+	// 超时或无法证明时回退到随机激励仿真
+	Formal        bool `json:"formal,omitempty"`
+	FormalTimeout int  `json:"formal_timeout,omitempty"` // 毫秒，省略时使用判题服务默认值
+	FormalDepth   int  `json:"formal_depth,omitempty"`   // 时序电路归纳证明的最大步数，省略时为 DefaultFormalDepth
+}
+
+// 等价性检查默认值
+const (
+	DefaultEquivalenceCycles = 1000
+	DefaultExhaustiveBits    = 16
+	DefaultFormalDepth       = 20
+)
+
+// JudgeResult 判题结果结构
+type JudgeResult struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	SubmissionID    string       `json:"submission_id"`
+	Status          string       `json:"status"`
+	Score           int          `json:"score"`
+	RunTime         int          `json:"run_time"` // 毫秒
+	Memory          int          `json:"memory"`   // KB
+	ErrorMessage    string       `json:"error_message"`
+	PassedTests     int          `json:"passed_tests"`
+	TotalTests      int          `json:"total_tests"`
+	JudgedAt        time.Time    `json:"judged_at"`
+	Cases           []CaseResult `json:"cases,omitempty"`  // 每个测试用例的结果，编译失败等情况下为空
+	Cached          bool         `json:"cached,omitempty"` // 结果来自相同代码、测试数据和判题环境的缓存，没有重新仿真
+}
+
+// CaseResult 单个测试用例的判题结果
+type CaseResult struct {
+	Index        int    `json:"index"` // 从1开始
+	Description  string `json:"description,omitempty"`
+	Status       string `json:"status"`
+	RunTime      int    `json:"run_time"` // 毫秒
+	Memory       int    `json:"memory"`   // KB
+	ErrorMessage string `json:"error_message,omitempty"`
+	SimTime      int64  `json:"sim_time,omitempty"` // 结束时的仿真时间（纳秒）
+	Cycles       int    `json:"cycles,omitempty"`   // VCD中时钟信号的上升沿数
+	// Score 检查程序给出的部分分（0-100），只在检查程序判为答案错误时出现
+	Score int `json:"score,omitempty"`
+	// Row 真值表测试中第一个输出不符合的行号，或 I/O 序列中第一个输出不符合的周期，从1开始
+	Row int `json:"row,omitempty"`
+	// Mismatch 期望输出为完整VCD且波形不一致时的比较报告
+	Mismatch *WaveformDiff `json:"mismatch,omitempty"`
+	// Captured 请求设置了 CaptureOutputs 且仿真正常结束时的实际输出
+	Captured *CapturedOutput `json:"captured,omitempty"`
+}
+
+// WaveformDiff 输出波形与期望波形的比较报告
+type WaveformDiff struct {
+	Signals      []SignalMismatch `json:"signals"`             // 按第一次不一致的时间排序，最多 MismatchSignals 个
+	TotalSignals int              `json:"total_signals"`       // 不一致的信号总数
+	Truncated    bool             `json:"truncated,omitempty"` // 非样例测试用例只保留第一个信号且不含前后取值
+}
+
+// SignalMismatch 单个信号第一次与期望波形不一致的位置
+type SignalMismatch struct {
+	Signal   string           `json:"signal"`           // 层次化信号名，例如 tb.sum
+	Time     int64            `json:"time"`             // 第一次不一致的仿真时间（纳秒）
+	Expected string           `json:"expected"`         // 期望取值，标量为 0/1/x/z，向量为 b 开头的二进制，实数为 r 开头
+	Actual   string           `json:"actual"`           // 实际取值，格式同 Expected，输出中没有该信号时为空
+	Window   []WaveformSample `json:"window,omitempty"` // 不一致前后若干个变化时刻的取值
+}
+
+// WaveformSample 某一时刻信号的期望取值和实际取值
+type WaveformSample struct {
+	Time     int64  `json:"time"` // 纳秒
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// 波形比较报告的大小
+const (
+	MismatchSignals = 5 // 最多报告的不一致信号数
+	MismatchWindow  = 3 // 不一致时刻前后各报告的变化时刻数
+)
+
+// 判题进度事件类型
+const (
+	EventQueued       = "queued"        // 已推送到判题队列
+	EventCompiling    = "compiling"     // 开始编译
+	EventRunning      = "running"       // 开始运行第 case_index 个测试用例
+	EventCaseFinished = "case_finished" // 第 case_index 个测试用例判题完成
+	EventFinished     = "finished"      // 判题结束，result 为最终结果
+)
+
+// JudgeEvent 判题进度事件
+type JudgeEvent struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	SubmissionID    string       `json:"submission_id"`
+	Type            string       `json:"type"`
+	CaseIndex       int          `json:"case_index,omitempty"`  // 从1开始
+	TotalCases      int          `json:"total_cases,omitempty"` // running 和 case_finished 事件中为测试用例总数
+	Case            *CaseResult  `json:"case,omitempty"`        // case_finished 事件的测试用例结果
+	Result          *JudgeResult `json:"result,omitempty"`      // finished 事件的最终结果
+	Time            time.Time    `json:"time"`
+
+	// ID 事件在事件日志中的位置，由队列实现读取时填充，不参与序列化
+	// 客户端断线重连时据此补发之后的事件
+	ID string `json:"-"`
+}
+
+// NewEvent 构造当前时间的进度事件
+func NewEvent(submissionID, eventType string) *JudgeEvent {
+	return &JudgeEvent{
+		ProtocolVersion: Version,
+		SubmissionID:    submissionID,
+		Type:            eventType,
+		Time:            time.Now(),
+	}
+}
+
+// NewRejectedResult 为无法处理的判题请求构造系统错误结果
+func NewRejectedResult(submissionID string, reason error) *JudgeResult {
+	return &JudgeResult{
+		ProtocolVersion: Version,
+		SubmissionID:    submissionID,
+		Status:          StatusSystemError,
+		ErrorMessage:    reason.Error(),
+		JudgedAt:        time.Now(),
+	}
+}
+
+// NewCancelledResult 为被取消的判题任务构造结果
+func NewCancelledResult(submissionID string) *JudgeResult {
+	return &JudgeResult{
+		ProtocolVersion: Version,
+		SubmissionID:    submissionID,
+		Status:          StatusCancelled,
+		ErrorMessage:    "judging was cancelled",
+		JudgedAt:        time.Now(),
+	}
+}
+
+// 判题结果投递
+const (
+	// ResultStream 判题结果的 Redis Stream，判题服务在 result 字段写入 JudgeResult 的JSON，
+	// 后端以消费组读取，写回提交记录后才确认，后端重启期间产生的结果不会丢失
+	ResultStream = "judge_results"
+	// ResultStreamMaxLen ResultStream 大约保留的结果数
+	ResultStreamMaxLen = 10000
+)
+
+// 取消判题
+const (
+	// CancelChannel 取消通知的 Redis 频道，消息为被取消的提交ID，判题服务据此终止正在进行的判题
+	CancelChannel = "judge_cancel"
+	// CancelTTL 取消标记的保留时间，覆盖任务已被取出但还未开始判题的情况
+	CancelTTL = 24 * time.Hour
+)
+
+// CancelKey 返回提交的取消标记键，格式为 judge_cancel:<submission_id>
+func CancelKey(submissionID string) string {
+	return CancelChannel + ":" + submissionID
+}
+
+// 判题节点注册表
+const (
+	// NodeRegistryKey 判题节点发布能力的 Redis 哈希键，字段为节点标识，值为 Capabilities 的JSON
+	NodeRegistryKey = "judge_nodes"
+	// NodeHeartbeatInterval 判题节点刷新能力的间隔
+	NodeHeartbeatInterval = 30 * time.Second
+	// NodeTTL 超过该时间未刷新的节点视为下线
+	NodeTTL = 3 * NodeHeartbeatInterval
+)
+
+// Capabilities 判题节点启动自检后发布的能力
+type Capabilities struct {
+	ProtocolVersion int               `json:"protocol_version"`
+	Node            string            `json:"node"`                  // 节点标识，主机名加进程号
+	Languages       []string          `json:"languages"`             // 通过自检的语言
+	Checkers        []string          `json:"checkers,omitempty"`    // 通过自检的检查程序语言，见 SpecialJudgeLanguages
+	Formal          bool              `json:"formal"`                // Yosys 形式化等价证明可用
+	Sandbox         bool              `json:"sandbox"`               // 仿真器在命名空间沙箱中运行
+	Concurrency     int               `json:"concurrency,omitempty"` // 当前的并发判题数
+	Versions        map[string]string `json:"versions"`              // 工具名 -> 版本，例如 iverilog -> 12.0
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// Supports 返回节点是否通过了该语言的自检
+func (c *Capabilities) Supports(language string) bool {
+	for _, supported := range c.Languages {
+		if supported == language {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsChecker 返回节点是否能编译和运行该语言的检查程序
+func (c *Capabilities) SupportsChecker(language string) bool {
+	for _, supported := range c.Checkers {
+		if supported == language {
+			return true
+		}
+	}
+	return false
+}
+
+// Alive 返回节点是否在 NodeTTL 内刷新过能力
+func (c *Capabilities) Alive(now time.Time) bool {
+	return now.Sub(c.UpdatedAt) <= NodeTTL
+}
+
+// 判题耗时记录
+const (
+	// JudgeDurationsKey 最近判题任务耗时（毫秒）的 Redis 列表，判题节点完成任务后追加，后端据此估算排队时间
+	JudgeDurationsKey = "judge_durations"
+	// JudgeDurationSamples JudgeDurationsKey 保留的记录数
+	JudgeDurationSamples = 100
+)
+
+// LanguageLane 只由通过该语言自检的判题节点消费的队列通道
+func LanguageLane(language string) string {
+	return "lang-" + language
+}
+
+// CheckerLane 只由通过该语言和该检查程序语言自检的判题节点消费的队列通道
+func CheckerLane(language, checker string) string {
+	return LanguageLane(language) + "-checker-" + checker
+}
+
+// RequestLane 返回判题请求应推送到的通道：运行检查程序的请求推送到检查程序通道，其他请求推送到语言通道
+func RequestLane(req *JudgeRequest) string {
+	if checker := req.CheckerLanguage(); checker != "" {
+		return CheckerLane(req.Language, checker)
+	}
+	return LanguageLane(req.Language)
+}
+
+// CheckerLanguage 返回判题时需要运行的检查程序语言，不运行检查程序时返回空字符串
+// 生成期望输出和没有测试用例的请求不运行检查程序
+func (req *JudgeRequest) CheckerLanguage() string {
+	if req.SpecialJudge == nil || req.CaptureOutputs || len(req.TestCases) == 0 {
+		return ""
+	}
+	return req.SpecialJudge.Language
+}
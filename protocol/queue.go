@@ -0,0 +1,42 @@
+package protocol
+
+// 等待索引
+//
+// 每个队列通道有一个等待索引：有序集合，成员为提交ID，分数为入队序号。推送任务时用 EnqueueScript
+// 在同一个脚本中写入索引和列表，判题节点从列表尾部按入队顺序取出任务，不需要修改索引，
+// 因此列表中的任务总是索引中分数最大的 LLEN 个，分数更小的成员是已被取出的任务。
+// 查询排队位置只需要 ZREVRANK 和 LLEN，不必读取和解码整个列表
+const (
+	// QueueSeqKey 入队序号计数器，所有通道共用
+	QueueSeqKey = "judge_queue_seq"
+	// queueIndexPrefix 等待索引键的前缀，不能以 <queue_name>: 开头，否则会被当作队列通道
+	queueIndexPrefix = "judge_queue_index:"
+)
+
+// EnqueueScript 推送任务的 Lua 脚本，KEYS 为通道列表、等待索引和 QueueSeqKey，ARGV 为提交ID和任务
+// 推送后清理索引中已被取出的成员；取消等待中的任务时应在同一个事务中 LREM 列表、ZREM 索引
+const EnqueueScript = `
+local seq = redis.call('INCR', KEYS[3])
+redis.call('ZADD', KEYS[2], seq, ARGV[1])
+redis.call('LPUSH', KEYS[1], ARGV[2])
+local stale = redis.call('ZCARD', KEYS[2]) - redis.call('LLEN', KEYS[1])
+if stale > 0 then
+	redis.call('ZREMRANGEBYRANK', KEYS[2], 0, stale - 1)
+end
+return seq
+`
+
+// QueueIndexKey 返回通道列表 laneKey 的等待索引键
+func QueueIndexKey(laneKey string) string {
+	return queueIndexPrefix + laneKey
+}
+
+// QueuedPosition 由索引中入队晚于该任务的成员数（ZREVRANK）和列表长度（LLEN）计算排队位置，
+// 两者应在同一个事务中读取。位置 1 表示下一个被取出，任务已被取出时返回 0
+// 同一提交重复入队或列表中有不经过索引推送的任务时，位置偏大，但不会把等待中的任务误判为已取出
+func QueuedPosition(newer, length int64) int64 {
+	if newer < 0 || newer >= length {
+		return 0
+	}
+	return length - newer
+}
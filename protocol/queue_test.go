@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// queueModel 按 EnqueueScript 的语义在内存中模拟一个通道列表和它的等待索引
+type queueModel struct {
+	list  []string       // 头部在前，与 LPUSH/RPOP 一致
+	index map[string]int // 提交ID -> 入队序号
+	seq   int
+}
+
+func (m *queueModel) enqueue(id string) {
+	m.seq++
+	m.index[id] = m.seq
+	m.list = append([]string{id}, m.list...)
+	if stale := len(m.index) - len(m.list); stale > 0 {
+		members := make([]string, 0, len(m.index))
+		for member := range m.index {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool { return m.index[members[i]] < m.index[members[j]] })
+		for _, member := range members[:stale] {
+			delete(m.index, member)
+		}
+	}
+}
+
+func (m *queueModel) pop() {
+	m.list = m.list[:len(m.list)-1]
+}
+
+func (m *queueModel) cancel(id string) {
+	for i, queued := range m.list {
+		if queued == id {
+			m.list = append(m.list[:i], m.list[i+1:]...)
+			break
+		}
+	}
+	delete(m.index, id)
+}
+
+// position 用 QueuedPosition 计算排队位置
+func (m *queueModel) position(id string) int64 {
+	score, ok := m.index[id]
+	if !ok {
+		return 0
+	}
+	newer := 0
+	for _, other := range m.index {
+		if other > score {
+			newer++
+		}
+	}
+	return QueuedPosition(int64(newer), int64(len(m.list)))
+}
+
+// actualPosition 直接在列表中查找的排队位置
+func (m *queueModel) actualPosition(id string) int64 {
+	for i, queued := range m.list {
+		if queued == id {
+			return int64(len(m.list) - i)
+		}
+	}
+	return 0
+}
+
+func TestQueuedPosition(t *testing.T) {
+	m := &queueModel{index: map[string]int{}}
+	check := func(step string) {
+		t.Helper()
+		for i := 1; i <= m.seq; i++ {
+			id := strconv.Itoa(i)
+			if got, want := m.position(id), m.actualPosition(id); got != want {
+				t.Fatalf("after %s: position of %s = %d, want %d (list %v)", step, id, got, want, m.list)
+			}
+		}
+	}
+
+	for i := 1; i <= 5; i++ {
+		m.enqueue(strconv.Itoa(i))
+	}
+	check("enqueue 1-5")
+	m.pop()
+	m.pop()
+	check("pop 1, 2")
+	m.cancel("4")
+	check("cancel 4")
+	m.enqueue("6")
+	check("enqueue 6")
+	if len(m.index) != len(m.list) {
+		t.Errorf("index has %d members after enqueue, want the %d queued tasks", len(m.index), len(m.list))
+	}
+	m.pop()
+	m.pop()
+	m.pop()
+	check("pop all")
+	m.enqueue("7")
+	check("enqueue 7")
+
+	for _, tt := range []struct{ newer, length, want int64 }{
+		{0, 1, 1},
+		{0, 3, 3},
+		{2, 3, 1},
+		{3, 3, 0},
+		{5, 3, 0},
+		{-1, 3, 0},
+	} {
+		if got := QueuedPosition(tt.newer, tt.length); got != tt.want {
+			t.Errorf("QueuedPosition(%d, %d) = %d, want %d", tt.newer, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestQueueIndexKey(t *testing.T) {
+	if got, want := QueueIndexKey("judge_queue:lang-verilog"), "judge_queue_index:judge_queue:lang-verilog"; got != want {
+		t.Errorf("QueueIndexKey() = %q, want %q", got, want)
+	}
+}
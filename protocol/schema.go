@@ -0,0 +1,257 @@
+package protocol
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas
+var schemaFS embed.FS
+
+// 协议相关错误
+var (
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	ErrInvalidMessage     = errors.New("invalid protocol message")
+)
+
+// MessageError 描述一条无法处理的协议消息
+type MessageError struct {
+	SubmissionID string // 能解析出的提交ID，可能为空
+	Version      int    // 消息声明的协议版本
+	Err          error
+}
+
+func (e *MessageError) Error() string {
+	if e.SubmissionID == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("submission %s: %v", e.SubmissionID, e.Err)
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// envelope 所有协议消息共有的头部字段
+type envelope struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	SubmissionID    string `json:"submission_id"`
+}
+
+// schemaSet 单个协议版本的Schema集合
+type schemaSet struct {
+	request *jsonschema.Schema
+	result  *jsonschema.Schema
+	event   *jsonschema.Schema
+	node    *jsonschema.Schema
+}
+
+var schemas = mustCompileSchemas()
+
+func mustCompileSchemas() map[int]*schemaSet {
+	compiled := make(map[int]*schemaSet, len(SupportedVersions))
+	for _, version := range SupportedVersions {
+		compiled[version] = &schemaSet{
+			request: mustCompile(version, "judge_request.json"),
+			result:  mustCompile(version, "judge_result.json"),
+			event:   mustCompile(version, "judge_event.json"),
+			node:    mustCompile(version, "node_capabilities.json"),
+		}
+	}
+	return compiled
+}
+
+func mustCompile(version int, name string) *jsonschema.Schema {
+	path := fmt.Sprintf("schemas/v%d/%s", version, name)
+	data, err := schemaFS.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("protocol: failed to read schema %s: %v", path, err))
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	if err := compiler.AddResource(path, bytes.NewReader(data)); err != nil {
+		panic(fmt.Sprintf("protocol: failed to load schema %s: %v", path, err))
+	}
+	// 同一版本的其他Schema也加入编译器，事件Schema通过 $ref 引用判题结果的定义
+	entries, err := schemaFS.ReadDir(fmt.Sprintf("schemas/v%d", version))
+	if err != nil {
+		panic(fmt.Sprintf("protocol: failed to list schemas for version %d: %v", version, err))
+	}
+	for _, entry := range entries {
+		other := fmt.Sprintf("schemas/v%d/%s", version, entry.Name())
+		if other == path {
+			continue
+		}
+		otherData, err := schemaFS.ReadFile(other)
+		if err != nil {
+			panic(fmt.Sprintf("protocol: failed to read schema %s: %v", other, err))
+		}
+		if err := compiler.AddResource(other, bytes.NewReader(otherData)); err != nil {
+			panic(fmt.Sprintf("protocol: failed to load schema %s: %v", other, err))
+		}
+	}
+	return compiler.MustCompile(path)
+}
+
+// Schema 返回指定版本的原始Schema文档，name 为 judge_request.json、judge_result.json、judge_event.json 或 node_capabilities.json
+func Schema(version int, name string) ([]byte, error) {
+	return schemaFS.ReadFile(fmt.Sprintf("schemas/v%d/%s", version, name))
+}
+
+// EncodeRequest 填充协议版本、校验并序列化判题请求
+func EncodeRequest(req *JudgeRequest) ([]byte, error) {
+	req.ProtocolVersion = Version
+	return encode(req, req.SubmissionID, func(s *schemaSet) *jsonschema.Schema { return s.request })
+}
+
+// DecodeRequest 校验并反序列化判题请求
+func DecodeRequest(data []byte) (*JudgeRequest, error) {
+	var req JudgeRequest
+	if err := decode(data, &req, func(s *schemaSet) *jsonschema.Schema { return s.request }); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EncodeResult 填充协议版本、校验并序列化判题结果
+func EncodeResult(result *JudgeResult) ([]byte, error) {
+	result.ProtocolVersion = Version
+	return encode(result, result.SubmissionID, func(s *schemaSet) *jsonschema.Schema { return s.result })
+}
+
+// DecodeResult 校验并反序列化判题结果
+func DecodeResult(data []byte) (*JudgeResult, error) {
+	var result JudgeResult
+	if err := decode(data, &result, func(s *schemaSet) *jsonschema.Schema { return s.result }); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EncodeEvent 填充协议版本、校验并序列化进度事件
+func EncodeEvent(event *JudgeEvent) ([]byte, error) {
+	event.ProtocolVersion = Version
+	if event.Result != nil {
+		event.Result.ProtocolVersion = Version
+	}
+	return encode(event, event.SubmissionID, func(s *schemaSet) *jsonschema.Schema { return s.event })
+}
+
+// DecodeEvent 校验并反序列化进度事件
+func DecodeEvent(data []byte) (*JudgeEvent, error) {
+	var event JudgeEvent
+	if err := decode(data, &event, func(s *schemaSet) *jsonschema.Schema { return s.event }); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// EncodeCapabilities 填充协议版本、校验并序列化判题节点能力
+func EncodeCapabilities(capabilities *Capabilities) ([]byte, error) {
+	capabilities.ProtocolVersion = Version
+	return encode(capabilities, "", func(s *schemaSet) *jsonschema.Schema { return s.node })
+}
+
+// DecodeCapabilities 校验并反序列化判题节点能力
+func DecodeCapabilities(data []byte) (*Capabilities, error) {
+	var capabilities Capabilities
+	if err := decode(data, &capabilities, func(s *schemaSet) *jsonschema.Schema { return s.node }); err != nil {
+		return nil, err
+	}
+	return &capabilities, nil
+}
+
+func encode(v interface{}, submissionID string, pick func(*schemaSet) *jsonschema.Schema) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %v", err)
+	}
+	if err := validate(data, Version, pick); err != nil {
+		return nil, &MessageError{SubmissionID: submissionID, Version: Version, Err: err}
+	}
+	return data, nil
+}
+
+func decode(data []byte, v interface{}, pick func(*schemaSet) *jsonschema.Schema) error {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return &MessageError{Err: fmt.Errorf("%w: %v", ErrInvalidMessage, err)}
+	}
+
+	if _, ok := schemas[env.ProtocolVersion]; !ok {
+		return &MessageError{
+			SubmissionID: env.SubmissionID,
+			Version:      env.ProtocolVersion,
+			Err: fmt.Errorf("%w %d (supported: %s)",
+				ErrUnsupportedVersion, env.ProtocolVersion, formatVersions(SupportedVersions)),
+		}
+	}
+
+	if err := validate(data, env.ProtocolVersion, pick); err != nil {
+		return &MessageError{SubmissionID: env.SubmissionID, Version: env.ProtocolVersion, Err: err}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return &MessageError{
+			SubmissionID: env.SubmissionID,
+			Version:      env.ProtocolVersion,
+			Err:          fmt.Errorf("%w: %v", ErrInvalidMessage, err),
+		}
+	}
+	return nil
+}
+
+func validate(data []byte, version int, pick func(*schemaSet) *jsonschema.Schema) error {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	if err := pick(schemas[version]).Validate(doc); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, describeValidationError(validationErr))
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	return nil
+}
+
+// describeValidationError 将Schema校验错误展开为单行描述
+func describeValidationError(err *jsonschema.ValidationError) string {
+	var leaves []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			location := e.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			leaves = append(leaves, fmt.Sprintf("%s: %s", location, e.Message))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(err)
+	return strings.Join(leaves, "; ")
+}
+
+func formatVersions(versions []int) string {
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,313 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validRequest() *JudgeRequest {
+	return &JudgeRequest{
+		SubmissionID: "42",
+		Code:         "module adder(input a, input b, output s); assign s = a ^ b; endmodule",
+		Language:     "verilog",
+		TimeLimit:    1000,
+		MemoryLimit:  128,
+		TestCases: []TestCase{
+			{Testbench: "module tb; endmodule", ExpectedVCD: "s"},
+		},
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	data, err := EncodeRequest(validRequest())
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	if decoded.ProtocolVersion != Version {
+		t.Errorf("ProtocolVersion = %d, want %d", decoded.ProtocolVersion, Version)
+	}
+	if decoded.SubmissionID != "42" || len(decoded.TestCases) != 1 {
+		t.Errorf("unexpected decoded request: %+v", decoded)
+	}
+}
+
+func TestRequestEquivalence(t *testing.T) {
+	request := validRequest()
+	request.TestCases = []TestCase{}
+	request.Equivalence = &Equivalence{
+		Reference: "module adder(input a, input b, output s); assign s = a ^ b; endmodule",
+		Seed:      7,
+		Hold:      map[string]uint64{"en": 1},
+		Formal:    true,
+	}
+
+	data, err := EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	if decoded.Equivalence == nil || decoded.Equivalence.Seed != 7 || decoded.Equivalence.Hold["en"] != 1 || !decoded.Equivalence.Formal {
+		t.Errorf("unexpected decoded equivalence: %+v", decoded.Equivalence)
+	}
+
+	request.Equivalence.FormalDepth = 5000
+	if _, err := EncodeRequest(request); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeRequest() with formal_depth out of range error = %v, want ErrInvalidMessage", err)
+	}
+
+	request.Equivalence.FormalDepth = 0
+	request.Equivalence.Reference = ""
+	if _, err := EncodeRequest(request); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeRequest() with empty reference error = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func TestDecodeRequest_UnsupportedVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"未来版本", `{"protocol_version": 2, "submission_id": "7"}`},
+		{"缺少版本", `{"submission_id": "7", "code": "x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeRequest([]byte(tt.data))
+			if !errors.Is(err, ErrUnsupportedVersion) {
+				t.Fatalf("DecodeRequest() error = %v, want ErrUnsupportedVersion", err)
+			}
+			var msgErr *MessageError
+			if !errors.As(err, &msgErr) || msgErr.SubmissionID != "7" {
+				t.Errorf("expected MessageError carrying submission id, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeRequest_SchemaViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "旧版字段名",
+			data:    `{"protocol_version":1,"submission_id":"1","code":"c","language":"verilog","time_limit":1,"memory_limit":1,"test_cases":[{"input":"tb","output":"x"}]}`,
+			wantErr: "/test_cases/0",
+		},
+		{
+			name:    "没有测试用例",
+			data:    `{"protocol_version":1,"submission_id":"1","code":"c","language":"verilog","time_limit":1,"memory_limit":1,"test_cases":[]}`,
+			wantErr: "/test_cases",
+		},
+		{
+			name:    "非法JSON",
+			data:    `{"protocol_version":`,
+			wantErr: ErrInvalidMessage.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeRequest([]byte(tt.data))
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Fatalf("DecodeRequest() error = %v, want ErrInvalidMessage", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeResult(t *testing.T) {
+	result := &JudgeResult{
+		SubmissionID: "9",
+		Status:       StatusAccepted,
+		Score:        100,
+		PassedTests:  3,
+		TotalTests:   3,
+		JudgedAt:     time.Now(),
+	}
+	data, err := EncodeResult(result)
+	if err != nil {
+		t.Fatalf("EncodeResult() error = %v", err)
+	}
+	if _, err := DecodeResult(data); err != nil {
+		t.Fatalf("DecodeResult() error = %v", err)
+	}
+
+	result.Status = "Accepted"
+	if _, err := EncodeResult(result); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeResult() with unknown status error = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func TestEncodeResult_Cases(t *testing.T) {
+	result := &JudgeResult{
+		SubmissionID: "9",
+		Status:       StatusWrongAnswer,
+		Score:        50,
+		PassedTests:  1,
+		TotalTests:   2,
+		JudgedAt:     time.Now(),
+		Cases: []CaseResult{
+			{Index: 1, Status: StatusAccepted, RunTime: 3, Memory: 1024},
+			{Index: 2, Status: StatusSimTimeExceeded, RunTime: 4, Memory: 1024, ErrorMessage: "mismatch", SimTime: 1000, Cycles: 100},
+			{Index: 3, Status: StatusWrongAnswer, RunTime: 5, Memory: 1024, Mismatch: &WaveformDiff{
+				TotalSignals: 2,
+				Signals: []SignalMismatch{{
+					Signal: "tb.sum", Time: 20, Expected: "b0011", Actual: "b0010",
+					Window: []WaveformSample{{Time: 10, Expected: "b0001", Actual: "b0001"}, {Time: 20, Expected: "b0011", Actual: "b0010"}},
+				}},
+			}},
+		},
+	}
+	data, err := EncodeResult(result)
+	if err != nil {
+		t.Fatalf("EncodeResult() error = %v", err)
+	}
+	decoded, err := DecodeResult(data)
+	if err != nil {
+		t.Fatalf("DecodeResult() error = %v", err)
+	}
+	if len(decoded.Cases) != 3 || decoded.Cases[1].ErrorMessage != "mismatch" || decoded.Cases[1].SimTime != 1000 || decoded.Cases[1].Cycles != 100 {
+		t.Errorf("unexpected decoded cases: %+v", decoded.Cases)
+	}
+	if mismatch := decoded.Cases[2].Mismatch; mismatch == nil || mismatch.TotalSignals != 2 || len(mismatch.Signals[0].Window) != 2 {
+		t.Errorf("unexpected decoded mismatch: %+v", mismatch)
+	}
+
+	result.Cases[2].Mismatch.Signals[0].Signal = ""
+	if _, err := EncodeResult(result); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeResult() with unnamed mismatch signal error = %v, want ErrInvalidMessage", err)
+	}
+	result.Cases[2].Mismatch.Signals[0].Signal = "tb.sum"
+
+	result.Cached = true
+	data, err = EncodeResult(result)
+	if err != nil {
+		t.Fatalf("EncodeResult() with cached error = %v", err)
+	}
+	if decoded, err = DecodeResult(data); err != nil || !decoded.Cached {
+		t.Errorf("DecodeResult() cached = %v, err = %v, want true", decoded != nil && decoded.Cached, err)
+	}
+
+	result.Cases[0].Status = "passed"
+	if _, err := EncodeResult(result); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeResult() with unknown case status error = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func TestEncodeEvent(t *testing.T) {
+	caseEvent := NewEvent("9", EventCaseFinished)
+	caseEvent.CaseIndex = 1
+	caseEvent.TotalCases = 2
+	caseEvent.Case = &CaseResult{Index: 1, Status: StatusAccepted, RunTime: 3, Memory: 1024}
+
+	finished := NewEvent("9", EventFinished)
+	finished.Result = &JudgeResult{
+		SubmissionID: "9",
+		Status:       StatusAccepted,
+		Score:        100,
+		PassedTests:  2,
+		TotalTests:   2,
+		JudgedAt:     time.Now(),
+	}
+
+	for _, event := range []*JudgeEvent{NewEvent("9", EventQueued), caseEvent, finished} {
+		data, err := EncodeEvent(event)
+		if err != nil {
+			t.Fatalf("EncodeEvent(%s) error = %v", event.Type, err)
+		}
+		decoded, err := DecodeEvent(data)
+		if err != nil {
+			t.Fatalf("DecodeEvent(%s) error = %v", event.Type, err)
+		}
+		if decoded.Type != event.Type {
+			t.Errorf("decoded type = %q, want %q", decoded.Type, event.Type)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event *JudgeEvent
+	}{
+		{"未知事件类型", NewEvent("9", "paused")},
+		{"测试用例事件缺少结果", &JudgeEvent{SubmissionID: "9", Type: EventCaseFinished, CaseIndex: 1, Time: time.Now()}},
+		{"结束事件缺少结果", NewEvent("9", EventFinished)},
+		{"结果状态无效", &JudgeEvent{SubmissionID: "9", Type: EventFinished, Time: time.Now(),
+			Result: &JudgeResult{SubmissionID: "9", Status: "Accepted", JudgedAt: time.Now()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EncodeEvent(tt.event); !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("EncodeEvent() error = %v, want ErrInvalidMessage", err)
+			}
+		})
+	}
+}
+
+func TestEncodeCapabilities(t *testing.T) {
+	capabilities := &Capabilities{
+		Node:        "judge-1:42",
+		Languages:   []string{"verilog", "systemverilog"},
+		Checkers:    []string{SpecialJudgeVerilog},
+		Formal:      true,
+		Concurrency: 4,
+		Versions:    map[string]string{"iverilog": "12.0"},
+		UpdatedAt:   time.Now(),
+	}
+	data, err := EncodeCapabilities(capabilities)
+	if err != nil {
+		t.Fatalf("EncodeCapabilities() error = %v", err)
+	}
+	decoded, err := DecodeCapabilities(data)
+	if err != nil {
+		t.Fatalf("DecodeCapabilities() error = %v", err)
+	}
+	if decoded.Concurrency != 4 {
+		t.Errorf("decoded concurrency = %d, want 4", decoded.Concurrency)
+	}
+	if !decoded.Supports("systemverilog") || decoded.Supports("vhdl") {
+		t.Errorf("decoded languages = %v", decoded.Languages)
+	}
+	if !decoded.SupportsChecker(SpecialJudgeVerilog) || decoded.SupportsChecker(SpecialJudgeGo) {
+		t.Errorf("decoded checkers = %v", decoded.Checkers)
+	}
+	if !decoded.Alive(time.Now()) || decoded.Alive(time.Now().Add(NodeTTL+time.Second)) {
+		t.Errorf("Alive() does not honour NodeTTL")
+	}
+
+	if _, err := EncodeCapabilities(&Capabilities{Languages: []string{}, Versions: map[string]string{}, UpdatedAt: time.Now()}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("EncodeCapabilities(无节点标识) error = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func TestEncodeCancelledResult(t *testing.T) {
+	data, err := EncodeResult(NewCancelledResult("9"))
+	if err != nil {
+		t.Fatalf("EncodeResult() error = %v", err)
+	}
+	decoded, err := DecodeResult(data)
+	if err != nil {
+		t.Fatalf("DecodeResult() error = %v", err)
+	}
+	if decoded.Status != StatusCancelled {
+		t.Errorf("decoded status = %q, want %q", decoded.Status, StatusCancelled)
+	}
+	if key := CancelKey("9"); key != "judge_cancel:9" {
+		t.Errorf("CancelKey() = %q", key)
+	}
+}
@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpecialJudge 出题人提供的检查程序，配置后由它判定测试用例的输出，适用于有多个正确输出的题目
+//
+// 判题服务先编译检查程序，每个测试用例仿真结束后在该测试用例的工作目录中运行，目录中包含：
+//
+//	stdout.txt    testbench 的标准输出，已去掉仿真器自身的提示
+//	output.vcd    testbench 输出的VCD，testbench 没有输出VCD时不存在
+//	expected.txt  测试用例的期望数据（ExpectedOutput，为空时为 ExpectedVCD）
+//
+// Go 检查程序以这三个文件名作为命令行参数运行；Verilog 检查程序通过 $value$plusargs 读取
+// +stdout=、+vcd=、+expected= 三个参数。检查程序在标准输出中按 ParseVerdict 的格式给出结论
+type SpecialJudge struct {
+	Language  string `json:"language"`             // SpecialJudgeGo 或 SpecialJudgeVerilog
+	Source    string `json:"source"`               // 检查程序源码
+	TimeLimit int    `json:"time_limit,omitempty"` // 每个测试用例运行检查程序的时间限制（毫秒），省略时使用判题服务默认值
+}
+
+// 检查程序语言
+const (
+	SpecialJudgeGo      = "go"
+	SpecialJudgeVerilog = "verilog"
+)
+
+// SpecialJudgeLanguages 支持的检查程序语言
+var SpecialJudgeLanguages = []string{SpecialJudgeGo, SpecialJudgeVerilog}
+
+// 检查程序在工作目录中读取的文件名
+const (
+	SpecialJudgeStdoutFile   = "stdout.txt"
+	SpecialJudgeVCDFile      = "output.vcd"
+	SpecialJudgeExpectedFile = "expected.txt"
+)
+
+// Validate 校验检查程序配置，不编译源码
+func (s *SpecialJudge) Validate() error {
+	supported := false
+	for _, language := range SpecialJudgeLanguages {
+		supported = supported || s.Language == language
+	}
+	if !supported {
+		return fmt.Errorf("checker language must be one of %s, got %q", strings.Join(SpecialJudgeLanguages, ", "), s.Language)
+	}
+	if strings.TrimSpace(s.Source) == "" {
+		return fmt.Errorf("checker source is empty")
+	}
+	if s.TimeLimit < 0 {
+		return fmt.Errorf("checker time_limit must not be negative")
+	}
+	return nil
+}
+
+// Verdict 检查程序的结论
+type Verdict struct {
+	Status  string // StatusAccepted 或 StatusWrongAnswer
+	Score   int    // 0-100，答案错误时可以给出部分分
+	Message string // 给提交者的说明，可以为空
+}
+
+// ParseVerdict 解析检查程序的标准输出，结论由以下几行给出，其他行忽略，同一关键字只取第一次出现：
+//
+//	VERDICT accepted|wrong_answer   必须
+//	SCORE <0-100>                   可选，accepted 时只能为 100，wrong_answer 时默认为 0
+//	MESSAGE <text>                  可选
+func ParseVerdict(output string) (*Verdict, error) {
+	var verdict *Verdict
+	score, message := -1, ""
+	hasMessage := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		keyword, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		value = strings.TrimSpace(value)
+		switch keyword {
+		case "VERDICT":
+			if verdict != nil {
+				continue
+			}
+			if value != StatusAccepted && value != StatusWrongAnswer {
+				return nil, fmt.Errorf("VERDICT must be %s or %s, got %q", StatusAccepted, StatusWrongAnswer, value)
+			}
+			verdict = &Verdict{Status: value}
+		case "SCORE":
+			if score >= 0 {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 100 {
+				return nil, fmt.Errorf("SCORE must be an integer between 0 and 100, got %q", value)
+			}
+			score = n
+		case "MESSAGE":
+			if !hasMessage {
+				message, hasMessage = value, true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if verdict == nil {
+		return nil, fmt.Errorf("checker output has no VERDICT line")
+	}
+	switch {
+	case verdict.Status == StatusAccepted && score >= 0 && score != 100:
+		return nil, fmt.Errorf("an accepted verdict must have SCORE 100, got %d", score)
+	case verdict.Status == StatusAccepted:
+		score = 100
+	case score < 0:
+		score = 0
+	}
+	verdict.Score = score
+	verdict.Message = message
+	return verdict, nil
+}
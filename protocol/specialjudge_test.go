@@ -0,0 +1,146 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    Verdict
+		wantErr string
+	}{
+		{name: "通过", output: "VERDICT accepted\n", want: Verdict{Status: StatusAccepted, Score: 100}},
+		{
+			name:   "部分分",
+			output: "checking 8 codes\nVERDICT wrong_answer\nSCORE 60\nMESSAGE code 5 differs from code 4 in 2 bits\n",
+			want:   Verdict{Status: StatusWrongAnswer, Score: 60, Message: "code 5 differs from code 4 in 2 bits"},
+		},
+		{name: "只取第一次出现", output: "VERDICT wrong_answer\nVERDICT accepted\nSCORE 10\nSCORE 20\n", want: Verdict{Status: StatusWrongAnswer, Score: 10}},
+		{name: "缺少结论", output: "SCORE 100\n", wantErr: "no VERDICT"},
+		{name: "未知结论", output: "VERDICT partial\n", wantErr: "VERDICT must be"},
+		{name: "分数超出范围", output: "VERDICT wrong_answer\nSCORE 101\n", wantErr: "SCORE must be"},
+		{name: "通过但不是满分", output: "VERDICT accepted\nSCORE 90\n", wantErr: "SCORE 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVerdict(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseVerdict() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVerdict() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseVerdict() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecialJudgeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spj     SpecialJudge
+		wantErr string
+	}{
+		{name: "Go", spj: SpecialJudge{Language: SpecialJudgeGo, Source: "package main\nfunc main() {}\n"}},
+		{name: "Verilog", spj: SpecialJudge{Language: SpecialJudgeVerilog, Source: "module checker; endmodule\n", TimeLimit: 1000}},
+		{name: "不支持的语言", spj: SpecialJudge{Language: "python", Source: "print()"}, wantErr: "language must be"},
+		{name: "没有源码", spj: SpecialJudge{Language: SpecialJudgeGo, Source: " \n"}, wantErr: "source is empty"},
+		{name: "负的时间限制", spj: SpecialJudge{Language: SpecialJudgeGo, Source: "package main", TimeLimit: -1}, wantErr: "time_limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spj.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestSpecialJudge(t *testing.T) {
+	request := validRequest()
+	request.SpecialJudge = &SpecialJudge{Language: SpecialJudgeGo, Source: "package main\nfunc main() {}\n", TimeLimit: 2000}
+
+	data, err := EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	if decoded.SpecialJudge == nil || *decoded.SpecialJudge != *request.SpecialJudge {
+		t.Errorf("unexpected decoded special judge: %+v", decoded.SpecialJudge)
+	}
+
+	request.SpecialJudge.Language = "python"
+	if _, err := EncodeRequest(request); err == nil {
+		t.Error("EncodeRequest() accepted an unsupported checker language")
+	}
+}
+
+func TestRequestLane(t *testing.T) {
+	spj := &SpecialJudge{Language: SpecialJudgeGo, Source: "package main\nfunc main() {}\n"}
+	tests := []struct {
+		name    string
+		modify  func(*JudgeRequest)
+		checker string
+		lane    string
+	}{
+		{
+			name:   "no checker",
+			modify: func(r *JudgeRequest) {},
+			lane:   "lang-verilog",
+		},
+		{
+			name:    "special judge",
+			modify:  func(r *JudgeRequest) { r.SpecialJudge = spj },
+			checker: SpecialJudgeGo,
+			lane:    "lang-verilog-checker-go",
+		},
+		{
+			name: "capturing outputs does not run the checker",
+			modify: func(r *JudgeRequest) {
+				r.SpecialJudge = spj
+				r.CaptureOutputs = true
+			},
+			lane: "lang-verilog",
+		},
+		{
+			name: "no test cases to check",
+			modify: func(r *JudgeRequest) {
+				r.SpecialJudge = spj
+				r.TestCases = nil
+			},
+			lane: "lang-verilog",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := validRequest()
+			tt.modify(request)
+			if got := request.CheckerLanguage(); got != tt.checker {
+				t.Errorf("CheckerLanguage() = %q, want %q", got, tt.checker)
+			}
+			if got := RequestLane(request); got != tt.lane {
+				t.Errorf("RequestLane() = %q, want %q", got, tt.lane)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package protocol
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// TruthTable 真值表测试
+// 判题服务根据端口列表生成 testbench，逐行施加输入，等待 Delay 纳秒后比较输出，全部行作为一个测试用例
+type TruthTable struct {
+	TopModule string              `json:"top_module"`      // 被测模块名
+	Ports     []Port              `json:"ports"`           // 被测模块的全部端口
+	Rows      []map[string]string `json:"rows"`            // 端口名 -> 取值，取值格式见 TruthTableBits
+	Delay     int                 `json:"delay,omitempty"` // 纳秒，省略时为 DefaultTruthTableDelay
+}
+
+// Port 被测模块的端口
+type Port struct {
+	Name      string `json:"name"`
+	Direction string `json:"direction"`       // PortInput 或 PortOutput
+	Width     int    `json:"width,omitempty"` // 省略时为 1
+}
+
+// 端口方向
+const (
+	PortInput  = "input"
+	PortOutput = "output"
+)
+
+// 真值表限制
+const (
+	DefaultTruthTableDelay = 1
+	MaxTruthTableRows      = 65536
+	MaxPortWidth           = 1024
+)
+
+// PortWidth 返回端口位宽，未指定时为 1
+func (p Port) PortWidth() int {
+	if p.Width <= 0 {
+		return 1
+	}
+	return p.Width
+}
+
+// Validate 校验端口声明，以及每一行的列是否都是声明的端口、输入是否齐全、取值是否符合位宽
+// 输出列可以省略，省略表示该行不检查这个输出
+func (t *TruthTable) Validate() error {
+	if err := checkIdentifier(t.TopModule); err != nil {
+		return fmt.Errorf("invalid top module name %q: %v", t.TopModule, err)
+	}
+	if t.Delay < 0 {
+		return fmt.Errorf("delay must not be negative, got %d", t.Delay)
+	}
+
+	ports, err := validatePorts(t.Ports)
+	if err != nil {
+		return err
+	}
+
+	if len(t.Rows) == 0 {
+		return fmt.Errorf("at least one row is required")
+	}
+	if len(t.Rows) > MaxTruthTableRows {
+		return fmt.Errorf("at most %d rows are allowed, got %d", MaxTruthTableRows, len(t.Rows))
+	}
+	for i, row := range t.Rows {
+		for name, value := range row {
+			port, ok := ports[name]
+			if !ok {
+				return fmt.Errorf("row %d: column %s is not a declared port", i+1, name)
+			}
+			if _, err := TruthTableBits(value, port.PortWidth(), port.Direction == PortOutput); err != nil {
+				return fmt.Errorf("row %d: %s: %v", i+1, name, err)
+			}
+		}
+		for _, port := range t.Ports {
+			if _, ok := row[port.Name]; !ok && port.Direction == PortInput {
+				return fmt.Errorf("row %d: missing input %s", i+1, port.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePorts 校验端口声明，至少需要一个输出端口，返回端口名到端口的映射
+func validatePorts(list []Port) (map[string]Port, error) {
+	ports := make(map[string]Port, len(list))
+	outputs := 0
+	for _, port := range list {
+		if err := checkIdentifier(port.Name); err != nil {
+			return nil, fmt.Errorf("invalid port name %q: %v", port.Name, err)
+		}
+		if _, ok := ports[port.Name]; ok {
+			return nil, fmt.Errorf("duplicate port %s", port.Name)
+		}
+		switch port.Direction {
+		case PortInput:
+		case PortOutput:
+			outputs++
+		default:
+			return nil, fmt.Errorf("port %s: direction must be %s or %s, got %q", port.Name, PortInput, PortOutput, port.Direction)
+		}
+		if port.Width < 0 || port.Width > MaxPortWidth {
+			return nil, fmt.Errorf("port %s: width must be between 1 and %d, got %d", port.Name, MaxPortWidth, port.Width)
+		}
+		ports[port.Name] = port
+	}
+	if outputs == 0 {
+		return nil, fmt.Errorf("at least one output port is required")
+	}
+	return ports, nil
+}
+
+// TruthTableBits 将真值表中的取值转换为 width 位、高位在前的二进制串
+// 取值可以是十进制、0x 开头的十六进制或 0b 开头的二进制，可以用 _ 分隔数字；
+// output 为 true 时，"-" 表示不检查该输出，二进制中的 -、x、? 表示不检查该位，结果中用 - 表示
+func TruthTableBits(value string, width int, output bool) (string, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "_", "")
+	if value == "" {
+		return "", fmt.Errorf("empty value")
+	}
+	if value == "-" {
+		if !output {
+			return "", fmt.Errorf("don't-care is only allowed for outputs")
+		}
+		return strings.Repeat("-", width), nil
+	}
+
+	lower := strings.ToLower(value)
+	if strings.HasPrefix(lower, "0b") {
+		bits := lower[2:]
+		if bits == "" {
+			return "", fmt.Errorf("invalid binary value %q", value)
+		}
+		var b strings.Builder
+		for _, c := range bits {
+			switch c {
+			case '0', '1':
+				b.WriteRune(c)
+			case '-', 'x', '?':
+				if !output {
+					return "", fmt.Errorf("don't-care bits are only allowed for outputs: %q", value)
+				}
+				b.WriteByte('-')
+			default:
+				return "", fmt.Errorf("invalid binary value %q", value)
+			}
+		}
+		return fitBits(b.String(), width, value)
+	}
+
+	n := new(big.Int)
+	base, digits := 10, lower
+	if strings.HasPrefix(lower, "0x") {
+		base, digits = 16, lower[2:]
+	}
+	if _, ok := n.SetString(digits, base); !ok || n.Sign() < 0 {
+		return "", fmt.Errorf("invalid value %q, expected a decimal, 0x hexadecimal or 0b binary number", value)
+	}
+	return fitBits(n.Text(2), width, value)
+}
+
+// fitBits 按位宽补齐高位的 0，超出位宽的高位必须为 0
+func fitBits(bits string, width int, value string) (string, error) {
+	if len(bits) > width {
+		extra := bits[:len(bits)-width]
+		if strings.Trim(extra, "0") != "" {
+			return "", fmt.Errorf("value %s does not fit in %d bit(s)", value, width)
+		}
+		return bits[len(bits)-width:], nil
+	}
+	return strings.Repeat("0", width-len(bits)) + bits, nil
+}
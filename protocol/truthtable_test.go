@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func validTruthTable() *TruthTable {
+	return &TruthTable{
+		TopModule: "adder",
+		Ports: []Port{
+			{Name: "a", Direction: PortInput, Width: 4},
+			{Name: "b", Direction: PortInput, Width: 4},
+			{Name: "sum", Direction: PortOutput, Width: 5},
+			{Name: "zero", Direction: PortOutput},
+		},
+		Rows: []map[string]string{
+			{"a": "3", "b": "0x2", "sum": "0b00101", "zero": "0"},
+			{"a": "0", "b": "0", "sum": "0", "zero": "-"},
+			{"a": "15", "b": "1", "sum": "0b1_00??"},
+		},
+	}
+}
+
+func TestTruthTableBits(t *testing.T) {
+	tests := []struct {
+		value   string
+		width   int
+		output  bool
+		want    string
+		wantErr bool
+	}{
+		{value: "5", width: 4, want: "0101"},
+		{value: "0xF", width: 8, want: "00001111"},
+		{value: "0b1_01", width: 3, want: "101"},
+		{value: "0b0011", width: 2, want: "11"},
+		{value: "0b1-x?", width: 4, output: true, want: "1---"},
+		{value: "-", width: 3, output: true, want: "---"},
+		{value: "16", width: 4, wantErr: true},
+		{value: "0b1-", width: 2, wantErr: true},
+		{value: "-", width: 1, wantErr: true},
+		{value: "abc", width: 8, wantErr: true},
+		{value: "", width: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := TruthTableBits(tt.value, tt.width, tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("TruthTableBits(%q, %d, %t) error = %v, wantErr %t", tt.value, tt.width, tt.output, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("TruthTableBits(%q, %d, %t) = %q, want %q", tt.value, tt.width, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestTruthTableValidate(t *testing.T) {
+	if err := validTruthTable().Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(table *TruthTable)
+		wantErr string
+	}{
+		{name: "未声明的列", modify: func(table *TruthTable) { table.Rows[0]["carry"] = "1" }, wantErr: "column carry is not a declared port"},
+		{name: "缺少输入", modify: func(table *TruthTable) { delete(table.Rows[1], "b") }, wantErr: "row 2: missing input b"},
+		{name: "取值超出位宽", modify: func(table *TruthTable) { table.Rows[0]["a"] = "16" }, wantErr: "row 1: a"},
+		{name: "没有输出端口", modify: func(table *TruthTable) { table.Ports = table.Ports[:2] }, wantErr: "output port"},
+		{name: "端口重名", modify: func(table *TruthTable) { table.Ports[1].Name = "a" }, wantErr: "duplicate port a"},
+		{name: "非法方向", modify: func(table *TruthTable) { table.Ports[0].Direction = "inout" }, wantErr: "direction"},
+		{name: "没有行", modify: func(table *TruthTable) { table.Rows = nil }, wantErr: "at least one row"},
+		{name: "端口名是关键字", modify: func(table *TruthTable) { table.Ports[0].Name = "reg" }, wantErr: "reg is a Verilog keyword"},
+		{name: "端口名是SystemVerilog关键字", modify: func(table *TruthTable) { table.Ports[0].Name = "logic" }, wantErr: "logic is a Verilog keyword"},
+		{name: "端口名使用保留前缀", modify: func(table *TruthTable) { table.Ports[0].Name = ReservedNamePrefix + "dut" }, wantErr: "reserved for the judge"},
+		{name: "模块名是关键字", modify: func(table *TruthTable) { table.TopModule = "module" }, wantErr: "invalid top module name"},
+		{name: "模块名使用保留前缀", modify: func(table *TruthTable) { table.TopModule = ReservedNamePrefix + "truth_tb" }, wantErr: "reserved for the judge"},
+		{name: "端口名不是标识符", modify: func(table *TruthTable) { table.Ports[0].Name = "a b" }, wantErr: "not a simple Verilog identifier"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := validTruthTable()
+			tt.modify(table)
+			err := table.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestTruthTable(t *testing.T) {
+	request := validRequest()
+	request.TestCases = []TestCase{}
+	request.TruthTable = validTruthTable()
+
+	data, err := EncodeRequest(request)
+	if err != nil {
+		t.Fatalf("EncodeRequest() error = %v", err)
+	}
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatalf("DecodeRequest() error = %v", err)
+	}
+	if decoded.TruthTable == nil || len(decoded.TruthTable.Rows) != 3 || decoded.TruthTable.Rows[2]["sum"] != "0b1_00??" {
+		t.Errorf("unexpected decoded truth table: %+v", decoded.TruthTable)
+	}
+}